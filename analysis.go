@@ -0,0 +1,144 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+
+	"github.com/h2non/bimg"
+)
+
+// Warnings reported by the image analysis.
+const (
+	WarningTooDark   = "too_dark"
+	WarningTooBlurry = "too_blurry"
+	WarningTooSmall  = "too_small"
+)
+
+const (
+	// analysisSize is the size images are reduced to before being analyzed,
+	// so sharpness doesn't depend on the source resolution.
+	analysisSize = 512
+	// minBrightness is the mean luminance, from 0 to 255, under which an image is too dark.
+	minBrightness = 40
+	// minSharpness is the Laplacian variance under which an image is too blurry.
+	minSharpness = 100
+	// minDimension is the shortest side, in pixels, under which an image is too small.
+	minDimension = 320
+)
+
+// ImageAnalysis represents the result of the image quality analysis
+type ImageAnalysis struct {
+	Brightness float64  `json:"brightness"`
+	Sharpness  float64  `json:"sharpness"`
+	Warnings   []string `json:"warnings"`
+}
+
+// analyzeImage measures the image brightness and sharpness, flagging likely
+// bad uploads: too dark, too blurry or too small.
+func analyzeImage(buf []byte, size bimg.ImageSize) (*ImageAnalysis, error) {
+	pixels, _, err := reducedPixels(buf, size)
+	if err != nil {
+		return nil, err
+	}
+
+	gray := luminance(pixels)
+	analysis := &ImageAnalysis{
+		Brightness: mean(gray),
+		Sharpness:  laplacianVariance(gray, pixels.Rect.Dx(), pixels.Rect.Dy()),
+		Warnings:   []string{},
+	}
+
+	if analysis.Brightness < minBrightness {
+		analysis.Warnings = append(analysis.Warnings, WarningTooDark)
+	}
+	if analysis.Sharpness < minSharpness {
+		analysis.Warnings = append(analysis.Warnings, WarningTooBlurry)
+	}
+	if min(size.Width, size.Height) < minDimension {
+		analysis.Warnings = append(analysis.Warnings, WarningTooSmall)
+	}
+	return analysis, nil
+}
+
+// reducedPixels decodes the image reduced to analysisSize on its longest
+// side, so statistics are cheap to compute even on large images.
+func reducedPixels(buf []byte, size bimg.ImageSize) (*image.NRGBA, bool, error) {
+	opts := bimg.Options{Type: bimg.PNG}
+	if size.Width >= size.Height && size.Width > analysisSize {
+		opts.Width = analysisSize
+	} else if size.Height > analysisSize {
+		opts.Height = analysisSize
+	}
+
+	reduced, err := bimg.Resize(buf, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return decodePixels(reduced)
+}
+
+// luminance returns the Rec. 601 luma of every pixel, row by row.
+func luminance(img *image.NRGBA) []float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	gray := make([]float64, 0, width*height)
+	for y := 0; y < height; y++ {
+		i := img.PixOffset(0, y)
+		for x := 0; x < width; x++ {
+			r, g, b := float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2])
+			gray = append(gray, 0.299*r+0.587*g+0.114*b)
+			i += 4
+		}
+	}
+	return gray
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// laplacianVariance computes the variance of the 4-neighbour Laplacian of the
+// grayscale image: sharp images have strong edges, hence a high variance.
+func laplacianVariance(gray []float64, width, height int) float64 {
+	if width < 3 || height < 3 {
+		return 0
+	}
+
+	laplacian := make([]float64, 0, (width-2)*(height-2))
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			laplacian = append(laplacian, gray[i-width]+gray[i+width]+gray[i-1]+gray[i+1]-4*gray[i])
+		}
+	}
+
+	m := mean(laplacian)
+	var variance float64
+	for _, v := range laplacian {
+		variance += (v - m) * (v - m)
+	}
+	return variance / float64(len(laplacian))
+}
@@ -0,0 +1,61 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestLaplacianVariance(t *testing.T) {
+	flat := make([]float64, 100)
+	for i := range flat {
+		flat[i] = 128
+	}
+	if v := laplacianVariance(flat, 10, 10); v != 0 {
+		t.Errorf("Expected a flat image to have no variance, got %f", v)
+	}
+
+	edges := make([]float64, 100)
+	for i := range edges {
+		if (i%10+i/10)%2 == 0 {
+			edges[i] = 255
+		}
+	}
+	if v := laplacianVariance(edges, 10, 10); v < minSharpness {
+		t.Errorf("Expected a checkerboard to be sharp, got %f", v)
+	}
+
+	if v := laplacianVariance(flat[:4], 2, 2); v != 0 {
+		t.Errorf("Expected tiny images to be ignored, got %f", v)
+	}
+}
+
+func TestLuminance(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	fillRect(img, image.Rect(0, 0, 1, 1), [4]uint8{255, 255, 255, 255})
+
+	gray := luminance(img)
+	if len(gray) != 2 || gray[0] < 254.9 || gray[1] != 0 {
+		t.Errorf("Invalid luminance: %v", gray)
+	}
+	if m := mean(gray); m < 127 || m > 128 {
+		t.Errorf("Invalid mean luminance: %f", m)
+	}
+}
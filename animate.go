@@ -0,0 +1,275 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxAnimationFrames is the maximum number of frames of an animation.
+const MaxAnimationFrames = 50
+
+// DefaultAnimationDelay is the duration of the frames, in milliseconds, when
+// no delay param is given.
+const DefaultAnimationDelay = 100
+
+var (
+	ErrMissingFrames        = NewError("Missing frames: upload them or list their urls", http.StatusBadRequest)
+	ErrTooManyFrames        = NewError("Maximum allowed animation frames exceeded", http.StatusBadRequest)
+	ErrAnimatedAVIF         = NewError("Animated AVIF images are not supported by libvips", http.StatusBadRequest)
+	ErrUnsupportedAnimation = NewError("Unsupported animation type. Allowed values are: gif, webp", http.StatusBadRequest)
+)
+
+// @Summary Animation
+// @Description Assembles the uploaded frames, or the frames of the urls param, into an animated GIF or WebP image
+// @Accept multipart/form-data
+// @Produce image/gif
+// @Produce image/webp
+// @Param file formData file false "Frames (repeat the field for each frame)"
+// @Param urls query string false "JSON list of the frame URLs"
+// @Param delay query string false "Frame duration in milliseconds, or comma separated list of durations per frame (default 100)"
+// @Param loop query int false "Number of loops, 0 looping forever (default 0)"
+// @Param width query int false "Width of the frames, along with height (default: first frame width)"
+// @Param height query int false "Height of the frames, along with width (default: first frame height)"
+// @Param type query string false "Output image format (gif, webp)"
+// @Param quality query int false "Quality of WebP animations (1-100)"
+// @Success 200 {file} binary "Animated image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /animate [post]
+func animateController(o ServerOptions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		o := withRequestLimits(req, o)
+
+		opts, vary, err := processImageOptions(req)
+		if err != nil {
+			ErrorReply(req, w, NewError(err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		if opts.Async || opts.Callback != "" {
+			ErrorReply(req, w, ErrAsyncUnsupported, o)
+			return
+		}
+
+		frames, err := readFrames(req, opts)
+		if err != nil {
+			ErrorReply(req, w, toError(err), o)
+			return
+		}
+		for i, frame := range frames {
+			if err := validateImageSize(frame, ImageOptions{}, o); err != nil {
+				xerr := toError(err)
+				xerr.Message = fmt.Sprintf("frame %d: %s", i, xerr.Message)
+				ErrorReply(req, w, xerr, o)
+				return
+			}
+		}
+		if err := validateAnimationSize(frames, opts, o); err != nil {
+			ErrorReply(req, w, toError(err), o)
+			return
+		}
+
+		image, err := Animate(frames, opts)
+		if err != nil {
+			if xerr, ok := err.(Error); ok {
+				ErrorReply(req, w, xerr, o)
+			} else {
+				handleProcessingError(w, req, vary, err, o)
+			}
+			return
+		}
+
+		sendResponse(w, signImage(image, req), vary, o)
+	}
+}
+
+// readFrames returns the frames uploaded under the file form field, or
+// loaded from the urls param.
+func readFrames(req *http.Request, opts ImageOptions) ([][]byte, error) {
+	if !isFormBody(req) {
+		if len(opts.URLs) == 0 {
+			return nil, ErrMissingFrames
+		}
+		if err := checkURLs(opts.URLs, MaxAnimationFrames); err != nil {
+			return nil, err
+		}
+
+		frames := make([][]byte, 0, len(opts.URLs))
+		for i, url := range opts.URLs {
+			frame, err := loadLayer(CompositeLayer{URL: url})
+			if err != nil {
+				return nil, NewError(fmt.Sprintf("Unable to load frame %d: %s", i, err), http.StatusBadRequest)
+			}
+			frames = append(frames, frame)
+		}
+		return frames, nil
+	}
+
+	files, err := readFormFiles(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > MaxAnimationFrames {
+		return nil, ErrTooManyFrames
+	}
+
+	frames := make([][]byte, 0, len(files))
+	for i, file := range files {
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		frame, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) == 0 {
+			return nil, NewError(fmt.Sprintf("Empty frame %d", i), http.StatusBadRequest)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// validateAnimationSize checks the resolution of the whole animation, every
+// frame being fitted to the requested size, or to the first frame one.
+func validateAnimationSize(frames [][]byte, opts ImageOptions, o ServerOptions) error {
+	if opts.Width < 0 || opts.Height < 0 {
+		return ErrNegativeDimensions
+	}
+	width, height := opts.Width, opts.Height
+	if width == 0 {
+		size, err := bimg.Size(frames[0])
+		if err != nil {
+			return NewError("Cannot process frame 0: "+err.Error(), http.StatusBadRequest)
+		}
+		width, height = size.Width, size.Height
+	}
+	if float64(width)*float64(height)*float64(len(frames))/1000000 > o.MaxAllowedPixels {
+		return ErrResolutionTooBig
+	}
+	return nil
+}
+
+// Animate assembles the frames into an animated GIF, the default, or WebP
+// image. Frames are fitted to the requested size, or to the first frame one,
+// and centered.
+func Animate(frames [][]byte, o ImageOptions) (Image, error) {
+	outputType := bimg.GIF
+	switch ImageType(o.Type) {
+	case bimg.UNKNOWN, bimg.GIF:
+	case bimg.WEBP:
+		outputType = bimg.WEBP
+	case bimg.AVIF:
+		return Image{}, ErrAnimatedAVIF
+	default:
+		return Image{}, ErrUnsupportedAnimation
+	}
+
+	delays, err := animationDelays(o.Delays, len(frames))
+	if err != nil {
+		return Image{}, err
+	}
+	if o.Loop < 0 {
+		return Image{}, NewError("Invalid param: loop must be positive", http.StatusBadRequest)
+	}
+	if o.Width < 0 || o.Height < 0 {
+		return Image{}, ErrNegativeDimensions
+	}
+	if (o.Width == 0) != (o.Height == 0) {
+		return Image{}, NewError("Invalid params: width and height must be defined together", http.StatusBadRequest)
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the formats encoded by bimg
+		quality = bimg.Quality
+	}
+
+	width, height := o.Width, o.Height
+	pages := make([][]byte, 0, len(frames))
+	for i, frame := range frames {
+		page, err := fitPage(frame, width, height)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot process frame %d: %s", i, err), http.StatusBadRequest)
+		}
+		if width == 0 {
+			size, err := bimg.Size(page)
+			if err != nil {
+				return Image{}, NewError("Cannot process frame 0: "+err.Error(), http.StatusBadRequest)
+			}
+			width, height = size.Width, size.Height
+		}
+		pages = append(pages, page)
+	}
+
+	body, err := saveAnimation(pages, animationOptions{
+		Delays:  delays,
+		Loop:    o.Loop,
+		WebP:    outputType == bimg.WEBP,
+		Quality: quality,
+	})
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{Body: body, Mime: GetImageMimeType(outputType)}, nil
+}
+
+// animationDelays returns the duration of every frame, the single delay, or
+// the default one, applying to all of them.
+func animationDelays(delays []int, frames int) ([]int, error) {
+	switch len(delays) {
+	case 0:
+		delays = []int{DefaultAnimationDelay}
+	case 1, frames:
+	default:
+		return nil, NewError(fmt.Sprintf("Invalid param: delay must have a single value or %d values, one per frame",
+			frames), http.StatusBadRequest)
+	}
+
+	result := make([]int, frames)
+	for i := range result {
+		result[i] = delays[min(i, len(delays)-1)]
+		if result[i] <= 0 {
+			return nil, NewError("Invalid param: delay must be positive", http.StatusBadRequest)
+		}
+	}
+	return result, nil
+}
+
+// parseDelays parses a comma separated list of frame durations.
+func parseDelays(val string) ([]int, error) {
+	var delays []int
+	for _, chunk := range strings.Split(val, ",") {
+		delay, err := strconv.Atoi(strings.TrimSpace(chunk))
+		if err != nil {
+			return nil, ErrUnsupportedValue
+		}
+		delays = append(delays, delay)
+	}
+	return delays, nil
+}
@@ -0,0 +1,147 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestAnimationDelays(t *testing.T) {
+	cases := []struct {
+		delays   []int
+		expected []int
+	}{
+		{nil, []int{DefaultAnimationDelay, DefaultAnimationDelay, DefaultAnimationDelay}},
+		{[]int{40}, []int{40, 40, 40}},
+		{[]int{40, 80, 500}, []int{40, 80, 500}},
+	}
+
+	for _, c := range cases {
+		delays, err := animationDelays(c.delays, 3)
+		if err != nil || len(delays) != 3 || delays[0] != c.expected[0] || delays[2] != c.expected[2] {
+			t.Errorf("Invalid delays of %v: %v, %v", c.delays, delays, err)
+		}
+	}
+
+	for _, delays := range [][]int{{40, 80}, {0}, {40, -1, 40}} {
+		if _, err := animationDelays(delays, 3); err == nil {
+			t.Errorf("Expected an error with %v", delays)
+		}
+	}
+}
+
+func TestParseDelays(t *testing.T) {
+	delays, err := parseDelays("40, 80,500")
+	if err != nil || len(delays) != 3 || delays[1] != 80 {
+		t.Errorf("Invalid delays: %v, %v", delays, err)
+	}
+	if _, err := parseDelays("40,fast"); err == nil {
+		t.Error("Expected an error with an invalid delay")
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+	frames := [][]byte{png, jpeg}
+
+	for _, outputType := range []string{"", "webp"} {
+		opts := ImageOptions{Type: outputType, Width: 200, Height: 100, Delays: []int{40, 200}}
+		img, err := Animate(frames, opts)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		expected := bimg.GIF
+		if outputType == "webp" {
+			expected = bimg.WEBP
+		}
+		if img.Mime != GetImageMimeType(expected) || bimg.DetermineImageType(img.Body) != expected {
+			t.Error(InvalidMimeType)
+		}
+	}
+
+	invalid := []ImageOptions{{Type: "avif"}, {Type: "png"}, {Width: 200}, {Width: -200, Height: -100},
+		{Loop: -1}, {Delays: []int{1, 2, 3}}}
+	for _, opts := range invalid {
+		if _, err := Animate(frames, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestValidateAnimationSize(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("imaginary.jpg"))
+	frames := [][]byte{buf, buf}
+	o := ServerOptions{MaxAllowedPixels: 1}
+
+	if err := validateAnimationSize(frames, ImageOptions{}, o); err != nil {
+		t.Errorf("Expected the frames to be allowed: %v", err)
+	}
+	if err := validateAnimationSize(frames, ImageOptions{Width: 1000, Height: 1000}, o); err != ErrResolutionTooBig {
+		t.Errorf("Expected the fitted frames to be too big: %v", err)
+	}
+	if err := validateAnimationSize(frames, ImageOptions{Width: -1, Height: 1}, o); err != ErrNegativeDimensions {
+		t.Errorf("Expected negative dimensions to be rejected: %v", err)
+	}
+}
+
+func TestAnimateController(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for _, name := range []string{"a.png", "b.png"} {
+		part, _ := form.CreateFormFile(formFieldName, name)
+		_, _ = part.Write(buf)
+	}
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/animate?delay=40,80&loop=2", &body)
+	req.Header.Set(ContentType, form.FormDataContentType())
+	res := httptest.NewRecorder()
+	animateController(ServerOptions{MaxAllowedPixels: 18.0})(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("Invalid response status: %d, %s", res.Code, res.Body.String())
+	}
+	if res.Header().Get(ContentType) != "image/gif" {
+		t.Errorf(InvalidMimeType)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/animate", strings.NewReader(""))
+	res = httptest.NewRecorder()
+	animateController(ServerOptions{MaxAllowedPixels: 18.0})(res, req)
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), ErrMissingFrames.Message) {
+		t.Errorf("Expected a missing frames error: %d, %s", res.Code, res.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/animate?async=true", strings.NewReader(""))
+	res = httptest.NewRecorder()
+	animateController(ServerOptions{MaxAllowedPixels: 18.0})(res, req)
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), ErrAsyncUnsupported.Message) {
+		t.Errorf("Expected async animations to be rejected: %d, %s", res.Code, res.Body.String())
+	}
+}
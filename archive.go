@@ -0,0 +1,154 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	ArchiveTar       = "tar"
+	ArchiveTarGz     = "tar.gz"
+	ArchiveZip       = "zip"
+	ArchiveMultipart = "multipart"
+)
+
+// ArchiveWriter streams multiple processed images into a single response body.
+type ArchiveWriter interface {
+	WriteFile(name, mimeType string, body []byte) error
+	Close() error
+}
+
+// tarArchiveWriter writes each entry as soon as it is processed, so the archive
+// never needs to be buffered in memory. The gzip layer is optional.
+type tarArchiveWriter struct {
+	tw  *tar.Writer
+	gz  *gzip.Writer
+	now time.Time
+}
+
+// NewArchiveWriter creates an ArchiveWriter for the given format and returns
+// the MIME type that must be used for the response.
+func NewArchiveWriter(w io.Writer, format string) (ArchiveWriter, string, error) {
+	switch parseArchiveFormat(format) {
+	case ArchiveTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(w), now: time.Now()}, "application/x-tar", nil
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz, now: time.Now()}, "application/gzip", nil
+	case ArchiveZip:
+		return &zipArchiveWriter{zw: zip.NewWriter(w), now: time.Now()}, "application/zip", nil
+	case ArchiveMultipart:
+		mw := multipart.NewWriter(w)
+		return &multipartArchiveWriter{mw: mw}, "multipart/mixed; boundary=" + mw.Boundary(), nil
+	default:
+		return nil, "", ErrUnsupportedArchive
+	}
+}
+
+func (a *tarArchiveWriter) WriteFile(name, _ string, body []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(body)),
+		ModTime: a.now,
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(body)
+	return err
+}
+
+func (a *tarArchiveWriter) Close() error {
+	err := a.tw.Close()
+	if a.gz != nil {
+		err = errors.Join(err, a.gz.Close())
+	}
+	return err
+}
+
+// zipArchiveWriter stores the entries uncompressed, images being compressed
+// already, and streams them as the tar one does.
+type zipArchiveWriter struct {
+	zw  *zip.Writer
+	now time.Time
+}
+
+func (a *zipArchiveWriter) WriteFile(name, _ string, body []byte) error {
+	f, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: a.now})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(body)
+	return err
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// multipartArchiveWriter writes each entry as a part of a multipart/mixed
+// body, along with its MIME type, so clients can read the images without
+// unpacking an archive.
+type multipartArchiveWriter struct {
+	mw *multipart.Writer
+}
+
+func (a *multipartArchiveWriter) WriteFile(name, mimeType string, body []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set(ContentType, mimeType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+
+	part, err := a.mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(body)
+	return err
+}
+
+func (a *multipartArchiveWriter) Close() error {
+	return a.mw.Close()
+}
+
+// parseArchiveFormat normalizes the archive format aliases accepted as param.
+func parseArchiveFormat(val string) string {
+	switch strings.TrimSpace(strings.ToLower(val)) {
+	case "", ArchiveTar:
+		return ArchiveTar
+	case ArchiveTarGz, "tgz", "gzip":
+		return ArchiveTarGz
+	case ArchiveZip:
+		return ArchiveZip
+	case ArchiveMultipart:
+		return ArchiveMultipart
+	default:
+		return ""
+	}
+}
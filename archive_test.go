@@ -0,0 +1,168 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+)
+
+func readTarEntries(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("cannot read tar entry: %s", err)
+		}
+		body, _ := io.ReadAll(tr)
+		entries[hdr.Name] = body
+	}
+	return entries
+}
+
+func TestNewArchiveWriter(t *testing.T) {
+	cases := []struct {
+		format string
+		mime   string
+		gzip   bool
+	}{
+		{"", "application/x-tar", false},
+		{"tar", "application/x-tar", false},
+		{"tar.gz", "application/gzip", true},
+		{"TGZ", "application/gzip", true},
+	}
+
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		archive, mime, err := NewArchiveWriter(&buf, tc.format)
+		if err != nil {
+			t.Fatalf("unexpected error for format %q: %s", tc.format, err)
+		}
+		if mime != tc.mime {
+			t.Errorf("invalid MIME type for format %q: %s != %s", tc.format, mime, tc.mime)
+		}
+
+		_ = archive.WriteFile("001-a.jpeg", ImageJPEG, []byte("foo"))
+		_ = archive.WriteFile("002-b.jpeg", ImageJPEG, []byte("barbaz"))
+		if err := archive.Close(); err != nil {
+			t.Fatalf("cannot close archive: %s", err)
+		}
+
+		var r io.Reader = &buf
+		if tc.gzip {
+			gz, err := gzip.NewReader(&buf)
+			if err != nil {
+				t.Fatalf("invalid gzip stream: %s", err)
+			}
+			r = gz
+		}
+
+		entries := readTarEntries(t, r)
+		if string(entries["001-a.jpeg"]) != "foo" || string(entries["002-b.jpeg"]) != "barbaz" {
+			t.Errorf("invalid archive entries for format %q: %v", tc.format, entries)
+		}
+	}
+}
+
+func TestNewArchiveWriterZip(t *testing.T) {
+	var buf bytes.Buffer
+	archive, mime, err := NewArchiveWriter(&buf, "zip")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if mime != "application/zip" {
+		t.Errorf("invalid MIME type: %s", mime)
+	}
+
+	_ = archive.WriteFile("001-a.jpeg", ImageJPEG, []byte("foo"))
+	_ = archive.WriteFile("002-b.jpeg", ImageJPEG, []byte("barbaz"))
+	if err := archive.Close(); err != nil {
+		t.Fatalf("cannot close archive: %s", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip archive: %s", err)
+	}
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		body, _ := io.ReadAll(r)
+		entries[f.Name] = string(body)
+	}
+	if entries["001-a.jpeg"] != "foo" || entries["002-b.jpeg"] != "barbaz" {
+		t.Errorf("invalid archive entries: %v", entries)
+	}
+}
+
+func TestNewArchiveWriterMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	archive, contentType, err := NewArchiveWriter(&buf, "multipart")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_ = archive.WriteFile("001-a.jpeg", ImageJPEG, []byte("foo"))
+	_ = archive.WriteFile("002-b.error.json", ContentTypeJSON, []byte("{}"))
+	if err := archive.Close(); err != nil {
+		t.Fatalf("cannot close archive: %s", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("invalid MIME type: %s", contentType)
+	}
+
+	expected := []struct{ name, mime, body string }{
+		{"001-a.jpeg", ImageJPEG, "foo"},
+		{"002-b.error.json", ContentTypeJSON, "{}"},
+	}
+	mr := multipart.NewReader(&buf, params["boundary"])
+	for _, e := range expected {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("cannot read part %s: %s", e.name, err)
+		}
+		body, _ := io.ReadAll(part)
+		if part.FileName() != e.name || part.Header.Get(ContentType) != e.mime || string(body) != e.body {
+			t.Errorf("invalid part %s: %s %s %s", e.name, part.FileName(), part.Header.Get(ContentType), body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected the parts to end, got: %v", err)
+	}
+}
+
+func TestNewArchiveWriterUnsupported(t *testing.T) {
+	if _, _, err := NewArchiveWriter(io.Discard, "rar"); err != ErrUnsupportedArchive {
+		t.Errorf("expected unsupported archive error, got: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// aspectCropInput crops the largest area of the source image matching the ar
+// param before the operation runs, positioned by the focal point or the
+// gravity. The cropped image is handed over as lossless PNG, so the output
+// type defaults to the source one as usual.
+func aspectCropInput(buf []byte, opts ImageOptions) ([]byte, ImageOptions, error) {
+	if !opts.Crop {
+		return nil, opts, NewError("Invalid params: ar requires crop=true", http.StatusBadRequest)
+	}
+	ratio := parseAspectRatio(opts.Ratio)
+	if ratio == nil || ratio["width"] <= 0 || ratio["height"] <= 0 {
+		return nil, opts, NewError("Invalid param: ar must be formatted as width:height, e.g. 16:9", http.StatusBadRequest)
+	}
+
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return nil, opts, NewError("Cannot retrieve image metadata: "+err.Error(), http.StatusBadRequest)
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if !opts.NoRotation && metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+	if width == 0 || height == 0 {
+		return nil, opts, NewError("Width or height of requested image is zero", http.StatusNotAcceptable)
+	}
+	areaWidth, areaHeight := aspectCropSize(width, height, ratio["width"], ratio["height"])
+
+	crop := bimg.Options{Type: bimg.PNG, NoAutoRotate: opts.NoRotation, AreaWidth: areaWidth, AreaHeight: areaHeight}
+	switch {
+	case opts.IsDefinedField.FocalX || opts.IsDefinedField.FocalY:
+		fx, fy := 0.5, 0.5
+		if opts.IsDefinedField.FocalX {
+			fx = opts.FocalX
+		}
+		if opts.IsDefinedField.FocalY {
+			fy = opts.FocalY
+		}
+		if fx > 1 || fy > 1 {
+			return nil, opts, NewError("Invalid params: fx and fy must be between 0 and 1", http.StatusBadRequest)
+		}
+		crop.Left, crop.Top = focalOffsets(width, height, areaWidth, areaHeight, fx, fy)
+	case opts.Gravity == bimg.GravitySmart:
+		crop = bimg.Options{Type: bimg.PNG, NoAutoRotate: opts.NoRotation, Width: areaWidth, Height: areaHeight,
+			Crop: true, Gravity: bimg.GravitySmart}
+	case opts.Gravity == GravityFace:
+		if faceDetector == nil {
+			return nil, opts, ErrFaceGravityDisabled
+		}
+		fx, fy, ok, err := detectFaces(buf, opts)
+		if err != nil {
+			return nil, opts, err
+		}
+		if !ok {
+			fx, fy = 0.5, 0.5
+		}
+		crop.Left, crop.Top = focalOffsets(width, height, areaWidth, areaHeight, fx, fy)
+	default:
+		fx, fy := gravityAnchor(opts.Gravity)
+		crop.Left, crop.Top = int(fx*float64(width-areaWidth)), int(fy*float64(height-areaHeight))
+	}
+
+	cropped, err := bimg.Resize(buf, crop)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	opts.Type = outputTypeName(buf, opts)
+	// The source orientation was applied while cropping, and the focal point
+	// referred to the source image
+	opts.NoRotation = true
+	opts.IsDefinedField.FocalX, opts.IsDefinedField.FocalY = false, false
+	return cropped, opts, nil
+}
+
+// aspectCropSize returns the largest area of the image with the given aspect
+// ratio.
+func aspectCropSize(width, height, ratioWidth, ratioHeight int) (int, int) {
+	if width*ratioHeight >= height*ratioWidth {
+		areaWidth := int(math.Round(float64(height) * float64(ratioWidth) / float64(ratioHeight)))
+		return max(min(areaWidth, width), 1), height
+	}
+	areaHeight := int(math.Round(float64(width) * float64(ratioHeight) / float64(ratioWidth)))
+	return width, max(min(areaHeight, height), 1)
+}
+
+// focalOffsets centers the area on the focal point, as close as the image
+// edges allow.
+func focalOffsets(width, height, areaWidth, areaHeight int, fx, fy float64) (left, top int) {
+	left = int(math.Round(fx*float64(width) - float64(areaWidth)/2))
+	top = int(math.Round(fy*float64(height) - float64(areaHeight)/2))
+	return max(0, min(left, width-areaWidth)), max(0, min(top, height-areaHeight))
+}
@@ -0,0 +1,110 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestAspectCropSize(t *testing.T) {
+	cases := []struct {
+		width, height, ratioWidth, ratioHeight int
+		areaWidth, areaHeight                  int
+	}{
+		{550, 740, 16, 9, 550, 309},
+		{550, 740, 1, 1, 550, 550},
+		{1920, 1080, 1, 1, 1080, 1080},
+		{1920, 1080, 16, 9, 1920, 1080},
+		{1000, 10, 1, 1000, 1, 10},
+	}
+
+	for _, c := range cases {
+		areaWidth, areaHeight := aspectCropSize(c.width, c.height, c.ratioWidth, c.ratioHeight)
+		if areaWidth != c.areaWidth || areaHeight != c.areaHeight {
+			t.Errorf("%dx%d at %d:%d: expected %dx%d, got %dx%d", c.width, c.height, c.ratioWidth, c.ratioHeight,
+				c.areaWidth, c.areaHeight, areaWidth, areaHeight)
+		}
+	}
+}
+
+func TestFocalOffsets(t *testing.T) {
+	cases := []struct {
+		fx, fy    float64
+		left, top int
+	}{
+		{0.5, 0.5, 50, 100},
+		{0, 0, 0, 0},
+		{1, 1, 100, 200},
+		{0.6, 0.25, 70, 0},
+	}
+
+	for _, c := range cases {
+		left, top := focalOffsets(200, 400, 100, 200, c.fx, c.fy)
+		if left != c.left || top != c.top {
+			t.Errorf("focal point %v,%v: expected %d,%d, got %d,%d", c.fx, c.fy, c.left, c.top, left, top)
+		}
+	}
+}
+
+func TestAspectCropInput(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	t.Run("ratio", func(t *testing.T) {
+		cropped, opts, err := aspectCropInput(buf, ImageOptions{Ratio: "16:9", Crop: true, Gravity: bimg.GravitySouth})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if opts.Type != JPEG || !opts.NoRotation {
+			t.Errorf("Unexpected options: type %s, norotation %v", opts.Type, opts.NoRotation)
+		}
+		// 550x740 -> 550x309.4
+		if err := assertSize(cropped, 550, 309); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("focal point", func(t *testing.T) {
+		cropped, opts, err := aspectCropInput(buf, ImageOptions{Ratio: "1:1", Crop: true, FocalY: 0.2,
+			IsDefinedField: IsDefinedField{FocalY: true}})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if opts.IsDefinedField.FocalY {
+			t.Error("The focal point should not apply to the cropped image")
+		}
+		if err := assertSize(cropped, 550, 550); err != nil {
+			t.Error(err)
+		}
+	})
+
+	invalid := []ImageOptions{
+		{Ratio: "16:9"},
+		{Ratio: "16", Crop: true},
+		{Ratio: "0:9", Crop: true},
+		{Ratio: "16:9", Crop: true, FocalX: 2, IsDefinedField: IsDefinedField{FocalX: true}},
+	}
+	for _, opts := range invalid {
+		if _, _, err := aspectCropInput(buf, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
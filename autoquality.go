@@ -0,0 +1,173 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"net/http"
+	"strconv"
+
+	"github.com/h2non/bimg"
+)
+
+// QualityAuto is the quality param value picking the quality from the image
+// content.
+const QualityAuto = "auto"
+
+// DefaultAutoQualitySSIM is the default -auto-quality-ssim value.
+const DefaultAutoQualitySSIM = 0.98
+
+// Range of the qualities tried by the auto quality.
+const (
+	MinAutoQuality = 30
+	MaxAutoQuality = 95
+)
+
+// autoQualitySSIM is the minimum similarity, between 0 and 1, of the images
+// encoded with the auto quality to the lossless ones.
+var autoQualitySSIM = DefaultAutoQualitySSIM
+
+// LoadAutoQuality sets the similarity threshold of the auto quality.
+func LoadAutoQuality(o ServerOptions) {
+	autoQualitySSIM = DefaultAutoQualitySSIM
+	if o.AutoQualitySSIM != 0 {
+		autoQualitySSIM = o.AutoQualitySSIM
+	}
+}
+
+// autoQualityOutputType returns the lossy output type requested along with
+// the auto quality.
+func autoQualityOutputType(buf []byte, opts ImageOptions) (bimg.ImageType, error) {
+	if opts.Lossless {
+		return bimg.UNKNOWN, NewError("Invalid params: quality=auto and lossless cannot be combined",
+			http.StatusBadRequest)
+	}
+	return lossyOutputType(buf, opts, "quality=auto")
+}
+
+// EncodeAutoQuality encodes the PNG image produced by the operation with the
+// lowest quality keeping its SSIM to the lossless image above the
+// -auto-quality-ssim threshold, found by binary search. The quality is
+// reported in the Image-Quality header. Other outputs, such as JSON ones, are
+// returned as is.
+func EncodeAutoQuality(img Image, t bimg.ImageType, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	reference, _, err := decodeImagePixels(img.Body)
+	if err != nil {
+		return Image{}, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+	luma := lumaPlane(reference)
+
+	var best Image
+	quality := 0
+	low, high := MinAutoQuality, MaxAutoQuality
+	for low <= high {
+		mid := (low + high) / 2
+		candidate, err := encodeQuality(img, t, o, mid)
+		if err != nil {
+			return Image{}, err
+		}
+		pixels, _, err := decodeImagePixels(candidate.Body)
+		if err != nil {
+			return Image{}, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+		}
+		if pixels.Rect.Size() == reference.Rect.Size() &&
+			ssim(luma, lumaPlane(pixels), reference.Rect.Dx(), reference.Rect.Dy()) >= autoQualitySSIM {
+			best, quality = candidate, mid
+			high = mid - 1
+		} else {
+			low = mid + 1
+		}
+	}
+
+	// Images too detailed for the threshold keep the highest quality
+	if quality == 0 {
+		quality = MaxAutoQuality
+		if best, err = encodeQuality(img, t, o, quality); err != nil {
+			return Image{}, err
+		}
+	}
+
+	header := best.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(QualityHeader, strconv.Itoa(quality))
+	best.Header = header
+	return best, nil
+}
+
+// lumaPlane returns the Rec. 601 luma of the pixels, row by row, the human
+// eye being mostly sensitive to the luminance artifacts.
+func lumaPlane(pixels *image.NRGBA) []float64 {
+	width, height := pixels.Rect.Dx(), pixels.Rect.Dy()
+	luma := make([]float64, 0, width*height)
+	for y := 0; y < height; y++ {
+		row := pixels.Pix[y*pixels.Stride : y*pixels.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			luma = append(luma, 0.299*float64(row[x])+0.587*float64(row[x+1])+0.114*float64(row[x+2]))
+		}
+	}
+	return luma
+}
+
+// ssim returns the mean structural similarity of the two planes, computed
+// over 8x8 windows overlapping by half, from 0 to 1 for identical planes.
+func ssim(a, b []float64, width, height int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	size := min(8, width, height)
+	if size == 0 {
+		return 1
+	}
+	step := max(size/2, 1)
+	count := float64(size * size)
+
+	var total float64
+	windows := 0
+	for top := 0; top+size <= height; top += step {
+		for left := 0; left+size <= width; left += step {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for y := top; y < top+size; y++ {
+				for x := left; x < left+size; x++ {
+					p, q := a[y*width+x], b[y*width+x]
+					sumA += p
+					sumB += q
+					sumAA += p * p
+					sumBB += q * q
+					sumAB += p * q
+				}
+			}
+
+			meanA, meanB := sumA/count, sumB/count
+			varA := sumAA/count - meanA*meanA
+			varB := sumBB/count - meanB*meanB
+			cov := sumAB/count - meanA*meanB
+			total += (2*meanA*meanB + c1) * (2*cov + c2) / ((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+			windows++
+		}
+	}
+	return total / float64(windows)
+}
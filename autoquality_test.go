@@ -0,0 +1,120 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"io"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestLumaPlane(t *testing.T) {
+	pixels := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	copy(pixels.Pix, []uint8{255, 255, 255, 255, 255, 0, 0, 255})
+
+	luma := lumaPlane(pixels)
+	if len(luma) != 2 || luma[0] < 254.99 || luma[0] > 255.01 || luma[1] < 76.2 || luma[1] > 76.3 {
+		t.Errorf("Invalid luma: %v", luma)
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	width, height := 32, 24
+	plane := make([]float64, width*height)
+	noisy := make([]float64, width*height)
+	for i := range plane {
+		plane[i] = float64((i * 37) % 256)
+		noisy[i] = plane[i] + float64((i%3-1)*40)
+	}
+
+	if actual := ssim(plane, plane, width, height); actual < 0.9999 || actual > 1.0001 {
+		t.Errorf("Expected identical planes to be similar: %f", actual)
+	}
+	if actual := ssim(plane, noisy, width, height); actual >= 0.99 {
+		t.Errorf("Expected noisy planes to be less similar: %f", actual)
+	}
+	if actual := ssim(plane[:4], plane[:4], 2, 2); actual < 0.9999 {
+		t.Errorf("Expected planes smaller than the window to be supported: %f", actual)
+	}
+}
+
+func TestAutoQualityParam(t *testing.T) {
+	opts, err := buildParamsFromQuery(url.Values{"quality": {QualityAuto}})
+	if err != nil || !opts.AutoQuality || opts.Quality != 0 {
+		t.Errorf("Invalid auto quality: %+v, %v", opts, err)
+	}
+}
+
+func TestAutoQualityOutputType(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+
+	if actual, err := autoQualityOutputType(jpeg, ImageOptions{AutoQuality: true}); err != nil || actual != bimg.JPEG {
+		t.Errorf("Invalid output type: %s, %v", bimg.ImageTypeName(actual), err)
+	}
+	if _, err := autoQualityOutputType(png, ImageOptions{AutoQuality: true}); err == nil {
+		t.Error("Expected an error with a PNG output")
+	}
+	if _, err := autoQualityOutputType(png, ImageOptions{Type: WebP, AutoQuality: true, Lossless: true}); err == nil {
+		t.Error("Expected an error with a lossless output")
+	}
+	if _, err := maxBytesOutputType(jpeg, ImageOptions{AutoQuality: true, MaxBytes: 1000}); err == nil {
+		t.Error("Expected an error with maxbytes")
+	}
+}
+
+func TestEncodeAutoQuality(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("large.jpg"))
+	png, err := bimg.NewImage(buf).Process(bimg.Options{Width: 400, Type: bimg.PNG})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	source := Image{Body: png, Mime: ImagePNG}
+
+	defer LoadAutoQuality(ServerOptions{})
+	qualities := make([]int, 0, 2)
+	for _, threshold := range []float64{0.9, 0.99} {
+		LoadAutoQuality(ServerOptions{AutoQualitySSIM: threshold})
+		img, err := EncodeAutoQuality(source, bimg.JPEG, ImageOptions{AutoQuality: true})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if img.Mime != ImageJPEG || bimg.DetermineImageType(img.Body) != bimg.JPEG {
+			t.Error(InvalidMimeType)
+		}
+		quality, err := strconv.Atoi(img.Header.Get(QualityHeader))
+		if err != nil || quality < MinAutoQuality || quality > MaxAutoQuality {
+			t.Errorf("Invalid %s header: %q", QualityHeader, img.Header.Get(QualityHeader))
+		}
+		qualities = append(qualities, quality)
+	}
+	if qualities[0] > qualities[1] {
+		t.Errorf("Expected a higher threshold to keep a higher quality: %v", qualities)
+	}
+
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	img, err := EncodeAutoQuality(json, bimg.JPEG, ImageOptions{AutoQuality: true})
+	if err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/h2non/bimg"
+)
+
+// DefaultAvatarSize is the default avatar width and height, in pixels.
+const DefaultAvatarSize = 128
+
+// DefaultAvatarFont is the font family used when no font param nor
+// -default-font flag is given.
+const DefaultAvatarFont = "sans-serif"
+
+// @Summary Generate avatar
+// @Description Generates an initial letters avatar, its background color being derived from the name
+// @Produce image/*
+// @Param name query string true "Name to take the initials from"
+// @Param width query int false "Width of the avatar (default 128)"
+// @Param height query int false "Height of the avatar (default to width)"
+// @Param font query string false "Font family (default sans-serif)"
+// @Param color query string false "RGB text color (default 255,255,255)"
+// @Param background query string false "RGB background color (default derived from the name)"
+// @Param type query string false "Output format (png, webp, svg, etc.)"
+// @Success 200 {file} binary "Generated avatar"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /avatar [get]
+func Avatar(_ []byte, o ImageOptions) (Image, error) {
+	letters := initials(o.Name)
+	if letters == "" {
+		return Image{}, NewError("Missing required param: name", http.StatusBadRequest)
+	}
+
+	width, height := o.Width, o.Height
+	if width == 0 {
+		width = DefaultAvatarSize
+	}
+	if height == 0 {
+		height = width
+	}
+
+	if err := checkFontAllowed(o.Font); err != nil {
+		return Image{}, err
+	}
+	font := o.Font
+	if font == "" {
+		font = defaultFont
+	}
+	if font == "" {
+		font = DefaultAvatarFont
+	}
+	// The SVG font-family only takes the families of the Pango description
+	families, _ := splitFont(withFontFallback(font, fontFallback))
+	font = strings.Join(families, ",")
+
+	background := opaqueColor(o.Background, avatarColor(o.Name))
+	color := opaqueColor(o.Color, [4]uint8{255, 255, 255, 255})
+	svg := avatarSVG(letters, width, height, font, background, color)
+
+	if ImageType(o.Type) == bimg.SVG {
+		return Image{Body: svg, Mime: ImageSVG}, nil
+	}
+
+	opts := bimg.Options{Type: ImageType(o.Type), Quality: o.Quality, Compression: o.Compression}
+	if opts.Type == bimg.UNKNOWN {
+		opts.Type = bimg.PNG
+	}
+	return Process(svg, opts)
+}
+
+// initials returns the uppercase first letter of the first and last words of the name.
+func initials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	letters := []rune{[]rune(words[0])[0]}
+	if len(words) > 1 {
+		letters = append(letters, []rune(words[len(words)-1])[0])
+	}
+	return strings.ToUpper(string(letters))
+}
+
+// avatarColor derives a stable background color from the name, picking the
+// hue from its hash with a fixed saturation and lightness so white text is
+// always readable.
+func avatarColor(name string) [4]uint8 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	r, g, b := hslToRGB(float64(h.Sum32()%360), 0.55, 0.45)
+	return [4]uint8{r, g, b, 255}
+}
+
+// hslToRGB converts a color given by its hue in degrees, saturation and lightness from 0 to 1.
+func hslToRGB(hue, saturation, lightness float64) (uint8, uint8, uint8) {
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := lightness - chroma/2
+
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+
+	channel := func(v float64) uint8 { return uint8(math.Round((v + m) * 255)) }
+	return channel(r), channel(g), channel(b)
+}
+
+// avatarSVG renders the initials centered over a solid background.
+func avatarSVG(letters string, width, height int, font string, background, color [4]uint8) []byte {
+	var text, family bytes.Buffer
+	_ = xml.EscapeText(&text, []byte(letters))
+	_ = xml.EscapeText(&family, []byte(font))
+
+	fontSize := float64(min(width, height)) * 0.4
+	return []byte(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+
+			`<rect width="100%%" height="100%%" fill="%s"/>`+
+			`<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" font-family="%s" font-size="%.1f" fill="%s">%s</text>`+
+			`</svg>`,
+		width, height, width, height, hexColor(background), family.String(), fontSize, hexColor(color), text.String(),
+	))
+}
+
+func hexColor(c [4]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
@@ -0,0 +1,107 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInitials(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"John Doe", "JD"},
+		{"john", "J"},
+		{"  Jean-Luc   de la Tour ", "JT"},
+		{"élodie martin", "ÉM"},
+		{"O'Brien", "OB"},
+		{"  ", ""},
+	}
+
+	for _, tc := range cases {
+		if letters := initials(tc.name); letters != tc.expected {
+			t.Errorf("Invalid initials for %q: %s != %s", tc.name, letters, tc.expected)
+		}
+	}
+}
+
+func TestAvatarColor(t *testing.T) {
+	if avatarColor("John Doe") != avatarColor(" john doe") {
+		t.Error("Expected the avatar color to be stable for the same name")
+	}
+	if avatarColor("John Doe") == avatarColor("Jane Roe") {
+		t.Error("Expected different names to get different colors")
+	}
+}
+
+func TestHSLToRGB(t *testing.T) {
+	if r, g, b := hslToRGB(0, 1, 0.5); r != 255 || g != 0 || b != 0 {
+		t.Errorf("Invalid red conversion: %d,%d,%d", r, g, b)
+	}
+	if r, g, b := hslToRGB(240, 1, 0.5); r != 0 || g != 0 || b != 255 {
+		t.Errorf("Invalid blue conversion: %d,%d,%d", r, g, b)
+	}
+}
+
+func TestAvatarSVG(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/avatar?name=John+Doe&type=svg&width=64&font=Georgia<", nil)
+	w := httptest.NewRecorder()
+	generatorController(ServerOptions{MaxAllowedPixels: 18.0}, Avatar)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Invalid response status: %d", w.Code)
+	}
+	if w.Header().Get(ContentType) != ImageSVG {
+		t.Errorf("Invalid content type: %s", w.Header().Get(ContentType))
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `width="64" height="64"`) || !strings.Contains(body, ">JD</text>") {
+		t.Errorf("Invalid avatar SVG: %s", body)
+	}
+	if !strings.Contains(body, `font-family="Georgia&lt;"`) {
+		t.Errorf("Expected the font to be escaped: %s", body)
+	}
+}
+
+func TestAvatarFontFallback(t *testing.T) {
+	defer func(fallback []string) { fontFallback = fallback }(fontFallback)
+	fontFallback = []string{"Noto Emoji"}
+
+	img, err := Avatar(nil, ImageOptions{Name: "John Doe", Type: "svg", Font: "Georgia bold 12"})
+	if err != nil {
+		t.Fatalf("Cannot render the avatar: %s", err)
+	}
+	if !strings.Contains(string(img.Body), `font-family="Georgia,Noto Emoji"`) {
+		t.Errorf("Expected the font families along with the fallback ones: %s", img.Body)
+	}
+}
+
+func TestAvatarMissingName(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/avatar", nil)
+	w := httptest.NewRecorder()
+	generatorController(ServerOptions{MaxAllowedPixels: 18.0}, Avatar)(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid response status: %d", w.Code)
+	}
+}
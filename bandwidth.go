@@ -0,0 +1,121 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AnonymousTenant is the tenant of the requests without a known API key.
+const AnonymousTenant = "anonymous"
+
+// dailyQuota enforces the daily bandwidth cap of every tenant. It is nil,
+// hence disabled, unless the -tenant-daily-quota flag is set.
+var dailyQuota *bandwidthQuota
+
+// bandwidthQuota accounts the bytes served to every tenant during the
+// current UTC day.
+type bandwidthQuota struct {
+	limit int64
+
+	mu     sync.Mutex
+	day    string
+	served map[string]int64
+}
+
+func newBandwidthQuota(limit int) *bandwidthQuota {
+	return &bandwidthQuota{limit: int64(limit), served: make(map[string]int64)}
+}
+
+// LoadBandwidthQuota enables the tenant daily bandwidth cap when configured.
+func LoadBandwidthQuota(o ServerOptions) {
+	if o.TenantDailyQuota > 0 {
+		dailyQuota = newBandwidthQuota(o.TenantDailyQuota)
+	}
+}
+
+// rollover resets the counters when the day changes. The mutex must be held.
+func (q *bandwidthQuota) rollover(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != q.day {
+		q.day = day
+		clear(q.served)
+	}
+}
+
+// exceeded reports whether the tenant already used its daily quota.
+func (q *bandwidthQuota) exceeded(tenant string, now time.Time) bool {
+	if q == nil {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover(now)
+	return q.served[tenant] >= q.limit
+}
+
+// add accounts the bytes served to the tenant.
+func (q *bandwidthQuota) add(tenant string, n int, now time.Time) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover(now)
+	q.served[tenant] += int64(n)
+}
+
+// requestTenant identifies the tenant by its API key, only if known so the
+// number of tenants stays bounded. Keys are hashed to keep them out of the
+// metrics.
+func requestTenant(r *http.Request, o ServerOptions) string {
+	key := requestAPIKey(r)
+	if _, trusted := o.TrustedKeys[key]; key == "" || (key != o.APIKey && !trusted) {
+		return AnonymousTenant
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// tenantBandwidth accounts the bytes served to every tenant, replying 429
+// once its daily quota is exceeded.
+func tenantBandwidth(next http.Handler, o ServerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := requestTenant(r, o)
+		if dailyQuota.exceeded(tenant, time.Now()) {
+			tenantRejected.WithLabelValues(tenant).Inc()
+			ErrorReply(r, w, ErrBandwidthExceeded, o)
+			return
+		}
+
+		rw := NewMetricsResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		tenantServedBytes.WithLabelValues(tenant).Add(float64(rw.Length))
+		dailyQuota.add(tenant, rw.Length, time.Now())
+	})
+}
@@ -0,0 +1,90 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBandwidthQuota(t *testing.T) {
+	quota := newBandwidthQuota(100)
+	now := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	quota.add("a", 60, now)
+	if quota.exceeded("a", now) {
+		t.Error("Expected tenant under its quota not to be rejected")
+	}
+	quota.add("a", 40, now)
+	if !quota.exceeded("a", now) {
+		t.Error("Expected tenant over its quota to be rejected")
+	}
+	if quota.exceeded("b", now) {
+		t.Error("Expected other tenants not to be rejected")
+	}
+	if quota.exceeded("a", now.Add(2*time.Hour)) {
+		t.Error("Expected the quota to be reset the next day")
+	}
+
+	var disabled *bandwidthQuota
+	disabled.add("a", 1000, now)
+	if disabled.exceeded("a", now) {
+		t.Error("Expected no quota to never reject")
+	}
+}
+
+func TestRequestTenant(t *testing.T) {
+	o := ServerOptions{APIKey: "secret", TrustedKeys: map[string]float64{"print": 80}}
+
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"", AnonymousTenant},
+		{"unknown", AnonymousTenant},
+		{"secret", "2bb80d53"},
+		{"print", "ce953a0e"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/resize?key="+c.key, nil)
+		if tenant := requestTenant(r, o); tenant != c.expected {
+			t.Errorf("Invalid tenant for key %q: %s != %s", c.key, tenant, c.expected)
+		}
+	}
+}
+
+func TestTenantBandwidth(t *testing.T) {
+	dailyQuota = newBandwidthQuota(10)
+	defer func() { dailyQuota = nil }()
+
+	handler := tenantBandwidth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}), ServerOptions{})
+
+	for _, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/resize", nil))
+		if res.Code != expected {
+			t.Errorf("Invalid response status: %d != %d", res.Code, expected)
+		}
+	}
+}
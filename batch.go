@@ -0,0 +1,231 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const maxBatchFiles = 50
+
+// @Summary Batch processing
+// @Description Applies the same operation to several uploaded images, or to the images of the urls param, and streams the results back as an archive
+// @Accept multipart/form-data
+// @Produce application/x-tar
+// @Produce application/gzip
+// @Produce application/zip
+// @Produce multipart/mixed
+// @Param file formData file false "Image files to process (repeat the field for each image)"
+// @Param urls query string false "JSON list of the image URLs to process"
+// @Param operation query string true "Operation name applied to every image (same names as pipeline)"
+// @Param archive query string false "Archive format: tar (default), tar.gz, zip or multipart"
+// @Success 200 {file} binary "Archive with the processed images"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Router /batch [post]
+func batchController(o ServerOptions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		o := withRequestLimits(req, o)
+		query := req.URL.Query()
+
+		name := query.Get("operation")
+		if name == "" {
+			ErrorReply(req, w, ErrMissingOperation, o)
+			return
+		}
+		operation, ok := OperationsMap[name]
+		if !ok {
+			ErrorReply(req, w, NewError(fmt.Sprintf("Unsupported operation name: %s", name), http.StatusBadRequest), o)
+			return
+		}
+
+		format := parseArchiveFormat(query.Get("archive"))
+		if format == "" {
+			ErrorReply(req, w, ErrUnsupportedArchive, o)
+			return
+		}
+
+		opts, vary, err := processImageOptions(req)
+		if err != nil {
+			ErrorReply(req, w, NewError(err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		if opts.Async || opts.Callback != "" {
+			ErrorReply(req, w, ErrAsyncUnsupported, o)
+			return
+		}
+
+		inputs, err := readBatchInputs(req, opts, o)
+		if err != nil {
+			ErrorReply(req, w, toError(err), o)
+			return
+		}
+
+		archive, mimeType, err := NewArchiveWriter(w, format)
+		if err != nil {
+			ErrorReply(req, w, ErrUnsupportedArchive, o)
+			return
+		}
+
+		w.Header().Set(ContentType, mimeType)
+		if format != ArchiveMultipart {
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="batch.%s"`, format))
+		}
+		if vary != "" {
+			w.Header().Set("Vary", vary)
+		}
+
+		// Headers are already sent once the first entry is written, so failures
+		// of a single file are reported as a JSON entry inside the archive.
+		for i, input := range inputs {
+			image, err := processBatchImage(input, operation, opts, o)
+			name := batchEntryName(i, input.name, GetImageExtension(image.Mime))
+			body, mimeType := image.Body, image.Mime
+			if err != nil {
+				name = batchEntryName(i, input.name, "error.json")
+				body, mimeType = toError(err).JSON(), ContentTypeJSON
+			}
+
+			if err := archive.WriteFile(name, mimeType, body); err != nil {
+				return
+			}
+		}
+
+		_ = archive.Close()
+	}
+}
+
+// batchInput is an image of the batch, either uploaded or fetched from its
+// URL once its turn comes, so a single image is held in memory at a time.
+type batchInput struct {
+	name string
+	read func() ([]byte, error)
+}
+
+// readBatchInputs returns the images uploaded under the file form field, or
+// listed by the urls param.
+func readBatchInputs(req *http.Request, opts ImageOptions, o ServerOptions) ([]batchInput, error) {
+	if !isFormBody(req) && len(opts.URLs) > 0 {
+		if !o.EnableURLSource {
+			return nil, ErrBatchURLsDisabled
+		}
+		if err := checkURLs(opts.URLs, maxBatchFiles); err != nil {
+			return nil, err
+		}
+
+		inputs := make([]batchInput, 0, len(opts.URLs))
+		for _, rawURL := range opts.URLs {
+			inputs = append(inputs, batchInput{name: batchURLName(rawURL), read: func() ([]byte, error) {
+				buf, err := loadLayer(CompositeLayer{URL: rawURL})
+				if err != nil {
+					return nil, NewError("Unable to load the image: "+err.Error(), http.StatusBadRequest)
+				}
+				return buf, nil
+			}})
+		}
+		return inputs, nil
+	}
+
+	files, err := readFormFiles(req)
+	if err != nil {
+		return nil, toError(err)
+	}
+	if len(files) > maxBatchFiles {
+		return nil, ErrTooManyBatchFiles
+	}
+
+	inputs := make([]batchInput, 0, len(files))
+	for _, file := range files {
+		inputs = append(inputs, batchInput{name: file.Filename, read: func() ([]byte, error) {
+			return readBatchFile(file)
+		}})
+	}
+	return inputs, nil
+}
+
+func readBatchFile(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func(f multipart.File) {
+		_ = f.Close()
+	}(f)
+
+	return io.ReadAll(f)
+}
+
+// batchURLName returns the file name of the image URL path.
+func batchURLName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+func processBatchImage(input batchInput, operation Operation, opts ImageOptions, o ServerOptions) (Image, error) {
+	buf, err := input.read()
+	if err != nil {
+		return Image{}, err
+	}
+	if len(buf) == 0 {
+		return Image{}, ErrEmptyBody
+	}
+
+	mimeType, err := inferMimeType(buf)
+	if err != nil || !IsImageMimeTypeSupported(mimeType) {
+		return Image{}, ErrUnsupportedMedia
+	}
+
+	if err := validateImageSize(buf, opts, o); err != nil {
+		return Image{}, err
+	}
+
+	image, err := runOperation(operation, buf, opts)
+	if err != nil {
+		return Image{}, NewError("Error while processing the image: "+err.Error(), http.StatusBadRequest)
+	}
+	return image, nil
+}
+
+// batchEntryName builds a unique archive entry name keeping the original file name.
+func batchEntryName(index int, filename, ext string) string {
+	base := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	base = strings.TrimSuffix(base, path.Ext(base))
+	if base == "" || base == "." || base == "/" {
+		base = "image"
+	}
+	return fmt.Sprintf("%03d-%s.%s", index+1, base, ext)
+}
+
+// toError converts any error into the public Error representation.
+func toError(err error) Error {
+	if xerr, ok := err.(Error); ok {
+		return xerr
+	}
+	return NewError(err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,138 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchEntryName(t *testing.T) {
+	cases := []struct {
+		index    int
+		filename string
+		ext      string
+		expected string
+	}{
+		{0, "photo.jpg", "webp", "001-photo.webp"},
+		{9, "dir/photo.png", "png", "010-photo.png"},
+		{1, `C:\images\cat.jpeg`, "jpeg", "002-cat.jpeg"},
+		{2, "", "jpeg", "003-image.jpeg"},
+		{3, "photo.jpg", "error.json", "004-photo.error.json"},
+	}
+
+	for _, tc := range cases {
+		if name := batchEntryName(tc.index, tc.filename, tc.ext); name != tc.expected {
+			t.Errorf("invalid entry name: %s != %s", name, tc.expected)
+		}
+	}
+}
+
+func TestBatchControllerErrors(t *testing.T) {
+	cases := []struct {
+		query   string
+		message string
+	}{
+		{"", ErrMissingOperation.Message},
+		{"operation=unknown", "Unsupported operation name"},
+		{"operation=resize&archive=rar", ErrUnsupportedArchive.Message},
+		{"operation=resize", ErrMissingParamFile.Message},
+		{"operation=resize&urls=" + url.QueryEscape(`["https://example.com/a.jpg"]`), ErrBatchURLsDisabled.Message},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/batch?"+tc.query, strings.NewReader(""))
+		w := httptest.NewRecorder()
+		batchController(ServerOptions{MaxAllowedPixels: 18.0})(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("invalid response status for %q: %d", tc.query, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.message) {
+			t.Errorf("invalid error message for %q: %s", tc.query, w.Body.String())
+		}
+	}
+}
+
+func TestBatchURLName(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/images/photo.jpg?w=1": "/images/photo.jpg",
+		"https://example.com":                      "",
+		"://invalid":                               "",
+	}
+
+	for rawURL, expected := range cases {
+		if name := batchURLName(rawURL); name != expected {
+			t.Errorf("invalid name of %s: %s != %s", rawURL, name, expected)
+		}
+	}
+	if name := batchEntryName(0, batchURLName("https://example.com/a/photo.jpg?v=2"), "webp"); name != "001-photo.webp" {
+		t.Errorf("invalid entry name: %s", name)
+	}
+}
+
+func TestBatchControllerURLs(t *testing.T) {
+	t.Cleanup(func() { LoadSources(ServerOptions{}) })
+	LoadSources(ServerOptions{EnableURLSource: true})
+
+	tsImage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		buf, _ := os.ReadFile("testdata/imaginary.jpg")
+		_, _ = w.Write(buf)
+	}))
+	defer tsImage.Close()
+
+	urls := `["` + tsImage.URL + `/a.jpg","` + tsImage.URL + `/missing.jpg"]`
+	query := "operation=resize&width=300&archive=zip&urls=" + url.QueryEscape(urls)
+	req := httptest.NewRequest(http.MethodGet, "/batch?"+query, nil)
+	w := httptest.NewRecorder()
+	batchController(ServerOptions{MaxAllowedPixels: 18.0, EnableURLSource: true})(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("invalid response status: %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(ContentType) != "application/zip" {
+		t.Errorf("invalid content type: %s", w.Header().Get(ContentType))
+	}
+
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("invalid zip archive: %s", err)
+	}
+	if len(zr.File) != 2 || zr.File[0].Name != "001-a.jpeg" || zr.File[1].Name != "002-missing.error.json" {
+		t.Fatalf("invalid archive entries: %v", zr.File)
+	}
+
+	r, _ := zr.File[0].Open()
+	buf := new(bytes.Buffer)
+	_, _ = buf.ReadFrom(r)
+	if err := assertSize(buf.Bytes(), 300, 404); err != nil {
+		t.Error(err)
+	}
+}
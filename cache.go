@@ -0,0 +1,115 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"image"
+	"sync"
+)
+
+// decodedPixels caches the images decoded by the Go pixel operations, keyed
+// by the hash of their source buffer. It is nil, hence disabled, unless the
+// -pixel-cache-size flag is set. libvips operations don't use it.
+var decodedPixels *pixelCache
+
+// pixelCache is a LRU cache of decoded images bounded by the total size of
+// their pixel buffers, so consecutive pixel operations on the same source
+// skip the decoding stage.
+type pixelCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	entries *list.List
+	items   map[[sha256.Size]byte]*list.Element
+}
+
+type pixelCacheEntry struct {
+	key    [sha256.Size]byte
+	pixels *image.NRGBA
+	alpha  bool
+}
+
+func newPixelCache(maxSize int) *pixelCache {
+	return &pixelCache{
+		maxSize: maxSize,
+		entries: list.New(),
+		items:   make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// LoadPixelCache enables the pixel operations cache when a size is configured.
+func LoadPixelCache(o ServerOptions) {
+	if o.PixelCacheSize > 0 {
+		decodedPixels = newPixelCache(o.PixelCacheSize)
+	}
+}
+
+// get returns a copy of the cached image, since operations edit the
+// pixels in place.
+func (c *pixelCache) get(key [sha256.Size]byte) (*image.NRGBA, bool, bool) {
+	if c == nil {
+		return nil, false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, false
+	}
+	c.entries.MoveToFront(el)
+	entry := el.Value.(*pixelCacheEntry)
+	return clonePixels(entry.pixels), entry.alpha, true
+}
+
+// add stores a copy of the image, evicting the least recently used entries
+// to stay within the size limit.
+func (c *pixelCache) add(key [sha256.Size]byte, pixels *image.NRGBA, alpha bool) {
+	if c == nil || len(pixels.Pix) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.entries.MoveToFront(el)
+		return
+	}
+
+	entry := &pixelCacheEntry{key: key, pixels: clonePixels(pixels), alpha: alpha}
+	c.items[key] = c.entries.PushFront(entry)
+	c.size += len(pixels.Pix)
+
+	for c.size > c.maxSize {
+		oldest := c.entries.Back()
+		evicted := c.entries.Remove(oldest).(*pixelCacheEntry)
+		delete(c.items, evicted.key)
+		c.size -= len(evicted.pixels.Pix)
+	}
+}
+
+func clonePixels(img *image.NRGBA) *image.NRGBA {
+	clone := *img
+	clone.Pix = append([]uint8(nil), img.Pix...)
+	return &clone
+}
@@ -0,0 +1,79 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"image"
+	"testing"
+)
+
+func TestPixelCache(t *testing.T) {
+	// Each 2x2 canvas takes 16 bytes
+	cache := newPixelCache(32)
+	keys := [][sha256.Size]byte{sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c"))}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	cache.add(keys[0], img, true)
+	img.Pix[0] = 255
+
+	cached, alpha, ok := cache.get(keys[0])
+	if !ok || !alpha {
+		t.Fatal("Expected the image to be cached")
+	}
+	if cached.Pix[0] != 0 {
+		t.Error("Expected the cache to store a copy of the image")
+	}
+	cached.Pix[1] = 255
+	if again, _, _ := cache.get(keys[0]); again.Pix[1] != 0 {
+		t.Error("Expected the cache to return a copy of the image")
+	}
+
+	cache.add(keys[1], image.NewNRGBA(image.Rect(0, 0, 2, 2)), false)
+	cache.get(keys[0])
+	cache.add(keys[2], image.NewNRGBA(image.Rect(0, 0, 2, 2)), false)
+
+	if _, _, ok := cache.get(keys[1]); ok {
+		t.Error("Expected the least recently used image to be evicted")
+	}
+	if _, _, ok := cache.get(keys[0]); !ok {
+		t.Error("Expected the recently used image to be kept")
+	}
+
+	cache.add(sha256.Sum256([]byte("d")), image.NewNRGBA(image.Rect(0, 0, 10, 10)), false)
+	if cache.size != 32 {
+		t.Errorf("Expected images larger than the cache to be skipped, size: %d", cache.size)
+	}
+}
+
+func TestDecodePixelsCache(t *testing.T) {
+	defer func() { decodedPixels = nil }()
+	decodedPixels = newPixelCache(1024)
+
+	buf := []byte("source")
+	decodedPixels.add(sha256.Sum256(buf), image.NewNRGBA(image.Rect(0, 0, 3, 2)), false)
+
+	pixels, _, err := decodePixels(buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if pixels.Rect.Dx() != 3 || pixels.Rect.Dy() != 2 {
+		t.Errorf("Expected the cached image to be returned, got %v", pixels.Rect)
+	}
+}
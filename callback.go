@@ -0,0 +1,180 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// callbackAttempts is the number of deliveries of a job callback before
+// giving up.
+const callbackAttempts = 5
+
+// JobIDHeader and JobStatusHeader identify the job notified by a callback.
+const (
+	JobIDHeader     = "X-Job-ID"
+	JobStatusHeader = "X-Job-Status"
+)
+
+// callbackBackoff is the delay before the first retry of a callback, doubled
+// on every retry.
+var callbackBackoff = time.Second
+
+// callbackClient doesn't follow redirects, which would post the signed
+// notification to origins not allowed by -callback-origins. Redirects are
+// reported as failed deliveries instead.
+var callbackClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+var (
+	ErrCallbackDisabled = NewError("Invalid param: callback requires the -callback-origins flag", http.StatusBadRequest)
+	ErrCallbackURL      = NewError("Invalid param: callback must be an http or https URL", http.StatusBadRequest)
+	ErrCallbackOrigin   = NewError("Callback URL origin not allowed", http.StatusForbidden)
+	ErrCallbackPayload  = NewError("Unsupported payload value. Allowed values are: status, image", http.StatusBadRequest)
+)
+
+// The payloads of the callbacks: the job status along with the location of
+// its result, or the processed image itself.
+const (
+	PayloadStatus = "status"
+	PayloadImage  = "image"
+)
+
+// jobCallback notifies the completion of an asynchronous job with a signed
+// POST request.
+type jobCallback struct {
+	url string
+	// image sends the processed image itself, rather than its location.
+	image bool
+	// input is the signature input of the request submitting the job.
+	input string
+	// result is the location of the result of the job.
+	result string
+}
+
+// newJobCallback returns the callback of the callback param, if any, once
+// checked against the -callback-origins flag.
+func newJobCallback(r *http.Request, opts ImageOptions, o ServerOptions) (*jobCallback, error) {
+	if opts.Callback == "" {
+		return nil, nil
+	}
+	if len(o.CallbackOrigins) == 0 {
+		return nil, ErrCallbackDisabled
+	}
+	if opts.Payload != "" && opts.Payload != PayloadStatus && opts.Payload != PayloadImage {
+		return nil, ErrCallbackPayload
+	}
+
+	u, err := url.Parse(opts.Callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrCallbackURL
+	}
+	if shouldRestrictOrigin(u, o.CallbackOrigins) {
+		return nil, ErrCallbackOrigin
+	}
+
+	return &jobCallback{
+		url:    u.String(),
+		image:  opts.Payload == PayloadImage,
+		input:  signatureInput(r),
+		result: o.PublicURL + join(o, "/jobs"),
+	}, nil
+}
+
+// deliver posts the notification of the completed job, retrying with an
+// exponential backoff when the delivery fails.
+func (c *jobCallback) deliver(j job) {
+	delay := callbackBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := c.post(j)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == callbackAttempts {
+			logf(LogLevelWarning, "cannot deliver the callback of job %s: %s", j.ID, err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends the notification, reporting whether a failed delivery may be
+// retried, i.e. on network errors, server errors and rate limiting.
+func (c *jobCallback) post(j job) (bool, error) {
+	req, err := c.request(j)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := callbackClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusRequestTimeout ||
+		res.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status %d", res.StatusCode)
+}
+
+// request builds the notification: the processed image, or the job status
+// along with the location of its result, absolute with -public-url. The
+// request Host header isn't trusted to build it, as clients can forge it.
+func (c *jobCallback) request(j job) (*http.Request, error) {
+	var body []byte
+	var mimeType string
+	if c.image && j.Status == JobDone {
+		body, mimeType = j.image.Body, j.image.Mime
+	} else {
+		status := j.Job
+		if status.Status == JobDone {
+			status.Result = c.result + "/" + j.ID + "/result"
+		}
+		body, _ = json.Marshal(status)
+		mimeType = ContentTypeJSON
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(ContentType, mimeType)
+	req.Header.Set("User-Agent", "imaginary/"+Version)
+	req.Header.Set(JobIDHeader, j.ID)
+	req.Header.Set(JobStatusHeader, j.Status)
+	if responseSigner != nil {
+		responseSigner.setHeaders(req.Header, body, c.input)
+	}
+	return req, nil
+}
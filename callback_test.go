@@ -0,0 +1,201 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewJobCallback(t *testing.T) {
+	o := ServerOptions{CallbackOrigins: parseOrigins("https://hooks.example.com")}
+	req := httptest.NewRequest(http.MethodPost, "https://imaginary.example.com/resize?width=300&async=true", nil)
+	req.Host = "evil.example.com"
+
+	cases := []struct {
+		opts ImageOptions
+		o    ServerOptions
+		err  error
+	}{
+		{ImageOptions{Callback: "https://hooks.example.com/done"}, ServerOptions{}, ErrCallbackDisabled},
+		{ImageOptions{Callback: "ftp://hooks.example.com/done"}, o, ErrCallbackURL},
+		{ImageOptions{Callback: "/done"}, o, ErrCallbackURL},
+		{ImageOptions{Callback: "https://evil.example.com/done"}, o, ErrCallbackOrigin},
+		{ImageOptions{Callback: "https://hooks.example.com/done", Payload: "json"}, o, ErrCallbackPayload},
+	}
+	for _, c := range cases {
+		if _, err := newJobCallback(req, c.opts, c.o); err != c.err {
+			t.Errorf("Expected %v for %+v, got %v", c.err, c.opts, err)
+		}
+	}
+
+	opts := ImageOptions{Callback: "https://hooks.example.com/done", Payload: PayloadImage}
+	callback, err := newJobCallback(req, opts, o)
+	if err != nil {
+		t.Fatalf("Cannot create callback: %s", err)
+	}
+	if !callback.image || callback.result != "/jobs" || callback.input != "/resize?async=true&width=300" {
+		t.Errorf("Invalid callback: %+v", callback)
+	}
+
+	o.PublicURL, o.PathPrefix = "https://imaginary.example.com", "/v1"
+	if callback, _ := newJobCallback(req, opts, o); callback.result != "https://imaginary.example.com/v1/jobs" {
+		t.Errorf("Expected the result to be located with -public-url, not the request host: %s", callback.result)
+	}
+
+	if callback, err := newJobCallback(req, ImageOptions{}, o); callback != nil || err != nil {
+		t.Errorf("Expected no callback without the callback param: %+v, %v", callback, err)
+	}
+}
+
+func TestJobCallbackDeliver(t *testing.T) {
+	defer func(backoff time.Duration) { callbackBackoff = backoff }(callbackBackoff)
+	callbackBackoff = time.Millisecond
+	defer func() { responseSigner = nil }()
+	LoadResponseSigning(ServerOptions{ResponseHMACKey: "4f46feebafc4b5e988f131c4ff8b5997"})
+
+	var attempts atomic.Int32
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	callback := &jobCallback{url: server.URL, input: "/resize?width=300", result: "https://imaginary.example.com/jobs"}
+	j := job{Job: Job{ID: "abc", Status: JobDone}, image: Image{Body: []byte("image"), Mime: ImagePNG}}
+	callback.deliver(j)
+
+	if n := attempts.Load(); n != 3 {
+		t.Fatalf("Expected the callback to be retried until delivered: %d attempts", n)
+	}
+	req, body := <-received, <-bodies
+	if req.Header.Get(JobIDHeader) != "abc" || req.Header.Get(JobStatusHeader) != JobDone {
+		t.Errorf("Invalid job headers: %v", req.Header)
+	}
+	if req.Header.Get(SignatureHeader) == "" || req.Header.Get(SignatureInputHeader) != "/resize?width=300" {
+		t.Errorf("Expected the callback to be signed: %v", req.Header)
+	}
+
+	var status Job
+	if err := json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("Cannot decode callback body: %s", err)
+	}
+	if status.Result != "https://imaginary.example.com/jobs/abc/result" {
+		t.Errorf("Invalid result location: %s", status.Result)
+	}
+}
+
+func TestJobCallbackImagePayload(t *testing.T) {
+	type notification struct{ mimeType, body string }
+	received := make(chan notification, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		received <- notification{r.Header.Get(ContentType), string(buf)}
+	}))
+	defer server.Close()
+
+	callback := &jobCallback{url: server.URL, image: true}
+	callback.deliver(job{Job: Job{ID: "abc", Status: JobDone}, image: Image{Body: []byte("image"), Mime: ImagePNG}})
+	if n := <-received; n.mimeType != ImagePNG || n.body != "image" {
+		t.Errorf("Expected the image to be sent: %+v", n)
+	}
+
+	errJob := NewError("Error while processing the image: boom", http.StatusBadRequest)
+	callback.deliver(job{Job: Job{ID: "abc", Status: JobError, Error: &errJob}})
+	if n := <-received; n.mimeType != ContentTypeJSON {
+		t.Errorf("Expected the error to be sent as JSON: %+v", n)
+	}
+}
+
+func TestJobCallbackClientError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	(&jobCallback{url: server.URL}).deliver(job{Job: Job{ID: "abc", Status: JobDone}})
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Expected client errors not to be retried: %d attempts", n)
+	}
+}
+
+func TestJobCallbackRedirect(t *testing.T) {
+	var redirected atomic.Int32
+	disallowed := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		redirected.Add(1)
+	}))
+	defer disallowed.Close()
+	var attempts atomic.Int32
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Redirect(w, r, disallowed.URL, http.StatusTemporaryRedirect)
+	}))
+	defer allowed.Close()
+
+	o := ServerOptions{CallbackOrigins: parseOrigins(allowed.URL)}
+	req := httptest.NewRequest(http.MethodPost, "/resize?width=300&async=true", nil)
+	callback, err := newJobCallback(req, ImageOptions{Callback: allowed.URL + "/done"}, o)
+	if err != nil {
+		t.Fatalf("Cannot create callback: %s", err)
+	}
+	callback.deliver(job{Job: Job{ID: "abc", Status: JobDone}})
+
+	if n := redirected.Load(); n != 0 {
+		t.Errorf("Expected the redirect to a disallowed origin not to be followed: %d requests", n)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Expected redirects to fail the delivery without retry: %d attempts", n)
+	}
+}
+
+func TestValidateCallbackOrigins(t *testing.T) {
+	origins := []*url.URL{{Scheme: "https", Host: "hooks.example.com"}}
+	key := "4f46feebafc4b5e988f131c4ff8b5997"
+
+	cases := []struct {
+		opts  ServerOptions
+		valid bool
+	}{
+		{ServerOptions{}, true},
+		{ServerOptions{CallbackOrigins: origins, JobsWorkers: 2, ResponseHMACKey: key}, true},
+		{ServerOptions{CallbackOrigins: origins, ResponseHMACKey: key}, false},
+		{ServerOptions{CallbackOrigins: origins, JobsWorkers: 2}, false},
+	}
+
+	for _, c := range cases {
+		if err := validateCallbackOrigins(c.opts); (err == nil) != c.valid {
+			t.Errorf("Invalid callback origins validation for %+v: %v", c.opts, err)
+		}
+	}
+}
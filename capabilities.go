@@ -0,0 +1,101 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Capabilities is the reply of the capabilities endpoint, listing the limits
+// applied to the requests so clients can check whether an upload is viable
+// before sending it. A zero limit means unlimited.
+type Capabilities struct {
+	// MaxBodySize is the maximum size in bytes of the request bodies.
+	MaxBodySize int `json:"maxBodySize"`
+	// MaxAllowedSize is the maximum size in bytes of the remote images.
+	MaxAllowedSize int `json:"maxAllowedSize"`
+	// MaxAllowedResolution is the maximum resolution in megapixels of the
+	// images, as raised by a trusted API key.
+	MaxAllowedResolution float64 `json:"maxAllowedResolution"`
+	URLSource            bool    `json:"urlSource"`
+	Async                bool    `json:"async"`
+}
+
+// @Summary Capabilities
+// @Description Returns the limits applied to the requests, such as the maximum body size, 0 meaning unlimited
+// @Produce json
+// @Success 200 {object} Capabilities
+// @Router /capabilities [get]
+func capabilitiesController(o ServerOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		capabilities := Capabilities{
+			MaxBodySize:          o.MaxBodySize,
+			MaxAllowedSize:       o.MaxAllowedSize,
+			MaxAllowedResolution: withRequestLimits(r, o).MaxAllowedPixels,
+			URLSource:            o.EnableURLSource,
+			Async:                jobs != nil,
+		}
+		body, _ := json.Marshal(capabilities)
+
+		w.Header().Set(ContentType, ContentTypeJSON)
+		_, _ = w.Write(body)
+	}
+}
+
+// bodyTooLarge returns the error replied to requests whose body exceeds the
+// -max-body-size limit.
+func bodyTooLarge(limit int64) Error {
+	message := fmt.Sprintf("Request body too large, the maximum is %d bytes", limit)
+	return NewError(message, http.StatusRequestEntityTooLarge)
+}
+
+// limitBody rejects the requests declaring a Content-Length above the
+// -max-body-size limit before their body is read, and caps the read of the
+// bodies of unknown length, e.g. chunked ones.
+func limitBody(next http.Handler, o ServerOptions) http.Handler {
+	if o.MaxBodySize <= 0 {
+		return next
+	}
+
+	limit := int64(o.MaxBodySize)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			// The body is left unread, hence the connection can't be reused
+			w.Header().Set("Connection", "close")
+			ErrorReply(r, w, bodyTooLarge(limit), o)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyReadError maps the error of a body read capped by limitBody to a 413
+// reply.
+func bodyReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return bodyTooLarge(maxBytesErr.Limit)
+	}
+	return err
+}
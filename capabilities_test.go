@@ -0,0 +1,102 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	o := ServerOptions{MaxBodySize: 10}
+
+	var read []byte
+	var readErr error
+	handler := limitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read, readErr = readRawBody(r)
+	}), o)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/resize", strings.NewReader("0123456789a")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a declared Content-Length above the limit to be rejected: %d", w.Code)
+	}
+	if read != nil || readErr != nil {
+		t.Error("Expected the body not to be read")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/resize", strings.NewReader("0123456789")))
+	if w.Code != http.StatusOK || string(read) != "0123456789" || readErr != nil {
+		t.Errorf("Expected a body within the limit to be read: %d %q %v", w.Code, read, readErr)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/resize", strings.NewReader("0123456789a"))
+	req.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if xerr, ok := readErr.(Error); !ok || xerr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a chunked body above the limit to fail with 413: %v", readErr)
+	}
+}
+
+func TestCapabilitiesController(t *testing.T) {
+	o := ServerOptions{
+		MaxBodySize:      1024,
+		MaxAllowedSize:   2048,
+		MaxAllowedPixels: 18,
+		EnableURLSource:  true,
+		TrustedKeys:      map[string]float64{"trusted": 50},
+	}
+
+	cases := []struct {
+		key        string
+		resolution float64
+	}{
+		{"", 18},
+		{"trusted", 50},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/capabilities", nil)
+		req.Header.Set("API-Key", c.key)
+		w := httptest.NewRecorder()
+		capabilitiesController(o)(w, req)
+
+		if ct := w.Header().Get(ContentType); ct != ContentTypeJSON {
+			t.Errorf("Invalid content type: %s", ct)
+		}
+
+		var capabilities Capabilities
+		if err := json.Unmarshal(w.Body.Bytes(), &capabilities); err != nil {
+			t.Fatal(err)
+		}
+		expected := Capabilities{
+			MaxBodySize:          1024,
+			MaxAllowedSize:       2048,
+			MaxAllowedResolution: c.resolution,
+			URLSource:            true,
+		}
+		if capabilities != expected {
+			t.Errorf("Invalid capabilities for key %q: %+v", c.key, capabilities)
+		}
+	}
+}
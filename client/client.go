@@ -0,0 +1,150 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package client is a Go client of the imaginary HTTP API, building and
+// signing the requests, running pipelines and parsing the errors replied.
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Image is a successful reply, an image or, for endpoints such as info, a
+// JSON document.
+type Image struct {
+	Body   []byte
+	Type   string
+	Header http.Header
+}
+
+// Client sends requests to an imaginary server.
+type Client struct {
+	// BaseURL is the URL of the server, including its -path-prefix, if any.
+	BaseURL *url.URL
+	// APIKey is sent in the API-Key header when set, see the -key flag.
+	APIKey string
+	// SignatureKey signs the request URLs when set, see the
+	// -enable-url-signature flag.
+	SignatureKey string
+	// HTTPClient sends the requests, http.DefaultClient being used when nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client of the server at the given URL.
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("imaginary: invalid server URL %q", baseURL)
+	}
+	return &Client{BaseURL: u}, nil
+}
+
+// URL returns the URL of the endpoint with the given params, signed when a
+// signature key is set.
+func (c *Client) URL(endpoint string, params Params) (*url.URL, error) {
+	query, err := params.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	u := *c.BaseURL
+	u.Path = path.Join("/", c.BaseURL.Path, endpoint)
+	u.RawPath = ""
+	if c.SignatureKey != "" {
+		query.Set("sign", Sign(u.Path, query, c.SignatureKey))
+	}
+	u.RawQuery = query.Encode()
+	return &u, nil
+}
+
+// NewRequest returns a request to the endpoint. The image body is uploaded when
+// given, else the image source must be given by the url or file param.
+func (c *Client) NewRequest(
+	ctx context.Context, endpoint string, params Params, body io.Reader,
+) (*http.Request, error) {
+	u, err := c.URL(endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	method := http.MethodGet
+	if body != nil {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("API-Key", c.APIKey)
+	}
+	return req, nil
+}
+
+// Do sends the request, returning the reply, or an *Error when the server
+// replies one.
+func (c *Client) Do(req *http.Request) (*Image, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if err := parseError(res); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Body: body, Type: res.Header.Get("Content-Type"), Header: res.Header}, nil
+}
+
+// Process runs the endpoint on the image, or on the image source given by
+// the url or file param when the image is nil.
+func (c *Client) Process(ctx context.Context, endpoint string, params Params, image io.Reader) (*Image, error) {
+	req, err := c.NewRequest(ctx, endpoint, params, image)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// isJSON reports whether the content type is the JSON one.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType) == "application/json"
+}
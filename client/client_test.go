@@ -0,0 +1,189 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"http://localhost:9000", "https://images.example.com/imaginary"} {
+		if _, err := New(baseURL); err != nil {
+			t.Errorf("Unexpected error for %s: %v", baseURL, err)
+		}
+	}
+	for _, baseURL := range []string{"", "localhost:9000", "ftp://localhost", "http://"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("Expected an error for %q", baseURL)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Test vector shared with the server tests, so both never drift
+	query, _ := url.ParseQuery("file=image.jpg&height=200&type=jpeg&width=300&sign=ignored")
+	sign := Sign("/resize", query, "4f46feebafc4b5e988f131c4ff8b5997")
+	if sign != "ruEWRoFO-ic-L38vTsjqIYE6DLZ532CTaZXOh1gwuVo" {
+		t.Errorf("Invalid signature: %s", sign)
+	}
+}
+
+func TestURL(t *testing.T) {
+	c, _ := New("https://images.example.com/imaginary/")
+
+	u, err := c.URL(EndpointResize, Params{ParamWidth: 300, ParamType: "webp", ParamURL: "https://example.com/a.jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "https://images.example.com/imaginary/resize?type=webp&url=https%3A%2F%2Fexample.com%2Fa.jpg&width=300"
+	if u.String() != expected {
+		t.Errorf("Invalid URL: %s", u)
+	}
+
+	c.SignatureKey = "4f46feebafc4b5e988f131c4ff8b5997"
+	u, _ = c.URL(EndpointResize, Params{ParamWidth: 300})
+	query := u.Query()
+	if sign := query.Get("sign"); sign == "" || sign != Sign(u.Path, query, c.SignatureKey) {
+		t.Errorf("Invalid signed URL: %s", u)
+	}
+
+	if _, err := c.URL(EndpointResize, Params{ParamWidth: "300"}); err == nil {
+		t.Error("Expected a param of invalid type to fail")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("API-Key") != "secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"Invalid or missing API key","status":401}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "image/webp")
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.URL.RawQuery + " " + string(body)))
+	}))
+	defer ts.Close()
+
+	c, _ := New(ts.URL)
+	_, err := c.Process(context.Background(), EndpointResize, Params{ParamWidth: 300}, strings.NewReader("image"))
+	var xerr *Error
+	if !errors.As(err, &xerr) || xerr.Status != http.StatusUnauthorized || xerr.Message != "Invalid or missing API key" {
+		t.Errorf("Expected the API error to be parsed: %v", err)
+	}
+
+	c.APIKey = "secret"
+	image, err := c.Process(context.Background(), EndpointResize, Params{ParamWidth: 300}, strings.NewReader("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(image.Body) != "POST /resize width=300 image" || image.Type != "image/webp" {
+		t.Errorf("Invalid upload reply: %s %q", image.Type, image.Body)
+	}
+
+	image, err = c.Process(context.Background(), EndpointResize, Params{ParamURL: "https://example.com/a.jpg"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(image.Body) != "GET /resize url=https%3A%2F%2Fexample.com%2Fa.jpg " {
+		t.Errorf("Invalid remote image reply: %q", image.Body)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	var operations []Operation
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operations = nil
+		_ = json.Unmarshal([]byte(r.URL.Query().Get(ParamOperations)), &operations)
+		w.Header().Set("Content-Type", "image/jpeg")
+	}))
+	defer ts.Close()
+
+	c, _ := New(ts.URL)
+	pipeline := []Operation{
+		{Name: "resize", Params: Params{ParamWidth: 300}},
+		{Name: "convert", Params: Params{ParamType: "webp"}, If: "width>200", IgnoreFailure: true},
+	}
+	if _, err := c.Pipeline(context.Background(), pipeline, Params{ParamFile: "a.jpg"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 2 || operations[1].Name != "convert" || operations[1].If != "width>200" ||
+		!operations[1].IgnoreFailure || operations[0].Params[ParamWidth] != 300.0 {
+		t.Errorf("Invalid pipeline operations: %+v", operations)
+	}
+
+	invalid := [][]Operation{
+		nil,
+		make([]Operation, MaxPipelineOperations+1),
+		{{Name: "unknown"}},
+		{{Name: "resize", Params: Params{ParamWidth: 1.5}}},
+	}
+	for _, operations := range invalid {
+		if _, err := c.Pipeline(context.Background(), operations, nil, nil); err == nil {
+			t.Errorf("Expected the pipeline to be rejected: %+v", operations)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		status      int
+		header      http.Header
+		body        string
+		expected    Error
+		description string
+	}{
+		{http.StatusBadRequest, http.Header{"Content-Type": {"application/json"}},
+			`{"message":"Invalid param: width","status":400,"param":{"step":1,"name":"width","expected":"int"}}`,
+			Error{"Invalid param: width", 400, &ParamError{1, "width", "int"}}, "pipeline param error"},
+		{http.StatusBadRequest, http.Header{"Content-Type": {"application/json"}}, `{"error":"strconv.Atoi", "status": 400}`,
+			Error{"strconv.Atoi", 400, nil}, "error field"},
+		{http.StatusBadGateway, http.Header{"Content-Type": {"text/html"}}, "<html>",
+			Error{"Bad Gateway", 502, nil}, "non JSON error"},
+		{http.StatusOK, http.Header{"Content-Type": {"image/jpeg"}, "Error": {`{"message":"Not found","status":404}`}}, "",
+			Error{"Not found", 404, nil}, "placeholder"},
+	}
+
+	for _, c := range cases {
+		res := &http.Response{StatusCode: c.status, Header: c.header, Body: io.NopCloser(strings.NewReader(c.body))}
+		var xerr *Error
+		if !errors.As(parseError(res), &xerr) {
+			t.Errorf("Expected an error for the %s", c.description)
+			continue
+		}
+		if xerr.Message != c.expected.Message || xerr.Status != c.expected.Status ||
+			(xerr.Param == nil) != (c.expected.Param == nil) || (xerr.Param != nil && *xerr.Param != *c.expected.Param) {
+			t.Errorf("Invalid %s: %+v", c.description, xerr)
+		}
+	}
+
+	res := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}
+	if err := parseError(res); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
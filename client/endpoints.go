@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+// Endpoints processing an image, uploaded or given by the url or file param.
+const (
+	EndpointAdjust         = "/adjust"
+	EndpointAutoRotate     = "/autorotate"
+	EndpointBlur           = "/blur"
+	EndpointBorder         = "/border"
+	EndpointCircle         = "/circle"
+	EndpointComposite      = "/composite"
+	EndpointConvert        = "/convert"
+	EndpointCrop           = "/crop"
+	EndpointDuotone        = "/duotone"
+	EndpointEnlarge        = "/enlarge"
+	EndpointExtract        = "/extract"
+	EndpointFit            = "/fit"
+	EndpointFlip           = "/flip"
+	EndpointFlop           = "/flop"
+	EndpointGamma          = "/gamma"
+	EndpointGrayscale      = "/grayscale"
+	EndpointHistogram      = "/histogram"
+	EndpointInfo           = "/info"
+	EndpointInvert         = "/invert"
+	EndpointModulate       = "/modulate"
+	EndpointPad            = "/pad"
+	EndpointPhash          = "/phash"
+	EndpointPipeline       = "/pipeline"
+	EndpointPixelate       = "/pixelate"
+	EndpointPolaroid       = "/polaroid"
+	EndpointPreprocess     = "/preprocess"
+	EndpointRedact         = "/redact"
+	EndpointResize         = "/resize"
+	EndpointRotate         = "/rotate"
+	EndpointRounded        = "/rounded"
+	EndpointSepia          = "/sepia"
+	EndpointSharpen        = "/sharpen"
+	EndpointSmartCrop      = "/smartcrop"
+	EndpointThumbnail      = "/thumbnail"
+	EndpointToPDF          = "/topdf"
+	EndpointToTIFF         = "/totiff"
+	EndpointTrim           = "/trim"
+	EndpointVariants       = "/variants"
+	EndpointVignette       = "/vignette"
+	EndpointWatermark      = "/watermark"
+	EndpointWatermarkImage = "/watermarkimage"
+	EndpointZoom           = "/zoom"
+)
+
+// Endpoints producing images from scratch or from several ones.
+const (
+	EndpointAnimate    = "/animate"
+	EndpointAvatar     = "/avatar"
+	EndpointBatch      = "/batch"
+	EndpointCollage    = "/collage"
+	EndpointGenerate   = "/generate"
+	EndpointStoryboard = "/storyboard"
+)
+
+// Service endpoints, replying JSON documents.
+const (
+	EndpointHealth       = "/health"
+	EndpointReady        = "/ready"
+	EndpointFonts        = "/fonts"
+	EndpointCapabilities = "/capabilities"
+)
@@ -0,0 +1,88 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxErrorSize is the maximum size of the error replies read.
+const maxErrorSize = 64 * 1024
+
+// Error is an error replied by the server.
+type Error struct {
+	Message string `json:"message"`
+	// Status is the HTTP status code of the reply.
+	Status int `json:"status"`
+	// Param details the invalid param of a pipeline operation, if any.
+	Param *ParamError `json:"param,omitempty"`
+}
+
+// ParamError details an invalid pipeline operation param.
+type ParamError struct {
+	Step     int    `json:"step"`
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+}
+
+func (e *Error) Error() string {
+	if e.Param != nil {
+		return fmt.Sprintf("imaginary: %s (status %d, step %d, param %s, %s expected)", e.Message, e.Status,
+			e.Param.Step, e.Param.Name, e.Param.Expected)
+	}
+	return fmt.Sprintf("imaginary: %s (status %d)", e.Message, e.Status)
+}
+
+// parseError returns the error of the reply, if any. Placeholder images
+// replied on errors, see the -enable-placeholder flag, are errors too, the
+// error being given by their Error header.
+func parseError(res *http.Response) error {
+	if header := res.Header.Get("Error"); header != "" {
+		xerr := &Error{Status: res.StatusCode}
+		if json.Unmarshal([]byte(header), xerr) == nil {
+			return xerr
+		}
+	}
+	if res.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	xerr := &Error{Status: res.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+	if isJSON(res.Header.Get("Content-Type")) {
+		// Some errors are replied with an error field instead of message
+		var reply struct {
+			Error
+			Alternative string `json:"error"`
+		}
+		if json.Unmarshal(body, &reply) == nil {
+			xerr.Message, xerr.Param = reply.Message, reply.Param
+			if xerr.Message == "" {
+				xerr.Message = reply.Alternative
+			}
+		}
+	}
+	if xerr.Message == "" {
+		xerr.Message = http.StatusText(res.StatusCode)
+	}
+	return xerr
+}
@@ -0,0 +1,258 @@
+// Code generated by go test -run TestClientGenerated -update-client; DO NOT EDIT.
+
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+// Params of the endpoints.
+const (
+	ParamAnalyze      = "analyze"
+	ParamAngle        = "angle"
+	ParamAr           = "ar"
+	ParamAreaheight   = "areaheight"
+	ParamAreawidth    = "areawidth"
+	ParamAspectratio  = "aspectratio"
+	ParamAsync        = "async"
+	ParamBackground   = "background"
+	ParamBitdepth     = "bitdepth"
+	ParamBlend        = "blend"
+	ParamBlocksize    = "blocksize"
+	ParamBorder       = "border"
+	ParamBrightness   = "brightness"
+	ParamCallback     = "callback"
+	ParamChannelorder = "channelorder"
+	ParamChroma       = "chroma"
+	ParamColor        = "color"
+	ParamColors       = "colors"
+	ParamColorspace   = "colorspace"
+	ParamColumns      = "columns"
+	ParamCompression  = "compression"
+	ParamContrast     = "contrast"
+	ParamCrop         = "crop"
+	ParamCx           = "cx"
+	ParamCy           = "cy"
+	ParamDelay        = "delay"
+	ParamDither       = "dither"
+	ParamDpi          = "dpi"
+	ParamDpr          = "dpr"
+	ParamEffort       = "effort"
+	ParamEmbed        = "embed"
+	ParamEnhance      = "enhance"
+	ParamExtend       = "extend"
+	ParamFactor       = "factor"
+	ParamFields       = "fields"
+	ParamFlat         = "flat"
+	ParamFlip         = "flip"
+	ParamFlop         = "flop"
+	ParamFont         = "font"
+	ParamForce        = "force"
+	ParamFx           = "fx"
+	ParamFy           = "fy"
+	ParamGamma        = "gamma"
+	ParamGravity      = "gravity"
+	ParamHeight       = "height"
+	ParamHighlight    = "highlight"
+	ParamHue          = "hue"
+	ParamImage        = "image"
+	ParamInterlace    = "interlace"
+	ParamInterpolator = "interpolator"
+	ParamInterval     = "interval"
+	ParamJagged       = "jagged"
+	ParamKernel       = "kernel"
+	ParamLayers       = "layers"
+	ParamLayout       = "layout"
+	ParamLeft         = "left"
+	ParamLoop         = "loop"
+	ParamLossless     = "lossless"
+	ParamManifest     = "manifest"
+	ParamMargin       = "margin"
+	ParamMaxbytes     = "maxbytes"
+	ParamMean         = "mean"
+	ParamMetadata     = "metadata"
+	ParamMinampl      = "minampl"
+	ParamMode         = "mode"
+	ParamName         = "name"
+	ParamNocrop       = "nocrop"
+	ParamNoprofile    = "noprofile"
+	ParamNoreplicate  = "noreplicate"
+	ParamNorotation   = "norotation"
+	ParamOpacity      = "opacity"
+	ParamOperations   = "operations"
+	ParamPage         = "page"
+	ParamPages        = "pages"
+	ParamPagesize     = "pagesize"
+	ParamPalette      = "palette"
+	ParamPattern      = "pattern"
+	ParamPayload      = "payload"
+	ParamPredictor    = "predictor"
+	ParamQuality      = "quality"
+	ParamRadius       = "radius"
+	ParamRegions      = "regions"
+	ParamRotate       = "rotate"
+	ParamSaturation   = "saturation"
+	ParamScale        = "scale"
+	ParamShadow       = "shadow"
+	ParamSigma        = "sigma"
+	ParamSpeed        = "speed"
+	ParamSprite       = "sprite"
+	ParamStd          = "std"
+	ParamStore        = "store"
+	ParamStrength     = "strength"
+	ParamStripmeta    = "stripmeta"
+	ParamSubsample    = "subsample"
+	ParamText         = "text"
+	ParamTextwidth    = "textwidth"
+	ParamThreshold    = "threshold"
+	ParamTiffcodec    = "tiffcodec"
+	ParamTop          = "top"
+	ParamType         = "type"
+	ParamUrls         = "urls"
+	ParamWidth        = "width"
+	ParamWidths       = "widths"
+)
+
+// paramTypes are the types of the params: bool, float, int, json or string.
+var paramTypes = map[string]string{
+	"analyze":      "bool",
+	"angle":        "int",
+	"ar":           "string",
+	"areaheight":   "int",
+	"areawidth":    "int",
+	"aspectratio":  "string",
+	"async":        "bool",
+	"background":   "string",
+	"bitdepth":     "int",
+	"blend":        "string",
+	"blocksize":    "int",
+	"border":       "int",
+	"brightness":   "float",
+	"callback":     "string",
+	"channelorder": "string",
+	"chroma":       "string",
+	"color":        "string",
+	"colors":       "int",
+	"colorspace":   "string",
+	"columns":      "int",
+	"compression":  "int",
+	"contrast":     "float",
+	"crop":         "bool",
+	"cx":           "int",
+	"cy":           "int",
+	"delay":        "string",
+	"dither":       "float",
+	"dpi":          "int",
+	"dpr":          "float",
+	"effort":       "int",
+	"embed":        "bool",
+	"enhance":      "string",
+	"extend":       "string",
+	"factor":       "int",
+	"fields":       "string",
+	"flat":         "float",
+	"flip":         "bool",
+	"flop":         "bool",
+	"font":         "string",
+	"force":        "bool",
+	"fx":           "float",
+	"fy":           "float",
+	"gamma":        "float",
+	"gravity":      "string",
+	"height":       "int",
+	"highlight":    "string",
+	"hue":          "float",
+	"image":        "string",
+	"interlace":    "bool",
+	"interpolator": "string",
+	"interval":     "float",
+	"jagged":       "float",
+	"kernel":       "string",
+	"layers":       "json",
+	"layout":       "string",
+	"left":         "int",
+	"loop":         "int",
+	"lossless":     "bool",
+	"manifest":     "bool",
+	"margin":       "int",
+	"maxbytes":     "int",
+	"mean":         "string",
+	"metadata":     "string",
+	"minampl":      "float",
+	"mode":         "string",
+	"name":         "string",
+	"nocrop":       "bool",
+	"noprofile":    "bool",
+	"noreplicate":  "bool",
+	"norotation":   "bool",
+	"opacity":      "float",
+	"operations":   "json",
+	"page":         "int",
+	"pages":        "int",
+	"pagesize":     "string",
+	"palette":      "bool",
+	"pattern":      "string",
+	"payload":      "string",
+	"predictor":    "string",
+	"quality":      "int",
+	"radius":       "int",
+	"regions":      "string",
+	"rotate":       "int",
+	"saturation":   "float",
+	"scale":        "float",
+	"shadow":       "string",
+	"sigma":        "float",
+	"speed":        "int",
+	"sprite":       "string",
+	"std":          "string",
+	"store":        "string",
+	"strength":     "float",
+	"stripmeta":    "bool",
+	"subsample":    "string",
+	"text":         "string",
+	"textwidth":    "int",
+	"threshold":    "float",
+	"tiffcodec":    "string",
+	"top":          "int",
+	"type":         "string",
+	"urls":         "json",
+	"width":        "int",
+	"widths":       "string",
+}
+
+// pipelineOperations are the operations a pipeline can run.
+var pipelineOperations = map[string]bool{
+	"adjust":         true,
+	"autorotate":     true,
+	"blur":           true,
+	"border":         true,
+	"circle":         true,
+	"composite":      true,
+	"convert":        true,
+	"crop":           true,
+	"duotone":        true,
+	"enlarge":        true,
+	"extract":        true,
+	"fit":            true,
+	"flip":           true,
+	"flop":           true,
+	"gamma":          true,
+	"grayscale":      true,
+	"invert":         true,
+	"modulate":       true,
+	"pad":            true,
+	"pixelate":       true,
+	"polaroid":       true,
+	"preprocess":     true,
+	"redact":         true,
+	"resize":         true,
+	"rotate":         true,
+	"rounded":        true,
+	"sepia":          true,
+	"sharpen":        true,
+	"smartcrop":      true,
+	"thumbnail":      true,
+	"trim":           true,
+	"vignette":       true,
+	"watermark":      true,
+	"watermarkImage": true,
+	"zoom":           true,
+}
@@ -0,0 +1,4 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+module github.com/sycured/imaginary/client
+
+go 1.24.2
@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// Params not bound to an operation, hence missing from the generated ones.
+const (
+	// ParamURL is the URL of the remote image, see the -enable-url-source flag.
+	ParamURL = "url"
+	// ParamFile is the path of the local image, see the -mount flag.
+	ParamFile = "file"
+	// ParamPreset is the name of the preset, see the -presets-file flag.
+	ParamPreset = "preset"
+	// ParamPreload lists the widths of the variants hinted, see the
+	// -enable-early-hints flag.
+	ParamPreload = "preload"
+)
+
+// Params are the params of a request, keyed by name, e.g. ParamWidth. The
+// values of the params known by the server are checked against their type:
+// an integer for the int params, a number for the float ones, a bool for the
+// bool ones, and any value marshaled as JSON, unless already a string, for
+// the json ones. Other params, such as url or file, are given as strings.
+type Params map[string]any
+
+// Values encodes the params as query values.
+func (p Params) Values() (url.Values, error) {
+	values := make(url.Values, len(p))
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		value, err := formatParam(name, p[name])
+		if err != nil {
+			return nil, err
+		}
+		values.Set(name, value)
+	}
+	return values, nil
+}
+
+// check checks the types of the params, as sent in the JSON body of the
+// pipeline operations.
+func (p Params) check() error {
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		if _, err := formatParam(name, p[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatParam formats the param value as expected by the server.
+func formatParam(name string, value any) (string, error) {
+	expected, known := paramTypes[name]
+	if !known {
+		expected = "string"
+	}
+
+	switch expected {
+	case "int":
+		if v, ok := toInt(value); ok {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case "float":
+		if v, ok := toFloat(value); ok {
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	case "bool":
+		if v, ok := value.(bool); ok {
+			return strconv.FormatBool(v), nil
+		}
+	case "json":
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+		buf, err := json.Marshal(value)
+		if err == nil {
+			return string(buf), nil
+		}
+	default:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("imaginary: invalid param %s: %T given, %s expected", name, value, expected)
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	if v, ok := toInt(value); ok {
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParamsValues(t *testing.T) {
+	params := Params{
+		ParamWidth:      int64(300),
+		ParamOpacity:    0.5,
+		ParamSigma:      2,
+		ParamForce:      true,
+		ParamType:       "webp",
+		ParamOperations: []map[string]any{{"operation": "resize"}},
+		ParamLayers:     `[{"image":"a.png"}]`,
+		ParamURL:        "https://example.com/a.jpg",
+	}
+
+	values, err := params.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"width":      {"300"},
+		"opacity":    {"0.5"},
+		"sigma":      {"2"},
+		"force":      {"true"},
+		"type":       {"webp"},
+		"operations": {`[{"operation":"resize"}]`},
+		"layers":     {`[{"image":"a.png"}]`},
+		"url":        {"https://example.com/a.jpg"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Invalid values: %v", values)
+	}
+
+	invalid := []Params{
+		{ParamWidth: 300.5},
+		{ParamWidth: "300"},
+		{ParamOpacity: "0.5"},
+		{ParamForce: "true"},
+		{ParamType: 1},
+		{ParamOperations: func() {}},
+		{ParamURL: 1},
+	}
+	for _, params := range invalid {
+		if _, err := params.Values(); err == nil {
+			t.Errorf("Expected an error for %v", params)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+)
+
+// MaxPipelineOperations is the maximum number of operations of a pipeline.
+const MaxPipelineOperations = 10
+
+// Operation is a step of a pipeline.
+type Operation struct {
+	// Name is the operation, i.e. the name of its endpoint, e.g. resize.
+	Name   string `json:"operation"`
+	Params Params `json:"params,omitempty"`
+	// If is the condition the operation is applied on, e.g. width>2000.
+	If string `json:"if,omitempty"`
+	// IgnoreFailure continues the pipeline when the operation fails.
+	IgnoreFailure bool `json:"ignore_failure,omitempty"`
+}
+
+// Pipeline runs the operations in a single request, on the image or on the
+// image source given by the url or file param when the image is nil.
+func (c *Client) Pipeline(ctx context.Context, operations []Operation, params Params, image io.Reader) (*Image, error) {
+	if len(operations) == 0 || len(operations) > MaxPipelineOperations {
+		return nil, fmt.Errorf("imaginary: a pipeline runs from 1 to %d operations", MaxPipelineOperations)
+	}
+	for i, operation := range operations {
+		if !pipelineOperations[operation.Name] {
+			return nil, fmt.Errorf("imaginary: unsupported operation %q in pipeline step %d", operation.Name, i)
+		}
+		if err := operation.Params.check(); err != nil {
+			return nil, fmt.Errorf("%w in pipeline step %d", err, i)
+		}
+	}
+
+	encoded, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+	pipelineParams := maps.Clone(params)
+	if pipelineParams == nil {
+		pipelineParams = Params{}
+	}
+	pipelineParams[ParamOperations] = string(encoded)
+
+	return c.Process(ctx, EndpointPipeline, pipelineParams, image)
+}
@@ -0,0 +1,43 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+)
+
+// Sign returns the URL signature of the request path, including the server
+// -path-prefix, and params, the sign one excluded, as verified by the server
+// when the -enable-url-signature flag is set.
+func Sign(path string, query url.Values, key string) string {
+	unsigned := url.Values{}
+	for name, values := range query {
+		if name != "sign" {
+			unsigned[name] = values
+		}
+	}
+
+	h := hmac.New(sha256.New, []byte(key))
+	_, _ = h.Write([]byte(path))
+	_, _ = h.Write([]byte(unsigned.Encode()))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,238 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var updateClient = flag.Bool("update-client", false, "Regenerate the code of the Go client package")
+
+// clientGenerated is the file of the Go client package generated from the
+// server params and pipeline operations.
+const clientGenerated = "client/generated.go"
+
+// clientIgnoredRoutes are the routes the Go client package has no endpoint
+// constant for.
+var clientIgnoredRoutes = []string{"/", "/form", "/metrics", "/log-level", "/jobs/{id}", "/jobs/{id}/result"}
+
+// clientSource renders the code of the Go client package generated from the
+// server params and pipeline operations, so the client never drifts.
+func clientSource() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go test -run TestClientGenerated -update-client; DO NOT EDIT.\n\n")
+	b.WriteString("// SPDX-License-Identifier: AGPL-3.0-only\n\npackage client\n\n")
+
+	params := slices.Sorted(maps.Keys(paramTypes))
+	b.WriteString("// Params of the endpoints.\nconst (\n")
+	for _, name := range params {
+		fmt.Fprintf(&b, "\tParam%s = %q\n", strings.ToUpper(name[:1])+name[1:], name)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// paramTypes are the types of the params: bool, float, int, json or string.\n")
+	b.WriteString("var paramTypes = map[string]string{\n")
+	for _, name := range params {
+		fmt.Fprintf(&b, "\t%q: %q,\n", name, paramTypes[name])
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// pipelineOperations are the operations a pipeline can run.\n")
+	b.WriteString("var pipelineOperations = map[string]bool{\n")
+	for _, name := range slices.Sorted(maps.Keys(OperationsMap)) {
+		fmt.Fprintf(&b, "\t%q: true,\n", name)
+	}
+	b.WriteString("}\n")
+
+	return format.Source(b.Bytes())
+}
+
+// TestClientGenerated checks the generated code of the Go client package is
+// up to date. Run it with -update-client to regenerate it after a change of
+// the params or pipeline operations.
+func TestClientGenerated(t *testing.T) {
+	source, err := clientSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updateClient {
+		if err := os.WriteFile(clientGenerated, source, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	current, err := os.ReadFile(clientGenerated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, source) {
+		t.Errorf("The Go client package drifted from the server, run the tests with -update-client to regenerate %s",
+			clientGenerated)
+	}
+}
+
+// TestClientEndpoints checks the endpoint constants of the Go client package
+// match the routes of the server.
+func TestClientEndpoints(t *testing.T) {
+	routes := stringLiterals(t, "server.go", func(call *ast.CallExpr) bool {
+		ident, ok := call.Fun.(*ast.Ident)
+		return ok && ident.Name == "join"
+	})
+	routes = slices.DeleteFunc(routes, func(route string) bool { return slices.Contains(clientIgnoredRoutes, route) })
+
+	endpoints := stringLiterals(t, "client/endpoints.go", nil)
+
+	if !slices.Equal(routes, endpoints) {
+		t.Errorf("The Go client endpoints drifted from the server routes:\n%v\n%v", endpoints, routes)
+	}
+}
+
+// stringLiterals returns the sorted string literals of the Go file, only
+// those given as argument of the calls matched when a matcher is given.
+func stringLiterals(t *testing.T, file string, matches func(*ast.CallExpr) bool) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var literals []string
+	add := func(node ast.Node) {
+		if lit, ok := node.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			value, _ := strconv.Unquote(lit.Value)
+			literals = append(literals, value)
+		}
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		if matches == nil {
+			add(node)
+		} else if call, ok := node.(*ast.CallExpr); ok && matches(call) {
+			for _, arg := range call.Args {
+				add(arg)
+			}
+		}
+		return true
+	})
+
+	slices.Sort(literals)
+	return slices.Compact(literals)
+}
+
+// TestClientSignature checks the URL signature of the server against the
+// test vector of the Go client package.
+func TestClientSignature(t *testing.T) {
+	query, _ := url.ParseQuery("file=image.jpg&height=200&type=jpeg&width=300")
+	sign := urlSignature("/resize", query, "4f46feebafc4b5e988f131c4ff8b5997")
+
+	if encoded := base64.RawURLEncoding.EncodeToString(sign); encoded != "ruEWRoFO-ic-L38vTsjqIYE6DLZ532CTaZXOh1gwuVo" {
+		t.Errorf("The URL signature drifted from the one of the Go client package: %s", encoded)
+	}
+}
+
+// TestClientError checks the error replies of the server against the test
+// vectors of the Go client package, and the JSON fields of its Error and
+// ParamError types against the server ones, so they round-trip.
+func TestClientError(t *testing.T) {
+	xerr := NewError("Invalid param: width", http.StatusBadRequest)
+	xerr.Param = &ParamError{Step: 1, Name: "width", Expected: "int"}
+	expected := `{"message":"Invalid param: width","status":400,"param":{"step":1,"name":"width","expected":"int"}}`
+	if body := string(xerr.JSON()); body != expected {
+		t.Errorf("The error reply drifted from the one of the Go client package: %s", body)
+	}
+
+	w := httptest.NewRecorder()
+	sendErrorResponse(w, http.StatusBadRequest, xerr)
+	var reply struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil || reply.Error != xerr.Message || reply.Status != 400 {
+		t.Errorf("The error field reply drifted from the one of the Go client package: %s", w.Body)
+	}
+
+	for name, server := range map[string]interface{}{"Error": Error{}, "ParamError": ParamError{}} {
+		if client := jsonFields(t, "client/error.go", name); !slices.Equal(client, serverJSONFields(server)) {
+			t.Errorf("The JSON fields of the Go client %s drifted from the server ones: %v", name, client)
+		}
+	}
+}
+
+// serverJSONFields returns the sorted JSON field names of the struct.
+func serverJSONFields(value interface{}) []string {
+	var fields []string
+	typ := reflect.TypeOf(value)
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// jsonFields returns the sorted JSON field names of the named struct type of
+// the Go file.
+func jsonFields(t *testing.T, file, name string) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields []string
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != name {
+			return true
+		}
+		if st, ok := spec.Type.(*ast.StructType); ok {
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag, _ := strconv.Unquote(field.Tag.Value)
+				name, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+				fields = append(fields, name)
+			}
+		}
+		return false
+	})
+
+	slices.Sort(fields)
+	return fields
+}
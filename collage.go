@@ -0,0 +1,201 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxCollageImages is the maximum number of images of a collage.
+const MaxCollageImages = 9
+
+// MaxURLs is the maximum number of URLs of the urls param, every operation
+// further limiting them.
+const MaxURLs = 100
+
+// Layouts supported by Collage.
+const (
+	LayoutGrid = "grid"
+	LayoutHero = "hero"
+)
+
+var defaultCollageBackground = [4]uint8{255, 255, 255, 255}
+
+// @Summary Collage
+// @Description Composes remote images into a single image, laid out as a grid or as a hero image with thumbnails
+// @Produce image/*
+// @Param urls query string true "JSON list of the image URLs"
+// @Param width query int true "Width of the output image"
+// @Param height query int true "Height of the output image"
+// @Param layout query string false "Layout (grid, hero)"
+// @Param margin query int false "Space between and around the images in pixels (default 0)"
+// @Param background query string false "RGB background color (default 255,255,255)"
+// @Param gravity query string false "Gravity of the images cropped to their cell"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Collage image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /collage [get]
+func Collage(_ []byte, o ImageOptions) (Image, error) {
+	if len(o.URLs) == 0 {
+		return Image{}, NewError("Missing required param: urls", http.StatusBadRequest)
+	}
+	if err := checkURLs(o.URLs, MaxCollageImages); err != nil {
+		return Image{}, err
+	}
+	if o.Width == 0 || o.Height == 0 {
+		return Image{}, NewError("Missing required params: height and width", http.StatusBadRequest)
+	}
+	if o.Width < 0 || o.Height < 0 {
+		return Image{}, ErrNegativeDimensions
+	}
+
+	cells, err := collageCells(o.Layout, len(o.URLs), image.Rect(0, 0, o.Width, o.Height), o.Margin)
+	if err != nil {
+		return Image{}, NewError(err.Error(), http.StatusBadRequest)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, o.Width, o.Height))
+	fillRect(img, img.Rect, opaqueColor(o.Background, defaultCollageBackground))
+	for i, url := range o.URLs {
+		buf, err := loadLayer(CompositeLayer{URL: url})
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Unable to load image %d: %s", i, err), http.StatusBadRequest)
+		}
+		if err := validateLayerSize(buf); err != nil {
+			return Image{}, err
+		}
+		tile, err := collageTile(buf, cells[i], o.Gravity)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot process image %d: %s", i, err), http.StatusBadRequest)
+		}
+		compositeLayer(img, tile, cells[i].Min.X, cells[i].Min.Y, 1, blendModes[BlendNormal])
+	}
+
+	return encodePixels(img, outputType(nil, o), o)
+}
+
+// parseURLs parses a JSON list of image URLs, validating them.
+func parseURLs(data string) ([]string, error) {
+	var urls []string
+	if err := json.NewDecoder(strings.NewReader(data)).Decode(&urls); err != nil {
+		return nil, err
+	}
+
+	if len(urls) > MaxURLs {
+		return nil, fmt.Errorf("too many images, the maximum allowed is %d", MaxURLs)
+	}
+	for i, url := range urls {
+		if url == "" {
+			return nil, fmt.Errorf("image %d has an empty url", i)
+		}
+	}
+	return urls, nil
+}
+
+// checkURLs checks the number of URLs of the urls param against the limit of
+// the operation.
+func checkURLs(urls []string, limit int) error {
+	if len(urls) > limit {
+		return NewError(fmt.Sprintf("Invalid param: urls allows up to %d images", limit), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// collageTile crops the image to cover its cell.
+func collageTile(buf []byte, cell image.Rectangle, gravity bimg.Gravity) (*image.NRGBA, error) {
+	tile, err := bimg.Resize(buf, bimg.Options{
+		Width:   cell.Dx(),
+		Height:  cell.Dy(),
+		Crop:    true,
+		Gravity: libvipsGravity(gravity),
+		Type:    bimg.PNG,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pixels, _, err := decodePixels(tile)
+	return pixels, err
+}
+
+// collageCells lays out the given number of images within the bounds,
+// separated and surrounded by the margin.
+func collageCells(layout string, count int, bounds image.Rectangle, margin int) ([]image.Rectangle, error) {
+	if margin < 0 {
+		return nil, errors.New("margin must be positive")
+	}
+
+	var cells []image.Rectangle
+	switch layout {
+	case "", LayoutGrid:
+		columns := int(math.Ceil(math.Sqrt(float64(count))))
+		rows := (count + columns - 1) / columns
+		for row := 0; row < rows; row++ {
+			// The last row may be shorter, its images sharing the whole width
+			rowColumns := min(columns, count-row*columns)
+			for column := 0; column < rowColumns; column++ {
+				cells = append(cells, collageCell(bounds, margin, column, rowColumns, row, rows))
+			}
+		}
+	case LayoutHero:
+		if count == 1 {
+			cells = append(cells, collageCell(bounds, margin, 0, 1, 0, 1))
+			break
+		}
+		// The hero image spans two thirds of the width, thumbnails are
+		// stacked in the remaining column
+		hero := collageCell(bounds, margin, 0, 3, 0, 1)
+		thumbs := collageCell(bounds, margin, 2, 3, 0, 1)
+		hero.Max.X = thumbs.Min.X - margin
+		cells = append(cells, hero)
+		for row := 0; row < count-1; row++ {
+			cells = append(cells, collageCell(bounds, margin, 2, 3, row, count-1))
+		}
+	default:
+		return nil, errors.New("unsupported layout. Allowed values are: grid, hero")
+	}
+
+	for _, cell := range cells {
+		if cell.Dx() <= 0 || cell.Dy() <= 0 {
+			return nil, errors.New("the images do not fit, try a smaller margin")
+		}
+	}
+	return cells, nil
+}
+
+// collageCell returns the cell at the given column and row of an evenly
+// divided grid, empty when the margin leaves no room for it.
+func collageCell(bounds image.Rectangle, margin, column, columns, row, rows int) image.Rectangle {
+	width, height := bounds.Dx()-margin, bounds.Dy()-margin
+	// Not using image.Rect, which would swap the coordinates of empty cells
+	return image.Rectangle{
+		Min: image.Pt(bounds.Min.X+margin+column*width/columns, bounds.Min.Y+margin+row*height/rows),
+		Max: image.Pt(bounds.Min.X+(column+1)*width/columns, bounds.Min.Y+(row+1)*height/rows),
+	}
+}
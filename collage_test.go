@@ -0,0 +1,147 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCollageCells(t *testing.T) {
+	bounds := image.Rect(0, 0, 1200, 630)
+
+	grid, err := collageCells(LayoutGrid, 3, bounds, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two images on the first row, the last one sharing the whole width
+	expected := []image.Rectangle{
+		image.Rect(10, 10, 595, 310),
+		image.Rect(605, 10, 1190, 310),
+		image.Rect(10, 320, 1190, 620),
+	}
+	for i, cell := range grid {
+		if cell != expected[i] {
+			t.Errorf("Invalid grid cell %d: %v", i, cell)
+		}
+	}
+
+	hero, err := collageCells(LayoutHero, 3, bounds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []image.Rectangle{
+		image.Rect(0, 0, 800, 630),
+		image.Rect(800, 0, 1200, 315),
+		image.Rect(800, 315, 1200, 630),
+	}
+	for i, cell := range hero {
+		if cell != expected[i] {
+			t.Errorf("Invalid hero cell %d: %v", i, cell)
+		}
+	}
+
+	if single, _ := collageCells(LayoutHero, 1, bounds, 0); len(single) != 1 || single[0] != bounds {
+		t.Errorf("Expected a single hero image to fill the collage, got %v", single)
+	}
+	if _, err := collageCells(LayoutGrid, 4, image.Rect(0, 0, 20, 20), 10); err == nil {
+		t.Error("Expected an error when the margin leaves no room for the images")
+	}
+	if _, err := collageCells("mosaic", 4, bounds, 0); err == nil {
+		t.Error("Expected an error with an unsupported layout")
+	}
+}
+
+func TestParseURLs(t *testing.T) {
+	urls, err := parseURLs(`["http://localhost/a.jpg","http://localhost/b.jpg"]`)
+	if err != nil || len(urls) != 2 {
+		t.Fatalf("Unexpected result: %v, %v", urls, err)
+	}
+
+	invalid := []string{
+		`"http://localhost/a.jpg"`,
+		`["http://localhost/a.jpg",""]`,
+		`["` + strings.Repeat(`1","`, MaxURLs) + `1"]`,
+	}
+	for _, data := range invalid {
+		if _, err := parseURLs(data); err == nil {
+			t.Errorf("Expected an error with %s", data)
+		}
+	}
+}
+
+func TestCheckURLs(t *testing.T) {
+	if err := checkURLs(make([]string, MaxCollageImages), MaxCollageImages); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkURLs(make([]string, MaxCollageImages+1), MaxCollageImages); err == nil {
+		t.Error("Expected an error with too many urls")
+	}
+}
+
+func TestCollage(t *testing.T) {
+	t.Cleanup(func() { LoadSources(ServerOptions{}) })
+	LoadSources(ServerOptions{EnableURLSource: true})
+
+	tsImage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		buf, _ := os.ReadFile("testdata/imaginary.jpg")
+		_, _ = w.Write(buf)
+	}))
+	defer tsImage.Close()
+
+	opts := ImageOptions{URLs: []string{tsImage.URL, tsImage.URL}, Width: 300, Height: 100, Margin: 10, Type: PNG}
+	img, err := Collage(nil, opts)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImagePNG {
+		t.Error(InvalidMimeType)
+	}
+	if err := assertSize(img.Body, 300, 100); err != nil {
+		t.Error(err)
+	}
+
+	pixels, _, err := decodePixels(img.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := pixels.NRGBAAt(5, 5); c != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("Expected the margin to show the background, got %v", c)
+	}
+
+	if _, err := Collage(nil, ImageOptions{Width: 300, Height: 100}); err == nil {
+		t.Error("Expected an error without urls")
+	}
+	opts.Width = -300
+	if _, err := Collage(nil, opts); err != ErrNegativeDimensions {
+		t.Errorf("Expected negative dimensions to be rejected, got %v", err)
+	}
+
+	t.Cleanup(func() { LoadLayerLimits(ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels}) })
+	LoadLayerLimits(ServerOptions{MaxAllowedPixels: 0.1})
+	opts.Width = 300
+	if _, err := Collage(nil, opts); err != ErrResolutionTooBig {
+		t.Errorf("Expected the tiles above the resolution limit to be rejected, got %v", err)
+	}
+}
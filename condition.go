@@ -0,0 +1,146 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// Condition is the parsed "if" clause of a pipeline operation: comparisons
+// of the current image metadata, joined by &&, which must all hold for the
+// operation to run, e.g. "width > 2000 && type == jpeg".
+type Condition []comparison
+
+// comparison compares an image metadata field to a value.
+type comparison struct {
+	field    string
+	operator string
+	value    string
+	number   float64
+}
+
+// conditionFields are the image metadata fields of the conditions, numeric
+// or not.
+var conditionFields = map[string]bool{
+	"width":  true,
+	"height": true,
+	"ratio":  true,
+	"size":   true,
+	"type":   false,
+	"alpha":  false,
+}
+
+var comparisonPattern = regexp.MustCompile(`^\s*([a-z]+)\s*(>=|<=|==|!=|>|<)\s*([\w.]+)\s*$`)
+
+// parseCondition parses the "if" clause of a pipeline operation.
+func parseCondition(clause string) (Condition, error) {
+	var condition Condition
+	for _, expression := range strings.Split(clause, "&&") {
+		match := comparisonPattern.FindStringSubmatch(expression)
+		if match == nil {
+			return nil, fmt.Errorf("invalid comparison %q", strings.TrimSpace(expression))
+		}
+
+		c := comparison{field: match[1], operator: match[2], value: strings.ToLower(match[3])}
+		numeric, ok := conditionFields[c.field]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("unknown field %q, allowed fields are: width, height, ratio, size, type and alpha",
+				c.field)
+		case numeric:
+			var err error
+			if c.number, err = strconv.ParseFloat(c.value, 64); err != nil {
+				return nil, fmt.Errorf("%s must be compared to a number", c.field)
+			}
+		case c.operator != "==" && c.operator != "!=":
+			return nil, fmt.Errorf("%s only supports the == and != operators", c.field)
+		case c.field == "type" && ImageType(c.value) == bimg.UNKNOWN:
+			return nil, fmt.Errorf("unknown image type %q", c.value)
+		case c.field == "alpha" && c.value != "true" && c.value != "false":
+			return nil, errors.New("alpha must be compared to true or false")
+		}
+		condition = append(condition, c)
+	}
+	return condition, nil
+}
+
+// Matches reports whether the image metadata satisfies the condition. The
+// width and height are the ones of the image once auto rotated.
+func (c Condition) Matches(buf []byte) (bool, error) {
+	if len(c) == 0 {
+		return true, nil
+	}
+
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return false, err
+	}
+	width, height := meta.Size.Width, meta.Size.Height
+	if meta.Orientation > 4 {
+		width, height = height, width
+	}
+
+	for _, comparison := range c {
+		var matches bool
+		switch comparison.field {
+		case "width":
+			matches = compareNumbers(float64(width), comparison.operator, comparison.number)
+		case "height":
+			matches = compareNumbers(float64(height), comparison.operator, comparison.number)
+		case "ratio":
+			matches = height != 0 && compareNumbers(float64(width)/float64(height), comparison.operator, comparison.number)
+		case "size":
+			matches = compareNumbers(float64(len(buf)), comparison.operator, comparison.number)
+		case "type":
+			matches = ImageType(meta.Type) == ImageType(comparison.value)
+		case "alpha":
+			matches = strconv.FormatBool(meta.Alpha) == comparison.value
+		}
+		if comparison.operator == "!=" && !conditionFields[comparison.field] {
+			matches = !matches
+		}
+		if !matches {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func compareNumbers(a float64, operator string, b float64) bool {
+	switch operator {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case "==":
+		return a == b
+	default:
+		return a != b
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	condition, err := parseCondition("width > 2000 && type == JPEG")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Condition{
+		{field: "width", operator: ">", value: "2000", number: 2000},
+		{field: "type", operator: "==", value: "jpeg"},
+	}
+	if len(condition) != len(expected) || condition[0] != expected[0] || condition[1] != expected[1] {
+		t.Errorf("Invalid condition: %+v", condition)
+	}
+
+	invalid := []string{
+		"width",
+		"width > abc",
+		"depth > 8",
+		"type > jpeg",
+		"type == unknown",
+		"alpha == maybe",
+		"width > 100 &&",
+		"width > 100 || height > 100",
+	}
+	for _, clause := range invalid {
+		if _, err := parseCondition(clause); err == nil {
+			t.Errorf("Expected an error with %q", clause)
+		}
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	cases := map[string]bool{
+		"width == 550":                   true,
+		"width > 2000":                   false,
+		"height >= 740 && width <= 550":  true,
+		"ratio < 1":                      true,
+		"size > 1000":                    true,
+		"type == jpeg && alpha == false": true,
+		"type != jpeg":                   false,
+		"alpha != false":                 false,
+	}
+
+	for clause, expected := range cases {
+		condition, err := parseCondition(clause)
+		if err != nil {
+			t.Fatalf("Unexpected error with %q: %v", clause, err)
+		}
+		matches, err := condition.Matches(buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if matches != expected {
+			t.Errorf("Invalid match of %q: %t", clause, matches)
+		}
+	}
+
+	if matches, _ := Condition(nil).Matches(nil); !matches {
+		t.Error("Expected an empty condition to match")
+	}
+}
+
+func TestPipelineCondition(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+	operations := PipelineOperations{
+		{Name: "resize", If: "width > 2000", Params: map[string]interface{}{"width": 1000}},
+		{Name: "resize", If: "width > 500", Params: map[string]interface{}{"width": 300}},
+	}
+
+	img, err := Pipeline(buf, ImageOptions{Operations: operations})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if err := assertSize(img.Body, 300, 404); err != nil {
+		t.Error(err)
+	}
+	if timing := img.Header.Values(PipelineTimingHeader); len(timing) != 2 || timing[0] != "0;operation=resize;unmet" {
+		t.Errorf("Invalid timing steps: %v", timing)
+	}
+
+	operations[0].If = "depth > 8"
+	if _, err := Pipeline(buf, ImageOptions{Operations: operations}); err == nil {
+		t.Error("Expected an error with an invalid if clause")
+	}
+}
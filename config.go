@@ -0,0 +1,410 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxHTTPCacheTTL is the maximum -http-cache-ttl value, i.e. one year.
+const MaxHTTPCacheTTL = 31556926
+
+// validateServerOptions checks every option and combination of options,
+// returning all the problems found so they can be fixed at once.
+func validateServerOptions(o ServerOptions) []error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	check(validateMount(o.Mount))
+	for _, name := range slices.Sorted(maps.Keys(o.Mounts)) {
+		if err := validateMount(o.Mounts[name].Path); err != nil {
+			check(fmt.Errorf("invalid -mount %q: %w", name, err))
+		}
+	}
+	check(validateFontsDir(o.FontsDir))
+	check(validateDefaultFont(o))
+	check(validateHTTPCacheTTL(o.HTTPCacheTTL))
+	check(validateSignatureKey(o))
+	check(validateResponseSignature(o))
+	check(validateCallbackOrigins(o))
+	check(validatePublicURL(o.PublicURL))
+	check(validateStore(o))
+	check(validateTLS(o))
+	check(validatePlaceholder(o))
+	check(validateLogLevel(o.LogLevel))
+	check(validateSurrogateKeys(o.SurrogateKeys))
+	check(validateFallbackFormat(o.FallbackFormat))
+	check(validateAutoQualitySSIM(o.AutoQualitySSIM))
+	check(validateCMYKProfile(o.CMYKProfile))
+	check(validateTmpDir(o))
+	check(validatePresets(o))
+	errs = append(errs, validateURLSourceFlags(o)...)
+	errs = append(errs, validateLimits(o)...)
+
+	return errs
+}
+
+func validateMount(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	src, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error while mounting directory: %w", err)
+	}
+	if !src.IsDir() {
+		return fmt.Errorf("mount path is not a directory: %s", path)
+	}
+	if path == "/" {
+		return errors.New("cannot mount root directory for security reasons")
+	}
+	return nil
+}
+
+func validateFontsDir(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	src, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("invalid -fonts-dir: %w", err)
+	}
+	if !src.IsDir() {
+		return fmt.Errorf("fonts path is not a directory: %s", path)
+	}
+	return nil
+}
+
+func validateDefaultFont(o ServerOptions) error {
+	if o.DefaultFont == "" || len(o.AllowedFonts) == 0 {
+		return nil
+	}
+
+	families, _ := splitFont(o.DefaultFont)
+	for _, family := range families {
+		if !slices.ContainsFunc(o.AllowedFonts, func(allowed string) bool { return strings.EqualFold(allowed, family) }) {
+			return fmt.Errorf("the -default-font family %q is not in -allowed-fonts", family)
+		}
+	}
+	return nil
+}
+
+func validateHTTPCacheTTL(ttl int) error {
+	if ttl != -1 && (ttl < 0 || ttl > MaxHTTPCacheTTL) {
+		return fmt.Errorf("the -http-cache-ttl flag only accepts a value from 0 to %d", MaxHTTPCacheTTL)
+	}
+	return nil
+}
+
+func validateSignatureKey(o ServerOptions) error {
+	if !o.EnableURLSignature {
+		return nil
+	}
+	if o.URLSignatureKey == "" {
+		return errors.New("URL signature key is required by -enable-url-signature")
+	}
+	if len(o.URLSignatureKey) < 32 {
+		return errors.New("URL signature key must be a minimum of 32 characters")
+	}
+	return nil
+}
+
+func validateResponseSignature(o ServerOptions) error {
+	if o.ResponseHMACKey != "" && o.ResponseEd25519Key != "" {
+		return errors.New("the -response-signature-key and -response-signature-ed25519-key flags are mutually exclusive")
+	}
+	if o.ResponseHMACKey != "" && len(o.ResponseHMACKey) < 32 {
+		return errors.New("response signature key must be a minimum of 32 characters")
+	}
+	if o.ResponseEd25519Key != "" {
+		if _, err := readEd25519Key(o.ResponseEd25519Key); err != nil {
+			return fmt.Errorf("invalid -response-signature-ed25519-key: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateCallbackOrigins checks the job callbacks can be enabled, callbacks
+// being always signed.
+func validateCallbackOrigins(o ServerOptions) error {
+	if len(o.CallbackOrigins) == 0 {
+		return nil
+	}
+	if o.JobsWorkers <= 0 {
+		return errors.New("the -callback-origins flag requires -jobs-workers")
+	}
+	if o.ResponseHMACKey == "" && o.ResponseEd25519Key == "" {
+		return errors.New("the -callback-origins flag requires -response-signature-key or -response-signature-ed25519-key")
+	}
+	return nil
+}
+
+// validatePublicURL checks the public base URL locating the job results.
+func validatePublicURL(publicURL string) error {
+	if publicURL == "" {
+		return nil
+	}
+	u, err := url.Parse(publicURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid -public-url: %q, an http or https URL is expected", publicURL)
+	}
+	return nil
+}
+
+// validateStore checks the storage the store param uploads the outputs to.
+func validateStore(o ServerOptions) error {
+	if o.StoreEndpoint == "" {
+		if len(o.StoreBuckets) > 0 {
+			return errors.New("the -store-buckets flag requires -store-endpoint")
+		}
+		return nil
+	}
+
+	endpoint, err := url.Parse(o.StoreEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("invalid -store-endpoint %q, an http(s) URL is expected", o.StoreEndpoint)
+	}
+	if len(o.StoreBuckets) == 0 {
+		return errors.New("the -store-endpoint flag requires -store-buckets")
+	}
+	for _, entry := range o.StoreBuckets {
+		if bucket, _, _ := strings.Cut(entry, "/"); !bucketPattern.MatchString(bucket) {
+			return fmt.Errorf("invalid bucket name in -store-buckets entry %q", entry)
+		}
+	}
+	if o.StoreRegion == "" {
+		return errors.New("the -store-endpoint flag requires -store-region")
+	}
+	if o.StoreAccessKey == "" || o.StoreSecretKey == "" {
+		return errors.New("the -store-endpoint flag requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables")
+	}
+	return nil
+}
+
+func validateTLS(o ServerOptions) error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("the -certfile and -keyfile flags must be defined together")
+	}
+	if o.CertFile == "" {
+		if o.QUICPublicPort != 0 {
+			return errors.New("the -qpp flag requires -certfile and -keyfile, since HTTP/3 is only served over TLS")
+		}
+		return nil
+	}
+
+	for _, file := range []string{o.CertFile, o.KeyFile} {
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("cannot read TLS file: %w", err)
+		}
+	}
+	return nil
+}
+
+func validatePlaceholder(o ServerOptions) error {
+	if o.PlaceholderStatus != 0 {
+		if o.Placeholder == "" && !o.EnablePlaceholder {
+			return errors.New("the -placeholder-status flag requires -placeholder or -enable-placeholder")
+		}
+		if o.PlaceholderStatus < 100 || o.PlaceholderStatus > 599 {
+			return fmt.Errorf("invalid -placeholder-status HTTP status code: %d", o.PlaceholderStatus)
+		}
+	}
+	if o.Placeholder == "" {
+		return nil
+	}
+
+	buf, err := os.ReadFile(o.Placeholder)
+	if err != nil {
+		return fmt.Errorf("cannot read placeholder image: %w", err)
+	}
+	if !bimg.IsImageTypeSupportedByVips(bimg.DetermineImageType(buf)).Load {
+		return errors.New("placeholder image type is not supported. Only JPEG, PNG or WEBP are supported")
+	}
+	return nil
+}
+
+func validateLogLevel(level string) error {
+	if _, err := parseLogLevel(level); err != nil {
+		return fmt.Errorf("invalid -log-level: %w", err)
+	}
+	return nil
+}
+
+func validateSurrogateKeys(keys []string) error {
+	for _, key := range keys {
+		if !isSurrogateKey(key) {
+			return fmt.Errorf("invalid -surrogate-keys value %q. Allowed values are: hash, host and tenant", key)
+		}
+	}
+	return nil
+}
+
+func validateFallbackFormat(format string) error {
+	if _, ok := fallbackImageType(format); !ok {
+		return fmt.Errorf("invalid -fallback-format value %q. Allowed values are: jpeg, png and none", format)
+	}
+	return nil
+}
+
+func validateAutoQualitySSIM(ssim float64) error {
+	if ssim < 0 || ssim >= 1 {
+		return errors.New("the -auto-quality-ssim flag only accepts a value from 0 to 1, excluded")
+	}
+	return nil
+}
+
+func validateCMYKProfile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("invalid -cmyk-profile: %w", err)
+	}
+	return nil
+}
+
+func validateTmpDir(o ServerOptions) error {
+	if o.TmpDir == "" {
+		if o.TmpDirQuota > 0 {
+			return errors.New("the -tmp-dir-quota flag requires -tmp-dir")
+		}
+		return nil
+	}
+
+	src, err := os.Stat(o.TmpDir)
+	if err != nil {
+		return fmt.Errorf("invalid -tmp-dir: %w", err)
+	}
+	if !src.IsDir() {
+		return fmt.Errorf("temporary files path is not a directory: %s", o.TmpDir)
+	}
+	return nil
+}
+
+func validatePresets(o ServerOptions) error {
+	if o.PresetsFile == "" {
+		if o.PresetsOnly {
+			return errors.New("the -presets-only flag requires -presets-file")
+		}
+		return nil
+	}
+	if _, _, err := readPresets(o.PresetsFile); err != nil {
+		return fmt.Errorf("invalid -presets-file: %w", err)
+	}
+	return nil
+}
+
+// validateURLSourceFlags checks the flags only effective along with
+// -enable-url-source.
+func validateURLSourceFlags(o ServerOptions) []error {
+	if o.EnableURLSource {
+		return nil
+	}
+
+	flags := map[string]bool{
+		"-enable-auth-forwarding":  o.AuthForwarding,
+		"-insecure":                o.AllowInsecureSSL,
+		"-authorization":           o.Authorization != "",
+		"-forward-headers":         len(o.ForwardHeaders) > 0,
+		"-source-response-headers": len(o.SrcResponseHeaders) > 0,
+		"-allowed-origins":         len(o.AllowedOrigins) > 0,
+		"-max-allowed-size":        o.MaxAllowedSize > 0,
+		"-origin-concurrency":      o.OriginConcurrency > 0,
+		"-origin-rate":             o.OriginRate > 0,
+		"-stale-if-error":          o.StaleIfError > 0,
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if flags[name] {
+			errs = append(errs, fmt.Errorf("the %s flag requires -enable-url-source", name))
+		}
+	}
+	return errs
+}
+
+func validateLimits(o ServerOptions) []error {
+	limits := map[string]int{
+		"-concurrency":              o.Concurrency,
+		"-burst":                    o.Burst,
+		"-http-read-timeout":        o.HTTPReadTimeout,
+		"-http-write-timeout":       o.HTTPWriteTimeout,
+		"-http-read-header-timeout": o.HTTPHeaderTimeout,
+		"-http-idle-timeout":        o.HTTPIdleTimeout,
+		"-http-batch-write-timeout": o.HTTPBatchTimeout,
+		"-max-connections":          o.MaxConnections,
+		"-min-read-rate":            o.MinReadRate,
+		"-processing-timeout":       o.ProcessingTimeout,
+		"-overload-requests":        o.OverloadRequests,
+		"-overload-latency":         o.OverloadLatency,
+		"-jobs-workers":             o.JobsWorkers,
+		"-jobs-queue":               o.JobsQueue,
+		"-jobs-ttl":                 o.JobsTTL,
+		"-jobs-results-size":        o.JobsResultsSize,
+		"-max-allowed-size":         o.MaxAllowedSize,
+		"-max-body-size":            o.MaxBodySize,
+		"-origin-concurrency":       o.OriginConcurrency,
+		"-origin-rate":              o.OriginRate,
+		"-origin-queue-timeout":     o.OriginQueueTimeout,
+		"-pixel-cache-size":         o.PixelCacheSize,
+		"-low-memory-cooldown":      o.LowMemoryCooldown,
+		"-stale-if-error":           o.StaleIfError,
+		"-stale-cache-size":         o.StaleCacheSize,
+		"-tenant-daily-quota":       o.TenantDailyQuota,
+		"-tmp-dir-quota":            o.TmpDirQuota,
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(limits)) {
+		if limits[name] < 0 {
+			errs = append(errs, fmt.Errorf("the %s flag must not be negative", name))
+		}
+	}
+	if o.StaleIfError > 0 && o.StaleCacheSize <= 0 {
+		errs = append(errs, errors.New("the -stale-if-error flag requires -stale-cache-size"))
+	}
+	if o.MaxAllowedPixels <= 0 {
+		errs = append(errs, errors.New("the -max-allowed-resolution flag must be positive"))
+	}
+	return errs
+}
+
+// newConfigErrors groups all the configuration problems in a single
+// startup error.
+func newConfigErrors(errs []error) StartupError {
+	problems := make([]string, 0, len(errs))
+	for _, err := range errs {
+		problems = append(problems, "  - "+err.Error())
+	}
+	return newConfigError("invalid configuration:\n%s", strings.Join(problems, "\n"))
+}
@@ -0,0 +1,315 @@
+//go:build contract
+// +build contract
+
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractCase is a request to the HTTP API along with the response contract
+// downstream integrators rely on. Error responses must also follow the JSON
+// error schema.
+type contractCase struct {
+	method      string
+	path        string
+	accept      string
+	status      int
+	contentType string
+	headers     map[string]string
+}
+
+// contractOrigin is the placeholder of the URL of the test origin serving
+// the testdata directory, replaced in the paths of the contract cases.
+var contractOrigin = url.QueryEscape("{origin}")
+
+// contractServer serves the whole API with the testdata directory mounted,
+// as configured by most deployments, along with the origin the remote images
+// are fetched from.
+func contractServer(t *testing.T) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+	opts := ServerOptions{
+		PathPrefix:       "/",
+		Mount:            "testdata",
+		EnableURLSource:  true,
+		MaxAllowedPixels: 18.0,
+		HTTPCacheTTL:     3600,
+		LogLevel:         "info",
+		JobsWorkers:      1,
+		JobsQueue:        10,
+		JobsTTL:          60,
+	}
+	LoadSources(opts)
+	LoadJobs(opts)
+	t.Cleanup(func() { jobs = nil })
+
+	origin := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(origin.Close)
+	ts := httptest.NewServer(NewServerMux(opts))
+	t.Cleanup(ts.Close)
+	return ts, origin
+}
+
+// contractURLs returns the urls param listing the image of the test origin
+// the given number of times.
+func contractURLs(n int) string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = "{origin}/imaginary.jpg"
+	}
+	list, _ := json.Marshal(urls)
+	return url.QueryEscape(string(list))
+}
+
+var contractCases = []contractCase{
+	// Service endpoints, never cached
+	{method: http.MethodGet, path: "/", status: 200, contentType: ContentTypeJSON,
+		headers: map[string]string{CacheControl: ""}},
+	{method: http.MethodGet, path: "/health", status: 200, contentType: ContentTypeJSON,
+		headers: map[string]string{CacheControl: ""}},
+	{method: http.MethodGet, path: "/ready", status: 200, contentType: ContentTypeJSON,
+		headers: map[string]string{CacheControl: ""}},
+	{method: http.MethodGet, path: "/fonts", status: 200, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/capabilities", status: 200, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/missing", status: 404, contentType: ContentTypeJSON},
+
+	// Image operations
+	{method: http.MethodGet, path: "/resize?width=300&file=imaginary.jpg", status: 200, contentType: ImageJPEG,
+		headers: map[string]string{CacheControl: getCacheControl(3600)}},
+	{method: http.MethodGet, path: "/fit?width=300&height=300&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/crop?width=300&height=200&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/smartcrop?width=300&height=200&file=imaginary.jpg", status: 200,
+		contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/thumbnail?width=100&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/enlarge?width=1000&height=1000&file=imaginary.jpg", status: 200,
+		contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/extract?areawidth=100&areaheight=100&file=imaginary.jpg", status: 200,
+		contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/zoom?factor=2&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/rotate?rotate=90&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/autorotate?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/flip?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/flop?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/convert?type=png&file=imaginary.jpg", status: 200, contentType: ImagePNG},
+	{method: http.MethodGet, path: "/convert?type=webp&file=imaginary.jpg", status: 200, contentType: ImageWebP},
+	{method: http.MethodGet, path: "/watermark?text=imaginary&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/blur?sigma=5&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/sharpen?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/adjust?brightness=10&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/gamma?gamma=2&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/modulate?saturation=1.5&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/duotone?shadow=0,0,128&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/grayscale?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/sepia?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/invert?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/pad?width=800&height=800&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/border?border=10&file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/rounded?radius=40&type=png&file=imaginary.jpg", status: 200, contentType: ImagePNG},
+	{method: http.MethodGet, path: "/pixelate?file=imaginary.jpg", status: 200, contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/redact?regions=10,20,100,50&file=imaginary.jpg", status: 200,
+		contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/info?file=imaginary.jpg", status: 200, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/info?fields=width,height&file=imaginary.jpg", status: 200,
+		contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/info?fields=size&file=imaginary.jpg", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/histogram?file=imaginary.jpg", status: 200, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/phash?file=imaginary.jpg", status: 200, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/jobs/unknown", status: 404, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/resize?width=300&store=s3://assets/image.jpg&file=imaginary.jpg", status: 400,
+		contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/variants?widths=100,200&file=imaginary.jpg", status: 200,
+		contentType: "application/zip"},
+	{method: http.MethodGet, path: "/variants?widths=100,200&manifest=true&file=imaginary.jpg", status: 200,
+		contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/topdf?file=imaginary.jpg", status: 200, contentType: ContentTypePDF},
+	{method: http.MethodGet, path: "/totiff?file=imaginary.jpg", status: 200, contentType: ImageTIFF},
+	{method: http.MethodGet, path: "/pipeline?file=imaginary.jpg&operations=" + url.QueryEscape(
+		`[{"operation":"resize","params":{"width":300}},{"operation":"convert","params":{"type":"webp"}}]`),
+		status: 200, contentType: ImageWebP},
+	{method: http.MethodPost, path: "/resize?width=300", status: 200, contentType: ImageJPEG,
+		headers: map[string]string{CacheControl: ""}},
+
+	// Content negotiation
+	{method: http.MethodGet, path: "/resize?width=300&type=auto&file=imaginary.jpg", accept: "image/webp,*/*",
+		status: 200, contentType: ImageWebP, headers: map[string]string{"Vary": "Accept"}},
+	{method: http.MethodGet, path: "/resize?width=300&type=auto&file=imaginary.jpg", accept: "image/jpeg",
+		status: 200, contentType: ImageJPEG, headers: map[string]string{"Vary": "Accept"}},
+
+	// Generators
+	{method: http.MethodGet, path: "/generate?width=100&height=100", status: 200, contentType: ImagePNG},
+	{method: http.MethodGet, path: "/avatar?name=Jane+Doe", status: 200, contentType: ImagePNG},
+
+	// Endpoints composing the images of the urls param
+	{method: http.MethodGet, path: "/collage?width=300&height=100&type=png&urls=" + contractURLs(2), status: 200,
+		contentType: ImagePNG},
+	{method: http.MethodGet, path: "/storyboard?width=160&height=90&urls=" + contractURLs(3), status: 200,
+		contentType: ImageJPEG},
+	{method: http.MethodGet, path: "/storyboard?width=160&height=90&type=vtt&urls=" + contractURLs(3), status: 200,
+		contentType: ContentTypeVTT},
+	{method: http.MethodPost, path: "/animate?urls=" + contractURLs(2), status: 200, contentType: "image/gif"},
+	{method: http.MethodPost, path: "/batch?operation=resize&width=100&urls=" + contractURLs(2), status: 200,
+		contentType: "application/x-tar"},
+	{method: http.MethodPost, path: "/batch?operation=resize&width=100&urls=" + contractURLs(2) + "&async=true",
+		status: 400, contentType: ContentTypeJSON},
+
+	// Errors
+	{method: http.MethodGet, path: "/resize?file=imaginary.jpg", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/resize?width=abc&file=imaginary.jpg", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/resize?width=300&type=unknown&file=imaginary.jpg", status: 400,
+		contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/resize?width=300&file=missing.jpg", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/pipeline?file=imaginary.jpg&operations=" + url.QueryEscape(
+		`[{"operation":"unknown"}]`), status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodPost, path: "/resize?width=300", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodDelete, path: "/resize?width=300&file=imaginary.jpg", status: 405,
+		contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/generate?width=100", status: 400, contentType: ContentTypeJSON},
+	{method: http.MethodGet, path: "/collage", status: 400, contentType: ContentTypeJSON},
+}
+
+// TestContract checks the status code, content type and headers of every
+// endpoint, and the schema of the error responses. Run it with
+// go test -tags contract -run TestContract.
+func TestContract(t *testing.T) {
+	ts, origin := contractServer(t)
+
+	for _, c := range contractCases {
+		name := c.method + " " + c.path
+		t.Run(name, func(t *testing.T) {
+			path := strings.ReplaceAll(c.path, contractOrigin, url.QueryEscape(origin.URL))
+			var body io.Reader
+			// Uploads use the image as body, the last POST case having none
+			if c.method == http.MethodPost && c.status == 200 {
+				file, err := os.Open("testdata/imaginary.jpg")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() { _ = file.Close() }()
+				body = file
+			}
+
+			req, err := http.NewRequest(c.method, ts.URL+path, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != nil {
+				req.Header.Set(ContentType, ImageJPEG)
+			}
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Cannot perform the request: %s", err)
+			}
+			defer func() { _ = res.Body.Close() }()
+			payload, _ := io.ReadAll(res.Body)
+
+			if res.StatusCode != c.status {
+				t.Fatalf("Invalid response status: %d, expected: %d, body: %s", res.StatusCode, c.status, payload)
+			}
+			if contentType := res.Header.Get(ContentType); !strings.HasPrefix(contentType, c.contentType) {
+				t.Errorf("Invalid content type: %s, expected: %s", contentType, c.contentType)
+			}
+			for name, expected := range c.headers {
+				if actual := res.Header.Get(name); actual != expected {
+					t.Errorf("Invalid %s header: %q, expected: %q", name, actual, expected)
+				}
+			}
+			if res.StatusCode >= 400 {
+				assertErrorSchema(t, payload, res.StatusCode)
+			}
+		})
+	}
+}
+
+// TestContractJobs checks the asynchronous jobs, from their submission to
+// their result.
+func TestContractJobs(t *testing.T) {
+	ts, _ := contractServer(t)
+
+	res, err := http.Get(ts.URL + "/resize?width=300&async=true&file=imaginary.jpg")
+	if err != nil {
+		t.Fatalf("Cannot perform the request: %s", err)
+	}
+	_ = res.Body.Close()
+	location := res.Header.Get("Location")
+	if res.StatusCode != http.StatusAccepted || !strings.HasPrefix(res.Header.Get(ContentType), ContentTypeJSON) ||
+		!strings.HasPrefix(location, "/jobs/") {
+		t.Fatalf("Invalid job submission: %d, %s, location: %s", res.StatusCode, res.Header.Get(ContentType), location)
+	}
+
+	var status Job
+	for i := 0; i < 100 && status.Status != JobDone; i++ {
+		time.Sleep(20 * time.Millisecond)
+		res, err := http.Get(ts.URL + location)
+		if err != nil {
+			t.Fatalf("Cannot perform the request: %s", err)
+		}
+		_ = json.NewDecoder(res.Body).Decode(&status)
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK || status.Status == JobError {
+			t.Fatalf("Invalid job status: %d, %+v", res.StatusCode, status)
+		}
+	}
+	if status.Status != JobDone || status.Result != location+"/result" {
+		t.Fatalf("Invalid completed job: %+v", status)
+	}
+
+	res, err = http.Get(ts.URL + status.Result)
+	if err != nil {
+		t.Fatalf("Cannot perform the request: %s", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get(ContentType) != ImageJPEG {
+		t.Errorf("Invalid job result: %d, %s", res.StatusCode, res.Header.Get(ContentType))
+	}
+}
+
+// assertErrorSchema checks the body is a JSON error with a message and the
+// response status code.
+func assertErrorSchema(t *testing.T, body []byte, status int) {
+	t.Helper()
+	var schema map[string]json.RawMessage
+	if err := json.Unmarshal(body, &schema); err != nil {
+		t.Fatalf("Invalid JSON error: %s", body)
+	}
+	for key := range schema {
+		if key != "message" && key != "status" && key != "param" {
+			t.Errorf("Unexpected error field: %s", key)
+		}
+	}
+
+	var reply Error
+	_ = json.Unmarshal(body, &reply)
+	if reply.Message == "" || reply.Code != status {
+		t.Errorf("Invalid error: %s, expected status: %d", body, status)
+	}
+}
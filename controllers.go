@@ -19,13 +19,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"mime"
 	"net/http"
 	"path"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/h2non/bimg"
 	"github.com/h2non/filetype"
@@ -70,15 +75,88 @@ func indexController(o ServerOptions) func(w http.ResponseWriter, r *http.Reques
 // @Summary Health check
 // @Description Returns the health status of the service
 // @Produce json
+// @Param fields query string false "Comma separated list of the fields to return"
 // @Success 200 {object} HealthStats
 // @Router /health [get]
-func healthController(w http.ResponseWriter, _ *http.Request) {
-	health := GetHealthStats()
-	body, _ := json.Marshal(health)
+func healthController(o ServerOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := canonicalJSON(GetHealthStats(), parseHeadersList(r.URL.Query().Get("fields")))
+		if err != nil {
+			ErrorReply(r, w, toError(err), o)
+			return
+		}
+		w.Header().Set(ContentType, ContentTypeJSON)
+		_, _ = w.Write(body)
+	}
+}
+
+// @Summary Readiness check
+// @Description Returns whether the instance accepts traffic, replying 503 when overloaded
+// @Produce json
+// @Success 200 {object} Readiness
+// @Failure 503 {object} Readiness
+// @Router /ready [get]
+func readyController(w http.ResponseWriter, _ *http.Request) {
+	score := load.utilization(time.Now())
+	readiness := Readiness{Ready: score < 1, Load: score}
+	body, _ := json.Marshal(readiness)
+
+	w.Header().Set(ContentType, ContentTypeJSON)
+	if load != nil {
+		w.Header().Set(LoadHeader, loadHeader(score))
+	}
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write(body)
+}
+
+// @Summary Fonts
+// @Description Lists the fonts loaded from the fonts directory and the fallback font families
+// @Produce json
+// @Success 200 {object} Fonts
+// @Router /fonts [get]
+func fontsController(w http.ResponseWriter, _ *http.Request) {
+	fonts := Fonts{Fonts: loadedFonts, Fallback: fontFallback}
+	if fonts.Fonts == nil {
+		fonts.Fonts = []Font{}
+	}
+	if fonts.Fallback == nil {
+		fonts.Fallback = []string{}
+	}
+
+	body, _ := json.Marshal(fonts)
 	w.Header().Set(ContentType, ContentTypeJSON)
 	_, _ = w.Write(body)
 }
 
+// @Summary Log level
+// @Description Returns the log level in use. A POST request with the level param switches it at runtime
+// @Produce json
+// @Param level query string false "New log level: error, warning, info or debug"
+// @Success 200 {object} map[string]string
+// @Router /log-level [get]
+// @Router /log-level [post]
+func logLevelController(o ServerOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if o.APIKey == "" {
+				ErrorReply(r, w, ErrLogLevelForbidden, o)
+				return
+			}
+			if err := SetLogLevel(r.FormValue("level")); err != nil {
+				ErrorReply(r, w, NewError(err.Error(), http.StatusBadRequest), o)
+				return
+			}
+			log.Printf("Log level set to %s", CurrentLogLevel())
+		}
+
+		body, _ := json.Marshal(map[string]string{"level": CurrentLogLevel()})
+		w.Header().Set(ContentType, ContentTypeJSON)
+		_, _ = w.Write(body)
+	}
+}
+
 // imageController is a generic handler for image processing operations
 func imageController(o ServerOptions, operation Operation) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -90,6 +168,9 @@ func imageController(o ServerOptions, operation Operation) func(http.ResponseWri
 
 		buf, srcResponseHeaders, err := imageSource.GetImage(req)
 		if err != nil {
+			if replyWithStale(w, req, err, o) {
+				return
+			}
 			if xerr, ok := err.(Error); ok {
 				ErrorReply(req, w, xerr, o)
 			} else {
@@ -106,6 +187,9 @@ func imageController(o ServerOptions, operation Operation) func(http.ResponseWri
 		if len(o.SrcResponseHeaders) > 0 {
 			setSrcResponseHeaders(w, srcResponseHeaders, o.SrcResponseHeaders)
 		}
+		if o.SurrogateKeyHeader != "" {
+			setSurrogateKeys(w, req, buf, o)
+		}
 
 		imageHandler(w, req, buf, operation, o)
 	}
@@ -139,6 +223,8 @@ func determineAcceptMimeType(accept string) string {
 }
 
 func imageHandler(w http.ResponseWriter, r *http.Request, buf []byte, operation Operation, o ServerOptions) {
+	o = withRequestLimits(r, o)
+
 	mimeType, err := inferMimeType(buf)
 	if err != nil || !IsImageMimeTypeSupported(mimeType) {
 		ErrorReply(r, w, ErrUnsupportedMedia, o)
@@ -151,20 +237,289 @@ func imageHandler(w http.ResponseWriter, r *http.Request, buf []byte, operation
 		return
 	}
 
-	if sizeErr := validateImageSize(buf, o); sizeErr != nil {
+	if sizeErr := validateImageSize(buf, opts, o); sizeErr == ErrLowMemory {
+		ErrorReply(r, w, ErrLowMemory, o)
+		return
+	} else if sizeErr != nil {
 		ErrorReply(r, w, NewError(sizeErr.Error(), http.StatusBadRequest), o)
 		return
 	}
 
-	image, operationErr := operation.Run(buf, opts)
+	destination, err := parseStoreDestination(opts.Store)
+	if err != nil {
+		ErrorReply(r, w, toError(err), o)
+		return
+	}
+
+	if opts.Async || opts.Callback != "" {
+		submitJob(w, r, func() (Image, error) {
+			image, err := runOperationWithin(operation, buf, opts, o.ProcessingTimeout)
+			if err == nil && destination != nil {
+				image, err = storeImage(context.Background(), destination, image)
+			}
+			return signImage(image, r), err
+		}, opts, vary, o)
+		return
+	}
+
+	image, operationErr := runOperationWithin(operation, buf, opts, o.ProcessingTimeout)
+	if operationErr == ErrProcessingTimeout || operationErr == ErrProcessingBusy || operationErr == ErrEncodeFailed {
+		ErrorReply(r, w, operationErr.(Error), o)
+		return
+	}
 	if operationErr != nil {
 		handleProcessingError(w, r, vary, operationErr, o)
 		return
 	}
 
+	if destination != nil {
+		image, err = storeImage(r.Context(), destination, image)
+		if err != nil {
+			ErrorReply(r, w, toError(err), o)
+			return
+		}
+	}
+
+	image = signImage(image, r)
+	if destination == nil {
+		keepStale(r, image, vary)
+	}
 	sendResponse(w, image, vary, o)
 }
 
+// abandonedOperations holds a slot per timed out operation still running in
+// background, bounding the processing no longer tied to a request.
+var abandonedOperations = make(chan struct{}, runtime.NumCPU())
+
+// runOperationWithin runs the operation, giving up after the given budget in
+// milliseconds, if any. libvips cannot be interrupted, so the processing still
+// completes in background and its result is discarded. Once abandonedOperations
+// is full, new operations are rejected, and timed out ones wait for a slot.
+func runOperationWithin(operation Operation, buf []byte, opts ImageOptions, budget int) (Image, error) {
+	if budget <= 0 {
+		return runOperation(operation, buf, opts)
+	}
+	slots := abandonedOperations
+	if len(slots) == cap(slots) {
+		return Image{}, ErrProcessingBusy
+	}
+
+	type result struct {
+		image Image
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		image, err := runOperation(operation, buf, opts)
+		done <- result{image, err}
+	}()
+
+	timer := time.NewTimer(time.Duration(budget) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case res := <-done:
+		return res.image, res.err
+	case <-timer.C:
+		select {
+		case slots <- struct{}{}:
+		case <-done:
+			return Image{}, ErrProcessingTimeout
+		}
+		go func() {
+			<-done
+			<-slots
+		}()
+		return Image{}, ErrProcessingTimeout
+	}
+}
+
+// generatorController serves operations producing an image from scratch, so
+// no image source is involved.
+func generatorController(o ServerOptions, operation Operation) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		o := withRequestLimits(req, o)
+
+		opts, vary, err := processImageOptions(req)
+		if err != nil {
+			ErrorReply(req, w, NewError(err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		if opts.Async || opts.Callback != "" {
+			ErrorReply(req, w, ErrAsyncUnsupported, o)
+			return
+		}
+
+		// Negative dimensions would pass the resolution check
+		if opts.Width < 0 || opts.Height < 0 {
+			ErrorReply(req, w, ErrNegativeDimensions, o)
+			return
+		}
+		if float64(opts.Width)*float64(opts.Height)/1000000 > o.MaxAllowedPixels {
+			ErrorReply(req, w, ErrResolutionTooBig, o)
+			return
+		}
+
+		image, err := runOperation(operation, nil, opts)
+		if err != nil {
+			if xerr, ok := err.(Error); ok {
+				ErrorReply(req, w, xerr, o)
+			} else {
+				handleProcessingError(w, req, vary, err, o)
+			}
+			return
+		}
+
+		sendResponse(w, signImage(image, req), vary, o)
+	}
+}
+
+// runOperation applies the operation to the image buffer, recording its
+// duration per format, and entering the degraded mode when libvips fails to
+// allocate memory for it.
+func runOperation(operation Operation, buf []byte, opts ImageOptions) (Image, error) {
+	start := time.Now()
+	image, err := applyOperation(operation, buf, opts)
+	if err == nil {
+		observeProcessing(buf, image, time.Since(start))
+		if opts.DPR != 0 {
+			image = withContentDPR(image, opts.DPR)
+		}
+	} else if lowMemory != nil && isOutOfMemory(err) {
+		lowMemory.degrade(inputMegapixels(buf, opts), time.Now())
+	}
+	return image, err
+}
+
+// applyOperation applies the operation to the image buffer, loading the
+// requested pages or density, resolving the relative sizes, cropping it to the
+// requested aspect ratio and enhancing it first if requested. Raw pixel, ICO,
+// size limited, auto quality, tuned HEIF and TIFF, quantized PNG, and chroma
+// subsampled JPEG and WebP outputs are encoded from a lossless PNG produced by
+// the operation.
+func applyOperation(operation Operation, buf []byte, opts ImageOptions) (Image, error) {
+	if buf != nil && hasLoadOptions(buf, opts) {
+		var err error
+		if buf, opts, err = loadInput(buf, opts); err != nil {
+			return Image{}, err
+		}
+	}
+	if hasRelativeSize(opts) {
+		var err error
+		if opts, err = resolveRelativeSize(buf, opts); err != nil {
+			return Image{}, err
+		}
+	}
+	if opts.Ratio != "" && buf != nil {
+		var err error
+		if buf, opts, err = aspectCropInput(buf, opts); err != nil {
+			return Image{}, err
+		}
+	}
+	if opts.Enhance != "" && buf != nil {
+		var err error
+		if buf, opts, err = enhanceInput(buf, opts); err != nil {
+			return Image{}, err
+		}
+	}
+
+	switch {
+	case encodesOutput(operation):
+		return operation.Run(buf, opts)
+	case IsRawOutputType(opts.Type):
+		rawType := opts.Type
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeRaw(image, rawType, opts.ChannelOrder)
+	case IsICOOutputType(opts.Type):
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeICO(image)
+	case opts.MaxBytes != 0:
+		maxBytesType, err := maxBytesOutputType(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		maxBytesOpts := opts
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeMaxBytes(image, maxBytesType, maxBytesOpts)
+	case opts.AutoQuality:
+		autoQualityType, err := autoQualityOutputType(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		autoQualityOpts := opts
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeAutoQuality(image, autoQualityType, autoQualityOpts)
+	case isTunedHEIFOutput(opts):
+		heifOpts := opts
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeHEIF(image, heifOpts)
+	case isTunedPNGOutput(buf, opts):
+		pngOpts := opts
+		opts.Type = PNG
+		opts.Palette = false
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodePNG(image, pngOpts)
+	case isTunedTIFFOutput(opts):
+		tiffOpts := opts
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeTIFF(image, tiffOpts)
+	case subsampledOutputType(buf, opts) != bimg.UNKNOWN:
+		subsampledType := subsampledOutputType(buf, opts)
+		subsampledOpts := opts
+		opts.Type = PNG
+
+		image, err := operation.Run(buf, opts)
+		if err != nil {
+			return Image{}, err
+		}
+		return EncodeSubsampled(image, subsampledType, subsampledOpts)
+	default:
+		return operation.Run(buf, opts)
+	}
+}
+
+// encodesOutput reports whether the operation encodes its outputs itself, as
+// Variants does with its renditions, its archive or manifest being no image
+// to encode.
+func encodesOutput(operation Operation) bool {
+	return reflect.ValueOf(operation).Pointer() == reflect.ValueOf(Variants).Pointer()
+}
+
 //nolint:unparam
 func inferMimeType(buf []byte) (string, error) {
 	mimeType := http.DetectContentType(buf)
@@ -184,23 +539,44 @@ func inferMimeType(buf []byte) (string, error) {
 }
 
 func processImageOptions(r *http.Request) (ImageOptions, string, error) {
-	opts, err := buildParamsFromQuery(r.URL.Query())
+	query, err := applyPreset(r.URL.Query())
+	if err != nil {
+		return ImageOptions{}, "", err
+	}
+	opts, err := buildParamsFromQuery(query)
 	if err != nil {
 		return ImageOptions{}, "", NewError("Error while processing parameters, "+err.Error(), http.StatusBadRequest)
 	}
+	if opts, err = applyDPR(opts); err != nil {
+		return ImageOptions{}, "", err
+	}
 
 	vary := ""
 	if opts.Type == "auto" {
 		opts.Type = determineAcceptMimeType(r.Header.Get("Accept"))
 		vary = "Accept"
-	} else if opts.Type != "" && ImageType(opts.Type) == 0 {
+	} else if opts.Type != "" && ImageType(opts.Type) == 0 && !IsRawOutputType(opts.Type) &&
+		!IsICOOutputType(opts.Type) {
+		return ImageOptions{}, "", ErrOutputFormat
+	} else if isHEIFOutput(opts) && !bimg.IsTypeSupportedSave(bimg.HEIF) {
 		return ImageOptions{}, "", ErrOutputFormat
 	}
 	return opts, vary, nil
 }
 
-func validateImageSize(buf []byte, o ServerOptions) error {
-	sizeInfo, err := bimg.Size(buf)
+// validateImageSize checks the resolution of the image, as rendered with the
+// requested pages or density, against the limits.
+func validateImageSize(buf []byte, opts ImageOptions, o ServerOptions) error {
+	var sizeInfo bimg.ImageSize
+	var err error
+	if hasLoadOptions(buf, opts) {
+		sizeInfo, err = loadedSize(buf, opts)
+	} else {
+		sizeInfo, err = bimg.Size(buf)
+	}
+	if xerr, ok := err.(Error); ok {
+		return xerr
+	}
 	if err != nil {
 		return NewError("Error while processing the image: "+err.Error(), http.StatusBadRequest)
 	}
@@ -208,6 +584,9 @@ func validateImageSize(buf []byte, o ServerOptions) error {
 	if (imgResolution / 1000000) > o.MaxAllowedPixels {
 		return ErrResolutionTooBig
 	}
+	if lowMemory.rejects(imgResolution/1000000, time.Now()) {
+		return ErrLowMemory
+	}
 	return nil
 }
 
@@ -215,13 +594,21 @@ func handleProcessingError(w http.ResponseWriter, r *http.Request, vary string,
 	if vary != "" {
 		w.Header().Set("Vary", vary)
 	}
-	ErrorReply(r, w, NewError("Error while processing the image: "+err.Error(), http.StatusBadRequest), o)
+	xerr := NewError("Error while processing the image: "+err.Error(), http.StatusBadRequest)
+	if cause, ok := err.(Error); ok {
+		// Keep the details of invalid pipeline params
+		xerr.Param = cause.Param
+	}
+	ErrorReply(r, w, xerr, o)
 }
 
 func sendResponse(w http.ResponseWriter, image Image, vary string, o ServerOptions) {
+	for key, values := range image.Header {
+		w.Header()[key] = values
+	}
 	w.Header().Set("Content-Length", strconv.Itoa(len(image.Body)))
 	w.Header().Set(ContentType, image.Mime)
-	if image.Mime != ContentTypeJSON && o.ReturnSize {
+	if strings.HasPrefix(image.Mime, "image/") && o.ReturnSize {
 		meta, err := bimg.Metadata(image.Body)
 		if err == nil {
 			w.Header().Set("Image-Width", strconv.Itoa(meta.Size.Width))
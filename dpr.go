@@ -0,0 +1,60 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// MaxDPR is the maximum device pixel ratio of the dpr param.
+const MaxDPR = 4
+
+// ContentDPRHeader is the response header reporting the device pixel ratio
+// the image was rendered for.
+const ContentDPRHeader = "Content-DPR"
+
+// applyDPR multiplies the width and height params by the device pixel ratio
+// of the dpr param, so a single URL template serves 1x, 2x and 3x images.
+func applyDPR(opts ImageOptions) (ImageOptions, error) {
+	if opts.DPR == 0 {
+		return opts, nil
+	}
+	if opts.DPR < 0 || opts.DPR > MaxDPR {
+		return opts, NewError(fmt.Sprintf("Invalid param: dpr must be between 0 and %d", MaxDPR), http.StatusBadRequest)
+	}
+
+	opts.Width = int(math.Round(float64(opts.Width) * opts.DPR))
+	opts.Height = int(math.Round(float64(opts.Height) * opts.DPR))
+	return opts, nil
+}
+
+// withContentDPR reports the device pixel ratio of the dpr param in the
+// Content-DPR header of the image.
+func withContentDPR(img Image, dpr float64) Image {
+	header := img.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(ContentDPRHeader, strconv.FormatFloat(dpr, 'f', -1, 64))
+	img.Header = header
+	return img
+}
@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplyDPR(t *testing.T) {
+	cases := []struct {
+		opts          ImageOptions
+		width, height int
+	}{
+		{ImageOptions{Width: 300, Height: 200}, 300, 200},
+		{ImageOptions{Width: 300, Height: 200, DPR: 2}, 600, 400},
+		{ImageOptions{Width: 300, DPR: 1.5}, 450, 0},
+		{ImageOptions{Width: 101, Height: 33, DPR: 2.5}, 253, 83},
+	}
+
+	for _, c := range cases {
+		opts, err := applyDPR(c.opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if opts.Width != c.width || opts.Height != c.height {
+			t.Errorf("Invalid size with %+v: %dx%d", c.opts, opts.Width, opts.Height)
+		}
+	}
+
+	for _, dpr := range []float64{-1, MaxDPR + 0.5} {
+		if _, err := applyDPR(ImageOptions{Width: 300, DPR: dpr}); err == nil {
+			t.Errorf("Expected an error with dpr %f", dpr)
+		}
+	}
+}
+
+func TestProcessImageOptionsDPR(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/resize?width=300&height=200&dpr=3", nil)
+	opts, _, err := processImageOptions(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Width != 900 || opts.Height != 600 {
+		t.Errorf("Invalid size: %dx%d", opts.Width, opts.Height)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/resize?width=300&dpr=10", nil)
+	if _, _, err := processImageOptions(req); err == nil {
+		t.Error("Expected an error with a too high dpr")
+	}
+}
+
+func TestWithContentDPR(t *testing.T) {
+	img := withContentDPR(Image{Header: http.Header{OrientationHeader: {"1"}}}, 2.5)
+	if img.Header.Get(ContentDPRHeader) != "2.5" || img.Header.Get(OrientationHeader) != "1" {
+		t.Errorf("Invalid headers: %v", img.Header)
+	}
+}
@@ -0,0 +1,364 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/h2non/bimg"
+)
+
+// pixelate replaces every size x size block of the given area with its average color.
+func pixelate(img *image.NRGBA, area image.Rectangle, size int) {
+	for y := area.Min.Y; y < area.Max.Y; y += size {
+		for x := area.Min.X; x < area.Max.X; x += size {
+			block := image.Rect(x, y, x+size, y+size).Intersect(area)
+			fillRect(img, block, averageColor(img, block))
+		}
+	}
+}
+
+// averageColor computes the mean color of the given area.
+func averageColor(img *image.NRGBA, area image.Rectangle) [4]uint8 {
+	var sum [4]int
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		i := img.PixOffset(area.Min.X, y)
+		for x := area.Min.X; x < area.Max.X; x++ {
+			for c := 0; c < 4; c++ {
+				sum[c] += int(img.Pix[i+c])
+			}
+			i += 4
+		}
+	}
+
+	var avg [4]uint8
+	count := area.Dx() * area.Dy()
+	if count == 0 {
+		return avg
+	}
+	for c := range avg {
+		avg[c] = uint8((sum[c] + count/2) / count)
+	}
+	return avg
+}
+
+// fillRect paints the given area with a solid color.
+func fillRect(img *image.NRGBA, area image.Rectangle, color [4]uint8) {
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		i := img.PixOffset(area.Min.X, y)
+		for x := area.Min.X; x < area.Max.X; x++ {
+			copy(img.Pix[i:i+4], color[:])
+			i += 4
+		}
+	}
+}
+
+// blurRect blurs the given area using three box blur passes, which closely
+// approximates a gaussian blur. Only pixels inside the area are sampled so
+// the redacted content cannot leak from its surroundings either.
+func blurRect(img *image.NRGBA, area image.Rectangle, radius int) {
+	if radius < 1 || area.Empty() {
+		return
+	}
+
+	width, height := area.Dx(), area.Dy()
+	line := make([][4]uint8, max(width, height))
+
+	for pass := 0; pass < 3; pass++ {
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			boxBlurLine(img, line[:width], img.PixOffset(area.Min.X, y), 4, radius)
+		}
+		for x := area.Min.X; x < area.Max.X; x++ {
+			boxBlurLine(img, line[:height], img.PixOffset(x, area.Min.Y), img.Stride, radius)
+		}
+	}
+}
+
+// boxBlurLine blurs a line of pixels starting at offset, using a sliding
+// window that clamps at both ends of the line.
+func boxBlurLine(img *image.NRGBA, line [][4]uint8, offset, step, radius int) {
+	n := len(line)
+	for i := range line {
+		copy(line[i][:], img.Pix[offset+i*step:offset+i*step+4])
+	}
+
+	clamp := func(i int) int { return min(max(i, 0), n-1) }
+
+	var sum [4]int
+	for i := -radius; i <= radius; i++ {
+		for c := 0; c < 4; c++ {
+			sum[c] += int(line[clamp(i)][c])
+		}
+	}
+
+	size := 2*radius + 1
+	for i := 0; i < n; i++ {
+		p := offset + i*step
+		for c := 0; c < 4; c++ {
+			img.Pix[p+c] = uint8((sum[c] + size/2) / size)
+			sum[c] += int(line[clamp(i+radius+1)][c]) - int(line[clamp(i-radius)][c])
+		}
+	}
+}
+
+// opaqueColor converts a RGB color param into an opaque color, falling back
+// to def when the param is not defined.
+func opaqueColor(rgb []uint8, def [4]uint8) [4]uint8 {
+	if len(rgb) < 3 {
+		return def
+	}
+	return [4]uint8{rgb[0], rgb[1], rgb[2], 255}
+}
+
+// addFrame extends the canvas by the given amount of pixels on each side,
+// painting the added area with a solid color. The extended canvas is checked
+// against the resolution limits before it is allocated.
+func addFrame(img *image.NRGBA, left, top, right, bottom int, color [4]uint8) (*image.NRGBA, error) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	// Summed as floats so huge sides can't overflow
+	canvasWidth := float64(width) + float64(left) + float64(right)
+	canvasHeight := float64(height) + float64(top) + float64(bottom)
+	if canvasWidth*canvasHeight/1000000 > layerLimits.MaxAllowedPixels {
+		return nil, ErrResolutionTooBig
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, width+left+right, height+top+bottom))
+	fillRect(canvas, canvas.Rect, color)
+	draw.Draw(canvas, image.Rect(left, top, left+width, top+height), img, img.Rect.Min, draw.Src)
+	return canvas, nil
+}
+
+// roundCorners makes the corners of the image transparent outside of a
+// quarter circle of the given radius, antialiasing its edge. The radius is
+// capped to half the shortest side.
+func roundCorners(img *image.NRGBA, radius int) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	radius = min(radius, width/2, height/2)
+	r := float64(radius)
+
+	for y := 0; y < height; y++ {
+		// Vertical distance to the center of the closest corner circle
+		var dy float64
+		switch {
+		case y < radius:
+			dy = r - float64(y) - 0.5
+		case y >= height-radius:
+			dy = float64(y) + 0.5 - float64(height-radius)
+		default:
+			continue
+		}
+
+		for x := 0; x < width; x++ {
+			var dx float64
+			switch {
+			case x < radius:
+				dx = r - float64(x) - 0.5
+			case x >= width-radius:
+				dx = float64(x) + 0.5 - float64(width-radius)
+			default:
+				continue
+			}
+
+			coverage := r - math.Hypot(dx, dy) + 0.5
+			if coverage >= 1 {
+				continue
+			}
+			i := img.PixOffset(x, y) + 3
+			img.Pix[i] = uint8(float64(img.Pix[i])*math.Max(coverage, 0) + 0.5)
+		}
+	}
+}
+
+// circleCrop crops the square bounding the circle of the given center and
+// radius, making the pixels outside of the circle transparent.
+func circleCrop(img *image.NRGBA, cx, cy, radius int) *image.NRGBA {
+	size := 2 * radius
+	canvas := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(canvas, canvas.Rect, img, image.Pt(cx-radius, cy-radius), draw.Src)
+
+	r := float64(radius)
+	for y := 0; y < size; y++ {
+		dy := float64(y) + 0.5 - r
+		for x := 0; x < size; x++ {
+			coverage := r - math.Hypot(float64(x)+0.5-r, dy) + 0.5
+			if coverage >= 1 {
+				continue
+			}
+			i := canvas.PixOffset(x, y) + 3
+			canvas.Pix[i] = uint8(float64(canvas.Pix[i])*math.Max(coverage, 0) + 0.5)
+		}
+	}
+	return canvas
+}
+
+// flatten blends the image over a solid background color, making it opaque.
+func flatten(img *image.NRGBA, background [4]uint8) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		alpha := int(img.Pix[i+3])
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8((int(img.Pix[i+c])*alpha + int(background[c])*(255-alpha) + 127) / 255)
+		}
+		img.Pix[i+3] = 255
+	}
+}
+
+// strokeFrame paints a solid color frame of the given width over the image
+// edges, keeping its dimensions.
+func strokeFrame(img *image.NRGBA, width int, color [4]uint8) {
+	b := img.Rect
+	fillRect(img, image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+width).Intersect(b), color)
+	fillRect(img, image.Rect(b.Min.X, b.Max.Y-width, b.Max.X, b.Max.Y).Intersect(b), color)
+	fillRect(img, image.Rect(b.Min.X, b.Min.Y, b.Min.X+width, b.Max.Y).Intersect(b), color)
+	fillRect(img, image.Rect(b.Max.X-width, b.Min.Y, b.Max.X, b.Max.Y).Intersect(b), color)
+}
+
+// padOffsets returns where to place an image on a canvas with dx and dy
+// extra pixels, according to the gravity. Images are centered by default.
+func padOffsets(gravity bimg.Gravity, dx, dy int) (left, top int) {
+	fx, fy := gravityAnchor(gravity)
+	return int(fx * float64(dx)), int(fy * float64(dy))
+}
+
+// vignette progressively darkens the image towards its corners. The effect
+// starts halfway from the center and strength, from 0 to 1, defines how dark
+// the corners get.
+func vignette(img *image.NRGBA, strength float64) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	cx, cy := float64(width)/2, float64(height)/2
+
+	for y := 0; y < height; y++ {
+		i := img.PixOffset(0, y)
+		dy := (float64(y) + 0.5 - cy) / cy
+		for x := 0; x < width; x++ {
+			dx := (float64(x) + 0.5 - cx) / cx
+			// Normalized so corners are at distance 1
+			dist := math.Sqrt(dx*dx+dy*dy) / math.Sqrt2
+			if dist > 0.5 {
+				falloff := (dist - 0.5) / 0.5
+				factor := 1 - strength*falloff*falloff
+				for c := 0; c < 3; c++ {
+					img.Pix[i+c] = uint8(float64(img.Pix[i+c])*factor + 0.5)
+				}
+			}
+			i += 4
+		}
+	}
+}
+
+// modulate scales the saturation of the image and rotates its hue by the
+// given degrees, using the luminance preserving color matrices of the CSS
+// saturate() and hue-rotate() filters.
+func modulate(img *image.NRGBA, saturation, hue float64) {
+	applyColorMatrix(img, modulateMatrix(saturation, hue))
+}
+
+// sepia tones the image with the CSS sepia() filter matrix, blended with the
+// original colors according to the given strength, between 0 and 1.
+func sepia(img *image.NRGBA, strength float64) {
+	tone := [3][3]float64{
+		{0.393, 0.769, 0.189},
+		{0.349, 0.686, 0.168},
+		{0.272, 0.534, 0.131},
+	}
+
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i][j] = tone[i][j] * strength
+		}
+		m[i][i] += 1 - strength
+	}
+	applyColorMatrix(img, m)
+}
+
+// invert negates the color channels of every pixel, leaving the alpha
+// channel untouched.
+func invert(img *image.NRGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255 - img.Pix[i]
+		img.Pix[i+1] = 255 - img.Pix[i+1]
+		img.Pix[i+2] = 255 - img.Pix[i+2]
+	}
+}
+
+// duotone maps the luminance of every pixel to the gradient going from the
+// shadow to the highlight color, blending the result with the original
+// pixel by the given strength. The alpha channel is left untouched.
+func duotone(img *image.NRGBA, shadow, highlight [4]uint8, strength float64) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2])
+		luma := (0.299*r + 0.587*g + 0.114*b) / 255
+		for c := 0; c < 3; c++ {
+			tone := float64(shadow[c]) + (float64(highlight[c])-float64(shadow[c]))*luma
+			img.Pix[i+c] = clampUint8(float64(img.Pix[i+c]) + (tone-float64(img.Pix[i+c]))*strength)
+		}
+	}
+}
+
+// applyColorMatrix transforms the RGB channels of every pixel by the given
+// matrix, leaving the alpha channel untouched.
+func applyColorMatrix(img *image.NRGBA, m [3][3]float64) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2])
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = clampUint8(m[c][0]*r + m[c][1]*g + m[c][2]*b)
+		}
+	}
+}
+
+// modulateMatrix returns the saturate(saturation) matrix multiplied by the
+// hue-rotate(hue) one.
+func modulateMatrix(saturation, hue float64) [3][3]float64 {
+	s := saturation
+	sat := [3][3]float64{
+		{0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s},
+		{0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s},
+	}
+
+	sin, cos := math.Sincos(hue * math.Pi / 180)
+	rot := [3][3]float64{
+		{0.213 + cos*0.787 - sin*0.213, 0.715 - cos*0.715 - sin*0.715, 0.072 - cos*0.072 + sin*0.928},
+		{0.213 - cos*0.213 + sin*0.143, 0.715 + cos*0.285 + sin*0.140, 0.072 - cos*0.072 - sin*0.283},
+		{0.213 - cos*0.213 - sin*0.787, 0.715 - cos*0.715 + sin*0.715, 0.072 + cos*0.928 + sin*0.072},
+	}
+
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += sat[i][k] * rot[k][j]
+			}
+		}
+	}
+	return m
+}
+
+// clampUint8 rounds the value to the nearest uint8.
+func clampUint8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
@@ -0,0 +1,268 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestPixelate(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{R: 100, A: 255})
+		img.SetNRGBA(x, 1, color.NRGBA{R: 200, A: 255})
+	}
+
+	pixelate(img, image.Rect(0, 0, 2, 2), 2)
+
+	for _, p := range []image.Point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		if c := img.NRGBAAt(p.X, p.Y); c.R != 150 {
+			t.Errorf("Expected block average at %v, got %v", p, c)
+		}
+	}
+	if c := img.NRGBAAt(3, 0); c.R != 100 {
+		t.Errorf("Expected pixels outside the area to be untouched, got %v", c)
+	}
+}
+
+func TestBlurRect(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+	img.SetNRGBA(4, 4, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	img.SetNRGBA(8, 8, color.NRGBA{R: 255, A: 255})
+
+	blurRect(img, image.Rect(0, 0, 8, 8), 2)
+
+	if c := img.NRGBAAt(4, 4); c.R == 0 || c.R == 255 {
+		t.Errorf("Expected the bright pixel to be spread, got %v", c)
+	}
+	if c := img.NRGBAAt(3, 4); c.R == 0 {
+		t.Errorf("Expected neighbour pixels to be blurred, got %v", c)
+	}
+	if c := img.NRGBAAt(8, 8); c.R != 255 {
+		t.Errorf("Expected pixels outside the area to be untouched, got %v", c)
+	}
+}
+
+func TestAddFrame(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	fillRect(img, img.Rect, [4]uint8{10, 20, 30, 255})
+
+	framed, err := addFrame(img, 1, 2, 3, 4, [4]uint8{255, 255, 255, 255})
+	if err != nil {
+		t.Fatalf("Cannot add frame: %s", err)
+	}
+	if framed.Rect != image.Rect(0, 0, 6, 8) {
+		t.Fatalf("Invalid framed size: %v", framed.Rect)
+	}
+	if c := framed.NRGBAAt(0, 0); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("Invalid frame color: %v", c)
+	}
+	if c := framed.NRGBAAt(1, 2); c != (color.NRGBA{10, 20, 30, 255}) {
+		t.Errorf("Expected the image to be placed after the frame, got %v", c)
+	}
+
+	if _, err := addFrame(img, 1, 1, 1, 1<<40, [4]uint8{}); err != ErrResolutionTooBig {
+		t.Errorf("Expected the frame to exceed the resolution limit: %v", err)
+	}
+}
+
+func TestVignette(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	fillRect(img, img.Rect, [4]uint8{200, 200, 200, 255})
+
+	vignette(img, 1)
+
+	if c := img.NRGBAAt(50, 50); c.R != 200 {
+		t.Errorf("Expected the center to be untouched, got %v", c)
+	}
+	if c := img.NRGBAAt(0, 0); c.R > 10 || c.A != 255 {
+		t.Errorf("Expected corners to be darkened, got %v", c)
+	}
+}
+
+func TestModulate(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 40, B: 40, A: 128})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 90, G: 90, B: 90, A: 255})
+
+	modulate(img, 1, 0)
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 200, G: 40, B: 40, A: 128}) {
+		t.Errorf("Expected identity modulation to keep the pixel, got %v", c)
+	}
+
+	modulate(img, 1, 120)
+	if c := img.NRGBAAt(0, 0); c.G <= c.R || c.G <= c.B || c.A != 128 {
+		t.Errorf("Expected red to be rotated towards green, got %v", c)
+	}
+	if c := img.NRGBAAt(1, 0); c != (color.NRGBA{R: 90, G: 90, B: 90, A: 255}) {
+		t.Errorf("Expected gray pixels to keep their color, got %v", c)
+	}
+
+	modulate(img, 0, 0)
+	if c := img.NRGBAAt(0, 0); c.R != c.G || c.G != c.B {
+		t.Errorf("Expected zero saturation to result in gray, got %v", c)
+	}
+}
+
+func TestSepia(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 200})
+
+	sepia(img, 0)
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 100, G: 100, B: 100, A: 200}) {
+		t.Errorf("Expected zero strength to keep the pixel, got %v", c)
+	}
+
+	sepia(img, 1)
+	if c := img.NRGBAAt(0, 0); c.R <= c.G || c.G <= c.B || c.A != 200 {
+		t.Errorf("Expected a warm brown tone, got %v", c)
+	}
+}
+
+func TestDuotone(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 100})
+
+	shadow, highlight := [4]uint8{20, 0, 80, 255}, [4]uint8{255, 200, 0, 255}
+	duotone(img, shadow, highlight, 1)
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 20, G: 0, B: 80, A: 255}) {
+		t.Errorf("Expected black to map to the shadow color, got %v", c)
+	}
+	if c := img.NRGBAAt(1, 0); c != (color.NRGBA{R: 255, G: 200, B: 0, A: 100}) {
+		t.Errorf("Expected white to map to the highlight color, got %v", c)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 100, B: 0, A: 50})
+
+	invert(img)
+
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 0, G: 155, B: 255, A: 50}) {
+		t.Errorf("Expected inverted colors with the same alpha, got %v", c)
+	}
+}
+
+func TestPadOffsets(t *testing.T) {
+	cases := []struct {
+		gravity   bimg.Gravity
+		left, top int
+	}{
+		{bimg.GravityCentre, 5, 10},
+		{bimg.GravitySmart, 5, 10},
+		{bimg.GravityNorth, 5, 0},
+		{bimg.GravitySouth, 5, 20},
+		{bimg.GravityWest, 0, 10},
+		{bimg.GravityEast, 10, 10},
+		{GravitySouthEast, 10, 20},
+		{GravityNorthWest, 0, 0},
+	}
+
+	for _, c := range cases {
+		if left, top := padOffsets(c.gravity, 10, 20); left != c.left || top != c.top {
+			t.Errorf("Invalid offsets for gravity %d: %d,%d, expected: %d,%d", c.gravity, left, top, c.left, c.top)
+		}
+	}
+}
+
+func TestRoundCorners(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	fillRect(img, img.Rect, [4]uint8{10, 20, 30, 255})
+
+	roundCorners(img, 50)
+
+	for _, p := range []image.Point{{0, 0}, {19, 0}, {0, 9}, {19, 9}} {
+		if c := img.NRGBAAt(p.X, p.Y); c.A != 0 {
+			t.Errorf("Expected transparent corner at %v, got %v", p, c)
+		}
+	}
+	for _, p := range []image.Point{{10, 0}, {10, 9}, {5, 5}, {14, 5}} {
+		if c := img.NRGBAAt(p.X, p.Y); c.A != 255 {
+			t.Errorf("Expected opaque pixel at %v, got %v", p, c)
+		}
+	}
+	if c := img.NRGBAAt(0, 2); c.A == 0 || c.A == 255 {
+		t.Errorf("Expected antialiased edge pixel, got %v", c)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0, G: 0, B: 0, A: 0})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 0, G: 100, B: 200, A: 128})
+
+	flatten(img, [4]uint8{255, 255, 255, 255})
+
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) {
+		t.Errorf("Expected transparent pixels to take the background color, got %v", c)
+	}
+	if c := img.NRGBAAt(1, 0); c != (color.NRGBA{R: 127, G: 177, B: 227, A: 255}) {
+		t.Errorf("Expected blended opaque pixel, got %v", c)
+	}
+}
+
+func TestCircleCrop(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 20))
+	fillRect(img, img.Rect, [4]uint8{10, 20, 30, 255})
+	img.SetNRGBA(12, 8, color.NRGBA{R: 255, A: 255})
+
+	circle := circleCrop(img, 15, 10, 5)
+
+	if circle.Rect.Dx() != 10 || circle.Rect.Dy() != 10 {
+		t.Fatalf("Invalid circle size: %v", circle.Rect)
+	}
+	if c := circle.NRGBAAt(2, 3); c.R != 255 {
+		t.Errorf("Expected the circle to be centered, got %v", c)
+	}
+	if c := circle.NRGBAAt(0, 0); c.A != 0 {
+		t.Errorf("Expected transparent corner, got %v", c)
+	}
+	if c := circle.NRGBAAt(5, 5); c.A != 255 {
+		t.Errorf("Expected opaque center, got %v", c)
+	}
+}
+
+func TestStrokeFrame(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 6))
+	fillRect(img, img.Rect, [4]uint8{255, 255, 255, 255})
+
+	strokeFrame(img, 2, [4]uint8{255, 0, 0, 255})
+
+	for _, p := range []image.Point{{0, 0}, {9, 5}, {1, 3}, {8, 3}, {5, 1}, {5, 4}} {
+		if c := img.NRGBAAt(p.X, p.Y); c.G != 0 {
+			t.Errorf("Expected border pixel at %v, got %v", p, c)
+		}
+	}
+	for _, p := range []image.Point{{2, 2}, {7, 3}} {
+		if c := img.NRGBAAt(p.X, p.Y); c.G != 255 {
+			t.Errorf("Expected untouched pixel at %v, got %v", p, c)
+		}
+	}
+
+	strokeFrame(img, 20, [4]uint8{0, 0, 255, 255})
+	if c := img.NRGBAAt(5, 3); c.B != 255 || c.R != 0 {
+		t.Errorf("Expected wide borders to cover the whole image, got %v", c)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// EnhanceAuto is the enhance param value applying automatic contrast and white balance.
+const EnhanceAuto = "auto"
+
+const (
+	// autoLevelsClip is the share of the darkest and brightest pixels ignored
+	// on each channel, so a few specular highlights or dead pixels don't
+	// prevent the stretch.
+	autoLevelsClip = 0.005
+	// autoLevelsMaxGain prevents amplifying noise on low contrast photos,
+	// e.g. shot in the fog, which would look unnatural stretched to the full range.
+	autoLevelsMaxGain = 3.0
+)
+
+// EXIF values tuning the automatic enhancement.
+const (
+	exifWhiteBalanceManual = 1
+	exifSceneCaptureNight  = 3
+)
+
+// enhanceInput applies the requested enhancement to the source image before
+// the operation runs. The enhanced image is handed over as lossless PNG, so
+// the output type defaults to the source one as usual.
+func enhanceInput(buf []byte, opts ImageOptions) ([]byte, ImageOptions, error) {
+	if opts.Enhance != EnhanceAuto {
+		return nil, opts, NewError("Unsupported enhance value. Allowed values are: auto", http.StatusBadRequest)
+	}
+
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return nil, opts, NewError("Cannot retrieve image metadata: "+err.Error(), http.StatusBadRequest)
+	}
+	// Night shots are meant to be dark, stretching them looks unnatural
+	if meta.EXIF.SceneCaptureType == exifSceneCaptureNight {
+		return buf, opts, nil
+	}
+
+	pixels, _, err := decodePixels(buf)
+	if err != nil {
+		return nil, opts, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+	// Keep the colors chosen by the photographer with a manual white balance
+	autoLevels(pixels, meta.EXIF.WhiteBalance != exifWhiteBalanceManual)
+
+	var out bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&out, pixels); err != nil {
+		return nil, opts, err
+	}
+
+	opts.Type = outputTypeName(buf, opts)
+	// The source orientation was applied while decoding
+	opts.NoRotation = true
+	return out.Bytes(), opts, nil
+}
+
+// autoLevels stretches the color histogram to the full range. When
+// whiteBalance is true, channels are stretched independently which also
+// neutralizes color casts, otherwise they share the same stretch.
+func autoLevels(img *image.NRGBA, whiteBalance bool) {
+	var histograms [3][256]int
+	total := 0
+
+	for y := 0; y < img.Rect.Dy(); y++ {
+		i := img.PixOffset(0, y)
+		for x := 0; x < img.Rect.Dx(); x++ {
+			// Fully transparent pixels carry no visible color
+			if img.Pix[i+3] != 0 {
+				for c := 0; c < 3; c++ {
+					histograms[c][img.Pix[i+c]]++
+				}
+				total++
+			}
+			i += 4
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	var luts [3][256]uint8
+	if whiteBalance {
+		for c := range histograms {
+			low, high := histogramBounds(histograms[c], int(float64(total)*autoLevelsClip))
+			luts[c] = stretchLUT(low, high)
+		}
+	} else {
+		var combined [256]int
+		for c := range histograms {
+			for v, count := range histograms[c] {
+				combined[v] += count
+			}
+		}
+		low, high := histogramBounds(combined, int(float64(3*total)*autoLevelsClip))
+		luts[0] = stretchLUT(low, high)
+		luts[1], luts[2] = luts[0], luts[0]
+	}
+
+	for y := 0; y < img.Rect.Dy(); y++ {
+		i := img.PixOffset(0, y)
+		for x := 0; x < img.Rect.Dx(); x++ {
+			for c := 0; c < 3; c++ {
+				img.Pix[i+c] = luts[c][img.Pix[i+c]]
+			}
+			i += 4
+		}
+	}
+}
+
+// histogramBounds returns the lowest and highest values once the given
+// amount of pixels is ignored at both ends of the histogram.
+func histogramBounds(histogram [256]int, clip int) (int, int) {
+	low, count := 0, 0
+	for ; low < 255; low++ {
+		if count += histogram[low]; count > clip {
+			break
+		}
+	}
+
+	high := 255
+	for count = 0; high > 0; high-- {
+		if count += histogram[high]; count > clip {
+			break
+		}
+	}
+	return low, high
+}
+
+// stretchLUT builds the lookup table mapping [low, high] to [0, 255], its
+// gain being limited by autoLevelsMaxGain around the middle of the range.
+func stretchLUT(low, high int) [256]uint8 {
+	var lut [256]uint8
+
+	lowF, highF := float64(low), float64(high)
+	if minRange := 255 / autoLevelsMaxGain; highF-lowF < minRange {
+		mid := (lowF + highF) / 2
+		lowF, highF = mid-minRange/2, mid+minRange/2
+	}
+
+	for v := range lut {
+		stretched := (float64(v) - lowF) * 255 / (highF - lowF)
+		lut[v] = uint8(min(max(stretched, 0), 255) + 0.5)
+	}
+	return lut
+}
@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestAutoLevels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	// Low contrast image with a blue cast
+	fillRect(img, image.Rect(0, 0, 1, 1), [4]uint8{60, 60, 100, 255})
+	fillRect(img, image.Rect(1, 0, 2, 1), [4]uint8{160, 160, 200, 255})
+
+	autoLevels(img, true)
+
+	dark, light := img.NRGBAAt(0, 0), img.NRGBAAt(1, 0)
+	if dark.R != 0 || dark.B != 0 || light.R != 255 || light.B != 255 {
+		t.Errorf("Expected channels to be stretched independently, got %v and %v", dark, light)
+	}
+}
+
+func TestAutoLevelsKeepsColorBalance(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	fillRect(img, image.Rect(0, 0, 1, 1), [4]uint8{60, 60, 100, 255})
+	fillRect(img, image.Rect(1, 0, 2, 1), [4]uint8{160, 160, 200, 255})
+
+	autoLevels(img, false)
+
+	dark, light := img.NRGBAAt(0, 0), img.NRGBAAt(1, 0)
+	if dark.R != 0 || light.B != 255 || dark.B <= dark.R || light.B <= light.R {
+		t.Errorf("Expected channels to share the same stretch, got %v and %v", dark, light)
+	}
+}
+
+func TestStretchLUT(t *testing.T) {
+	lut := stretchLUT(50, 200)
+	if lut[50] != 0 || lut[200] != 255 || lut[0] != 0 || lut[255] != 255 {
+		t.Errorf("Invalid stretch bounds: %d %d", lut[50], lut[200])
+	}
+
+	// Gain is limited for very low contrast images
+	lut = stretchLUT(120, 130)
+	if lut[120] == 0 || lut[130] == 255 {
+		t.Errorf("Expected the gain to be limited: %d %d", lut[120], lut[130])
+	}
+}
+
+func TestHistogramBounds(t *testing.T) {
+	var histogram [256]int
+	histogram[0] = 1
+	histogram[10] = 100
+	histogram[240] = 100
+	histogram[255] = 1
+
+	if low, high := histogramBounds(histogram, 2); low != 10 || high != 240 {
+		t.Errorf("Expected outliers to be clipped: %d %d", low, high)
+	}
+}
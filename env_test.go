@@ -0,0 +1,69 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestApplyEnvFlags(t *testing.T) {
+	fs := flag.NewFlagSet("imaginary", flag.ContinueOnError)
+	port := fs.Int("p", 9000, "")
+	size := fs.Int("max-allowed-size", 0, "")
+	cors := fs.Bool("cors", false, "")
+	key := fs.String("key", "", "")
+	_ = fs.Parse([]string{"-p", "8080"})
+
+	env := map[string]string{
+		"IMAGINARY_P":                "7000",
+		"IMAGINARY_MAX_ALLOWED_SIZE": "1024",
+		"IMAGINARY_CORS":             "true",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	if err := applyEnvFlags(fs, lookup); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if *port != 8080 {
+		t.Errorf("Expected command line flags to take precedence, got port %d", *port)
+	}
+	if *size != 1024 || !*cors {
+		t.Errorf("Expected flags to be set from the environment, got %d and %t", *size, *cors)
+	}
+	if *key != "" {
+		t.Errorf("Expected flags without environment variable to keep their default, got %q", *key)
+	}
+
+	fs = flag.NewFlagSet("imaginary", flag.ContinueOnError)
+	fs.Bool("cors", false, "")
+	env["IMAGINARY_CORS"] = "maybe"
+	if err := applyEnvFlags(fs, lookup); err == nil {
+		t.Error("Expected invalid environment variable to result in an error")
+	}
+}
+
+func TestFlagEnvName(t *testing.T) {
+	if name := flagEnvName("max-allowed-resolution"); name != "IMAGINARY_MAX_ALLOWED_RESOLUTION" {
+		t.Errorf("Invalid environment variable name: %s", name)
+	}
+}
@@ -37,17 +37,44 @@ var (
 	ErrEmptyBody            = NewError("Empty or unreadable image", http.StatusBadRequest)
 	ErrMissingParamFile     = NewError("Missing required param: file", http.StatusBadRequest)
 	ErrInvalidFilePath      = NewError("Invalid file path", http.StatusBadRequest)
+	ErrFileNotAllowed       = NewError("File not allowed by the mount allowlist", http.StatusForbidden)
 	ErrInvalidImageURL      = NewError("Invalid image URL", http.StatusBadRequest)
 	ErrMissingImageSource   = NewError("Cannot process the image due to missing or invalid params", http.StatusBadRequest)
 	ErrNotImplemented       = NewError("Not implemented endpoint", http.StatusNotImplemented)
 	ErrInvalidURLSignature  = NewError("Invalid URL signature", http.StatusBadRequest)
 	ErrURLSignatureMismatch = NewError("URL signature mismatch", http.StatusForbidden)
 	ErrResolutionTooBig     = NewError("Image resolution is too big", http.StatusUnprocessableEntity)
+	ErrNegativeDimensions   = NewError("Invalid params: width and height must not be negative", http.StatusBadRequest)
+	ErrNegativeBorder       = NewError("Invalid param: border must be positive", http.StatusBadRequest)
+	ErrUnsupportedArchive   = NewError("Unsupported archive format. Allowed values are: tar, tar.gz, zip, multipart", http.StatusBadRequest) //nolint:lll
+	ErrMissingOperation     = NewError("Missing required param: operation", http.StatusBadRequest)
+	ErrLogLevelForbidden    = NewError("Changing the log level requires an API key (-key flag)", http.StatusForbidden)
+	ErrProcessingTimeout    = NewError("Image processing timed out", http.StatusGatewayTimeout)
+	ErrProcessingBusy       = NewError("Too many timed out operations still running", http.StatusServiceUnavailable)
+	ErrOriginBusy           = NewError("Too many requests queued for the image origin host", http.StatusServiceUnavailable)
+	ErrTooManyBatchFiles    = NewError("Maximum allowed batch files exceeded", http.StatusBadRequest)
+	ErrBatchURLsDisabled    = NewError("Invalid param: urls requires the -enable-url-source flag", http.StatusBadRequest)
+	ErrFaceGravityDisabled  = NewError("Face gravity requires the -enable-face-detection flag", http.StatusBadRequest)
+	ErrBandwidthExceeded    = NewError("Daily bandwidth quota exceeded", http.StatusTooManyRequests)
+	ErrLowMemory            = NewError("Server low on memory, try a smaller image", http.StatusServiceUnavailable)
+	ErrStagingFull          = NewError("Temporary files quota exceeded, try again later", http.StatusServiceUnavailable)
+	ErrAsyncUnsupported     = NewError("Invalid params: async and callback are not supported here", http.StatusBadRequest)
+	ErrLossyWebP444         = NewError("Unsupported chroma value: lossy WebP images are always 420", http.StatusBadRequest)
+	ErrEncodeFailed         = NewError("Cannot encode the image in the requested format", http.StatusNotAcceptable)
 )
 
 type Error struct {
-	Message string `json:"message,omitempty"`
-	Code    int    `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Code    int         `json:"status"`
+	Param   *ParamError `json:"param,omitempty"`
+}
+
+// ParamError details an invalid pipeline operation param, so API consumers
+// can fix payloads programmatically.
+type ParamError struct {
+	Step     int    `json:"step"`
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
 }
 
 func (e Error) JSON() []byte {
@@ -40,3 +40,13 @@ func TestDefaultError(t *testing.T) {
 		t.Fatalf("Invalid JSON output: %s", json)
 	}
 }
+
+func TestParamErrorJSON(t *testing.T) {
+	err := Error{Message: "invalid width", Code: 400, Param: &ParamError{Step: 1, Name: "width", Expected: "int"}}
+
+	json := string(err.JSON())
+	expected := `{"message":"invalid width","status":400,"param":{"step":1,"name":"width","expected":"int"}}`
+	if json != expected {
+		t.Fatalf("Invalid JSON output: %s", json)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes of the startup failures, so orchestrators can tell a
+// configuration to fix from a runtime failure, e.g. a port already in use,
+// worth a restart.
+const (
+	ExitRuntimeError = 1
+	ExitConfigError  = 2
+)
+
+// StartupError is a fatal error preventing the server from starting.
+type StartupError struct {
+	Code int
+	Err  error
+}
+
+func (e StartupError) Error() string {
+	return e.Err.Error()
+}
+
+func (e StartupError) Unwrap() error {
+	return e.Err
+}
+
+// newConfigError returns a startup error caused by an invalid configuration.
+func newConfigError(format string, args ...interface{}) StartupError {
+	return StartupError{Code: ExitConfigError, Err: fmt.Errorf(format, args...)}
+}
+
+// newRuntimeError returns a startup error caused by the environment.
+func newRuntimeError(format string, args ...interface{}) StartupError {
+	return StartupError{Code: ExitRuntimeError, Err: fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code of the error, ExitRuntimeError by default.
+func exitCode(err error) int {
+	var startupErr StartupError
+	if errors.As(err, &startupErr) {
+		return startupErr.Code
+	}
+	return ExitRuntimeError
+}
+
+// exitWithError prints the error and exits with its exit code.
+func exitWithError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,65 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStartupErrors(t *testing.T) {
+	cause := errors.New("address already in use")
+
+	cases := []struct {
+		err     error
+		code    int
+		message string
+	}{
+		{newConfigError("invalid -%s flag: %d", "concurrency", -1), ExitConfigError, "invalid -concurrency flag: -1"},
+		{newRuntimeError("HTTP(S) server error: %w", cause), ExitRuntimeError, "HTTP(S) server error: address already in use"},
+		{fmt.Errorf("wrapped: %w", newConfigError("bad")), ExitConfigError, "wrapped: bad"},
+		{cause, ExitRuntimeError, "address already in use"},
+	}
+
+	for _, c := range cases {
+		if code := exitCode(c.err); code != c.code {
+			t.Errorf("Invalid exit code for %q: %d, expected: %d", c.err, code, c.code)
+		}
+		if c.err.Error() != c.message {
+			t.Errorf("Invalid error message: %q, expected: %q", c.err, c.message)
+		}
+	}
+
+	if !errors.Is(newRuntimeError("HTTP(S) server error: %w", cause), cause) {
+		t.Error("Expected startup errors to wrap their cause")
+	}
+}
+
+func TestNewConfigErrors(t *testing.T) {
+	err := newConfigErrors([]error{errors.New("first"), errors.New("second")})
+
+	if err.Code != ExitConfigError {
+		t.Errorf("Invalid exit code: %d", err.Code)
+	}
+	if !strings.Contains(err.Error(), "  - first\n  - second") {
+		t.Errorf("Expected every problem to be listed, got: %s", err)
+	}
+}
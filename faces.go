@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	_ "embed"
+	"math"
+
+	pigo "github.com/esimov/pigo/core"
+	"github.com/h2non/bimg"
+)
+
+// GravityFace keeps the detected faces in frame. It is resolved by imaginary
+// itself and falls back to the smart gravity when passed down to libvips.
+const GravityFace bimg.Gravity = -1
+
+const (
+	// faceDetectionSize is the size images are reduced to before detecting
+	// faces, bounding the CPU cost regardless of the source resolution.
+	faceDetectionSize = 640
+	// minFaceSize is the size, in pixels of the reduced image, under which
+	// faces are ignored.
+	minFaceSize = 20
+	// minFaceQuality is the detection score under which a face is discarded
+	// as a false positive.
+	minFaceQuality = 5
+)
+
+// faceCascade is the face classification cascade shipped with pigo.
+//
+//go:embed cascade/facefinder
+var faceCascade []byte
+
+// faceDetector holds the face classifier. It is nil, hence disabled, unless
+// the -enable-face-detection flag is set.
+var faceDetector *pigo.Pigo
+
+// LoadFaceDetector enables the face detection when configured.
+func LoadFaceDetector(o ServerOptions) {
+	if !o.FaceDetection {
+		return
+	}
+
+	classifier, err := pigo.NewPigo().Unpack(faceCascade)
+	if err != nil {
+		exitWithError(newRuntimeError("cannot load the face detection cascade: %w", err))
+	}
+	faceDetector = classifier
+}
+
+// detectFaces returns the focal point centered on the faces found in the
+// image, as fractions of its dimensions, or false if none was found.
+func detectFaces(buf []byte, o ImageOptions) (float64, float64, bool, error) {
+	size, err := bimg.Size(buf)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	opts := bimg.Options{Type: bimg.PNG, NoAutoRotate: o.NoRotation}
+	if size.Width >= size.Height && size.Width > faceDetectionSize {
+		opts.Width = faceDetectionSize
+	} else if size.Height > faceDetectionSize {
+		opts.Height = faceDetectionSize
+	}
+
+	reduced, err := bimg.Resize(buf, opts)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	pixels, _, err := decodePixels(reduced)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	width, height := pixels.Rect.Dx(), pixels.Rect.Dy()
+	gray := make([]uint8, 0, width*height)
+	for _, v := range luminance(pixels) {
+		gray = append(gray, uint8(v))
+	}
+
+	detections := faceDetector.RunCascade(pigo.CascadeParams{
+		MinSize:     minFaceSize,
+		MaxSize:     max(width, height),
+		ShiftFactor: 0.1,
+		ScaleFactor: 1.1,
+		ImageParams: pigo.ImageParams{Pixels: gray, Rows: height, Cols: width, Dim: width},
+	}, 0)
+	detections = faceDetector.ClusterDetections(detections, 0.2)
+
+	fx, fy, ok := facesCenter(detections)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	return fx / float64(width), fy / float64(height), true, nil
+}
+
+// facesCenter returns the center of the box bounding all the faces detected
+// above the quality threshold.
+func facesCenter(detections []pigo.Detection) (float64, float64, bool) {
+	left, top := math.Inf(1), math.Inf(1)
+	right, bottom := math.Inf(-1), math.Inf(-1)
+	found := false
+
+	for _, d := range detections {
+		if d.Q < minFaceQuality {
+			continue
+		}
+		half := float64(d.Scale) / 2
+		left = math.Min(left, float64(d.Col)-half)
+		top = math.Min(top, float64(d.Row)-half)
+		right = math.Max(right, float64(d.Col)+half)
+		bottom = math.Max(bottom, float64(d.Row)+half)
+		found = true
+	}
+
+	if !found {
+		return 0, 0, false
+	}
+	return (left + right) / 2, (top + bottom) / 2, true
+}
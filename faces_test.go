@@ -0,0 +1,78 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"net/url"
+	"testing"
+
+	pigo "github.com/esimov/pigo/core"
+	"github.com/h2non/bimg"
+)
+
+func TestFacesCenter(t *testing.T) {
+	detections := []pigo.Detection{
+		{Row: 100, Col: 100, Scale: 40, Q: 10},
+		{Row: 200, Col: 300, Scale: 40, Q: 8},
+		// Discarded as a false positive
+		{Row: 900, Col: 900, Scale: 40, Q: 1},
+	}
+
+	fx, fy, ok := facesCenter(detections)
+	if !ok || fx != 200 || fy != 150 {
+		t.Errorf("Invalid faces center: %v, %v, %t", fx, fy, ok)
+	}
+
+	if _, _, ok := facesCenter(detections[2:]); ok {
+		t.Error("Expected low quality detections to be ignored")
+	}
+}
+
+func TestFaceGravity(t *testing.T) {
+	o, _ := buildParamsFromQuery(url.Values{"gravity": []string{"face"}})
+	if o.Gravity != GravityFace {
+		t.Errorf("Invalid gravity: %v", o.Gravity)
+	}
+	if opts := BimgOptions(o); opts.Gravity != bimg.GravitySmart {
+		t.Errorf("Expected face gravity to fall back to smart in libvips, got: %v", opts.Gravity)
+	}
+}
+
+func TestImageFaceCrop(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+	opts := ImageOptions{Width: 200, Height: 200, Gravity: GravityFace}
+
+	if _, err := Crop(buf, opts); err != ErrFaceGravityDisabled {
+		t.Errorf("Expected disabled face detection to result in an error, got: %v", err)
+	}
+
+	LoadFaceDetector(ServerOptions{FaceDetection: true})
+	defer func() { faceDetector = nil }()
+
+	for _, op := range []Operation{Crop, SmartCrop} {
+		img, err := op(buf, opts)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if assertSize(img.Body, 200, 200) != nil {
+			t.Errorf(InvalidImageSize, 200, 200)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/h2non/bimg"
+)
+
+// Font is a font file loaded from the -fonts-dir directory.
+type Font struct {
+	Family string `json:"family"`
+	File   string `json:"file"`
+}
+
+// Fonts lists the fonts available to text rendering.
+type Fonts struct {
+	Fonts    []Font   `json:"fonts"`
+	Fallback []string `json:"fallback"`
+}
+
+// fontExtensions are the extensions of the font files loaded from the
+// -fonts-dir directory.
+var fontExtensions = map[string]bool{".ttf": true, ".otf": true, ".ttc": true}
+
+// loadedFonts holds the fonts loaded from the -fonts-dir directory,
+// fontFallback the families of the -font-fallback flag, defaultFont the
+// -default-font flag and allowedFonts the lowercased families of the
+// -allowed-fonts flag.
+var (
+	loadedFonts  []Font
+	fontFallback []string
+	defaultFont  string
+	allowedFonts map[string]bool
+)
+
+// ErrInvalidText is returned for text params which are not valid UTF-8.
+var ErrInvalidText = NewError("Invalid param: text must be UTF-8 encoded", http.StatusBadRequest)
+
+// LoadFonts registers the fonts of the -fonts-dir directory to libvips, and
+// the default, allowed and fallback font families, when configured.
+func LoadFonts(o ServerOptions) {
+	fontFallback = o.FontFallback
+	defaultFont = o.DefaultFont
+	allowedFonts = nil
+	if len(o.AllowedFonts) > 0 {
+		allowedFonts = make(map[string]bool, len(o.AllowedFonts))
+		for _, family := range o.AllowedFonts {
+			allowedFonts[strings.ToLower(family)] = true
+		}
+	}
+
+	if o.FontsDir != "" {
+		fonts, err := scanFonts(o.FontsDir)
+		if err != nil {
+			exitWithError(newRuntimeError("cannot load the fonts directory: %w", err))
+		}
+		for _, font := range fonts {
+			if err := registerFont(filepath.Join(o.FontsDir, font.File)); err != nil {
+				exitWithError(newRuntimeError("cannot load the font "+font.File+": %w", err))
+			}
+		}
+		loadedFonts = fonts
+	}
+
+	// Rendering with the default and allowed fonts once makes Pango cache
+	// them, so the first requests using them skip the fontconfig lookups
+	for _, font := range preloadedFonts(o) {
+		if err := loadFont(font); err != nil {
+			exitWithError(newRuntimeError("cannot load the font "+font+": %w", err))
+		}
+	}
+}
+
+// preloadedFonts returns the Pango font descriptions loaded at startup: the
+// default font and the allowed families.
+func preloadedFonts(o ServerOptions) []string {
+	var fonts []string
+	if o.DefaultFont != "" {
+		fonts = append(fonts, o.DefaultFont)
+	}
+	for _, family := range o.AllowedFonts {
+		if !slices.Contains(fonts, family) {
+			fonts = append(fonts, family)
+		}
+	}
+	return fonts
+}
+
+// scanFonts lists the font files of the directory, sorted by family.
+func scanFonts(dir string) ([]Font, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fonts := []Font{}
+	for _, entry := range entries {
+		if entry.IsDir() || !fontExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, entry.Name())) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		family, err := fontFamily(buf)
+		if err != nil {
+			return nil, errors.New(entry.Name() + ": " + err.Error())
+		}
+		fonts = append(fonts, Font{Family: family, File: entry.Name()})
+	}
+
+	sort.Slice(fonts, func(i, j int) bool {
+		if fonts[i].Family != fonts[j].Family {
+			return fonts[i].Family < fonts[j].Family
+		}
+		return fonts[i].File < fonts[j].File
+	})
+	return fonts, nil
+}
+
+// fontFamily returns the family name of a TrueType or OpenType font, read
+// from its name table. Collections return the family of their first font.
+func fontFamily(buf []byte) (string, error) {
+	errInvalid := errors.New("invalid font file")
+	font := 0
+	if len(buf) >= 16 && string(buf[:4]) == "ttcf" {
+		// Table offsets are relative to the collection file
+		font = int(binary.BigEndian.Uint32(buf[12:]))
+	}
+	if font+12 > len(buf) {
+		return "", errInvalid
+	}
+
+	numTables := int(binary.BigEndian.Uint16(buf[font+4:]))
+	for i := 0; i < numTables; i++ {
+		record := font + 12 + i*16
+		if record+16 > len(buf) {
+			return "", errInvalid
+		}
+		if string(buf[record:record+4]) != "name" {
+			continue
+		}
+		return nameTableFamily(buf, int(binary.BigEndian.Uint32(buf[record+8:])))
+	}
+	return "", errors.New("missing font name table")
+}
+
+// nameTableFamily returns the font family of the name table starting at the
+// given offset of the font file, preferring the Windows Unicode names to the
+// Macintosh Roman ones.
+func nameTableFamily(font []byte, offset int) (string, error) {
+	if offset+6 > len(font) {
+		return "", errors.New("invalid font name table")
+	}
+	table := font[offset:]
+	count := int(binary.BigEndian.Uint16(table[2:]))
+	storage := int(binary.BigEndian.Uint16(table[4:]))
+
+	family := ""
+	for i := 0; i < count; i++ {
+		record := 6 + i*12
+		if record+12 > len(table) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(table[record:])
+		nameID := binary.BigEndian.Uint16(table[record+6:])
+		length := int(binary.BigEndian.Uint16(table[record+8:]))
+		start := storage + int(binary.BigEndian.Uint16(table[record+10:]))
+		if nameID != 1 || start+length > len(table) {
+			continue
+		}
+
+		value := table[start : start+length]
+		switch platform {
+		case 3:
+			units := make([]uint16, 0, length/2)
+			for j := 0; j+1 < len(value); j += 2 {
+				units = append(units, binary.BigEndian.Uint16(value[j:]))
+			}
+			return string(utf16.Decode(units)), nil
+		case 1:
+			if family == "" {
+				family = string(value)
+			}
+		}
+	}
+	if family == "" {
+		return "", errors.New("missing font family name")
+	}
+	return family, nil
+}
+
+// pangoStyleWords are the words of a Pango font description following its
+// family list.
+var pangoStyleWords = map[string]bool{
+	"normal": true, "roman": true, "oblique": true, "italic": true,
+	"small-caps": true, "all-small-caps": true, "petite-caps": true, "all-petite-caps": true,
+	"unicase": true, "title-caps": true,
+	"thin": true, "ultra-light": true, "ultralight": true, "extra-light": true, "extralight": true,
+	"light": true, "semi-light": true, "semilight": true, "demi-light": true, "demilight": true,
+	"book": true, "regular": true, "medium": true,
+	"semi-bold": true, "semibold": true, "demi-bold": true, "demibold": true, "bold": true,
+	"ultra-bold": true, "ultrabold": true, "extra-bold": true, "extrabold": true,
+	"heavy": true, "ultra-heavy": true, "ultraheavy": true,
+	"black": true, "ultra-black": true, "ultrablack": true, "extra-black": true, "extrablack": true,
+	"ultra-condensed": true, "ultracondensed": true, "extra-condensed": true, "extracondensed": true,
+	"condensed": true, "semi-condensed": true, "semicondensed": true,
+	"semi-expanded": true, "semiexpanded": true, "expanded": true,
+	"extra-expanded": true, "extraexpanded": true, "ultra-expanded": true, "ultraexpanded": true,
+	"not-rotated": true, "south": true, "upside-down": true, "north": true,
+	"rotated-left": true, "east": true, "rotated-right": true, "west": true,
+}
+
+// isPangoStyleWord reports whether the word of a Pango font description is a
+// style, weight, stretch, gravity, variations or size one.
+func isPangoStyleWord(word string) bool {
+	if pangoStyleWords[strings.ToLower(word)] || strings.HasPrefix(word, "@") {
+		return true
+	}
+	_, err := strconv.ParseFloat(strings.TrimSuffix(word, "px"), 64)
+	return err == nil
+}
+
+// withFontFallback appends the fallback families to the family list of the
+// Pango font description, e.g. "sans bold 12" becomes "sans,Noto Emoji bold
+// 12", so characters the requested font lacks, such as emoji or CJK ones, are
+// rendered with the first fallback font having them.
+func withFontFallback(font string, fallback []string) string {
+	if len(fallback) == 0 {
+		return font
+	}
+
+	families, style := splitFont(font)
+	if len(families) == 0 {
+		families = []string{"sans"}
+	}
+
+	families = append(families, fallback...)
+	return strings.Join(append([]string{strings.Join(families, ",")}, style...), " ")
+}
+
+// splitFont splits the Pango font description into its family list and its
+// trailing style, weight, stretch, gravity, variations and size words.
+func splitFont(font string) ([]string, []string) {
+	words := strings.Fields(font)
+	end := len(words)
+	for end > 0 && isPangoStyleWord(words[end-1]) {
+		end--
+	}
+
+	var families []string
+	for _, family := range strings.Split(strings.Join(words[:end], " "), ",") {
+		if family = strings.TrimSpace(family); family != "" {
+			families = append(families, family)
+		}
+	}
+	return families, words[end:]
+}
+
+// checkFontAllowed checks every family of the requested Pango font
+// description is allowed by the -allowed-fonts flag, so clients cannot
+// trigger arbitrary fontconfig lookups.
+func checkFontAllowed(font string) error {
+	if allowedFonts == nil {
+		return nil
+	}
+	families, _ := splitFont(font)
+	for _, family := range families {
+		if !allowedFonts[strings.ToLower(family)] {
+			return NewError("Font not allowed: "+family, http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// watermarkFont returns the Pango font description of the watermark text,
+// the -default-font one when no font is requested, with the fallback
+// families.
+func watermarkFont(font string) (string, error) {
+	if err := checkFontAllowed(font); err != nil {
+		return "", err
+	}
+	if font == "" {
+		font = defaultFont
+	}
+	if font == "" {
+		font = bimg.WatermarkFont
+	}
+	return withFontFallback(font, fontFallback), nil
+}
+
+// validateText checks the text param is valid UTF-8, which Pango requires.
+func validateText(text string) error {
+	if !utf8.ValidString(text) {
+		return ErrInvalidText
+	}
+	return nil
+}
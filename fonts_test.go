@@ -0,0 +1,179 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// testFont builds a minimal font file holding a name table with the family
+// name as Macintosh Roman and Windows Unicode names. The font starts at the
+// given offset of the file, as in collections.
+func testFont(family string, offset int) []byte {
+	utf16Family := make([]byte, 0, len(family)*2)
+	for _, unit := range utf16.Encode([]rune(family)) {
+		utf16Family = binary.BigEndian.AppendUint16(utf16Family, unit)
+	}
+	storage := append([]byte("Mac "+family), utf16Family...)
+
+	name := binary.BigEndian.AppendUint16(nil, 0)
+	name = binary.BigEndian.AppendUint16(name, 2)
+	name = binary.BigEndian.AppendUint16(name, 6+2*12)
+	records := [][6]int{
+		{1, 0, 0, 1, len(family) + 4, 0},
+		{3, 1, 0x409, 1, len(utf16Family), len(family) + 4},
+	}
+	for _, record := range records {
+		for _, value := range record {
+			name = binary.BigEndian.AppendUint16(name, uint16(value))
+		}
+	}
+	name = append(name, storage...)
+
+	font := binary.BigEndian.AppendUint32(nil, 0x00010000)
+	font = binary.BigEndian.AppendUint16(font, 1)
+	font = append(font, make([]byte, 6)...)
+	font = append(font, "name"...)
+	font = binary.BigEndian.AppendUint32(font, 0)
+	font = binary.BigEndian.AppendUint32(font, uint32(offset+28))
+	font = binary.BigEndian.AppendUint32(font, uint32(len(name)))
+	return append(font, name...)
+}
+
+func TestFontFamily(t *testing.T) {
+	if family, err := fontFamily(testFont("Noto Sans CJK JP", 0)); err != nil || family != "Noto Sans CJK JP" {
+		t.Errorf("Invalid font family: %q, %v", family, err)
+	}
+
+	collection := append([]byte("ttcf"), 0, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 16)
+	if family, err := fontFamily(append(collection, testFont("Noto Emoji", 16)...)); err != nil || family != "Noto Emoji" {
+		t.Errorf("Invalid collection font family: %q, %v", family, err)
+	}
+
+	if _, err := fontFamily([]byte("not a font")); err == nil {
+		t.Error("Expected an error with an invalid font")
+	}
+}
+
+func TestWithFontFallback(t *testing.T) {
+	fallback := []string{"Noto Emoji", "Noto Sans CJK JP"}
+	cases := map[string]string{
+		"sans bold 12":          "sans,Noto Emoji,Noto Sans CJK JP bold 12",
+		"DejaVu Sans":           "DejaVu Sans,Noto Emoji,Noto Sans CJK JP",
+		"serif, Italic 10.5px":  "serif,Noto Emoji,Noto Sans CJK JP Italic 10.5px",
+		"bold 12":               "sans,Noto Emoji,Noto Sans CJK JP bold 12",
+		"Inter semi-bold @wght": "Inter,Noto Emoji,Noto Sans CJK JP semi-bold @wght",
+	}
+
+	for font, expected := range cases {
+		if actual := withFontFallback(font, fallback); actual != expected {
+			t.Errorf("Invalid font fallback of %q: %q != %q", font, actual, expected)
+		}
+	}
+	if actual := withFontFallback("sans 10", nil); actual != "sans 10" {
+		t.Errorf("Expected the font as is without fallback: %q", actual)
+	}
+}
+
+func TestWatermarkFont(t *testing.T) {
+	defaultFont, allowedFonts = "DejaVu Sans 12", map[string]bool{"dejavu sans": true, "noto sans": true}
+	defer func() { defaultFont, allowedFonts = "", nil }()
+
+	cases := map[string]string{
+		"":                      "DejaVu Sans 12",
+		"Noto Sans bold 20":     "Noto Sans bold 20",
+		"dejavu sans,Noto Sans": "dejavu sans,Noto Sans",
+	}
+	for font, expected := range cases {
+		actual, err := watermarkFont(font)
+		if err != nil || actual != expected {
+			t.Errorf("Invalid watermark font of %q: %q != %q, error: %v", font, actual, expected, err)
+		}
+	}
+
+	for _, font := range []string{"Comic Sans 12", "Noto Sans,Comic Sans bold", "/etc/passwd"} {
+		if _, err := watermarkFont(font); err == nil {
+			t.Errorf("Expected an error with the not allowed font %q", font)
+		}
+	}
+}
+
+func TestPreloadedFonts(t *testing.T) {
+	o := ServerOptions{DefaultFont: "DejaVu Sans 12", AllowedFonts: []string{"DejaVu Sans", "Noto Sans", "DejaVu Sans"}}
+	fonts := preloadedFonts(o)
+	if len(fonts) != 3 || fonts[0] != "DejaVu Sans 12" || fonts[1] != "DejaVu Sans" || fonts[2] != "Noto Sans" {
+		t.Errorf("Invalid preloaded fonts: %v", fonts)
+	}
+	if fonts := preloadedFonts(ServerOptions{}); len(fonts) != 0 {
+		t.Errorf("Expected no preloaded fonts: %v", fonts)
+	}
+}
+
+func TestScanFonts(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "emoji.ttf"), testFont("Noto Emoji", 0), 0o600)
+	_ = os.WriteFile(filepath.Join(dir, "cjk.OTF"), testFont("Noto Sans CJK JP", 0), 0o600)
+	_ = os.WriteFile(filepath.Join(dir, "README.txt"), []byte("fonts"), 0o600)
+
+	fonts, err := scanFonts(dir)
+	if err != nil {
+		t.Fatalf("Cannot scan fonts: %s", err)
+	}
+	expected := []Font{{Family: "Noto Emoji", File: "emoji.ttf"}, {Family: "Noto Sans CJK JP", File: "cjk.OTF"}}
+	if len(fonts) != len(expected) || fonts[0] != expected[0] || fonts[1] != expected[1] {
+		t.Errorf("Invalid fonts: %+v", fonts)
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, "broken.ttf"), []byte("broken"), 0o600)
+	if _, err := scanFonts(dir); err == nil {
+		t.Error("Expected an error with an invalid font file")
+	}
+}
+
+func TestValidateText(t *testing.T) {
+	if err := validateText("© 2025 イマジナリー 🖼\nimaginary"); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if err := validateText("invalid \xff"); err != ErrInvalidText {
+		t.Errorf("Expected an error with invalid UTF-8: %v", err)
+	}
+}
+
+func TestFontsController(t *testing.T) {
+	loadedFonts, fontFallback = []Font{{Family: "Noto Emoji", File: "emoji.ttf"}}, []string{"Noto Emoji"}
+	defer func() { loadedFonts, fontFallback = nil, nil }()
+
+	res := httptest.NewRecorder()
+	fontsController(res, httptest.NewRequest(http.MethodGet, "/fonts", nil))
+
+	var fonts Fonts
+	if err := json.Unmarshal(res.Body.Bytes(), &fonts); err != nil {
+		t.Fatalf("Invalid JSON response: %s", err)
+	}
+	if len(fonts.Fonts) != 1 || fonts.Fonts[0].Family != "Noto Emoji" || len(fonts.Fallback) != 1 {
+		t.Errorf("Invalid fonts response: %s", res.Body.String())
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"math"
+	"net/http"
+)
+
+// Patterns supported by Generate.
+const (
+	PatternSolid        = "solid"
+	PatternLinear       = "linear"
+	PatternRadial       = "radial"
+	PatternCheckerboard = "checkerboard"
+)
+
+// DefaultGradientAngle draws linear gradients from top to bottom.
+const DefaultGradientAngle = 180
+
+// DefaultCheckerboardSize is the default checkerboard square size, in pixels.
+const DefaultCheckerboardSize = 16
+
+var (
+	defaultGenerateColor      = [4]uint8{204, 204, 204, 255}
+	defaultGenerateBackground = [4]uint8{255, 255, 255, 255}
+)
+
+// @Summary Generate image
+// @Description Generates a solid color, gradient or checkerboard image, e.g. to be used as placeholder
+// @Produce image/*
+// @Param width query int true "Width of the output image"
+// @Param height query int true "Height of the output image"
+// @Param pattern query string false "Pattern (solid, linear, radial, checkerboard)"
+// @Param color query string false "RGB main color (default 204,204,204)"
+// @Param background query string false "RGB secondary color of gradients and checkerboards (default 255,255,255)"
+// @Param angle query int false "Linear gradient direction in degrees, CSS-like (default 180, top to bottom)"
+// @Param blocksize query int false "Checkerboard square size in pixels (default 16)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Generated image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /generate [get]
+func Generate(_ []byte, o ImageOptions) (Image, error) {
+	if o.Width == 0 || o.Height == 0 {
+		return Image{}, NewError("Missing required params: height and width", http.StatusBadRequest)
+	}
+	if o.Width < 0 || o.Height < 0 {
+		return Image{}, ErrNegativeDimensions
+	}
+	if o.BlockSize < 0 {
+		return Image{}, NewError("Invalid param: blocksize must be positive", http.StatusBadRequest)
+	}
+
+	from := opaqueColor(o.Color, defaultGenerateColor)
+	to := opaqueColor(o.Background, defaultGenerateBackground)
+	img := image.NewNRGBA(image.Rect(0, 0, o.Width, o.Height))
+
+	switch o.Pattern {
+	case "", PatternSolid:
+		fillRect(img, img.Rect, from)
+	case PatternLinear:
+		angle := DefaultGradientAngle
+		if o.IsDefinedField.Angle {
+			angle = o.Angle
+		}
+		linearGradient(img, float64(angle), from, to)
+	case PatternRadial:
+		radialGradient(img, from, to)
+	case PatternCheckerboard:
+		size := o.BlockSize
+		if size == 0 {
+			size = DefaultCheckerboardSize
+		}
+		checkerboard(img, size, from, to)
+	default:
+		return Image{}, NewError("Unsupported pattern. Allowed values are: solid, linear, radial, checkerboard", http.StatusBadRequest)
+	}
+
+	return encodePixels(img, outputType(nil, o), o)
+}
+
+// linearGradient paints a gradient along the given angle, following the CSS
+// convention: 0 goes from bottom to top, 90 from left to right and so on.
+func linearGradient(img *image.NRGBA, angle float64, from, to [4]uint8) {
+	width, height := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	// Gradient line long enough for the corners to get the pure colors
+	length := math.Abs(width*sin) + math.Abs(height*cos)
+
+	paint(img, func(x, y float64) float64 {
+		return 0.5 + ((x-width/2)*sin-(y-height/2)*cos)/length
+	}, from, to)
+}
+
+// radialGradient paints a gradient from the image center to its corners.
+func radialGradient(img *image.NRGBA, from, to [4]uint8) {
+	cx, cy := float64(img.Rect.Dx())/2, float64(img.Rect.Dy())/2
+	radius := math.Hypot(cx, cy)
+
+	paint(img, func(x, y float64) float64 {
+		return math.Hypot(x-cx, y-cy) / radius
+	}, from, to)
+}
+
+// checkerboard paints alternating size x size squares.
+func checkerboard(img *image.NRGBA, size int, from, to [4]uint8) {
+	for y := 0; y < img.Rect.Dy(); y += size {
+		for x := 0; x < img.Rect.Dx(); x += size {
+			color := from
+			if (x/size+y/size)%2 == 1 {
+				color = to
+			}
+			fillRect(img, image.Rect(x, y, x+size, y+size).Intersect(img.Rect), color)
+		}
+	}
+}
+
+// paint interpolates both colors at every pixel center, position returning
+// the gradient progress from 0 to 1.
+func paint(img *image.NRGBA, position func(x, y float64) float64, from, to [4]uint8) {
+	for y := 0; y < img.Rect.Dy(); y++ {
+		i := img.PixOffset(0, y)
+		for x := 0; x < img.Rect.Dx(); x++ {
+			t := math.Min(math.Max(position(float64(x)+0.5, float64(y)+0.5), 0), 1)
+			for c := 0; c < 4; c++ {
+				img.Pix[i+c] = uint8(float64(from[c]) + (float64(to[c])-float64(from[c]))*t + 0.5)
+			}
+			i += 4
+		}
+	}
+}
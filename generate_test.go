@@ -0,0 +1,104 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLinearGradient(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 100))
+	linearGradient(img, DefaultGradientAngle, [4]uint8{0, 0, 0, 255}, [4]uint8{200, 200, 200, 255})
+
+	top, bottom := img.NRGBAAt(5, 0), img.NRGBAAt(5, 99)
+	if top.R > 2 || bottom.R < 198 {
+		t.Errorf("Expected a top to bottom gradient, got %v and %v", top, bottom)
+	}
+	if left, right := img.NRGBAAt(0, 50), img.NRGBAAt(9, 50); left != right {
+		t.Errorf("Expected rows to share the same color, got %v and %v", left, right)
+	}
+
+	linearGradient(img, 90, [4]uint8{0, 0, 0, 255}, [4]uint8{200, 200, 200, 255})
+	if left, right := img.NRGBAAt(0, 50), img.NRGBAAt(9, 50); left.R >= right.R {
+		t.Errorf("Expected a left to right gradient, got %v and %v", left, right)
+	}
+}
+
+func TestRadialGradient(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	radialGradient(img, [4]uint8{255, 255, 255, 255}, [4]uint8{0, 0, 0, 255})
+
+	if center, corner := img.NRGBAAt(50, 50), img.NRGBAAt(0, 0); center.R < 250 || corner.R > 5 {
+		t.Errorf("Expected a center to corners gradient, got %v and %v", center, corner)
+	}
+}
+
+func TestCheckerboard(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	checkerboard(img, 10, [4]uint8{255, 0, 0, 255}, [4]uint8{0, 0, 255, 255})
+
+	if c := img.NRGBAAt(0, 0); c.R != 255 {
+		t.Errorf("Invalid first square color: %v", c)
+	}
+	if c := img.NRGBAAt(15, 0); c.B != 255 {
+		t.Errorf("Invalid second square color: %v", c)
+	}
+	if c := img.NRGBAAt(15, 15); c.R != 255 {
+		t.Errorf("Invalid diagonal square color: %v", c)
+	}
+}
+
+func TestGenerateControllerErrors(t *testing.T) {
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"width=100&height=100&pattern=stripes", http.StatusBadRequest},
+		{"width=100&height=100&type=bmp", http.StatusBadRequest},
+		{"width=10000&height=10000", http.StatusUnprocessableEntity},
+		{"width=100&height=100&async=true", http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/generate?"+tc.query, nil)
+		w := httptest.NewRecorder()
+		generatorController(ServerOptions{MaxAllowedPixels: 18.0}, Generate)(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("invalid response status for %q: %d", tc.query, w.Code)
+		}
+	}
+}
+
+func TestGenerateInvalidSize(t *testing.T) {
+	cases := []ImageOptions{
+		{Width: -100, Height: 100},
+		{Width: 100, Height: -100000},
+		{Width: 100, Height: 100, Pattern: PatternCheckerboard, BlockSize: -1},
+	}
+	for _, opts := range cases {
+		if _, err := Generate(nil, opts); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
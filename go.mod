@@ -5,6 +5,7 @@ go 1.24.2
 
 require (
 	github.com/bytedance/gopkg v0.1.2
+	github.com/esimov/pigo v1.4.6
 	github.com/h2non/bimg v1.1.9
 	github.com/h2non/filetype v1.1.3
 	github.com/prometheus/client_golang v1.22.0
@@ -13,6 +14,7 @@ require (
 	github.com/swaggo/http-swagger v1.3.4
 	github.com/swaggo/swag v1.16.4
 	github.com/throttled/throttled/v2 v2.13.0
+	golang.org/x/net v0.39.0
 	golang.org/x/sys v0.33.0
 )
 
@@ -40,7 +42,6 @@ require (
 	go.uber.org/mock v0.5.2 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	golang.org/x/tools v0.32.0 // indirect
@@ -0,0 +1,142 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+// goldenSSIM is the minimum similarity of the outputs to their goldens, so
+// small encoder changes pass while visible drifts fail.
+const goldenSSIM = 0.95
+
+var updateGolden = flag.Bool("update-golden", false, "Regenerate the golden images of the regression tests")
+
+// goldenOperations are the operations rendered by the golden image tests,
+// each one in every golden format.
+var goldenOperations = []struct {
+	name      string
+	operation Operation
+	opts      ImageOptions
+}{
+	{"resize", Resize, ImageOptions{Width: 300}},
+	{"fit", Fit, ImageOptions{Width: 300, Height: 300}},
+	{"crop", Crop, ImageOptions{Width: 300, Height: 200}},
+	{"smartcrop", SmartCrop, ImageOptions{Width: 300, Height: 200}},
+	{"thumbnail", Thumbnail, ImageOptions{Width: 100}},
+	{"rotate", Rotate, ImageOptions{Rotate: 90}},
+	{"flip", Flip, ImageOptions{}},
+	{"blur", GaussianBlur, ImageOptions{Sigma: 5}},
+	{"sharpen", Sharpen, ImageOptions{Sigma: 1.5}},
+	{"grayscale", Grayscale, ImageOptions{}},
+	{"sepia", Sepia, ImageOptions{}},
+	{"pixelate", Pixelate, ImageOptions{BlockSize: 16}},
+	{"rounded", Rounded, ImageOptions{Radius: 40}},
+}
+
+var goldenFormats = []string{JPEG, PNG, WebP, AVIF}
+
+// TestGolden renders the golden operations in every format and compares the
+// decoded outputs to the golden images of testdata/golden, catching output
+// drifts across libvips upgrades. Run it with -update-golden to regenerate
+// the goldens after an expected change.
+func TestGolden(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	for _, format := range goldenFormats {
+		if !bimg.IsTypeSupportedSave(ImageType(format)) {
+			continue
+		}
+		for _, golden := range goldenOperations {
+			name := golden.name + "_" + format
+			t.Run(name, func(t *testing.T) {
+				opts := golden.opts
+				opts.Type = format
+				img, err := golden.operation(buf, opts)
+				if err != nil {
+					t.Fatalf(CannotProcessImageS, err)
+				}
+				assertGolden(t, filepath.Join("testdata", "golden", name+".png"), img.Body)
+			})
+		}
+	}
+}
+
+// assertGolden compares the image to the golden one, stored as lossless PNG
+// so every output format shares the same comparison, or overwrites the golden
+// with -update-golden.
+func assertGolden(t *testing.T, path string, buf []byte) {
+	t.Helper()
+	pixels, _, err := decodeImagePixels(buf)
+	if err != nil {
+		t.Fatalf("Cannot decode image pixels: %v", err)
+	}
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Create(path) //nolint:gosec
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = file.Close() }()
+		if err := png.Encode(file, pixels); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(path) //nolint:gosec
+	if os.IsNotExist(err) {
+		t.Fatalf("Missing golden image %s, run make golden to generate it", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	expected, _, err := decodeImagePixels(mustReadAll(t, file))
+	if err != nil {
+		t.Fatalf("Cannot decode golden image: %v", err)
+	}
+
+	if pixels.Rect.Size() != expected.Rect.Size() {
+		t.Fatalf("Invalid image size: %v, golden: %v", pixels.Rect.Size(), expected.Rect.Size())
+	}
+	width, height := pixels.Rect.Dx(), pixels.Rect.Dy()
+	if similarity := ssim(lumaPlane(pixels), lumaPlane(expected), width, height); similarity < goldenSSIM {
+		t.Errorf("Image drifted from its golden, SSIM: %.4f, expected at least %.2f", similarity, goldenSSIM)
+	}
+}
+
+func mustReadAll(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
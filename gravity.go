@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import "github.com/h2non/bimg"
+
+// Corner gravities, resolved by imaginary itself as libvips only supports
+// the edges. When passed down to libvips, they fall back to their vertical
+// edge.
+const (
+	GravityNorthEast bimg.Gravity = -2
+	GravitySouthEast bimg.Gravity = -3
+	GravitySouthWest bimg.Gravity = -4
+	GravityNorthWest bimg.Gravity = -5
+)
+
+// isCornerGravity reports whether the gravity is a corner one.
+func isCornerGravity(gravity bimg.Gravity) bool {
+	switch gravity {
+	case GravityNorthEast, GravitySouthEast, GravitySouthWest, GravityNorthWest:
+		return true
+	default:
+		return false
+	}
+}
+
+// gravityAnchor returns the point of the image the gravity anchors to, from
+// 0 (left or top) to 1 (right or bottom). Content aware gravities anchor to
+// the center.
+func gravityAnchor(gravity bimg.Gravity) (fx, fy float64) {
+	fx, fy = 0.5, 0.5
+	switch gravity {
+	case bimg.GravityNorth, GravityNorthEast, GravityNorthWest:
+		fy = 0
+	case bimg.GravitySouth, GravitySouthEast, GravitySouthWest:
+		fy = 1
+	}
+	switch gravity {
+	case bimg.GravityWest, GravityNorthWest, GravitySouthWest:
+		fx = 0
+	case bimg.GravityEast, GravityNorthEast, GravitySouthEast:
+		fx = 1
+	}
+	return fx, fy
+}
+
+// libvipsGravity returns the gravity libvips supports closest to the given
+// one.
+func libvipsGravity(gravity bimg.Gravity) bimg.Gravity {
+	switch gravity {
+	case GravityFace:
+		return bimg.GravitySmart
+	case GravityNorthEast, GravityNorthWest:
+		return bimg.GravityNorth
+	case GravitySouthEast, GravitySouthWest:
+		return bimg.GravitySouth
+	default:
+		return gravity
+	}
+}
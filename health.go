@@ -29,6 +29,7 @@ var start = time.Now()
 const MB float64 = 1.0 * 1024 * 1024
 
 type HealthStats struct {
+	Schema               int     `json:"schema"`
 	Uptime               int64   `json:"uptime"`
 	AllocatedMemory      float64 `json:"allocatedMemory"`
 	TotalAllocatedMemory float64 `json:"totalAllocatedMemory"`
@@ -46,6 +47,7 @@ func GetHealthStats() *HealthStats {
 	runtime.ReadMemStats(mem)
 
 	return &HealthStats{
+		Schema:               HealthSchemaVersion,
 		Uptime:               GetUptime(),
 		AllocatedMemory:      toMegaBytes(mem.Alloc),
 		TotalAllocatedMemory: toMegaBytes(mem.TotalAlloc),
@@ -0,0 +1,167 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	HEIF      = "heif"
+	ImageHEIF = "image/heif"
+)
+
+// Chroma subsampling values supported by the HEIF encoder.
+const (
+	Chroma420 = "420"
+	Chroma444 = "444"
+)
+
+// libvips VipsForeignSubsample values.
+const (
+	subsampleAuto = 0
+	subsampleOn   = 1
+	subsampleOff  = 2
+)
+
+// DefaultHEIFEffort is the libvips default HEIF CPU effort, matching speed 5.
+const DefaultHEIFEffort = 4
+
+// MaxHEIFSpeed is the fastest HEIF encoding speed.
+const MaxHEIFSpeed = 9
+
+// MaxHEIFEffort is the slowest HEIF encoding CPU effort.
+const MaxHEIFEffort = 9
+
+// LoadHEIFSupport reports at startup whether libvips is built with HEIF
+// support, HEIF output being rejected otherwise.
+func LoadHEIFSupport() {
+	switch {
+	case !bimg.IsTypeSupported(bimg.HEIF):
+		logf(LogLevelWarning, "libvips is built without HEIF support, HEIF images are not supported")
+	case !bimg.IsTypeSupportedSave(bimg.HEIF):
+		logf(LogLevelWarning, "libvips is built without HEIF encoder, HEIF output is not supported")
+	}
+}
+
+// isHEIFOutput reports whether HEIF output is requested.
+func isHEIFOutput(opts ImageOptions) bool {
+	return ImageType(opts.Type) == bimg.HEIF
+}
+
+// isTunedHEIFOutput reports whether HEIF or AVIF output is requested with
+// encoder options bimg does not support, so the image is encoded by imaginary.
+// bimg supports the AVIF speed.
+func isTunedHEIFOutput(opts ImageOptions) bool {
+	tuned := opts.Chroma != "" || opts.IsDefinedField.Effort || opts.BitDepth != 0
+	switch ImageType(opts.Type) {
+	case bimg.HEIF:
+		return tuned || opts.Speed != 0
+	case bimg.AVIF:
+		return tuned
+	default:
+		return false
+	}
+}
+
+// EncodeHEIF encodes the PNG image produced by the operation as HEIF, or as
+// AVIF, with the requested speed or effort, bit depth and chroma subsampling.
+// Other outputs, such as JSON ones, are returned as is.
+func EncodeHEIF(img Image, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	subsample, err := chromaSubsample(o.Chroma)
+	if err != nil {
+		return Image{}, err
+	}
+	effort, err := heifEffort(o)
+	if err != nil {
+		return Image{}, err
+	}
+	if o.BitDepth != 0 && o.BitDepth != 8 && o.BitDepth != 10 && o.BitDepth != 12 {
+		return Image{}, NewError("Unsupported bitdepth value. Allowed values are: 8, 10, 12", http.StatusBadRequest)
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the formats encoded by bimg
+		quality = bimg.Quality
+	}
+
+	av1 := ImageType(o.Type) == bimg.AVIF
+	body, err := saveHEIF(img.Body, heifOptions{
+		Quality:   quality,
+		Lossless:  o.Lossless,
+		AV1:       av1,
+		Effort:    effort,
+		BitDepth:  o.BitDepth,
+		Subsample: subsample,
+		Strip:     o.StripMetadata,
+	})
+	if err != nil {
+		return Image{}, NewError("Cannot encode "+strings.ToUpper(o.Type)+" image: "+err.Error(), http.StatusBadRequest)
+	}
+
+	mime := ImageHEIF
+	if av1 {
+		mime = ImageAVIF
+	}
+	return Image{Body: body, Mime: mime, Header: img.Header}, nil
+}
+
+// heifEffort returns the encoder CPU effort of the effort param, or derived
+// from the speed param.
+func heifEffort(o ImageOptions) (int, error) {
+	switch {
+	case o.IsDefinedField.Effort && o.Speed != 0:
+		return 0, NewError("Invalid params: speed and effort cannot be combined", http.StatusBadRequest)
+	case o.IsDefinedField.Effort:
+		if o.Effort < 0 || o.Effort > MaxHEIFEffort {
+			return 0, NewError("Invalid param: effort must be between 0 and 9", http.StatusBadRequest)
+		}
+		return o.Effort, nil
+	case o.Speed < 0 || o.Speed > MaxHEIFSpeed:
+		return 0, NewError("Invalid param: speed must be between 0 and 9", http.StatusBadRequest)
+	case o.Speed > 0:
+		return MaxHEIFSpeed - o.Speed, nil
+	default:
+		return DefaultHEIFEffort, nil
+	}
+}
+
+// chromaSubsample returns the libvips subsampling mode of the chroma param.
+func chromaSubsample(chroma string) (int, error) {
+	switch chroma {
+	case "":
+		return subsampleAuto, nil
+	case Chroma420:
+		return subsampleOn, nil
+	case Chroma444:
+		return subsampleOff, nil
+	case "422":
+		return 0, NewError("Unsupported chroma value: libvips cannot encode 422 images. Allowed values are: 420, 444",
+			http.StatusBadRequest)
+	default:
+		return 0, NewError("Unsupported chroma value. Allowed values are: 420, 444", http.StatusBadRequest)
+	}
+}
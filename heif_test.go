@@ -0,0 +1,141 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestChromaSubsample(t *testing.T) {
+	cases := []struct {
+		chroma   string
+		expected int
+	}{
+		{"", subsampleAuto},
+		{Chroma420, subsampleOn},
+		{Chroma444, subsampleOff},
+	}
+
+	for _, c := range cases {
+		if subsample, err := chromaSubsample(c.chroma); err != nil || subsample != c.expected {
+			t.Errorf("Invalid subsampling mode for %q: %d, %v", c.chroma, subsample, err)
+		}
+	}
+	if _, err := chromaSubsample("422"); err == nil {
+		t.Error("Expected an error with an unsupported chroma")
+	}
+}
+
+func TestIsTunedHEIFOutput(t *testing.T) {
+	cases := []struct {
+		opts     ImageOptions
+		expected bool
+	}{
+		{ImageOptions{Type: HEIF}, false},
+		{ImageOptions{Type: HEIF, Lossless: true}, false},
+		{ImageOptions{Type: "heic", Speed: 8}, true},
+		{ImageOptions{Type: HEIF, Chroma: Chroma444}, true},
+		{ImageOptions{Type: HEIF, BitDepth: 10}, true},
+		{ImageOptions{Type: AVIF, Speed: 8}, false},
+		{ImageOptions{Type: AVIF, IsDefinedField: IsDefinedField{Effort: true}}, true},
+		{ImageOptions{Type: AVIF, BitDepth: 10}, true},
+		{ImageOptions{Type: WebP, BitDepth: 10}, false},
+	}
+
+	for _, c := range cases {
+		if isTunedHEIFOutput(c.opts) != c.expected {
+			t.Errorf("Expected %v with %+v", c.expected, c.opts)
+		}
+	}
+}
+
+func TestHEIFEffort(t *testing.T) {
+	effort := func(value int) ImageOptions {
+		return ImageOptions{Effort: value, IsDefinedField: IsDefinedField{Effort: true}}
+	}
+	cases := []struct {
+		opts     ImageOptions
+		expected int
+	}{
+		{ImageOptions{}, DefaultHEIFEffort},
+		{ImageOptions{Speed: 8}, 1},
+		{effort(0), 0},
+		{effort(9), 9},
+	}
+
+	for _, c := range cases {
+		if value, err := heifEffort(c.opts); err != nil || value != c.expected {
+			t.Errorf("Invalid effort for %+v: %d, %v", c.opts, value, err)
+		}
+	}
+
+	invalid := []ImageOptions{{Speed: 10}, effort(10), {Speed: 8, Effort: 2, IsDefinedField: IsDefinedField{Effort: true}}}
+	for _, opts := range invalid {
+		if _, err := heifEffort(opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestEncodeHEIF(t *testing.T) {
+	if !bimg.IsTypeSupportedSave(bimg.HEIF) {
+		t.Skip("libvips is built without HEIF encoder")
+	}
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	img, err := EncodeHEIF(Image{Body: buf, Mime: ImagePNG}, ImageOptions{Speed: 8, Chroma: Chroma444})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageHEIF || bimg.DetermineImageType(img.Body) != bimg.HEIF {
+		t.Error(InvalidMimeType)
+	}
+
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	if img, err := EncodeHEIF(json, ImageOptions{Speed: 8}); err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+	if _, err := EncodeHEIF(Image{Body: buf, Mime: ImagePNG}, ImageOptions{Speed: 10}); err == nil {
+		t.Error("Expected an error with an invalid speed")
+	}
+}
+
+func TestEncodeAVIF(t *testing.T) {
+	if !bimg.IsTypeSupportedSave(bimg.AVIF) {
+		t.Skip("libvips is built without AVIF encoder")
+	}
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	opts := ImageOptions{Type: AVIF, Effort: 2, BitDepth: 10, IsDefinedField: IsDefinedField{Effort: true}}
+	img, err := EncodeHEIF(Image{Body: buf, Mime: ImagePNG}, opts)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageAVIF || bimg.DetermineImageType(img.Body) != bimg.AVIF {
+		t.Error(InvalidMimeType)
+	}
+
+	opts.BitDepth = 16
+	if _, err := EncodeHEIF(Image{Body: buf, Mime: ImagePNG}, opts); err == nil {
+		t.Error("Expected an error with an unsupported bit depth")
+	}
+}
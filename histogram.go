@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"math"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// histogramBins is the number of bins of every channel histogram, one per
+// 8 bits value.
+const histogramBins = 256
+
+// ImageHistogram represents the histograms and statistics of the image
+// channels: red, green and blue, followed by alpha if the image has one.
+type ImageHistogram struct {
+	Channels []ChannelHistogram `json:"channels"`
+}
+
+// ChannelHistogram represents the distribution of the values, from 0 to 255,
+// of an image channel.
+type ChannelHistogram struct {
+	Channel   string             `json:"channel"`
+	Mean      float64            `json:"mean"`
+	Stddev    float64            `json:"stddev"`
+	Min       int                `json:"min"`
+	Max       int                `json:"max"`
+	Histogram [histogramBins]int `json:"histogram"`
+}
+
+// @Summary Image histogram
+// @Description Returns the histogram, mean, standard deviation, min and max of every channel of the image, e.g. for automatic exposure checks
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image file to analyze"
+// @Success 200 {object} ImageHistogram
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /histogram [post]
+func Histogram(buf []byte, _ ImageOptions) (Image, error) {
+	image := Image{Mime: "application/json"}
+
+	size, err := bimg.Size(buf)
+	if err != nil {
+		return image, NewError("Cannot retrieve image size: "+err.Error(), http.StatusBadRequest)
+	}
+
+	pixels, alpha, err := reducedPixels(buf, size)
+	if err != nil {
+		return image, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+
+	body, _ := json.Marshal(histogram(pixels, alpha))
+	image.Body = body
+
+	return image, nil
+}
+
+// histogram computes the statistics of the RGB channels of the canvas, and
+// of its alpha channel if requested.
+func histogram(img *image.NRGBA, alpha bool) ImageHistogram {
+	names := []string{"red", "green", "blue"}
+	if alpha {
+		names = append(names, "alpha")
+	}
+
+	channels := make([]ChannelHistogram, len(names))
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < height; y++ {
+		i := img.PixOffset(0, y)
+		for x := 0; x < width; x++ {
+			for c := range channels {
+				channels[c].Histogram[img.Pix[i+c]]++
+			}
+			i += 4
+		}
+	}
+
+	for c := range channels {
+		channels[c].Channel = names[c]
+		channelStats(&channels[c])
+	}
+	return ImageHistogram{Channels: channels}
+}
+
+// channelStats derives the mean, standard deviation and range of the channel
+// from its histogram.
+func channelStats(ch *ChannelHistogram) {
+	var count, sum float64
+	ch.Min = -1
+	for v, n := range ch.Histogram {
+		if n == 0 {
+			continue
+		}
+		if ch.Min < 0 {
+			ch.Min = v
+		}
+		ch.Max = v
+		count += float64(n)
+		sum += float64(v * n)
+	}
+	if count == 0 {
+		ch.Min = 0
+		return
+	}
+
+	ch.Mean = sum / count
+	var variance float64
+	for v, n := range ch.Histogram {
+		d := float64(v) - ch.Mean
+		variance += d * d * float64(n)
+	}
+	ch.Stddev = math.Sqrt(variance / count)
+}
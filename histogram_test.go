@@ -0,0 +1,76 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestHistogramStats(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 0, B: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 30, G: 0, B: 255, A: 128})
+
+	hist := histogram(img, false)
+	if len(hist.Channels) != 3 {
+		t.Fatalf("Invalid number of channels: %d", len(hist.Channels))
+	}
+
+	red := hist.Channels[0]
+	if red.Channel != "red" || red.Min != 10 || red.Max != 30 || red.Mean != 20 || red.Stddev != 10 {
+		t.Errorf("Invalid red channel stats: %+v", red)
+	}
+	if red.Histogram[10] != 1 || red.Histogram[30] != 1 {
+		t.Error("Invalid red channel histogram")
+	}
+
+	blue := hist.Channels[2]
+	if blue.Min != 255 || blue.Max != 255 || blue.Stddev != 0 {
+		t.Errorf("Invalid blue channel stats: %+v", blue)
+	}
+
+	hist = histogram(img, true)
+	if len(hist.Channels) != 4 || hist.Channels[3].Channel != "alpha" || hist.Channels[3].Min != 128 {
+		t.Error("Expected the alpha channel stats")
+	}
+}
+
+func TestImageHistogram(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Histogram(buf, ImageOptions{})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != "application/json" {
+		t.Error(InvalidMimeType)
+	}
+
+	var hist ImageHistogram
+	if err := json.Unmarshal(img.Body, &hist); err != nil {
+		t.Fatalf("Cannot decode histogram: %s", err)
+	}
+	if len(hist.Channels) != 3 {
+		t.Errorf("Invalid number of channels: %d", len(hist.Channels))
+	}
+}
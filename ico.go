@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	ICO            = "ico"
+	ContentTypeICO = "image/x-icon"
+)
+
+// ICOSizes are the square sizes bundled in ICO outputs, the usual favicon ones.
+var ICOSizes = []int{16, 32, 48}
+
+// IsICOOutputType returns true if the given type alias is the ICO output.
+func IsICOOutputType(name string) bool {
+	return name == ICO
+}
+
+// EncodeICO bundles the PNG image produced by the operation into an ICO file
+// holding one icon per ICOSizes size. Non square images are fitted and
+// centered over a transparent background. Other outputs, such as JSON ones,
+// are returned as is.
+func EncodeICO(img Image) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	size, err := bimg.Size(img.Body)
+	if err != nil {
+		return Image{}, NewError("Cannot retrieve image size: "+err.Error(), http.StatusBadRequest)
+	}
+
+	icons := make([][]byte, 0, len(ICOSizes))
+	for _, side := range ICOSizes {
+		icon, err := icoIcon(img.Body, size, side)
+		if err != nil {
+			return Image{}, NewError("Cannot encode ICO image: "+err.Error(), http.StatusBadRequest)
+		}
+		icons = append(icons, icon)
+	}
+
+	return Image{Body: icoFile(ICOSizes, icons), Mime: ContentTypeICO, Header: img.Header}, nil
+}
+
+// icoIcon resizes the image to fit a square of the given side and encodes it
+// as PNG.
+func icoIcon(buf []byte, size bimg.ImageSize, side int) ([]byte, error) {
+	width, height := calculateDestinationFitDimension(size.Width, size.Height, side, side)
+	width, height = max(width, 1), max(height, 1)
+
+	resized, err := bimg.Resize(buf, bimg.Options{
+		Type: bimg.PNG, Width: width, Height: height, Force: true, Enlarge: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pixels, _, err := decodePixels(resized)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, side, side))
+	left, top := (side-width)/2, (side-height)/2
+	draw.Draw(canvas, image.Rect(left, top, left+width, top+height), pixels, pixels.Rect.Min, draw.Src)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, canvas); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// icoFile writes the ICO directory followed by the PNG encoded icons, which
+// every ICO reader supports since Windows Vista. A side of 256 is stored as 0.
+func icoFile(sides []int, icons [][]byte) []byte {
+	const dirSize, entrySize = 6, 16
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(icons))})
+
+	offset := dirSize + entrySize*len(icons)
+	for i, icon := range icons {
+		side := uint8(sides[i])
+		buf.Write([]byte{side, side, 0, 0})
+		_ = binary.Write(&buf, binary.LittleEndian, [2]uint16{1, 32})
+		_ = binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(icon)), uint32(offset)})
+		offset += len(icon)
+	}
+	for _, icon := range icons {
+		buf.Write(icon)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestICOFile(t *testing.T) {
+	icons := [][]byte{[]byte("first"), []byte("second icon")}
+	file := icoFile([]int{16, 256}, icons)
+
+	if header := file[:6]; !bytes.Equal(header, []byte{0, 0, 1, 0, 2, 0}) {
+		t.Fatalf("invalid ICO header: %v", header)
+	}
+	if entry := file[6:22]; !bytes.Equal(entry, []byte{16, 16, 0, 0, 1, 0, 32, 0, 5, 0, 0, 0, 38, 0, 0, 0}) {
+		t.Errorf("invalid first entry: %v", entry)
+	}
+	if entry := file[22:38]; !bytes.Equal(entry, []byte{0, 0, 0, 0, 1, 0, 32, 0, 11, 0, 0, 0, 43, 0, 0, 0}) {
+		t.Errorf("invalid second entry: %v", entry)
+	}
+	if payload := string(file[38:]); payload != "firstsecond icon" {
+		t.Errorf("invalid payload: %s", payload)
+	}
+}
+
+func TestEncodeICO(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	img, err := EncodeICO(Image{Body: buf, Mime: ImagePNG})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ContentTypeICO {
+		t.Error(InvalidMimeType)
+	}
+
+	count := int(binary.LittleEndian.Uint16(img.Body[4:]))
+	if count != len(ICOSizes) {
+		t.Fatalf("Invalid number of icons: %d", count)
+	}
+	for i, side := range ICOSizes {
+		entry := img.Body[6+i*16:]
+		size := binary.LittleEndian.Uint32(entry[8:])
+		offset := binary.LittleEndian.Uint32(entry[12:])
+		if int(entry[0]) != side || int(entry[1]) != side {
+			t.Errorf("Invalid icon size: %dx%d", entry[0], entry[1])
+		}
+
+		config, err := png.DecodeConfig(bytes.NewReader(img.Body[offset : offset+size]))
+		if err != nil {
+			t.Fatalf("Cannot decode icon: %s", err)
+		}
+		if config.Width != side || config.Height != side {
+			t.Errorf("Invalid icon dimensions: %dx%d", config.Width, config.Height)
+		}
+	}
+
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	if img, err := EncodeICO(json); err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+}
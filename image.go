@@ -19,19 +19,65 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"io"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/h2non/bimg"
 )
 
 const MissingHeightWidth = "Missing required param: height or width"
 
+// DefaultBlockSize is the default mosaic block size, in pixels, used by Pixelate.
+const DefaultBlockSize = 10
+
+// Redaction modes supported by Redact.
+const (
+	RedactFill     = "fill"
+	RedactBlur     = "blur"
+	RedactPixelate = "pixelate"
+)
+
+// Resize modes supported by Resize, matching the sharp fit modes: fill
+// covers the dimensions cropping the overflow, inside fits within them and
+// outside covers them, both keeping the whole image.
+const (
+	ResizeFill    = "fill"
+	ResizeInside  = "inside"
+	ResizeOutside = "outside"
+)
+
+// Border modes supported by Border.
+const (
+	BorderOutside = "outside"
+	BorderInside  = "inside"
+)
+
+// DefaultRedactSigma is the default blur strength used by Redact.
+const DefaultRedactSigma = 10
+
+// MaxRedactSigma is the maximum blur strength of Redact, the sigma range
+// libvips accepts for the blur endpoint.
+const MaxRedactSigma = 1000
+
+// DefaultVignetteStrength is the default corners darkening used by Vignette.
+const DefaultVignetteStrength = 0.5
+
+// DefaultTrimThreshold is the default color distance tolerated by Trim.
+const DefaultTrimThreshold = 10
+
+// PipelineSkippedHeader lists the pipeline steps skipped via ignore_failure.
+const PipelineSkippedHeader = "Image-Pipeline-Skipped"
+
+// PipelineTimingHeader lists the duration of every pipeline step.
+const PipelineTimingHeader = "Image-Pipeline-Timing"
+
 // OperationsMap defines the allowed image transformation operations listed by name.
 // Used for pipeline image processing.
 var OperationsMap = map[string]Operation{
@@ -49,14 +95,35 @@ var OperationsMap = map[string]Operation{
 	"watermark":      Watermark,
 	"watermarkImage": WatermarkImage,
 	"blur":           GaussianBlur,
+	"sharpen":        Sharpen,
+	"adjust":         Adjust,
+	"gamma":          Gamma,
+	"modulate":       Modulate,
+	"grayscale":      Grayscale,
+	"sepia":          Sepia,
+	"invert":         Invert,
+	"duotone":        Duotone,
+	"trim":           Trim,
+	"pad":            Pad,
+	"rounded":        Rounded,
+	"circle":         Circle,
 	"smartcrop":      SmartCrop,
 	"fit":            Fit,
+	"preprocess":     Preprocess,
+	"pixelate":       Pixelate,
+	"redact":         Redact,
+	"composite":      Composite,
+	"border":         Border,
+	"polaroid":       Polaroid,
+	"vignette":       Vignette,
 }
 
 // Image stores an image binary buffer and its MIME type
 type Image struct {
 	Body []byte
 	Mime string
+	// Header holds optional response headers set by the operation.
+	Header http.Header
 }
 
 // Operation implements an image transformation runnable interface
@@ -69,6 +136,7 @@ func (o Operation) Run(buf []byte, opts ImageOptions) (Image, error) {
 
 // ImageInfo represents an image details and additional metadata
 type ImageInfo struct {
+	Schema      int    `json:"schema"`
 	Width       int    `json:"width"`
 	Height      int    `json:"height"`
 	Type        string `json:"type"`
@@ -77,6 +145,9 @@ type ImageInfo struct {
 	Profile     bool   `json:"hasProfile"`
 	Channels    int    `json:"channels"`
 	Orientation int    `json:"orientation"`
+	// Analysis and Metadata are only filled on demand, being more expensive
+	Analysis *ImageAnalysis `json:"analysis,omitempty"`
+	Metadata *ImageMetadata `json:"metadata,omitempty"`
 }
 
 // @Summary Get image info
@@ -84,23 +155,31 @@ type ImageInfo struct {
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Image file to analyze"
+// @Param analyze query bool false "Analyze the image quality, flagging too dark, too blurry or too small images"
+// @Param metadata query string false "Return the EXIF, XMP and IPTC metadata with full"
+// @Param fields query string false "Comma separated list of the fields to return"
 // @Success 200 {object} ImageInfo
 // @Failure 400 {object} Error "Bad request"
 // @Failure 404 {object} Error "Not found"
 // @Failure 401 {object} Error "Unauthorized"
 // @Failure 422 {object} Error "Unprocessable entity"
 // @Router /info [post]
-func Info(buf []byte, _ ImageOptions) (Image, error) {
+func Info(buf []byte, o ImageOptions) (Image, error) {
 	// We're not handling an image here, but we reused the struct.
 	// An interface will be definitively better here.
 	image := Image{Mime: "application/json"}
 
+	if o.Metadata != "" && o.Metadata != MetadataFull {
+		return image, NewError("Unsupported metadata value. Allowed values are: full", http.StatusBadRequest)
+	}
+
 	meta, err := bimg.Metadata(buf)
 	if err != nil {
 		return image, NewError("Cannot retrieve image metadata: %s"+err.Error(), http.StatusBadRequest)
 	}
 
 	info := ImageInfo{
+		Schema:      InfoSchemaVersion,
 		Width:       meta.Size.Width,
 		Height:      meta.Size.Height,
 		Type:        meta.Type,
@@ -111,7 +190,21 @@ func Info(buf []byte, _ ImageOptions) (Image, error) {
 		Orientation: meta.Orientation,
 	}
 
-	body, _ := json.Marshal(info)
+	if o.Analyze {
+		info.Analysis, err = analyzeImage(buf, meta.Size)
+		if err != nil {
+			return image, NewError("Cannot analyze image: "+err.Error(), http.StatusBadRequest)
+		}
+	}
+
+	if o.Metadata == MetadataFull {
+		info.Metadata = fullMetadata(buf, meta, redactGPS)
+	}
+
+	body, err := canonicalJSON(info, o.Fields)
+	if err != nil {
+		return image, err
+	}
 	image.Body = body
 
 	return image, nil
@@ -124,6 +217,7 @@ func Info(buf []byte, _ ImageOptions) (Image, error) {
 // @Param file formData file true "Image file to process"
 // @Param width query int false "Width of the output image"
 // @Param height query int false "Height of the output image"
+// @Param mode query string false "Resize mode: fill, inside or outside"
 // @Param type query string false "Output image format (jpeg, png, webp, etc.)"
 // @Param quality query int false "Quality of the output image (1-100)"
 // @Success 200 {file} binary "Processed image"
@@ -138,14 +232,33 @@ func Resize(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError(MissingHeightWidth, http.StatusBadRequest)
 	}
 
+	switch o.Mode {
+	case "":
+	case ResizeFill, ResizeInside, ResizeOutside:
+		if o.Width == 0 || o.Height == 0 {
+			return Image{}, NewError("Missing required params: height, width", http.StatusBadRequest)
+		}
+		if o.Mode == ResizeFill {
+			return Crop(buf, o)
+		}
+		return resizeKeepingImage(buf, o)
+	default:
+		return Image{}, NewError("Unsupported resize mode. Allowed values are: fill, inside and outside",
+			http.StatusBadRequest)
+	}
+
+	return processWithKernel(buf, resizeOptions(o), o.Kernel)
+}
+
+// resizeOptions returns the bimg options of the default resize mode.
+func resizeOptions(o ImageOptions) bimg.Options {
 	opts := BimgOptions(o)
 	opts.Embed = true
 
 	if o.IsDefinedField.NoCrop {
 		opts.Crop = !o.NoCrop
 	}
-
-	return Process(buf, opts)
+	return opts
 }
 
 // @Summary Fit image
@@ -191,15 +304,29 @@ func Fit(buf []byte, o ImageOptions) (Image, error) {
 	// 7: CW 270, flip horizontal
 	// 8: CW 90
 
+	// The EXIF orientation is applied before resizing, so the pixels are
+	// always returned upright unless norotation is set
+	orientation := max(metadata.Orientation, 1)
+	normalize := !o.NoRotation && orientation > 1
+	if normalize {
+		if buf, o, err = uprightInput(buf, o); err != nil {
+			return Image{}, err
+		}
+		if orientation > 4 {
+			dims.Width, dims.Height = dims.Height, dims.Width
+		}
+		orientation = 1
+	}
+
 	var originHeight, originWidth int
 	var fitHeight, fitWidth *int
-	if o.NoRotation || (metadata.Orientation <= 4) {
+	if o.Rotate%180 == 0 {
 		originHeight = dims.Height
 		originWidth = dims.Width
 		fitHeight = &o.Height
 		fitWidth = &o.Width
 	} else {
-		// width/height will be switched with auto rotation
+		// width/height will be switched with the rotation
 		originWidth = dims.Height
 		originHeight = dims.Width
 		fitWidth = &o.Height
@@ -211,7 +338,58 @@ func Fit(buf []byte, o ImageOptions) (Image, error) {
 	opts := BimgOptions(o)
 	opts.Embed = true
 
-	return Process(buf, opts)
+	image, err := processWithKernel(buf, opts, o.Kernel)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if normalize {
+		resetOrientation(image.Body)
+	}
+	image.Header = http.Header{OrientationHeader: {strconv.Itoa(orientation)}}
+	return image, nil
+}
+
+// resizeKeepingImage resizes the whole image keeping its aspect ratio, within
+// the requested dimensions for the inside mode or covering them for the
+// outside one.
+func resizeKeepingImage(buf []byte, o ImageOptions) (Image, error) {
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return Image{}, err
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if !o.NoRotation && metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+	if width == 0 || height == 0 {
+		return Image{}, NewError("Width or height of requested image is zero", http.StatusNotAcceptable)
+	}
+
+	if o.Mode == ResizeInside {
+		o.Width, o.Height = calculateDestinationFitDimension(width, height, o.Width, o.Height)
+	} else {
+		o.Width, o.Height = calculateDestinationCoverDimension(width, height, o.Width, o.Height)
+	}
+
+	opts := BimgOptions(o)
+	opts.Embed = true
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// calculateDestinationCoverDimension calculates the smallest area keeping the image aspect ratio covering the
+// desired dimensions
+func calculateDestinationCoverDimension(imageWidth, imageHeight, coverWidth, coverHeight int) (int, int) {
+	if imageWidth*coverHeight > coverWidth*imageHeight {
+		// constrained by height
+		coverWidth = int(math.Round(float64(coverHeight) * float64(imageWidth) / float64(imageHeight)))
+	} else {
+		// constrained by width
+		coverHeight = int(math.Round(float64(coverWidth) * float64(imageHeight) / float64(imageWidth)))
+	}
+
+	return coverWidth, coverHeight
 }
 
 // calculateDestinationFitDimension calculates the fit area based on the image and desired fit dimensions
@@ -248,13 +426,17 @@ func Enlarge(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError("Missing required params: height, width", http.StatusBadRequest)
 	}
 
+	return processWithKernel(buf, enlargeOptions(o), o.Kernel)
+}
+
+// enlargeOptions returns the bimg options of the enlarge operation.
+func enlargeOptions(o ImageOptions) bimg.Options {
 	opts := BimgOptions(o)
 	opts.Enlarge = true
 
 	// Since both width & height is required, we allow cropping by default.
 	opts.Crop = !o.NoCrop
-
-	return Process(buf, opts)
+	return opts
 }
 
 // @Summary Extract area from image
@@ -266,6 +448,7 @@ func Enlarge(buf []byte, o ImageOptions) (Image, error) {
 // @Param left query int false "Left offset for extraction"
 // @Param areawidth query int true "Width of the area to extract"
 // @Param areaheight query int true "Height of the area to extract"
+// @Param gravity query string false "Anchor the area to the image center, an edge or a corner, instead of top and left"
 // @Param type query string false "Output image format (jpeg, png, webp, etc.)"
 // @Success 200 {file} binary "Processed image"
 // @Failure 400 {object} Error "Bad request"
@@ -279,13 +462,71 @@ func Extract(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError("Missing required params: areawidth or areaheight", http.StatusBadRequest)
 	}
 
+	if o.IsDefinedField.Gravity {
+		var err error
+		if o.Left, o.Top, err = anchorArea(buf, o); err != nil {
+			return Image{}, err
+		}
+	}
+
 	opts := BimgOptions(o)
 	opts.Top = o.Top
 	opts.Left = o.Left
 	opts.AreaWidth = o.AreaWidth
 	opts.AreaHeight = o.AreaHeight
 
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// anchorArea returns the position of the area to extract according to the
+// gravity, e.g. centered or in the bottom right corner.
+func anchorArea(buf []byte, o ImageOptions) (left, top int, err error) {
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if !o.NoRotation && metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+	if o.AreaWidth > width || o.AreaHeight > height {
+		return 0, 0, NewError("Invalid params: areawidth and areaheight must fit within the image", http.StatusBadRequest)
+	}
+
+	fx, fy := gravityAnchor(o.Gravity)
+	left = int(fx * float64(width-o.AreaWidth))
+	top = int(fy * float64(height-o.AreaHeight))
+	return left, top, nil
+}
+
+// @Summary Trim image
+// @Description Removes the surrounding borders matching the background color
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param background query string false "Color of the borders to remove (default 255,255,255)"
+// @Param threshold query number false "Color distance tolerated from the background (default 10)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /trim [post]
+func Trim(buf []byte, o ImageOptions) (Image, error) {
+	opts := BimgOptions(o)
+	opts.Trim = true
+	opts.Threshold = DefaultTrimThreshold
+	if o.IsDefinedField.Threshold {
+		opts.Threshold = o.Threshold
+	}
+	if len(o.Background) == 0 {
+		opts.Background = bimg.Color{R: 255, G: 255, B: 255}
+	}
+
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Crop image
@@ -295,6 +536,8 @@ func Extract(buf []byte, o ImageOptions) (Image, error) {
 // @Param file formData file true "Image file to process"
 // @Param width query int false "Width of the output image"
 // @Param height query int false "Height of the output image"
+// @Param fx query number false "Horizontal focal point, between 0 and 1"
+// @Param fy query number false "Vertical focal point, between 0 and 1"
 // @Param type query string false "Output image format (jpeg, png, webp, etc.)"
 // @Param quality query int false "Quality of the output image (1-100)"
 // @Success 200 {file} binary "Processed image"
@@ -309,9 +552,100 @@ func Crop(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError(MissingHeightWidth, http.StatusBadRequest)
 	}
 
+	if o.IsDefinedField.FocalX || o.IsDefinedField.FocalY {
+		return focalCrop(buf, o)
+	}
+	if o.Gravity == GravityFace {
+		return faceCrop(buf, o)
+	}
+	if isCornerGravity(o.Gravity) {
+		o.FocalX, o.FocalY = gravityAnchor(o.Gravity)
+		o.IsDefinedField.FocalX, o.IsDefinedField.FocalY = true, true
+		return focalCrop(buf, o)
+	}
+
 	opts := BimgOptions(o)
 	opts.Crop = true
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// focalCrop crops the image around the focal point, resizing it first to
+// cover the requested dimensions.
+func focalCrop(buf []byte, o ImageOptions) (Image, error) {
+	fx, fy := 0.5, 0.5
+	if o.IsDefinedField.FocalX {
+		fx = o.FocalX
+	}
+	if o.IsDefinedField.FocalY {
+		fy = o.FocalY
+	}
+	if fx > 1 || fy > 1 {
+		return Image{}, NewError("Invalid params: fx and fy must be between 0 and 1", http.StatusBadRequest)
+	}
+
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return Image{}, err
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if !o.NoRotation && metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+	if width == 0 || height == 0 {
+		return Image{}, NewError("Width or height of requested image is zero", http.StatusNotAcceptable)
+	}
+
+	opts := BimgOptions(o)
+	opts.Width, opts.Height, opts.Left, opts.Top, opts.AreaWidth, opts.AreaHeight = focalCropWindow(
+		width, height, opts.Width, opts.Height, fx, fy)
+	opts.Force = true
+	opts.Embed = false
+
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// focalCropWindow returns the dimensions the image is resized to in order to
+// cover the crop ones, without enlarging it, and the crop window centered on
+// the focal point as close as the image edges allow.
+func focalCropWindow(imageWidth, imageHeight, cropWidth, cropHeight int, fx, fy float64) (
+	resizeWidth, resizeHeight, left, top, width, height int) {
+	if cropWidth == 0 {
+		cropWidth = int(math.Round(float64(cropHeight) * float64(imageWidth) / float64(imageHeight)))
+	}
+	if cropHeight == 0 {
+		cropHeight = int(math.Round(float64(cropWidth) * float64(imageHeight) / float64(imageWidth)))
+	}
+
+	scale := math.Min(math.Max(float64(cropWidth)/float64(imageWidth), float64(cropHeight)/float64(imageHeight)), 1)
+	resizeWidth = max(int(math.Round(float64(imageWidth)*scale)), 1)
+	resizeHeight = max(int(math.Round(float64(imageHeight)*scale)), 1)
+	width, height = min(cropWidth, resizeWidth), min(cropHeight, resizeHeight)
+
+	left, top = focalOffsets(resizeWidth, resizeHeight, width, height, fx, fy)
+	return resizeWidth, resizeHeight, left, top, width, height
+}
+
+// faceCrop crops the image around the detected faces, falling back to the
+// smart gravity when none is found.
+func faceCrop(buf []byte, o ImageOptions) (Image, error) {
+	if faceDetector == nil {
+		return Image{}, ErrFaceGravityDisabled
+	}
+
+	fx, fy, ok, err := detectFaces(buf, o)
+	if err != nil {
+		return Image{}, err
+	}
+	if !ok {
+		opts := BimgOptions(o)
+		opts.Crop = true
+		return processWithKernel(buf, opts, o.Kernel)
+	}
+
+	o.FocalX, o.FocalY = fx, fy
+	o.IsDefinedField.FocalX, o.IsDefinedField.FocalY = true, true
+	return focalCrop(buf, o)
 }
 
 // @Summary Smart crop image
@@ -335,10 +669,88 @@ func SmartCrop(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError(MissingHeightWidth, http.StatusBadRequest)
 	}
 
+	if o.Gravity == GravityFace {
+		return faceCrop(buf, o)
+	}
+
 	opts := BimgOptions(o)
 	opts.Crop = true
 	opts.Gravity = bimg.GravitySmart
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// @Summary Preprocess image for inference
+// @Description Resizes and center-crops an image to the exact tensor size expected by a model
+// @Accept multipart/form-data
+// @Produce image/*,application/octet-stream,application/x-npy
+// @Param file formData file true "Image file to process"
+// @Param width query int true "Width of the output tensor"
+// @Param height query int true "Height of the output tensor"
+// @Param interpolator query string false "Resize interpolator (bicubic, bilinear, nohalo, nearest)"
+// @Param channelorder query string false "Channel order of raw outputs (rgb, bgr)"
+// @Param mean query string false "Comma separated normalization mean per channel"
+// @Param std query string false "Comma separated normalization standard deviation per channel"
+// @Param type query string false "Output format (png, raw, npy, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /preprocess [post]
+func Preprocess(buf []byte, o ImageOptions) (Image, error) {
+	if o.Width == 0 || o.Height == 0 {
+		return Image{}, NewError("Missing required params: height and width", http.StatusBadRequest)
+	}
+	if err := validateNormalization(o.Mean, o.Std); err != nil {
+		return Image{}, err
+	}
+
+	opts := BimgOptions(o)
+	opts.Crop = true
+	opts.Enlarge = true
+	if o.Type == "" {
+		// Avoid lossy artifacts in the tensor
+		opts.Type = bimg.PNG
+	}
+
+	image, err := processWithKernel(buf, opts, o.Kernel)
+	if err != nil {
+		return Image{}, err
+	}
+
+	// Normalization is left to the client, which usually works with float
+	// tensors, so the values are just echoed for it to apply.
+	image.Header = make(http.Header)
+	if len(o.Mean) > 0 {
+		image.Header.Set("Normalize-Mean", formatFloatList(o.Mean))
+	}
+	if len(o.Std) > 0 {
+		image.Header.Set("Normalize-Std", formatFloatList(o.Std))
+	}
+	return image, nil
+}
+
+// validateNormalization checks mean and std define the same number of channels
+// and that std can be used as a divisor.
+func validateNormalization(mean, std []float64) error {
+	if len(mean) > 0 && len(std) > 0 && len(mean) != len(std) {
+		return NewError("Invalid params: mean and std must have the same number of values", http.StatusBadRequest)
+	}
+	for _, v := range std {
+		if v <= 0 {
+			return NewError("Invalid param: std values must be greater than zero", http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+func formatFloatList(list []float64) string {
+	values := make([]string, len(list))
+	for i, v := range list {
+		values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(values, ",")
 }
 
 // @Summary Rotate image
@@ -361,7 +773,7 @@ func Rotate(buf []byte, o ImageOptions) (Image, error) {
 	}
 
 	opts := BimgOptions(o)
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Auto-rotate image
@@ -418,7 +830,7 @@ func AutoRotate(buf []byte, _ ImageOptions) (out Image, err error) {
 func Flip(buf []byte, o ImageOptions) (Image, error) {
 	opts := BimgOptions(o)
 	opts.Flip = true
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Flip image horizontally
@@ -437,7 +849,7 @@ func Flip(buf []byte, o ImageOptions) (Image, error) {
 func Flop(buf []byte, o ImageOptions) (Image, error) {
 	opts := BimgOptions(o)
 	opts.Flop = true
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Create thumbnail
@@ -460,7 +872,7 @@ func Thumbnail(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError("Missing required params: width or height", http.StatusBadRequest)
 	}
 
-	return Process(buf, BimgOptions(o))
+	return processWithKernel(buf, BimgOptions(o), o.Kernel)
 }
 
 // @Summary Zoom image
@@ -504,7 +916,7 @@ func Zoom(buf []byte, o ImageOptions) (Image, error) {
 	}
 
 	opts.Zoom = o.Factor
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Convert image format
@@ -530,7 +942,7 @@ func Convert(buf []byte, o ImageOptions) (Image, error) {
 	}
 	opts := BimgOptions(o)
 
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
 // @Summary Add text watermark
@@ -541,6 +953,7 @@ func Convert(buf []byte, o ImageOptions) (Image, error) {
 // @Param text query string true "Watermark text"
 // @Param font query string false "Font name and size (e.g., 'sans 12')"
 // @Param opacity query number false "Opacity of the watermark (0.0-1.0)"
+// @Param blend query string false "Blend mode (normal, multiply, screen, overlay, darken, lighten, difference)"
 // @Param color query string false "Color of the watermark (R,G,B)"
 // @Param textwidth query int false "Width of the text area"
 // @Param type query string false "Output image format (jpeg, png, webp, etc.)"
@@ -555,11 +968,28 @@ func Watermark(buf []byte, o ImageOptions) (Image, error) {
 	if o.Text == "" {
 		return Image{}, NewError("Missing required param: text", http.StatusBadRequest)
 	}
+	if err := validateText(o.Text); err != nil {
+		return Image{}, err
+	}
+
+	opts, err := watermarkOptions(o)
+	if err != nil {
+		return Image{}, err
+	}
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// watermarkOptions returns the bimg options of the text watermark.
+func watermarkOptions(o ImageOptions) (bimg.Options, error) {
+	font, err := watermarkFont(o.Font)
+	if err != nil {
+		return bimg.Options{}, err
+	}
 
 	opts := BimgOptions(o)
 	opts.Watermark.DPI = o.DPI
 	opts.Watermark.Text = o.Text
-	opts.Watermark.Font = o.Font
+	opts.Watermark.Font = font
 	opts.Watermark.Margin = o.Margin
 	opts.Watermark.Width = o.TextWidth
 	opts.Watermark.Opacity = o.Opacity
@@ -568,8 +998,7 @@ func Watermark(buf []byte, o ImageOptions) (Image, error) {
 	if len(o.Color) > 2 {
 		opts.Watermark.Background = bimg.Color{R: o.Color[0], G: o.Color[1], B: o.Color[2]}
 	}
-
-	return Process(buf, opts)
+	return opts, nil
 }
 
 // @Summary Add image watermark
@@ -593,6 +1022,9 @@ func WatermarkImage(buf []byte, o ImageOptions) (Image, error) {
 	if o.Image == "" {
 		return Image{}, NewError("Missing required param: image", http.StatusBadRequest)
 	}
+	if o.Opacity < 0 || o.Opacity > 1 {
+		return Image{}, NewError("Invalid param: opacity must be between 0 and 1", http.StatusBadRequest)
+	}
 	response, err := http.Get(o.Image)
 	if err != nil {
 		return Image{}, NewError(fmt.Sprintf("Unable to retrieve watermark image. %s", o.Image), http.StatusBadRequest)
@@ -613,6 +1045,14 @@ func WatermarkImage(buf []byte, o ImageOptions) (Image, error) {
 
 		return Image{}, NewError(errMessage, http.StatusBadRequest)
 	}
+	if err := validateLayerSize(imageBuf); err != nil {
+		return Image{}, err
+	}
+
+	// libvips blend modes are not exposed by bimg, so blending is done on the pixels
+	if o.Blend != "" && o.Blend != BlendNormal {
+		return blendWatermarkImage(buf, imageBuf, o)
+	}
 
 	opts := BimgOptions(o)
 	opts.WatermarkImage.Left = o.Left
@@ -620,7 +1060,75 @@ func WatermarkImage(buf []byte, o ImageOptions) (Image, error) {
 	opts.WatermarkImage.Buf = imageBuf
 	opts.WatermarkImage.Opacity = o.Opacity
 
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// @Summary Composite images
+// @Description Draws a list of image layers over the base image, with opacity and blend modes
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param layers query string true "JSON list of layers, each one with url or file, left, top, opacity and blend"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /composite [post]
+func Composite(buf []byte, o ImageOptions) (Image, error) {
+	if len(o.Layers) == 0 {
+		return Image{}, NewError("Missing required param: layers", http.StatusBadRequest)
+	}
+
+	layers := make([]*image.NRGBA, len(o.Layers))
+	for i, layer := range o.Layers {
+		layerBuf, err := loadLayer(layer)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Unable to load layer %d: %s", i, err), http.StatusBadRequest)
+		}
+		if err := validateLayerSize(layerBuf); err != nil {
+			return Image{}, err
+		}
+		if layers[i], _, err = decodePixels(layerBuf); err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot decode layer %d: %s", i, err), http.StatusBadRequest)
+		}
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		for i, layer := range o.Layers {
+			opacity := layer.Opacity
+			if opacity == 0 {
+				opacity = 1
+			}
+			blend, ok := blendModes[layer.Blend]
+			if !ok {
+				blend = blendModes[BlendNormal]
+			}
+			compositeLayer(img, layers[i], layer.Left, layer.Top, opacity, blend)
+		}
+		return img, nil
+	})
+}
+
+// blendWatermarkImage draws the watermark over the image with the requested
+// blend mode.
+func blendWatermarkImage(buf, watermarkBuf []byte, o ImageOptions) (Image, error) {
+	watermark, _, err := decodePixels(watermarkBuf)
+	if err != nil {
+		return Image{}, NewError("Cannot decode watermark image: "+err.Error(), http.StatusBadRequest)
+	}
+
+	opacity := float64(o.Opacity)
+	if opacity == 0 {
+		opacity = 1
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		compositeLayer(img, watermark, o.Left, o.Top, opacity, blendModes[o.Blend])
+		return img, nil
+	})
 }
 
 // @Summary Apply Gaussian blur
@@ -643,72 +1151,796 @@ func GaussianBlur(buf []byte, o ImageOptions) (Image, error) {
 		return Image{}, NewError("Missing required param: sigma or minampl", http.StatusBadRequest)
 	}
 	opts := BimgOptions(o)
-	return Process(buf, opts)
+	return processWithKernel(buf, opts, o.Kernel)
 }
 
-// @Summary Apply multiple operations
-// @Description Applies a pipeline of operations to an image
+// @Summary Sharpen image
+// @Description Applies libvips unsharp masking, e.g. to restore thumbnails softened by downscaling
 // @Accept multipart/form-data
 // @Produce image/*
 // @Param file formData file true "Image file to process"
-// @Param operations query string true "JSON array of operations to apply"
+// @Param sigma query number false "Sigma of the gaussian mask, rounded to an integer (default 1)"
+// @Param flat query number false "Sharpening applied to flat areas (default 0)"
+// @Param jagged query number false "Sharpening applied to jagged areas (default 3)"
+// @Param width query int false "Width of the output image"
+// @Param height query int false "Height of the output image"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
 // @Success 200 {file} binary "Processed image"
 // @Failure 400 {object} Error "Bad request"
 // @Failure 404 {object} Error "Not found"
 // @Failure 401 {object} Error "Unauthorized"
 // @Failure 406 {object} Error "Not acceptable"
 // @Failure 422 {object} Error "Unprocessable entity"
-// @Router /pipeline [post]
-func Pipeline(buf []byte, o ImageOptions) (Image, error) {
-	if len(o.Operations) == 0 {
-		return Image{}, NewError("Missing or invalid pipeline operations JSON", http.StatusBadRequest)
+// @Router /sharpen [post]
+func Sharpen(buf []byte, o ImageOptions) (Image, error) {
+	opts := BimgOptions(o)
+	// sigma defines the sharpening mask here, not a blur
+	opts.GaussianBlur = bimg.GaussianBlur{}
+	opts.Sharpen = sharpenOptions(o)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// sharpenOptions maps the sharpen params onto bimg, which only exposes the
+// deprecated libvips integer radius, turned by libvips into a sigma of
+// 1 + radius/2 (integer division). Other values are the libvips defaults.
+func sharpenOptions(o ImageOptions) bimg.Sharpen {
+	sigma := math.Max(math.Round(o.Sigma), 1)
+
+	sharpen := bimg.Sharpen{
+		Radius: max(1, int(2*(sigma-1))),
+		X1:     2,
+		Y2:     10,
+		Y3:     20,
+		M1:     o.Flat,
+		M2:     3,
 	}
-	if len(o.Operations) > 10 {
-		return Image{}, NewError("Maximum allowed pipeline operations exceeded", http.StatusBadRequest)
+	if o.IsDefinedField.Jagged {
+		sharpen.M2 = o.Jagged
 	}
+	return sharpen
+}
 
-	// Validate and built operations
-	for i, operation := range o.Operations {
-		// Validate supported operation name
-		var exists bool
-		if operation.Operation, exists = OperationsMap[operation.Name]; !exists {
-			return Image{}, NewError(fmt.Sprintf("Unsupported operation name: %s", operation.Name), http.StatusBadRequest)
-		}
+// @Summary Adjust brightness and contrast
+// @Description Adjusts the image brightness and contrast with a libvips linear transformation
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param brightness query number false "Value added to every pixel channel, between -255 and 255"
+// @Param contrast query number false "Multiplier applied to every pixel channel, e.g. 1.2"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /adjust [post]
+func Adjust(buf []byte, o ImageOptions) (Image, error) {
+	if o.Brightness == 0 && o.Contrast == 0 {
+		return Image{}, NewError("Missing required param: brightness or contrast", http.StatusBadRequest)
+	}
+	if math.Abs(o.Brightness) > 255 {
+		return Image{}, NewError("Invalid param: brightness must be between -255 and 255", http.StatusBadRequest)
+	}
 
-		// Parse and construct operation options
-		var err error
+	opts := BimgOptions(o)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// @Summary Gamma correction
+// @Description Applies a gamma correction, e.g. to fix images produced by cameras with poor tone curves
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param gamma query number true "Gamma exponent, e.g. 2.2"
+// @Param width query int false "Width of the resized image"
+// @Param height query int false "Height of the resized image"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /gamma [post]
+func Gamma(buf []byte, o ImageOptions) (Image, error) {
+	if o.Gamma == 0 {
+		return Image{}, NewError("Missing required param: gamma", http.StatusBadRequest)
+	}
+
+	opts := BimgOptions(o)
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// @Summary Pixelate image
+// @Description Pixelates the whole image or the given regions, e.g. to censor faces or license plates
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param blocksize query int false "Size in pixels of the mosaic blocks (default 10)"
+// @Param regions query string false "Semicolon separated list of left,top,width,height regions"
+// @Param top query int false "Top edge of the region to pixelate"
+// @Param left query int false "Left edge of the region to pixelate"
+// @Param areawidth query int false "Width of the region to pixelate"
+// @Param areaheight query int false "Height of the region to pixelate"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /pixelate [post]
+func Pixelate(buf []byte, o ImageOptions) (Image, error) {
+	size := o.BlockSize
+	if size == 0 {
+		size = DefaultBlockSize
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		for _, region := range imageRegions(o, img.Rect) {
+			pixelate(img, region, size)
+		}
+		return img, nil
+	})
+}
+
+// @Summary Redact image regions
+// @Description Hides a list of regions in a single pass, filling them with a solid color, blurring or pixelating them
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param regions query string true "Semicolon separated list of left,top,width,height regions"
+// @Param mode query string false "Redaction mode (fill, blur, pixelate)"
+// @Param color query string false "RGB fill color (default 0,0,0)"
+// @Param sigma query number false "Blur strength (default 10)"
+// @Param blocksize query int false "Size in pixels of the mosaic blocks (default 10)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /redact [post]
+func Redact(buf []byte, o ImageOptions) (Image, error) {
+	if len(o.Regions) == 0 && (o.AreaWidth == 0 || o.AreaHeight == 0) {
+		return Image{}, NewError("Missing required param: regions", http.StatusBadRequest)
+	}
+
+	var redact func(img *image.NRGBA, area image.Rectangle)
+	switch o.Mode {
+	case "", RedactFill:
+		fill := opaqueColor(o.Color, [4]uint8{0, 0, 0, 255})
+		redact = func(img *image.NRGBA, area image.Rectangle) { fillRect(img, area, fill) }
+	case RedactBlur:
+		if o.Sigma < 0 || o.Sigma > MaxRedactSigma {
+			return Image{}, NewError(fmt.Sprintf("Invalid param: sigma must be between 0 and %d", MaxRedactSigma),
+				http.StatusBadRequest)
+		}
+		radius := int(math.Round(o.Sigma))
+		if radius == 0 {
+			radius = DefaultRedactSigma
+		}
+		redact = func(img *image.NRGBA, area image.Rectangle) { blurRect(img, area, radius) }
+	case RedactPixelate:
+		size := o.BlockSize
+		if size == 0 {
+			size = DefaultBlockSize
+		}
+		redact = func(img *image.NRGBA, area image.Rectangle) { pixelate(img, area, size) }
+	default:
+		return Image{}, NewError("Unsupported redact mode. Allowed values are: fill, blur, pixelate", http.StatusBadRequest)
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		for _, region := range imageRegions(o, img.Rect) {
+			redact(img, region)
+		}
+		return img, nil
+	})
+}
+
+// @Summary Add border
+// @Description Surrounds the image with a solid color border
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param border query int true "Border width in pixels"
+// @Param color query string false "RGB border color (default 0,0,0)"
+// @Param mode query string false "Draw the border outside of the image, increasing its dimensions, or inside, keeping them (default outside)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /border [post]
+func Border(buf []byte, o ImageOptions) (Image, error) {
+	if o.Border == 0 {
+		return Image{}, NewError("Missing required param: border", http.StatusBadRequest)
+	}
+	if o.Border < 0 {
+		return Image{}, ErrNegativeBorder
+	}
+
+	color := opaqueColor(o.Color, [4]uint8{0, 0, 0, 255})
+	switch o.Mode {
+	case "", BorderOutside:
+		return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+			return addFrame(img, o.Border, o.Border, o.Border, o.Border, color)
+		})
+	case BorderInside:
+		return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+			strokeFrame(img, o.Border, color)
+			return img, nil
+		})
+	default:
+		return Image{}, NewError("Unsupported border mode. Allowed values are: outside, inside", http.StatusBadRequest)
+	}
+}
+
+// @Summary Round corners
+// @Description Rounds the image corners, transparent when the output format supports alpha or filled with the background color otherwise
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param radius query int true "Corners radius in pixels"
+// @Param background query string false "RGB corners color for formats without alpha, e.g. JPEG (default 255,255,255)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /rounded [post]
+func Rounded(buf []byte, o ImageOptions) (Image, error) {
+	if o.Radius == 0 {
+		return Image{}, NewError("Missing required param: radius", http.StatusBadRequest)
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		roundCorners(img, o.Radius)
+		if !supportsAlpha(outputType(buf, o)) {
+			flatten(img, opaqueColor(o.Background, [4]uint8{255, 255, 255, 255}))
+		}
+		return img, nil
+	})
+}
+
+// @Summary Circular crop
+// @Description Crops a circle out of the image, with a transparent background
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param cx query int false "Horizontal position of the circle center (default image center)"
+// @Param cy query int false "Vertical position of the circle center (default image center)"
+// @Param radius query int false "Circle radius in pixels (default largest circle fitting the image)"
+// @Param background query string false "RGB background color for formats without alpha, e.g. JPEG (default 255,255,255)"
+// @Param type query string false "Output image format (default source format if it supports alpha, PNG otherwise)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /circle [post]
+func Circle(buf []byte, o ImageOptions) (Image, error) {
+	pixels, _, err := decodePixels(buf)
+	if err != nil {
+		return Image{}, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+
+	width, height := pixels.Rect.Dx(), pixels.Rect.Dy()
+	cx, cy := width/2, height/2
+	if o.IsDefinedField.CenterX {
+		cx = o.CenterX
+	}
+	if o.IsDefinedField.CenterY {
+		cy = o.CenterY
+	}
+
+	radius := min(cx, cy, width-cx, height-cy)
+	if o.Radius > 0 {
+		if o.Radius > radius {
+			return Image{}, NewError("Invalid param: the circle must fit within the image", http.StatusBadRequest)
+		}
+		radius = o.Radius
+	}
+	if radius <= 0 {
+		return Image{}, NewError("Invalid param: the circle center must be within the image", http.StatusBadRequest)
+	}
+
+	pixels = circleCrop(pixels, cx, cy, radius)
+
+	t := outputType(buf, o)
+	if !supportsAlpha(t) {
+		if o.Type == "" {
+			t = bimg.PNG
+		} else {
+			flatten(pixels, opaqueColor(o.Background, [4]uint8{255, 255, 255, 255}))
+		}
+	}
+	return encodePixels(pixels, t, o)
+}
+
+// @Summary Pad image
+// @Description Extends the canvas to the given size, placing the image according to the gravity
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param width query int false "Width of the canvas (default image width)"
+// @Param height query int false "Height of the canvas (default image height)"
+// @Param aspectratio query string false "Aspect ratio of the smallest canvas containing the image, when no width or height is given, e.g. 1:1"
+// @Param gravity query string false "Position of the image on the canvas (north, south, east, west or centre)"
+// @Param background query string false "RGB canvas color (default 255,255,255)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /pad [post]
+func Pad(buf []byte, o ImageOptions) (Image, error) {
+	if o.Width == 0 && o.Height == 0 && o.AspectRatio == "" {
+		return Image{}, NewError(MissingHeightWidth+" or aspectratio", http.StatusBadRequest)
+	}
+
+	color := opaqueColor(o.Background, [4]uint8{255, 255, 255, 255})
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		width, height := img.Rect.Dx(), img.Rect.Dy()
+		canvasWidth, canvasHeight, err := padSize(width, height, o)
+		if err != nil {
+			return nil, err
+		}
+
+		dx, dy := canvasWidth-width, canvasHeight-height
+		left, top := padOffsets(o.Gravity, dx, dy)
+		return addFrame(img, left, top, dx-left, dy-top, color)
+	})
+}
+
+// padSize returns the canvas size to pad an image of the given size to.
+// Missing dimensions default to the image ones, unless an aspect ratio is
+// given, in which case the smallest canvas containing the image is used.
+func padSize(width, height int, o ImageOptions) (int, int, error) {
+	canvasWidth, canvasHeight := o.Width, o.Height
+	if canvasWidth == 0 && canvasHeight == 0 {
+		ratio := parseAspectRatio(o.AspectRatio)
+		if ratio == nil || ratio["width"] <= 0 || ratio["height"] <= 0 {
+			return 0, 0, NewError("Invalid param: aspectratio must be formatted as width:height, e.g. 1:1", http.StatusBadRequest)
+		}
+
+		rw, rh := ratio["width"], ratio["height"]
+		if width*rh >= height*rw {
+			canvasWidth, canvasHeight = width, (width*rh+rw-1)/rw
+		} else {
+			canvasWidth, canvasHeight = (height*rw+rh-1)/rh, height
+		}
+	}
+	if canvasWidth == 0 {
+		canvasWidth = width
+	}
+	if canvasHeight == 0 {
+		canvasHeight = height
+	}
+
+	if canvasWidth < width || canvasHeight < height {
+		return 0, 0, NewError("Invalid param: width and height must be greater than or equal to the image size", http.StatusBadRequest)
+	}
+	if err := validateOutputSize(canvasWidth, canvasHeight); err != nil {
+		return 0, 0, err
+	}
+	return canvasWidth, canvasHeight, nil
+}
+
+// @Summary Add polaroid frame
+// @Description Surrounds the image with a polaroid-style frame, wider at the bottom
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param border query int false "Frame width in pixels, the bottom being five times wider (default 6% of the image width)"
+// @Param color query string false "RGB frame color (default 255,255,255)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /polaroid [post]
+func Polaroid(buf []byte, o ImageOptions) (Image, error) {
+	if o.Border < 0 {
+		return Image{}, ErrNegativeBorder
+	}
+
+	color := opaqueColor(o.Color, [4]uint8{255, 255, 255, 255})
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		side := o.Border
+		if side == 0 {
+			side = max(1, img.Rect.Dx()*6/100)
+		}
+		return addFrame(img, side, side, side, side*5, color)
+	})
+}
+
+// @Summary Add vignette
+// @Description Progressively darkens the image towards its corners
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param strength query number false "Corners darkening, between 0 and 1 (default 0.5)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /vignette [post]
+func Vignette(buf []byte, o ImageOptions) (Image, error) {
+	strength := o.Strength
+	if strength == 0 {
+		strength = DefaultVignetteStrength
+	}
+	if strength > 1 {
+		return Image{}, NewError("Invalid param: strength must be between 0 and 1", http.StatusBadRequest)
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		vignette(img, strength)
+		return img, nil
+	})
+}
+
+// @Summary Modulate saturation and hue
+// @Description Scales the image saturation and rotates its hue
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param saturation query number false "Saturation multiplier, e.g. 0 to desaturate or 1.5 to boost (default 1)"
+// @Param hue query number false "Hue rotation in degrees, between -360 and 360"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /modulate [post]
+func Modulate(buf []byte, o ImageOptions) (Image, error) {
+	if !o.IsDefinedField.Saturation && o.Hue == 0 {
+		return Image{}, NewError("Missing required param: saturation or hue", http.StatusBadRequest)
+	}
+	if math.Abs(o.Hue) > 360 {
+		return Image{}, NewError("Invalid param: hue must be between -360 and 360", http.StatusBadRequest)
+	}
+
+	saturation := 1.0
+	if o.IsDefinedField.Saturation {
+		saturation = o.Saturation
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		modulate(img, saturation, o.Hue)
+		return img, nil
+	})
+}
+
+// @Summary Convert to grayscale
+// @Description Converts the image to grayscale, same as colorspace=bw
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param width query int false "Width of the resized image"
+// @Param height query int false "Height of the resized image"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /grayscale [post]
+func Grayscale(buf []byte, o ImageOptions) (Image, error) {
+	opts := BimgOptions(o)
+	opts.Interpretation = bimg.InterpretationBW
+	return processWithKernel(buf, opts, o.Kernel)
+}
+
+// @Summary Sepia tone
+// @Description Applies a sepia tone to the image
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param strength query number false "Sepia tone strength, between 0 and 1 (default 1)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /sepia [post]
+func Sepia(buf []byte, o ImageOptions) (Image, error) {
+	strength := o.Strength
+	if strength == 0 {
+		strength = 1
+	}
+	if strength > 1 {
+		return Image{}, NewError("Invalid param: strength must be between 0 and 1", http.StatusBadRequest)
+	}
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		sepia(img, strength)
+		return img, nil
+	})
+}
+
+// @Summary Duotone
+// @Description Maps the image shadows and highlights to two colors
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param shadow query string false "Shadows color, as R,G,B or hex (default 0,0,0)"
+// @Param highlight query string false "Highlights color, as R,G,B or hex (default 255,255,255)"
+// @Param strength query number false "Effect strength, between 0 and 1 (default 1)"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /duotone [post]
+func Duotone(buf []byte, o ImageOptions) (Image, error) {
+	if len(o.Shadow) == 0 && len(o.Highlight) == 0 {
+		return Image{}, NewError("Missing required param: shadow or highlight", http.StatusBadRequest)
+	}
+
+	strength := o.Strength
+	if strength == 0 {
+		strength = 1
+	}
+	if strength > 1 {
+		return Image{}, NewError("Invalid param: strength must be between 0 and 1", http.StatusBadRequest)
+	}
+
+	shadow := opaqueColor(o.Shadow, [4]uint8{0, 0, 0, 255})
+	highlight := opaqueColor(o.Highlight, [4]uint8{255, 255, 255, 255})
+
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		duotone(img, shadow, highlight, strength)
+		return img, nil
+	})
+}
+
+// @Summary Invert colors
+// @Description Negates the image colors, keeping its transparency
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param type query string false "Output image format (jpeg, png, webp, etc.)"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /invert [post]
+func Invert(buf []byte, o ImageOptions) (Image, error) {
+	return processPixels(buf, o, func(img *image.NRGBA) (*image.NRGBA, error) {
+		invert(img)
+		return img, nil
+	})
+}
+
+// @Summary Apply multiple operations
+// @Description Applies a pipeline of operations to an image
+// @Accept multipart/form-data
+// @Produce image/*
+// @Param file formData file true "Image file to process"
+// @Param operations query string true "JSON array of operations to apply"
+// @Param type query string false "Response image format, unless set by the final operation"
+// @Success 200 {file} binary "Processed image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 406 {object} Error "Not acceptable"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /pipeline [post]
+func Pipeline(buf []byte, o ImageOptions) (Image, error) {
+	if len(o.Operations) == 0 {
+		return Image{}, NewError("Missing or invalid pipeline operations JSON", http.StatusBadRequest)
+	}
+	if len(o.Operations) > 10 {
+		return Image{}, NewError("Maximum allowed pipeline operations exceeded", http.StatusBadRequest)
+	}
+
+	// Validate and built operations
+	for i, operation := range o.Operations {
+		// Validate supported operation name
+		var exists bool
+		if operation.Operation, exists = OperationsMap[operation.Name]; !exists {
+			return Image{}, NewError(fmt.Sprintf("Unsupported operation name: %s", operation.Name), http.StatusBadRequest)
+		}
+
+		// Parse and construct operation options
+		var err error
 		operation.ImageOptions, err = buildParamsFromOperation(operation)
+		if xerr, ok := err.(Error); ok && xerr.Param != nil {
+			xerr.Message = fmt.Sprintf("operation %d: %s", i, xerr.Message)
+			xerr.Param.Step = i
+			return Image{}, xerr
+		}
 		if err != nil {
 			return Image{}, err
 		}
+		if err := validatePipelineType(i, len(o.Operations), operation.ImageOptions.Type); err != nil {
+			return Image{}, err
+		}
+		if operation.If != "" {
+			if operation.Condition, err = parseCondition(operation.If); err != nil {
+				return Image{}, NewError(fmt.Sprintf("Invalid if clause of operation %d: %s", i, err), http.StatusBadRequest)
+			}
+		}
 
 		// Mutate list by value
 		o.Operations[i] = operation
 	}
 
+	// The final operation determines the response type
+	final := &o.Operations[len(o.Operations)-1].ImageOptions
+	if o.Type != "" && final.Type != "" && ImageType(o.Type) != ImageType(final.Type) {
+		message := fmt.Sprintf("Conflicting response types: %s is requested but the final operation outputs %s",
+			o.Type, final.Type)
+		return Image{}, NewError(message, http.StatusBadRequest)
+	}
+	if final.Type == "" {
+		final.Type = o.Type
+	}
+	mergePipelineOperations(o.Operations)
+
 	var image Image
 	var err error
 
 	// Reduce image by running multiple operations
+	var skipped []string
+	timing := make([]string, 0, len(o.Operations))
 	image = Image{Body: buf}
-	for _, operation := range o.Operations {
+	for i, operation := range o.Operations {
+		// Merged into a previous operation
+		if operation.Operation == nil {
+			timing = append(timing, fmt.Sprintf("%d;operation=%s;merged", i, operation.Name))
+			continue
+		}
+
+		// Conditional operations only run when the current image matches
+		var matches bool
+		if matches, err = operation.Condition.Matches(image.Body); err != nil {
+			return Image{}, NewError("Cannot retrieve image metadata: "+err.Error(), http.StatusBadRequest)
+		}
+		if !matches {
+			timing = append(timing, fmt.Sprintf("%d;operation=%s;unmet", i, operation.Name))
+			continue
+		}
+		start := time.Now()
+
+		// Relative sizes apply to the output of the previous operation
+		opts := operation.ImageOptions
+		if hasRelativeSize(opts) {
+			if opts, err = resolveRelativeSize(image.Body, opts); err != nil {
+				return Image{}, err
+			}
+		}
+
 		var curImage Image
-		curImage, err = operation.Operation(image.Body, operation.ImageOptions)
+		curImage, err = operation.Operation(image.Body, opts)
 		if err != nil && !operation.IgnoreFailure {
 			return Image{}, err
 		}
-		if operation.IgnoreFailure {
+		step := pipelineTimingStep(i, operation.Name, time.Since(start))
+		if err != nil {
+			skipped = append(skipped, pipelineSkippedStep(i, operation.Name, err))
+			timing = append(timing, step+";skipped")
 			err = nil
+			continue
 		}
-		if err == nil {
-			image = curImage
-		}
+		timing = append(timing, step)
+		image = curImage
+	}
+
+	header := image.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
 	}
+	header[PipelineTimingHeader] = timing
+	// Report the steps skipped via ignore_failure, so clients can tell the
+	// image is the output of the last successful one.
+	if len(skipped) > 0 {
+		header[PipelineSkippedHeader] = skipped
+	}
+	image.Header = header
 
 	return image, err
 }
 
-func Process(buf []byte, opts bimg.Options) (out Image, err error) {
+// pipelineTimingStep formats the duration of the pipeline step, in
+// milliseconds as the Server-Timing header does.
+func pipelineTimingStep(index int, name string, duration time.Duration) string {
+	return fmt.Sprintf("%d;operation=%s;dur=%.3f", index, name, float64(duration.Microseconds())/1000)
+}
+
+// validatePipelineType checks the type param of the pipeline operation at the
+// given index. Intermediate operations may use any format libvips can both
+// save and load, e.g. to keep a lossless PNG until the final operation, which
+// alone determines the response type.
+func validatePipelineType(index, count int, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	imageType := ImageType(name)
+	switch {
+	case IsRawOutputType(name) || IsICOOutputType(name) || name == "auto":
+		return NewError(fmt.Sprintf("Invalid type of operation %d: %s is only supported by the type param of the pipeline",
+			index, name), http.StatusBadRequest)
+	case imageType == bimg.UNKNOWN:
+		return NewError(fmt.Sprintf("Invalid type of operation %d: %s", index, name), http.StatusBadRequest)
+	case index < count-1 && (!bimg.IsTypeSupportedSave(imageType) || !bimg.IsTypeSupported(imageType)):
+		return NewError(fmt.Sprintf("Invalid type of operation %d: %s cannot be used as intermediate format, "+
+			"only the final operation determines the response type", index, name), http.StatusBadRequest)
+	default:
+		return nil
+	}
+}
+
+// pipelineSkippedStep formats a skipped pipeline step as
+// <index>;operation=<name>;error="<message>".
+func pipelineSkippedStep(index int, name string, err error) string {
+	return fmt.Sprintf("%d;operation=%s;error=%s", index, name, strconv.Quote(err.Error()))
+}
+
+// DefaultFallbackFormat is the default -fallback-format value.
+const DefaultFallbackFormat = JPEG
+
+// FallbackNone disables the encoding fallback.
+const FallbackNone = "none"
+
+// encodeFallback is the format images are encoded in when libvips fails to
+// encode a WebP or HEIF image. UNKNOWN disables the fallback.
+var encodeFallback = bimg.JPEG
+
+// LoadEncodeFallback sets the encoding fallback format.
+func LoadEncodeFallback(o ServerOptions) {
+	encodeFallback, _ = fallbackImageType(o.FallbackFormat)
+}
+
+// fallbackImageType returns the image type of the -fallback-format value, and
+// whether the value is allowed.
+func fallbackImageType(format string) (bimg.ImageType, bool) {
+	switch format {
+	case "", JPEG:
+		return bimg.JPEG, true
+	case PNG:
+		return bimg.PNG, true
+	case FallbackNone:
+		return bimg.UNKNOWN, true
+	default:
+		return bimg.UNKNOWN, false
+	}
+}
+
+func Process(buf []byte, opts bimg.Options) (Image, error) {
+	return processWithKernel(buf, opts, "")
+}
+
+// processWithKernel processes the image with bimg, downscaling it with the
+// given kernel of the kernel param, if any.
+func processWithKernel(buf []byte, opts bimg.Options, kernel string) (out Image, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			switch value := r.(type) {
@@ -723,13 +1955,32 @@ func Process(buf []byte, opts bimg.Options) (out Image, err error) {
 		}
 	}()
 
+	// CMYK images are converted to sRGB with their ICC profile first, bimg
+	// conversion ignoring it, and encoded back to their type
+	if isCMYK(buf) {
+		if opts.Type == bimg.UNKNOWN {
+			opts.Type = bimg.DetermineImageType(buf)
+		}
+		if buf, err = loadPNG(buf, "", cmykProfile); err != nil {
+			return Image{}, err
+		}
+	}
+
+	if (premultiplyAlpha || kernel != "") && (opts.Width > 0 || opts.Height > 0) {
+		if buf, opts, err = resizedInput(buf, opts, kernel); err != nil {
+			return Image{}, err
+		}
+	}
+
 	// Resize image via bimg
 	ibuf, err := bimg.Resize(buf, opts)
 
 	// Handle specific type encode errors gracefully
 	if err != nil && strings.Contains(err.Error(), "encode") && (opts.Type == bimg.WEBP || opts.Type == bimg.HEIF) {
-		// Always fallback to JPEG
-		opts.Type = bimg.JPEG
+		if encodeFallback == bimg.UNKNOWN {
+			return Image{}, ErrEncodeFailed
+		}
+		opts.Type = encodeFallback
 		ibuf, err = bimg.Resize(buf, opts)
 	}
 
@@ -19,8 +19,21 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/h2non/bimg"
 )
 
 const CannotProcessImageS = "Cannot process image: %s"
@@ -95,6 +108,337 @@ func TestImageFit(t *testing.T) {
 	if assertSize(img.Body, 223, 300) != nil {
 		t.Errorf(InvalidImageSize, opts.Width, opts.Height)
 	}
+	if orientation := img.Header.Get(OrientationHeader); orientation != "1" {
+		t.Errorf("Invalid orientation header: %s", orientation)
+	}
+}
+
+func TestImagePreprocess(t *testing.T) {
+	opts := ImageOptions{Width: 224, Height: 224, Mean: []float64{0.485, 0.456, 0.406}, Std: []float64{0.229, 0.224, 0.225}}
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Preprocess(buf, opts)
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImagePNG {
+		t.Error(InvalidMimeType)
+	}
+	if assertSize(img.Body, 224, 224) != nil {
+		t.Errorf(InvalidImageSize, opts.Width, opts.Height)
+	}
+	if mean := img.Header.Get("Normalize-Mean"); mean != "0.485,0.456,0.406" {
+		t.Errorf("Invalid mean header: %s", mean)
+	}
+}
+
+func TestImagePixelate(t *testing.T) {
+	opts := ImageOptions{BlockSize: 20, Regions: []Region{{Left: 100, Top: 100, Width: 200, Height: 200}}}
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Pixelate(buf, opts)
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageJPEG {
+		t.Error(InvalidMimeType)
+	}
+	if assertSize(img.Body, 550, 740) != nil {
+		t.Errorf(InvalidImageSize, 550, 740)
+	}
+}
+
+func TestImageRedact(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	for _, mode := range []string{RedactFill, RedactBlur, RedactPixelate} {
+		opts := ImageOptions{Mode: mode, Regions: []Region{{Left: 10, Top: 10, Width: 100, Height: 40}}}
+		img, err := Redact(buf, opts)
+		if err != nil {
+			t.Errorf(CannotProcessImageS, err)
+		}
+		if assertSize(img.Body, 550, 740) != nil {
+			t.Errorf(InvalidImageSize, 550, 740)
+		}
+	}
+}
+
+func TestImageRedactErrors(t *testing.T) {
+	if _, err := Redact(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing regions to result in an error")
+	}
+
+	opts := ImageOptions{Mode: "erase", Regions: []Region{{Width: 10, Height: 10}}}
+	if _, err := Redact(nil, opts); err == nil {
+		t.Error("Expected unsupported mode to result in an error")
+	}
+
+	for _, sigma := range []float64{-1, MaxRedactSigma + 1} {
+		opts := ImageOptions{Mode: RedactBlur, Sigma: sigma, Regions: []Region{{Width: 10, Height: 10}}}
+		if _, err := Redact(nil, opts); err == nil {
+			t.Errorf("Expected sigma %v to result in an error", sigma)
+		}
+	}
+}
+
+func TestImageDecorationErrors(t *testing.T) {
+	if _, err := Border(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing border to result in an error")
+	}
+	if _, err := Border(nil, ImageOptions{Border: 5, Mode: "middle"}); err == nil {
+		t.Error("Expected unsupported border mode to result in an error")
+	}
+	if _, err := Border(nil, ImageOptions{Border: -5}); err != ErrNegativeBorder {
+		t.Errorf("Expected negative border to be rejected: %v", err)
+	}
+	if _, err := Polaroid(nil, ImageOptions{Border: -5}); err != ErrNegativeBorder {
+		t.Errorf("Expected negative polaroid border to be rejected: %v", err)
+	}
+	if _, err := Vignette(nil, ImageOptions{Strength: 2}); err == nil {
+		t.Error("Expected out of range strength to result in an error")
+	}
+}
+
+func TestImageTrim(t *testing.T) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(5, 5, 11, 9), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, canvas)
+
+	img, err := Trim(buf.Bytes(), ImageOptions{})
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if assertSize(img.Body, 6, 4) != nil {
+		t.Errorf(InvalidImageSize, 6, 4)
+	}
+}
+
+func TestPadSize(t *testing.T) {
+	cases := []struct {
+		opts          ImageOptions
+		width, height int
+		valid         bool
+	}{
+		{ImageOptions{Width: 500}, 500, 200, true},
+		{ImageOptions{Width: 500, Height: 400}, 500, 400, true},
+		{ImageOptions{AspectRatio: "1:1"}, 300, 300, true},
+		{ImageOptions{AspectRatio: "16:9"}, 356, 200, true},
+		{ImageOptions{AspectRatio: "1:2"}, 300, 600, true},
+		{ImageOptions{AspectRatio: "square"}, 0, 0, false},
+		{ImageOptions{Width: 200}, 0, 0, false},
+		{ImageOptions{Width: 100000, Height: 100000}, 0, 0, false},
+		{ImageOptions{AspectRatio: "1000:1"}, 0, 0, false},
+	}
+
+	for _, c := range cases {
+		width, height, err := padSize(300, 200, c.opts)
+		if (err == nil) != c.valid {
+			t.Errorf("Invalid pad size validation for %+v: %v", c.opts, err)
+			continue
+		}
+		if width != c.width || height != c.height {
+			t.Errorf("Invalid pad size for %+v: %dx%d, expected: %dx%d", c.opts, width, height, c.width, c.height)
+		}
+	}
+
+	if _, err := Pad(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing width, height and aspectratio to result in an error")
+	}
+}
+
+func TestImageCircle(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Circle(buf, ImageOptions{})
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if img.Mime != "image/png" {
+		t.Error(InvalidMimeType)
+	}
+	if assertSize(img.Body, 550, 550) != nil {
+		t.Errorf(InvalidImageSize, 550, 550)
+	}
+
+	if _, err := Circle(buf, ImageOptions{Radius: 300}); err == nil {
+		t.Error("Expected a circle larger than the image to result in an error")
+	}
+}
+
+func TestImageRoundedErrors(t *testing.T) {
+	if _, err := Rounded(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing radius to result in an error")
+	}
+}
+
+func TestImageConvertLossless(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+	expected, _, err := decodeImagePixels(buf)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+
+	img, err := Convert(buf, ImageOptions{Type: "webp", Lossless: true})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != "image/webp" {
+		t.Error(InvalidMimeType)
+	}
+	pixels, _, err := decodeImagePixels(img.Body)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if len(pixels.Pix) != len(expected.Pix) {
+		t.Fatal("Lossless WebP size differs from the source one")
+	}
+	// The color of fully transparent pixels is not kept
+	for i := 0; i < len(pixels.Pix); i += 4 {
+		if expected.Pix[i+3] != 0 && !bytes.Equal(pixels.Pix[i:i+4], expected.Pix[i:i+4]) {
+			t.Fatalf("Lossless WebP pixel %d differs from the source one", i/4)
+		}
+	}
+}
+
+func TestImageGrayscale(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Grayscale(buf, ImageOptions{Width: 300})
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if assertSize(img.Body, 300, 404) != nil {
+		t.Errorf(InvalidImageSize, 300, 404)
+	}
+}
+
+func TestImageSepiaErrors(t *testing.T) {
+	if _, err := Sepia(nil, ImageOptions{Strength: 2}); err == nil {
+		t.Error("Expected out of range strength to result in an error")
+	}
+}
+
+func TestImageDuotoneErrors(t *testing.T) {
+	if _, err := Duotone(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing colors to result in an error")
+	}
+	if _, err := Duotone(nil, ImageOptions{Highlight: []uint8{255, 0, 0}, Strength: 2}); err == nil {
+		t.Error("Expected out of range strength to result in an error")
+	}
+}
+
+func TestImageModulateErrors(t *testing.T) {
+	if _, err := Modulate(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing saturation and hue to result in an error")
+	}
+	if _, err := Modulate(nil, ImageOptions{Hue: 400}); err == nil {
+		t.Error("Expected out of range hue to result in an error")
+	}
+}
+
+func TestImageSharpen(t *testing.T) {
+	opts := ImageOptions{Width: 300, Sigma: 2}
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Sharpen(buf, opts)
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageJPEG {
+		t.Error(InvalidMimeType)
+	}
+	if assertSize(img.Body, 300, 404) != nil {
+		t.Errorf(InvalidImageSize, 300, 404)
+	}
+}
+
+func TestSharpenOptions(t *testing.T) {
+	cases := []struct {
+		opts   ImageOptions
+		radius int
+		m2     float64
+	}{
+		{ImageOptions{}, 1, 3},
+		{ImageOptions{Sigma: 1.2}, 1, 3},
+		{ImageOptions{Sigma: 2}, 2, 3},
+		{ImageOptions{Sigma: 3.6}, 6, 3},
+		{ImageOptions{Jagged: 0, IsDefinedField: IsDefinedField{Jagged: true}}, 1, 0},
+	}
+
+	for _, tc := range cases {
+		sharpen := sharpenOptions(tc.opts)
+		if sharpen.Radius != tc.radius || sharpen.M2 != tc.m2 {
+			t.Errorf("Invalid sharpen options for %+v: %+v", tc.opts, sharpen)
+		}
+		// bimg skips sharpening unless these are set
+		if sharpen.Y2 == 0 || sharpen.Y3 == 0 {
+			t.Errorf("Expected the sharpen thresholds to be defined: %+v", sharpen)
+		}
+	}
+}
+
+func TestImageAdjust(t *testing.T) {
+	opts := ImageOptions{Brightness: -20, Contrast: 1.2}
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Adjust(buf, opts)
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if assertSize(img.Body, 550, 740) != nil {
+		t.Errorf(InvalidImageSize, 550, 740)
+	}
+}
+
+func TestImageAdjustErrors(t *testing.T) {
+	if _, err := Adjust(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing brightness and contrast to result in an error")
+	}
+	if _, err := Adjust(nil, ImageOptions{Brightness: -300}); err == nil {
+		t.Error("Expected out of range brightness to result in an error")
+	}
+}
+
+func TestImageGamma(t *testing.T) {
+	opts := ImageOptions{Gamma: 2.2, Width: 300}
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Gamma(buf, opts)
+	if err != nil {
+		t.Errorf(CannotProcessImageS, err)
+	}
+	if assertSize(img.Body, 300, 404) != nil {
+		t.Errorf(InvalidImageSize, 300, 404)
+	}
+}
+
+func TestImageGammaErrors(t *testing.T) {
+	if _, err := Gamma(nil, ImageOptions{}); err == nil {
+		t.Error("Expected missing gamma to result in an error")
+	}
+}
+
+func TestValidateNormalization(t *testing.T) {
+	cases := []struct {
+		mean  []float64
+		std   []float64
+		valid bool
+	}{
+		{nil, nil, true},
+		{[]float64{0.5}, nil, true},
+		{[]float64{0.485, 0.456, 0.406}, []float64{0.229, 0.224, 0.225}, true},
+		{[]float64{0.485, 0.456, 0.406}, []float64{0.229}, false},
+		{nil, []float64{0.229, 0, 0.225}, false},
+	}
+
+	for _, tc := range cases {
+		if err := validateNormalization(tc.mean, tc.std); (err == nil) != tc.valid {
+			t.Errorf("Invalid normalization validation for %v/%v: %v", tc.mean, tc.std, err)
+		}
+	}
 }
 
 func TestImageAutoRotate(t *testing.T) {
@@ -145,6 +489,172 @@ func TestImagePipelineOperations(t *testing.T) {
 	}
 }
 
+func TestImagePipelineTypes(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	// Lossless intermediate image, the final operation setting the response type
+	operations := PipelineOperations{
+		PipelineOperation{Name: "crop", Params: map[string]interface{}{"width": 300, "type": "png"}},
+		PipelineOperation{Name: "blur", Params: map[string]interface{}{"sigma": 2.0, "type": "webp", "quality": 60}},
+	}
+	img, err := Pipeline(buf, ImageOptions{Operations: operations})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageWebP {
+		t.Error(InvalidMimeType)
+	}
+
+	// The pipeline type param applies to the final operation
+	operations = PipelineOperations{
+		PipelineOperation{Name: "crop", Params: map[string]interface{}{"width": 300}},
+	}
+	img, err = Pipeline(buf, ImageOptions{Operations: operations, Type: PNG})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImagePNG {
+		t.Error(InvalidMimeType)
+	}
+
+	invalid := []struct {
+		operations PipelineOperations
+		typeName   string
+	}{
+		{PipelineOperations{{Name: "crop", Params: map[string]interface{}{"width": 300, "type": "webp"}}}, PNG},
+		{PipelineOperations{{Name: "crop", Params: map[string]interface{}{"width": 300, "type": RAW}}}, ""},
+		{PipelineOperations{{Name: "crop", Params: map[string]interface{}{"width": 300, "type": "bmp"}}}, ""},
+		{PipelineOperations{
+			{Name: "crop", Params: map[string]interface{}{"width": 300, "type": "pdf"}},
+			{Name: "blur", Params: map[string]interface{}{"sigma": 2.0}},
+		}, ""},
+	}
+	for _, c := range invalid {
+		if _, err := Pipeline(buf, ImageOptions{Operations: c.operations, Type: c.typeName}); err == nil {
+			t.Errorf("Expected an error with %+v and type %q", c.operations, c.typeName)
+		}
+	}
+}
+
+func TestImagePipelineParamError(t *testing.T) {
+	operations := PipelineOperations{
+		PipelineOperation{Name: "crop", Params: map[string]interface{}{"width": 300}},
+		PipelineOperation{Name: "blur", Params: map[string]interface{}{"sigma": true}},
+	}
+
+	_, err := Pipeline([]byte("source"), ImageOptions{Operations: operations})
+	xerr, ok := err.(Error)
+	if !ok || xerr.Param == nil {
+		t.Fatalf("Expected an error detailing the param, got %v", err)
+	}
+	if *xerr.Param != (ParamError{Step: 1, Name: "sigma", Expected: "float"}) {
+		t.Errorf("Invalid param error: %+v", xerr.Param)
+	}
+}
+
+func TestImagePipelineSkippedSteps(t *testing.T) {
+	operations := PipelineOperations{
+		PipelineOperation{Name: "adjust", IgnoreFailure: true},
+		PipelineOperation{Name: "gamma", IgnoreFailure: true},
+	}
+
+	buf := []byte("source")
+	img, err := Pipeline(buf, ImageOptions{Operations: operations})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if string(img.Body) != "source" {
+		t.Error("Expected the source image to be returned")
+	}
+
+	skipped := img.Header.Values(PipelineSkippedHeader)
+	expected := []string{
+		`0;operation=adjust;error="Missing required param: brightness or contrast"`,
+		`1;operation=gamma;error="Missing required param: gamma"`,
+	}
+	if len(skipped) != len(expected) {
+		t.Fatalf("Invalid skipped steps: %v", skipped)
+	}
+	for i := range expected {
+		if skipped[i] != expected[i] {
+			t.Errorf("Invalid skipped step: %s, expected: %s", skipped[i], expected[i])
+		}
+	}
+
+	timing := img.Header.Values(PipelineTimingHeader)
+	if len(timing) != 2 {
+		t.Fatalf("Invalid timing steps: %v", timing)
+	}
+	for i, name := range []string{"adjust", "gamma"} {
+		prefix := fmt.Sprintf("%d;operation=%s;dur=", i, name)
+		if !strings.HasPrefix(timing[i], prefix) || !strings.HasSuffix(timing[i], ";skipped") {
+			t.Errorf("Invalid timing step: %s", timing[i])
+		}
+	}
+}
+
+func TestPipelineTimingStep(t *testing.T) {
+	step := pipelineTimingStep(1, "resize", 12345*time.Microsecond)
+	if step != "1;operation=resize;dur=12.345" {
+		t.Errorf("Invalid timing step: %s", step)
+	}
+}
+
+func TestImageResizeModes(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	cases := []struct {
+		mode          string
+		width, height int
+	}{
+		// 550x740 covering 300x300, cropped
+		{ResizeFill, 300, 300},
+		// 550x740 -> 222.9x300
+		{ResizeInside, 223, 300},
+		// 550x740 -> 300x403.6
+		{ResizeOutside, 300, 404},
+	}
+	for _, tc := range cases {
+		img, err := Resize(buf, ImageOptions{Width: 300, Height: 300, Mode: tc.mode})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if err := assertSize(img.Body, tc.width, tc.height); err != nil {
+			t.Errorf("%s: %s", tc.mode, err)
+		}
+	}
+
+	if _, err := Resize(buf, ImageOptions{Width: 300, Mode: ResizeInside}); err == nil {
+		t.Error("The inside mode should require both dimensions")
+	}
+	if _, err := Resize(buf, ImageOptions{Width: 300, Height: 300, Mode: "contain"}); err == nil {
+		t.Error("Unsupported modes should be rejected")
+	}
+}
+
+func TestCalculateDestinationCoverDimension(t *testing.T) {
+	cases := []struct {
+		imageWidth, imageHeight   int
+		optionWidth, optionHeight int
+		coverWidth, coverHeight   int
+	}{
+		// Leading width
+		{1280, 1000, 710, 100, 710, 555},
+		{1299, 2000, 710, 999, 710, 1093},
+		{500, 900, 100, 100, 100, 180},
+		// Leading height
+		{900, 500, 100, 100, 180, 100},
+	}
+
+	for _, tc := range cases {
+		coverWidth, coverHeight := calculateDestinationCoverDimension(tc.imageWidth, tc.imageHeight, tc.optionWidth,
+			tc.optionHeight)
+		if coverWidth != tc.coverWidth || coverHeight != tc.coverHeight {
+			t.Errorf("Expected %dx%d, got %dx%d for %+v", tc.coverWidth, tc.coverHeight, coverWidth, coverHeight, tc)
+		}
+	}
+}
+
 func TestCalculateDestinationFitDimension(t *testing.T) {
 	cases := []struct {
 		// Image
@@ -181,3 +691,92 @@ func TestCalculateDestinationFitDimension(t *testing.T) {
 		}
 	}
 }
+
+func TestFocalCropWindow(t *testing.T) {
+	cases := []struct {
+		imageWidth, imageHeight int
+		cropWidth, cropHeight   int
+		fx, fy                  float64
+		expected                [6]int
+	}{
+		// Centered
+		{1000, 500, 200, 200, 0.5, 0.5, [6]int{400, 200, 100, 0, 200, 200}},
+		// Focal point on the right, clamped to the edge
+		{1000, 500, 200, 200, 0.9, 0.5, [6]int{400, 200, 200, 0, 200, 200}},
+		{1000, 500, 200, 200, 0.3, 0.5, [6]int{400, 200, 20, 0, 200, 200}},
+		// Portrait crop of a portrait image
+		{500, 1000, 250, 250, 0.5, 0.2, [6]int{250, 500, 0, 0, 250, 250}},
+		{500, 1000, 250, 250, 0.5, 0.6, [6]int{250, 500, 0, 175, 250, 250}},
+		// Only width given, keeping the image aspect ratio
+		{1000, 500, 400, 0, 0, 0, [6]int{400, 200, 0, 0, 400, 200}},
+		// Never enlarged
+		{300, 200, 600, 100, 0.5, 0.5, [6]int{300, 200, 0, 50, 300, 100}},
+	}
+
+	for _, c := range cases {
+		rw, rh, left, top, width, height := focalCropWindow(c.imageWidth, c.imageHeight, c.cropWidth, c.cropHeight, c.fx, c.fy)
+		if actual := [6]int{rw, rh, left, top, width, height}; actual != c.expected {
+			t.Errorf("Invalid focal crop window for %+v: %v", c, actual)
+		}
+	}
+}
+
+func TestImageFocalCrop(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	opts := ImageOptions{Width: 200, Height: 200, FocalX: 0.2, FocalY: 0.8}
+	opts.IsDefinedField.FocalX = true
+	opts.IsDefinedField.FocalY = true
+
+	img, err := Crop(buf, opts)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if assertSize(img.Body, 200, 200) != nil {
+		t.Errorf(InvalidImageSize, 200, 200)
+	}
+
+	opts.FocalX = 1.5
+	if _, err := Crop(buf, opts); err == nil {
+		t.Error("Expected out of range focal point to result in an error")
+	}
+}
+
+func TestFallbackImageType(t *testing.T) {
+	cases := []struct {
+		format   string
+		expected bimg.ImageType
+		valid    bool
+	}{
+		{"", bimg.JPEG, true},
+		{JPEG, bimg.JPEG, true},
+		{PNG, bimg.PNG, true},
+		{FallbackNone, bimg.UNKNOWN, true},
+		{"gif", bimg.UNKNOWN, false},
+	}
+
+	for _, c := range cases {
+		if imageType, valid := fallbackImageType(c.format); imageType != c.expected || valid != c.valid {
+			t.Errorf("Invalid fallback of %q: %v, %v", c.format, imageType, valid)
+		}
+	}
+}
+
+func TestWatermarkImageLimits(t *testing.T) {
+	t.Cleanup(func() { LoadLayerLimits(ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels}) })
+	LoadLayerLimits(ServerOptions{MaxAllowedPixels: 0.1})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		buf, _ := os.ReadFile("testdata/" + ImaginaryJpeg)
+		_, _ = w.Write(buf)
+	}))
+	defer ts.Close()
+
+	buf, _ := os.ReadFile("testdata/test.png")
+	if _, err := WatermarkImage(buf, ImageOptions{Image: ts.URL}); err != ErrResolutionTooBig {
+		t.Errorf("Expected the watermark above the resolution limit to be rejected, got %v", err)
+	}
+	if _, err := WatermarkImage(buf, ImageOptions{Image: ts.URL, Opacity: 1.5}); err == nil {
+		t.Error("Expected an out of range opacity to be rejected")
+	}
+}
@@ -21,7 +21,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
 	"net/url"
 	"os"
 	"runtime"
@@ -43,6 +42,7 @@ var (
 	aVersl              = flag.Bool("version", false, "Show version")
 	aHelp               = flag.Bool("h", false, "Show help")
 	aHelpl              = flag.Bool("help", false, "Show help")
+	aValidateConfig     = flag.Bool("validate-config", false, "Validate the configuration and exit")
 	aPathPrefix         = flag.String("path-prefix", "/", "Url path prefix to listen to")
 	aCors               = flag.Bool("cors", false, "Enable CORS support")
 	aGzip               = flag.Bool("gzip", false, "Enable gzip compression (deprecated)")
@@ -51,27 +51,77 @@ var (
 	aAllowInsecureSSL   = flag.Bool("insecure", false, "Allow connections to endpoints with insecure SSL certificates. -enable-url-source flag must be defined. Note: Should only be used in development.") //nolint:lll
 	aEnablePlaceholder  = flag.Bool("enable-placeholder", false, "Enable image response placeholder to be used in case of error")                                                                           //nolint:lll
 	aEnableURLSignature = flag.Bool("enable-url-signature", false, "Enable URL signature (URL-safe Base64-encoded HMAC digest)")                                                                            //nolint:lll
+	aEnableEarlyHints   = flag.Bool("enable-early-hints", false, "Enable 103 Early Hints for the sibling variants listed by the preload param")                                                             //nolint:lll
+	aFaceDetection      = flag.Bool("enable-face-detection", false, "Enable face detection for the face gravity. Note: Detection is CPU intensive")                                                         //nolint:lll
+	aRedactGPS          = flag.Bool("redact-gps", false, "Redact the GPS location from the metadata returned by the info endpoint")                                                                         //nolint:lll
+	aFallbackFormat     = flag.String("fallback-format", DefaultFallbackFormat, "Format used when WebP or HEIF encoding fails. Allowed values are: jpeg, png and none, replying 406 instead")               //nolint:lll
+	aAutoQualitySSIM    = flag.Float64("auto-quality-ssim", DefaultAutoQualitySSIM, "Minimum SSIM, between 0 and 1, of the images encoded with quality=auto")                                               //nolint:lll
+	aCMYKProfile        = flag.String("cmyk-profile", "", "ICC profile converting the CMYK images without embedded profile to sRGB")                                                                        //nolint:lll
+	aPremultiplyAlpha   = flag.Bool("premultiply-alpha", false, "Resize the transparent images with premultiplied alpha, avoiding dark fringes")                                                            //nolint:lll
+	aPresetsFile        = flag.String("presets-file", "", "JSON file of named presets, mapping their names to params. E.g: {\"thumb\": \"width=200\"}")                                                     //nolint:lll
+	aPresetsOnly        = flag.Bool("presets-only", false, "Only allow the image params given by a preset")                                                                                                 //nolint:lll
+	aFontsDir           = flag.String("fonts-dir", "", "Directory of font files available to text rendering, e.g. emoji or CJK fonts")                                                                      //nolint:lll
+	aFontFallback       = flag.String("font-fallback", "", "Comma separated font families rendering the characters the requested font lacks. E.g: Noto Emoji,Noto Sans CJK JP")                             //nolint:lll
+	aDefaultFont        = flag.String("default-font", "", "Pango font description of the text when no font param is given. E.g: DejaVu Sans 12")                                                            //nolint:lll
+	aAllowedFonts       = flag.String("allowed-fonts", "", "Comma separated font families the font param may use. Any installed font is allowed by default")                                                //nolint:lll
 	aURLSignatureKey    = flag.String("url-signature-key", "", "The URL signature key (32 characters minimum)")
+	aStoreRegion        = flag.String("store-region", "us-east-1", "Region of the -store-endpoint storage")
+	aRespSignatureKey   = flag.String("response-signature-key", "", "HMAC-SHA256 key signing the image responses (32 characters minimum)")                                                             //nolint:lll
+	aRespSignatureEdKey = flag.String("response-signature-ed25519-key", "", "Path of the PEM encoded Ed25519 private key signing the image responses")                                                 //nolint:lll
+	aCallbackOrigins    = flag.String("callback-origins", "", "Comma separated origins the callback param of the asynchronous jobs may notify")                                                        //nolint:lll
+	aPublicURL          = flag.String("public-url", "", "Public base URL of the server locating the job results notified by the callbacks. E.g: https://imaginary.example.com")                        //nolint:lll
+	aStoreEndpoint      = flag.String("store-endpoint", "", "S3-compatible storage endpoint the store param uploads the outputs to. E.g: https://s3.eu-west-1.amazonaws.com")                          //nolint:lll
+	aStoreBuckets       = flag.String("store-buckets", "", "Comma separated buckets, optionally followed by a key prefix, the store param may upload to")                                              //nolint:lll
 	aAllowedOrigins     = flag.String("allowed-origins", "", "Restrict remote image source processing to certain origins (separated by commas). Note: Origins are validated against host *AND* path.") //nolint:lll
-	aMaxAllowedSize     = flag.Int("max-allowed-size", 0, "Restrict maximum size of http image source (in bytes)")                                                                                     //nolint:lll
-	aMaxAllowedPixels   = flag.Float64("max-allowed-resolution", 18.0, "Restrict maximum resolution of the image (in megapixels)")                                                                     //nolint:lll
+	aOriginConcurrency  = flag.Int("origin-concurrency", 0, "Maximum number of concurrent remote image fetches per origin host")
+	aOriginRate         = flag.Int("origin-rate", 0, "Maximum number of remote image fetches per second per origin host")
+	aStaleIfError       = flag.Int("stale-if-error", 0, "Time in seconds remote images are served stale on origin errors")
+	aStaleCacheSize     = flag.Int("stale-cache-size", 0, "Maximum size in bytes of the stale remote images cache")
+	aOriginQueueTimeout = flag.Int("origin-queue-timeout", DefaultOriginQueueTimeout, "Maximum time in seconds a remote image fetch waits for its origin host") //nolint:lll
+	aMaxAllowedSize     = flag.Int("max-allowed-size", 0, "Restrict maximum size of http image source (in bytes)")                                              //nolint:lll
+	aMaxBodySize        = flag.Int("max-body-size", 0, "Restrict maximum size of request bodies, e.g. uploaded images (in bytes)")                              //nolint:lll
+	aMaxAllowedPixels   = flag.Float64("max-allowed-resolution", DefaultMaxAllowedPixels, "Restrict maximum resolution of the image (in megapixels)")           //nolint:lll
+	aPixelCacheSize     = flag.Int("pixel-cache-size", 0, "Maximum size in bytes of the in-memory cache of the pixels decoded by the Go pixel operations")      //nolint:lll
 	aKey                = flag.String("key", "", "Define API key for authorization")
-	aMount              = flag.String("mount", "", "Mount server local directory")
+	aLowMemoryCooldown  = flag.Int("low-memory-cooldown", DefaultLowMemoryCooldown, "Time in seconds large images are rejected after libvips runs out of memory")                             //nolint:lll
+	aTrustedKeys        = flag.String("trusted-keys", "", "Comma separated API keys allowed to exceed -max-allowed-resolution up to their own ceiling (in megapixels). E.g: key1:80,key2:40") //nolint:lll
+	aMount              = newListFlag("mount", "Mount server local directory, or name:directory to select it with file=name:path. Can be repeated")                                           //nolint:lll
+	aMountAllow         = flag.String("mount-allow", "", "Comma separated name:pattern file path patterns the named mounts are restricted to. E.g: assets:*.png")                             //nolint:lll
+	aMountCacheTTL      = flag.String("mount-cache-ttl", "", "Comma separated TTLs in seconds per named mount, overriding -http-cache-ttl. E.g: assets:31556926")                             //nolint:lll
+	aTmpDir             = flag.String("tmp-dir", "", "Directory, e.g. a tmpfs mount, staging the temporary files such as large uploads. Defaults to the system one")                          //nolint:lll
+	aTmpDirQuota        = flag.Int("tmp-dir-quota", 0, "Maximum size in bytes of the temporary files staged in -tmp-dir, rejecting uploads with 503 when exceeded")                           //nolint:lll
 	aCertFile           = flag.String("certfile", "", "TLS certificate file path")
 	aKeyFile            = flag.String("keyfile", "", "TLS private key file path")
 	aAuthorization      = flag.String("authorization", "", "Defines a constant Authorization header value passed to all the image source servers. -enable-url-source flag must be defined. This overwrites authorization headers forwarding behavior via X-Forward-Authorization")                                                                                                                                        //nolint:lll
 	aForwardHeaders     = flag.String("forward-headers", "", "Forwards custom headers to the image source server. -enable-url-source flag must be defined.")                                                                                                                                                                                                                                                              //nolint:lll
+	aRequestIDHeader    = flag.String("request-id-header", DefaultRequestIDHeader, "Header carrying the request ID, generated if missing and forwarded to the image source server. Empty disables it")                                                                                                                                                                                                                    //nolint:lll
 	aSrcResponseHeaders = flag.String("source-response-headers", "", "Returns selected headers from the source image server response. Has precedence over -http-cache-ttl when cache-control is specified and the source response has a cache-control header, otherwise falls back to -http-cache-ttl value if provided. Missing and/or unlisted response headers are ignored. -enable-url-source flag must be defined.") //nolint:lll
+	aSurrogateKeyHeader = flag.String("surrogate-key-header", "", "Header carrying the CDN cache tags of the image, e.g. Surrogate-Key or Cache-Tag. Empty disables it")                                                                                                                                                                                                                                                  //nolint:lll
+	aSurrogateKeys      = flag.String("surrogate-keys", DefaultSurrogateKeys, "Comma separated cache tags derived from the image source. Allowed values are: hash, host and tenant")                                                                                                                                                                                                                                      //nolint:lll
 	aPlaceholder        = flag.String("placeholder", "", "Image path to image custom placeholder to be used in case of error. Recommended minimum image size is: 1200x1200")                                                                                                                                                                                                                                              //nolint:lll
 	aPlaceholderStatus  = flag.Int("placeholder-status", 0, "HTTP status returned when use -placeholder flag")
 	aDisableEndpoints   = flag.String("disable-endpoints", "", "Comma separated endpoints to disable. E.g: form,crop,rotate,health") //nolint:lll
 	aHTTPCacheTTL       = flag.Int("http-cache-ttl", -1, "The TTL in seconds")
+	aEndpointCacheTTL   = flag.String("endpoint-cache-ttl", "", "Comma separated TTLs in seconds per endpoint, overriding -http-cache-ttl. E.g: thumbnail:31556926,info:0") //nolint:lll
 	aReadTimeout        = flag.Int("http-read-timeout", 60, "HTTP read timeout in seconds")
 	aWriteTimeout       = flag.Int("http-write-timeout", 60, "HTTP write timeout in seconds")
+	aHeaderTimeout      = flag.Int("http-read-header-timeout", 0, "HTTP read header timeout in seconds. Defaults to the read timeout")
+	aIdleTimeout        = flag.Int("http-idle-timeout", 0, "HTTP keep-alive idle timeout in seconds. Defaults to the read timeout")
+	aMaxConnections     = flag.Int("max-connections", 0, "Maximum number of simultaneous HTTP connections")
+	aMinReadRate        = flag.Int("min-read-rate", 0, "Minimum request body read rate in bytes per second")
+	aProcessingTimeout  = flag.Int("processing-timeout", 0, "Image processing time budget in milliseconds, replying 504 or the placeholder when exceeded")
+	aBatchWriteTimeout  = flag.Int("http-batch-write-timeout", 0, "HTTP write timeout in seconds for the batch and pipeline endpoints. Defaults to the write timeout") //nolint:lll
+	aOverloadRequests   = flag.Int("overload-requests", 0, "Number of image requests in progress flipping /ready to not ready")                                        //nolint:lll
+	aOverloadLatency    = flag.Int("overload-latency", 0, "Average image processing latency in milliseconds flipping /ready to not ready")                             //nolint:lll
+	aJobsWorkers        = flag.Int("jobs-workers", 0, "Number of workers processing the asynchronous jobs, enabling the async param")                                  //nolint:lll
+	aJobsQueue          = flag.Int("jobs-queue", 100, "Maximum number of asynchronous jobs waiting for a worker")                                                      //nolint:lll
+	aJobsTTL            = flag.Int("jobs-ttl", 600, "Retention in seconds of the status and result of the completed asynchronous jobs")                                //nolint:lll
+	aJobsResultsSize    = flag.Int("jobs-results-size", 268435456, "Maximum size in bytes of the retained results of the completed asynchronous jobs")                 //nolint:lll
 	aConcurrency        = flag.Int("concurrency", 0, "Throttle concurrency limit per second")
 	aBurst              = flag.Int("burst", 100, "Throttle burst max cache size")
+	aTenantDailyQuota   = flag.Int("tenant-daily-quota", 0, "Maximum bytes served per tenant, identified by its API key, per UTC day. Replies 429 when exceeded") //nolint:lll
 	aMRelease           = flag.Int("mrelease", 30, "OS memory release interval in seconds")
-	aLogLevel           = flag.String("log-level", "info", "Define log level for http-server. E.g: info,warning,error")
+	aLogLevel           = flag.String("log-level", "info", "Define log level for http-server. E.g: debug,info,warning,error")
 	aReturnSize         = flag.Bool("return-size", false, "Return the image size in the HTTP headers")
 )
 
@@ -92,6 +142,7 @@ Usage:
   imaginary -enable-url-source -placeholder ./placeholder.jpg
   imaginary -enable-url-signature -url-signature-key 4f46feebafc4b5e988f131c4ff8b5997
   imaginary -enable-url-source -forward-headers X-Custom,X-Token
+  imaginary -enable-url-source -validate-config
   imaginary -h | -help
   imaginary -v | -version
 
@@ -103,15 +154,25 @@ Options:
   -qpp <port>                          QUIC Public Port (port on which the reverse proxy or load-balancer listen")
   -h, -help                            Show help
   -v, -version                         Show version
+  -validate-config                     Validate the configuration and exit. Exits with code 2 if invalid
   -path-prefix <value>                 Url path prefix to listen to [default: "/"]
   -cors                                Enable CORS support [default: false]
   -gzip                                Enable gzip compression (deprecated) [default: false]
   -disable-endpoints                   Comma separated endpoints to disable. E.g: form,crop,rotate,health [default: ""]
   -key <key>                           Define API key for authorization
-  -mount <path>                        Mount server local directory
+  -mount <[name:]path>                 Mount server local directory. Named mounts are selected with file=name:path. Can be repeated
+  -mount-allow <name:pattern>          Comma separated file path patterns a named mount is restricted to. E.g: assets:*.png,assets:icons/*.svg
+  -mount-cache-ttl <name:ttl>          Comma separated TTLs in seconds per named mount, overriding -http-cache-ttl, -endpoint-cache-ttl and the preset TTLs. E.g: assets:31556926
   -http-cache-ttl <num>                The TTL in seconds. Adds caching headers to locally served files.
+  -endpoint-cache-ttl <endpoint:num>   Comma separated TTLs in seconds per endpoint, overriding -http-cache-ttl. 0 disables caching. E.g: thumbnail:31556926,info:0
   -http-read-timeout <num>             HTTP read timeout in seconds [default: 30]
   -http-write-timeout <num>            HTTP write timeout in seconds [default: 30]
+  -http-read-header-timeout <num>      HTTP read header timeout in seconds [default: read timeout]
+  -http-idle-timeout <num>             HTTP keep-alive idle timeout in seconds [default: read timeout]
+  -http-batch-write-timeout <num>      HTTP write timeout in seconds for the batch and pipeline endpoints [default: write timeout]
+  -max-connections <num>               Maximum number of simultaneous HTTP connections [default: unlimited]
+  -min-read-rate <bytes>               Minimum request body read rate in bytes per second, after a 5 seconds grace period [default: disabled]
+  -processing-timeout <ms>             Image processing time budget in milliseconds. Replies 504, or the placeholder if enabled, when exceeded [default: disabled]
   -enable-url-source                   Enable remote HTTP URL image source processing
   -insecure                            Allow connections to endpoints with insecure SSL certificates.
                                        -enable-url-source flag must be defined.
@@ -119,23 +180,74 @@ Options:
   -enable-placeholder                  Enable image response placeholder to be used in case of error [default: false]
   -enable-auth-forwarding              Forwards X-Forward-Authorization or Authorization header to the image source server. -enable-url-source flag must be defined. Tip: secure your server from public access to prevent attack vectors
   -forward-headers                     Forwards custom headers to the image source server. -enable-url-source flag must be defined.
+  -request-id-header <name>            Header carrying the request ID, generated if missing, logged and forwarded to the image source server.
+                                       Pass an empty value to disable it [default: X-Request-ID]
   -source-response-headers             Returns selected headers from the source image server response. Has precedence over -http-cache-ttl when cache-control is specified and the source response has a cache-control header, otherwise falls back to -http-cache-ttl value if provided. Missing and/or unlisted response headers are ignored. -enable-url-source flag must be defined.
+  -surrogate-key-header <name>         Header carrying the CDN cache tags of the image, e.g. Surrogate-Key (Fastly) or Cache-Tag (Cloudflare) [default: disabled]
+  -surrogate-keys <keys>               Comma separated cache tags derived from the image source. Allowed values are: hash, host and tenant [default: hash,host,tenant]
   -enable-url-signature                Enable URL signature (URL-safe Base64-encoded HMAC digest) [default: false]
   -url-signature-key                   The URL signature key (32 characters minimum)
+  -response-signature-key <key>        HMAC-SHA256 key (32 characters minimum) signing the image responses in the X-Signature header [default: disabled]
+  -response-signature-ed25519-key <path>
+                                       PEM encoded PKCS #8 Ed25519 private key signing the image responses in the X-Signature header [default: disabled]
+  -enable-early-hints                  Enable 103 Early Hints for the sibling variants listed by the preload param [default: false]
+  -enable-face-detection               Enable face detection for the face gravity, keeping faces in frame when cropping.
+                                       Note: Detection is CPU intensive [default: false]
+  -redact-gps                          Redact the GPS location from the full metadata returned by the info endpoint [default: false]
+  -fallback-format <format>            Format used when WebP or HEIF encoding fails. Allowed values are: jpeg, png and none,
+                                       replying 406 instead [default: jpeg]
+  -auto-quality-ssim <ssim>            Minimum similarity to the lossless image, between 0 and 1, of the images encoded
+                                       with quality=auto. Higher values keep more details [default: 0.98]
+  -cmyk-profile <path>                 ICC profile converting the CMYK JPEG and TIFF images without embedded profile to sRGB,
+                                       e.g. a FOGRA39 or SWOP one [default: libvips built-in profile]
+  -premultiply-alpha                   Downscale the images with transparency with their colors premultiplied by the alpha,
+                                       avoiding dark fringes around the transparent areas [default: false]
+  -presets-file <path>                 JSON file of named presets applied by the preset param, mapping their names to
+                                       params, e.g. {"thumb": "width=200&height=200&type=webp&quality=80"}
+  -presets-only                        Only allow the image params given by a preset, rejecting the others [default: false]
+  -fonts-dir <path>                    Directory of font files (ttf, otf and ttc) available to text rendering, e.g. emoji or CJK fonts
+  -font-fallback <families>            Comma separated font families rendering the characters the requested font lacks.
+                                       E.g: Noto Emoji,Noto Sans CJK JP
+  -default-font <font>                 Pango font description of the text when no font param is given. E.g: DejaVu Sans 12
+  -allowed-fonts <families>            Comma separated font families the font param may use. Any installed font is allowed
+                                       by default
   -allowed-origins <urls>              Restrict remote image source processing to certain origins (separated by commas)
   -max-allowed-size <bytes>            Restrict maximum size of http image source (in bytes)
+  -max-body-size <bytes>               Restrict maximum size of request bodies, e.g. uploaded images (in bytes) [default: unlimited]
+  -origin-concurrency <num>            Maximum number of concurrent remote image fetches per origin host [default: unlimited]
+  -origin-rate <num>                   Maximum number of remote image fetches per second per origin host [default: unlimited]
+  -origin-queue-timeout <num>          Maximum time in seconds a remote image fetch waits for its origin host [default: 10]
+  -stale-if-error <num>                Time in seconds processed remote images are served stale, with a Warning header, when their origin fails [default: disabled]
+  -stale-cache-size <bytes>            Maximum size of the in-memory cache of processed remote images served stale on origin errors. Required by -stale-if-error
   -max-allowed-resolution <megapixels> Restrict maximum resolution of the image [default: 18.0]
+  -low-memory-cooldown <num>           Time in seconds images larger than a shrinking threshold are rejected with 503 after libvips runs out of memory. 0 disables it [default: 60]
+  -pixel-cache-size <bytes>            Maximum size of the in-memory cache of the pixels decoded by the Go pixel operations, e.g. pixelate or sepia, not by libvips ones [default: disabled]
+  -trusted-keys <key:megapixels>       Comma separated API keys allowed to exceed -max-allowed-resolution up to their own ceiling. E.g: key1:80,key2:40
+  -tmp-dir <path>                      Directory, e.g. a tmpfs mount, staging the temporary files such as uploads exceeding 64 MB and libvips disc caches. Removed on shutdown [default: system temporary directory]
+  -tmp-dir-quota <bytes>               Maximum size of the temporary files staged in -tmp-dir, rejecting uploads with 503 when exceeded [default: unlimited]
   -certfile <path>                     TLS certificate file path
   -keyfile <path>                      TLS private key file path
   -authorization <value>               Defines a constant Authorization header value passed to all the image source servers. -enable-url-source flag must be defined. This overwrites authorization headers forwarding behavior via X-Forward-Authorization
   -placeholder <path>                  Image path to image custom placeholder to be used in case of error. Recommended minimum image size is: 1200x1200
   -placeholder-status <code>           HTTP status returned when use -placeholder flag
+  -overload-requests <num>             Number of image requests in progress flipping /ready to not ready, reporting the load in the X-Load header [default: disabled]
+  -overload-latency <ms>               Average image processing latency over the last 10 seconds flipping /ready to not ready, reporting the load in the X-Load header [default: disabled]
+  -jobs-workers <num>                  Number of workers processing the asynchronous jobs submitted with the async param [default: disabled]
+  -jobs-queue <num>                    Maximum number of asynchronous jobs waiting for a worker, replying 503 when exceeded [default: 100]
+  -jobs-ttl <seconds>                  Retention of the status and result of the completed asynchronous jobs [default: 600]
+  -jobs-results-size <bytes>           Maximum size of the retained results of the completed asynchronous jobs, evicting the oldest first [default: 268435456]
+  -callback-origins <urls>             Restrict the callback param of the asynchronous jobs to certain origins (separated by commas), enabling it [default: disabled]
+  -public-url <url>                    Public base URL of the server, e.g. https://imaginary.example.com, making the job result locations notified by the callbacks absolute [default: relative locations]
+  -store-endpoint <url>                S3-compatible storage endpoint the store param uploads the outputs to, with the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY credentials [default: disabled]
+  -store-region <name>                 Region of the -store-endpoint storage [default: us-east-1]
+  -store-buckets <list>                Comma separated buckets, optionally followed by a key prefix, e.g. assets/thumbs/, the store param may upload to
   -concurrency <num>                   Throttle concurrency limit per second [default: disabled]
   -burst <num>                         Throttle burst max cache size [default: 100]
+  -tenant-daily-quota <bytes>          Maximum bytes served per tenant, identified by its API key, per UTC day. Replies 429 when exceeded [default: unlimited]
   -mrelease <num>                      OS memory release interval in seconds [default: 30]
   -cpus <num>                          Number of used cpu cores.
                                        (default for current machine is %d cores)
-  -log-level                           Set log level for http-server. E.g: info,warning,error [default: info].
+  -log-level                           Set log level for http-server. E.g: debug,info,warning,error [default: info].
                                        Or can use the environment variable GOLANG_LOG=info.
   -return-size                         Return the image size with X-Width and X-Height HTTP header. [default: disabled].
 `
@@ -157,6 +269,9 @@ func main() {
 		_, _ = fmt.Fprintf(os.Stderr, usage, Version, runtime.NumCPU())
 	}
 	flag.Parse()
+	if err := applyEnvFlags(flag.CommandLine, os.LookupEnv); err != nil {
+		exitWithError(newConfigError("%w", err))
+	}
 
 	if *aHelp || *aHelpl {
 		showUsage()
@@ -167,8 +282,7 @@ func main() {
 
 	memoryLimit := getMemoryLimit()
 	if memoryLimit == 0 {
-		log.Panicf("Failed to determine host memory limit")
-		return
+		exitWithError(newRuntimeError("failed to determine host memory limit"))
 	}
 
 	var gcThresholdCoeff = 0.7
@@ -189,22 +303,70 @@ func main() {
 
 	opts := createServerOptions(port, quicPort, quicPublicPort, urlSignature)
 
+	errs := validateServerOptions(opts)
+	if _, err := parseTrustedKeys(*aTrustedKeys); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -trusted-keys flag: %w", err))
+	}
+	if _, err := parseEndpointCacheTTL(*aEndpointCacheTTL); err != nil {
+		errs = append(errs, fmt.Errorf("invalid -endpoint-cache-ttl flag: %w", err))
+	}
+	if _, _, err := parseMounts(*aMount, *aMountAllow, *aMountCacheTTL); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		exitWithError(newConfigErrors(errs))
+	}
+	if *aValidateConfig {
+		fmt.Println("configuration is valid")
+		os.Exit(0)
+	}
+
+	// Set the log level before loading anything, so the debug output of the
+	// loaders isn't dropped
+	if err := SetLogLevel(opts.LogLevel); err != nil {
+		exitWithError(newConfigError("%w", err))
+	}
+
 	handleDeprecationWarnings()
 	configureMemoryRelease()
-	validateMountDirectory()
-	validateCacheTTL(opts)
+	if opts.HTTPCacheTTL == 0 {
+		debug("Adding HTTP cache control headers set to prevent caching.")
+	}
 	managePlaceholderImage(&opts)
-	validateURLSignatureKey(urlSignature, opts)
 
 	debug("imaginary server listening on port :%d/%s", opts.Port, strings.TrimPrefix(opts.PathPrefix, "/"))
 
 	// Load image source providers and start the server
+	LoadStaging(opts)
 	LoadSources(opts)
+	LoadLayerLimits(opts)
+	LoadPixelCache(opts)
+	LoadFaceDetector(opts)
+	LoadBandwidthQuota(opts)
+	LoadStaleCache(opts)
+	LoadMemoryGuard(opts)
+	LoadOverloadMonitor(opts)
+	LoadJobs(opts)
+	LoadResponseSigning(opts)
+	LoadObjectStore(opts)
+	LoadMetadataRedaction(opts)
+	LoadHEIFSupport()
+	LoadEncodeFallback(opts)
+	LoadAutoQuality(opts)
+	LoadCMYKProfile(opts)
+	LoadPremultiplyAlpha(opts)
+	LoadPresets(opts)
+	LoadFonts(opts)
 	Server(opts)
 }
 
 // createServerOptions initializes the ServerOptions
 func createServerOptions(port int, quicPort int, quicPublicPort int, urlSignature URLSignature) ServerOptions {
+	// Invalid values are reported by the startup validation
+	trustedKeys, _ := parseTrustedKeys(*aTrustedKeys)
+	endpointCacheTTL, _ := parseEndpointCacheTTL(*aEndpointCacheTTL)
+	mount, mounts, _ := parseMounts(*aMount, *aMountAllow, *aMountCacheTTL)
+
 	return ServerOptions{
 		Port:               port,
 		QUICPort:           quicPort,
@@ -216,25 +378,77 @@ func createServerOptions(port int, quicPort int, quicPublicPort int, urlSignatur
 		AllowInsecureSSL:   *aAllowInsecureSSL,
 		EnablePlaceholder:  *aEnablePlaceholder,
 		EnableURLSignature: *aEnableURLSignature,
+		EnableEarlyHints:   *aEnableEarlyHints,
+		FaceDetection:      *aFaceDetection,
+		RedactGPS:          *aRedactGPS,
+		FallbackFormat:     *aFallbackFormat,
+		AutoQualitySSIM:    *aAutoQualitySSIM,
+		CMYKProfile:        *aCMYKProfile,
+		PremultiplyAlpha:   *aPremultiplyAlpha,
+		PresetsFile:        *aPresetsFile,
+		PresetsOnly:        *aPresetsOnly,
+		FontsDir:           *aFontsDir,
+		FontFallback:       parseHeadersList(*aFontFallback),
+		DefaultFont:        *aDefaultFont,
+		AllowedFonts:       parseHeadersList(*aAllowedFonts),
 		URLSignatureKey:    urlSignature.Key,
+		ResponseHMACKey:    *aRespSignatureKey,
+		ResponseEd25519Key: *aRespSignatureEdKey,
 		PathPrefix:         *aPathPrefix,
 		APIKey:             *aKey,
 		Concurrency:        *aConcurrency,
 		Burst:              *aBurst,
-		Mount:              *aMount,
+		Mount:              mount,
+		Mounts:             mounts,
+		TmpDir:             *aTmpDir,
+		TmpDirQuota:        *aTmpDirQuota,
 		CertFile:           *aCertFile,
 		KeyFile:            *aKeyFile,
 		Placeholder:        *aPlaceholder,
 		PlaceholderStatus:  *aPlaceholderStatus,
 		HTTPCacheTTL:       *aHTTPCacheTTL,
+		EndpointCacheTTL:   endpointCacheTTL,
 		HTTPReadTimeout:    *aReadTimeout,
 		HTTPWriteTimeout:   *aWriteTimeout,
+		HTTPHeaderTimeout:  *aHeaderTimeout,
+		HTTPIdleTimeout:    *aIdleTimeout,
+		HTTPBatchTimeout:   *aBatchWriteTimeout,
+		MaxConnections:     *aMaxConnections,
+		MinReadRate:        *aMinReadRate,
+		ProcessingTimeout:  *aProcessingTimeout,
+		OverloadRequests:   *aOverloadRequests,
+		OverloadLatency:    *aOverloadLatency,
+		JobsWorkers:        *aJobsWorkers,
+		JobsQueue:          *aJobsQueue,
+		JobsTTL:            *aJobsTTL,
+		JobsResultsSize:    *aJobsResultsSize,
 		Authorization:      *aAuthorization,
 		ForwardHeaders:     parseHeadersList(*aForwardHeaders),
 		SrcResponseHeaders: parseHeadersList(*aSrcResponseHeaders),
+		RequestIDHeader:    *aRequestIDHeader,
+		SurrogateKeyHeader: *aSurrogateKeyHeader,
+		SurrogateKeys:      parseEndpoints(*aSurrogateKeys),
 		AllowedOrigins:     parseOrigins(*aAllowedOrigins),
+		CallbackOrigins:    parseOrigins(*aCallbackOrigins),
+		PublicURL:          strings.TrimSuffix(*aPublicURL, "/"),
+		StoreEndpoint:      *aStoreEndpoint,
+		StoreRegion:        *aStoreRegion,
+		StoreBuckets:       parseHeadersList(*aStoreBuckets),
+		StoreAccessKey:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		StoreSecretKey:     os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		StoreSessionToken:  os.Getenv("AWS_SESSION_TOKEN"),
 		MaxAllowedSize:     *aMaxAllowedSize,
+		MaxBodySize:        *aMaxBodySize,
+		OriginConcurrency:  *aOriginConcurrency,
+		OriginRate:         *aOriginRate,
+		OriginQueueTimeout: *aOriginQueueTimeout,
 		MaxAllowedPixels:   *aMaxAllowedPixels,
+		PixelCacheSize:     *aPixelCacheSize,
+		LowMemoryCooldown:  *aLowMemoryCooldown,
+		StaleIfError:       *aStaleIfError,
+		StaleCacheSize:     *aStaleCacheSize,
+		TenantDailyQuota:   *aTenantDailyQuota,
+		TrustedKeys:        trustedKeys,
 		LogLevel:           getLogLevel(*aLogLevel),
 		ReturnSize:         *aReturnSize,
 		Endpoints:          parseEndpoints(*aDisableEndpoints),
@@ -255,31 +469,17 @@ func configureMemoryRelease() {
 	}
 }
 
-// validateMountDirectory checks if the mount directory exists
-func validateMountDirectory() {
-	if *aMount != "" {
-		checkMountDirectory(*aMount)
-	}
-}
-
-// validateCacheTTL checks the HTTP cache parameter
-func validateCacheTTL(opts ServerOptions) {
-	if opts.HTTPCacheTTL != -1 {
-		checkHTTPCacheTTL(opts.HTTPCacheTTL)
-	}
-}
-
 // managePlaceholderImage configures the placeholder image
 func managePlaceholderImage(opts *ServerOptions) {
 	if *aPlaceholder != "" {
 		buf, err := os.ReadFile(*aPlaceholder)
 		if err != nil {
-			exitWithError("cannot start the server: %s", err)
+			exitWithError(newConfigError("cannot read placeholder image: %w", err))
 		}
 
 		imageType := bimg.DetermineImageType(buf)
 		if !bimg.IsImageTypeSupportedByVips(imageType).Load {
-			exitWithError("Placeholder image type is not supported. Only JPEG, PNG or WEBP are supported")
+			exitWithError(newConfigError("placeholder image type is not supported. Only JPEG, PNG or WEBP are supported"))
 		}
 
 		opts.PlaceholderImage = buf
@@ -289,19 +489,6 @@ func managePlaceholderImage(opts *ServerOptions) {
 	}
 }
 
-// validateURLSignatureKey checks the URL signature key if required
-func validateURLSignatureKey(urlSignature URLSignature, opts ServerOptions) {
-	if opts.EnableURLSignature {
-		if urlSignature.Key == "" {
-			exitWithError("URL signature key is required")
-		}
-
-		if len(urlSignature.Key) < 32 {
-			exitWithError("URL signature key must be a minimum of 32 characters")
-		}
-	}
-}
-
 func getPort(port int) int {
 	if portEnv := os.Getenv("PORT"); portEnv != "" {
 		newPort, _ := strconv.Atoi(portEnv)
@@ -344,6 +531,9 @@ func getLogLevel(logLevel string) string {
 	if logLevelEnv := os.Getenv("GOLANG_LOG"); logLevelEnv != "" {
 		logLevel = logLevelEnv
 	}
+	if debug := os.Getenv("DEBUG"); debug == "imaginary" || debug == "*" {
+		logLevel = "debug"
+	}
 	return logLevel
 }
 
@@ -357,29 +547,6 @@ func showVersion() {
 	os.Exit(1)
 }
 
-func checkMountDirectory(path string) {
-	src, err := os.Stat(path)
-	if err != nil {
-		exitWithError("error while mounting directory: %s", err)
-	}
-	if !src.IsDir() {
-		exitWithError("mount path is not a directory: %s", path)
-	}
-	if path == "/" {
-		exitWithError("cannot mount root directory for security reasons")
-	}
-}
-
-func checkHTTPCacheTTL(ttl int) {
-	if ttl < 0 || ttl > 31556926 {
-		exitWithError("The -http-cache-ttl flag only accepts a value from 0 to 31556926")
-	}
-
-	if ttl == 0 {
-		debug("Adding HTTP cache control headers set to prevent caching.")
-	}
-}
-
 func parseHeadersList(headerString string) []string {
 	var headers []string
 	if headerString == "" {
@@ -430,6 +597,53 @@ func parseEndpoints(input string) Endpoints {
 	return endpoints
 }
 
+// parseTrustedKeys parses a comma separated list of key:megapixels pairs.
+func parseTrustedKeys(input string) (map[string]float64, error) {
+	keys := make(map[string]float64)
+	for _, entry := range strings.Split(input, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("missing megapixels ceiling for key in %q", entry)
+		}
+
+		ceiling, err := strconv.ParseFloat(entry[i+1:], 64)
+		if err != nil || ceiling <= 0 {
+			return nil, fmt.Errorf("invalid megapixels ceiling in %q", entry)
+		}
+		keys[entry[:i]] = ceiling
+	}
+	return keys, nil
+}
+
+// parseEndpointCacheTTL parses a comma separated list of endpoint:ttl pairs.
+func parseEndpointCacheTTL(input string) (map[string]int, error) {
+	ttls := make(map[string]int)
+	for _, entry := range strings.Split(input, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		endpoint, value, ok := strings.Cut(entry, ":")
+		endpoint = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(endpoint, "/")))
+		if !ok || endpoint == "" {
+			return nil, fmt.Errorf("missing TTL for endpoint in %q", entry)
+		}
+
+		ttl, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || ttl < 0 || ttl > MaxHTTPCacheTTL {
+			return nil, fmt.Errorf("invalid TTL in %q, only a value from 0 to %d is accepted", entry, MaxHTTPCacheTTL)
+		}
+		ttls[endpoint] = ttl
+	}
+	return ttls, nil
+}
+
 func memoryRelease(interval int) {
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	go func() {
@@ -440,14 +654,6 @@ func memoryRelease(interval int) {
 	}()
 }
 
-func exitWithError(format string, args ...interface{}) {
-	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args)
-	os.Exit(1)
-}
-
 func debug(msg string, values ...interface{}) {
-	debug := os.Getenv("DEBUG")
-	if debug == "imaginary" || debug == "*" {
-		log.Printf(msg, values...)
-	}
+	logf(LogLevelDebug, msg, values...)
 }
@@ -0,0 +1,303 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobError   = "error"
+)
+
+var (
+	ErrJobsDisabled  = NewError("Invalid param: async requires the -jobs-workers flag", http.StatusBadRequest)
+	ErrJobsQueueFull = NewError("Too many asynchronous jobs queued, try again later", http.StatusServiceUnavailable)
+	ErrJobNotFound   = NewError("Job not found or expired", http.StatusNotFound)
+	ErrJobNotDone    = NewError("Job not done yet", http.StatusConflict)
+)
+
+// jobs runs the image requests submitted with the async param. It is nil,
+// hence asynchronous jobs are rejected, if -jobs-workers is not set.
+var jobs *jobQueue
+
+// Job is the status of an asynchronous job, as reported by the jobs
+// endpoint.
+type Job struct {
+	ID      string    `json:"id"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+	// Result is the URL of the processed image once the job is done.
+	Result string `json:"result,omitempty"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+type job struct {
+	Job
+	run      func() (Image, error)
+	image    Image
+	vary     string
+	callback *jobCallback
+	finished time.Time
+}
+
+// jobQueue processes the jobs with a fixed pool of workers, so large
+// sources, e.g. multi-page TIFF or PDF documents, don't need to be processed
+// within the HTTP timeouts. Jobs are kept in memory until they expire, their
+// TTL starting once completed, or until the results of the more recent ones
+// exceed maxResults bytes.
+type jobQueue struct {
+	ttl        time.Duration
+	maxResults int
+	pending    chan *job
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	results int
+}
+
+func newJobQueue(workers, size int, ttl time.Duration, maxResults int) *jobQueue {
+	q := &jobQueue{ttl: ttl, maxResults: maxResults, pending: make(chan *job, size), jobs: make(map[string]*job)}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// LoadJobs starts the workers of the asynchronous jobs when configured.
+func LoadJobs(o ServerOptions) {
+	if o.JobsWorkers > 0 {
+		jobs = newJobQueue(o.JobsWorkers, o.JobsQueue, time.Duration(o.JobsTTL)*time.Second, o.JobsResultsSize)
+	}
+}
+
+// submit queues a new job, failing when all the workers are busy and the
+// queue is full.
+func (q *jobQueue) submit(run func() (Image, error), vary string, callback *jobCallback, now time.Time) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire(now)
+	j := &job{
+		Job:      Job{ID: newRequestID(), Status: JobPending, Created: now},
+		run:      run,
+		vary:     vary,
+		callback: callback,
+	}
+	select {
+	case q.pending <- j:
+	default:
+		return Job{}, ErrJobsQueueFull
+	}
+	q.jobs[j.ID] = j
+	return j.Job, nil
+}
+
+// work processes the queued jobs one after the other, notifying their
+// callback, if any, once completed.
+func (q *jobQueue) work() {
+	for j := range q.pending {
+		q.mu.Lock()
+		j.Status = JobRunning
+		q.mu.Unlock()
+
+		// Jobs weigh on the instance load as the synchronous requests do
+		started := time.Now()
+		load.begin()
+		image, err := j.run()
+		load.end(started, time.Now())
+
+		q.mu.Lock()
+		j.finished = time.Now()
+		if err != nil {
+			// Keep the status of the API errors, e.g. 404 from the source or 503
+			// on low memory, so server faults are not reported as client ones
+			xerr, ok := err.(Error)
+			if !ok {
+				xerr = NewError("Error while processing the image: "+err.Error(), http.StatusInternalServerError)
+			}
+			j.Status, j.Error = JobError, &xerr
+		} else {
+			j.Status, j.image = JobDone, image
+			q.results += len(image.Body)
+		}
+		completed := *j
+		q.evict()
+		q.mu.Unlock()
+
+		if j.callback != nil {
+			go j.callback.deliver(completed)
+		}
+	}
+}
+
+// get returns a copy of the job, unless unknown or expired.
+func (q *jobQueue) get(id string, now time.Time) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire(now)
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// expire forgets the jobs completed for longer than the TTL. The mutex must
+// be held.
+func (q *jobQueue) expire(now time.Time) {
+	for _, j := range q.jobs {
+		if !j.finished.IsZero() && now.Sub(j.finished) >= q.ttl {
+			q.forget(j)
+		}
+	}
+}
+
+// evict forgets the oldest completed jobs until their results fit within
+// maxResults, if set. The mutex must be held.
+func (q *jobQueue) evict() {
+	for q.maxResults > 0 && q.results > q.maxResults {
+		var oldest *job
+		for _, j := range q.jobs {
+			if j.Status == JobDone && (oldest == nil || j.finished.Before(oldest.finished)) {
+				oldest = j
+			}
+		}
+		q.forget(oldest)
+	}
+}
+
+// forget removes the job, releasing the size of its result. The mutex must
+// be held.
+func (q *jobQueue) forget(j *job) {
+	delete(q.jobs, j.ID)
+	q.results -= len(j.image.Body)
+}
+
+// submitJob queues the processing instead of running it, replying with the
+// job status and its URL. run must apply the -processing-timeout, as the
+// synchronous requests do.
+// The callback param, if any, is notified once the job is completed.
+func submitJob(w http.ResponseWriter, r *http.Request, run func() (Image, error), opts ImageOptions, vary string,
+	o ServerOptions,
+) {
+	if jobs == nil {
+		ErrorReply(r, w, ErrJobsDisabled, o)
+		return
+	}
+
+	callback, err := newJobCallback(r, opts, o)
+	if err != nil {
+		ErrorReply(r, w, toError(err), o)
+		return
+	}
+
+	j, err := jobs.submit(run, vary, callback, time.Now())
+	if err != nil {
+		ErrorReply(r, w, toError(err), o)
+		return
+	}
+
+	body, _ := json.Marshal(j)
+	noStore(w)
+	w.Header().Set("Location", join(o, "/jobs/"+j.ID))
+	w.Header().Set(ContentType, ContentTypeJSON)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(body)
+}
+
+// @Summary Job status
+// @Description Returns the status of an asynchronous job, submitted with the async param of the image endpoints
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} Job
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Router /jobs/{id} [get]
+func jobController(o ServerOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := findJob(w, r, o)
+		if !ok {
+			return
+		}
+		if j.Status == JobDone {
+			j.Result = join(o, "/jobs/"+j.ID+"/result")
+		}
+
+		body, _ := json.Marshal(j.Job)
+		w.Header().Set(ContentType, ContentTypeJSON)
+		_, _ = w.Write(body)
+	}
+}
+
+// @Summary Job result
+// @Description Returns the image processed by an asynchronous job, or its error
+// @Produce image/*
+// @Param id path string true "Job ID"
+// @Success 200 {file} binary "Processed image"
+// @Failure 404 {object} Error "Not found"
+// @Failure 409 {object} Error "Job not done yet"
+// @Failure 401 {object} Error "Unauthorized"
+// @Router /jobs/{id}/result [get]
+func jobResultController(o ServerOptions) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := findJob(w, r, o)
+		if !ok {
+			return
+		}
+
+		switch j.Status {
+		case JobDone:
+			sendResponse(w, j.image, j.vary, o)
+		case JobError:
+			ErrorReply(r, w, *j.Error, o)
+		default:
+			ErrorReply(r, w, ErrJobNotDone, o)
+		}
+	}
+}
+
+// findJob looks up the job of the request path, replying with an error when
+// it cannot be found.
+func findJob(w http.ResponseWriter, r *http.Request, o ServerOptions) (job, bool) {
+	noStore(w)
+
+	if jobs == nil {
+		ErrorReply(r, w, ErrJobNotFound, o)
+		return job{}, false
+	}
+	j, ok := jobs.get(r.PathValue("id"), time.Now())
+	if !ok {
+		ErrorReply(r, w, ErrJobNotFound, o)
+	}
+	return j, ok
+}
+
+// noStore overrides the cache headers, job replies changing with their status.
+func noStore(w http.ResponseWriter) {
+	w.Header().Del("Expires")
+	w.Header().Set("Cache-Control", "no-store")
+}
@@ -0,0 +1,217 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// waitJob polls the job until it completes.
+func waitJob(t *testing.T, q *jobQueue, id string) job {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if j, ok := q.get(id, time.Now()); ok && (j.Status == JobDone || j.Status == JobError) {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s not completed", id)
+	return job{}
+}
+
+func TestJobQueue(t *testing.T) {
+	q := newJobQueue(1, 10, time.Minute, 0)
+
+	done, err := q.submit(func() (Image, error) { return Image{Body: []byte("image"), Mime: ImagePNG}, nil }, "", nil,
+		time.Now())
+	if err != nil || done.Status != JobPending || done.ID == "" {
+		t.Fatalf("Invalid submitted job: %+v, %v", done, err)
+	}
+	failed, _ := q.submit(func() (Image, error) { return Image{}, errors.New("boom") }, "", nil, time.Now())
+	lowMemory, _ := q.submit(func() (Image, error) { return Image{}, ErrLowMemory }, "", nil, time.Now())
+
+	if j := waitJob(t, q, done.ID); j.Status != JobDone || string(j.image.Body) != "image" {
+		t.Errorf("Invalid done job: %+v", j)
+	}
+	j := waitJob(t, q, failed.ID)
+	if j.Status != JobError || j.Error == nil || j.Error.Message != "Error while processing the image: boom" ||
+		j.Error.Code != http.StatusInternalServerError {
+		t.Errorf("Invalid failed job: %+v", j)
+	}
+	if j := waitJob(t, q, lowMemory.ID); j.Error == nil || *j.Error != ErrLowMemory {
+		t.Errorf("Expected the API error of the failed job to be kept: %+v", j)
+	}
+
+	if _, ok := q.get(done.ID, time.Now().Add(time.Minute)); ok {
+		t.Error("Expected the completed job to expire")
+	}
+	if _, ok := q.get("unknown", time.Now()); ok {
+		t.Error("Expected an unknown job not to be found")
+	}
+}
+
+func TestJobQueueTracksLoad(t *testing.T) {
+	load = newLoadMonitor(1, 0)
+	defer func() { load = nil }()
+	q := newJobQueue(1, 10, time.Minute, 0)
+
+	var score float64
+	j, _ := q.submit(func() (Image, error) {
+		score = load.utilization(time.Now())
+		return Image{}, nil
+	}, "", nil, time.Now())
+	waitJob(t, q, j.ID)
+	if score != 1 {
+		t.Errorf("Expected the running job to weigh on the load: %f", score)
+	}
+}
+
+func TestJobQueueFull(t *testing.T) {
+	q := newJobQueue(0, 1, time.Minute, 0)
+	run := func() (Image, error) { return Image{}, nil }
+
+	j, err := q.submit(run, "", nil, time.Now())
+	if err != nil {
+		t.Fatalf("Cannot submit job: %s", err)
+	}
+	if _, err := q.submit(run, "", nil, time.Now()); err != ErrJobsQueueFull {
+		t.Errorf("Expected the queue to be full, got %v", err)
+	}
+	if _, ok := q.get(j.ID, time.Now().Add(time.Hour)); !ok {
+		t.Error("Expected pending jobs not to expire")
+	}
+}
+
+func TestJobQueueResultsSize(t *testing.T) {
+	q := newJobQueue(1, 10, time.Hour, 10)
+	run := func() (Image, error) { return Image{Body: []byte("image"), Mime: ImagePNG}, nil }
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		j, _ := q.submit(run, "", nil, time.Now())
+		waitJob(t, q, j.ID)
+		ids = append(ids, j.ID)
+	}
+	failed, _ := q.submit(func() (Image, error) { return Image{}, errors.New("boom") }, "", nil, time.Now())
+	waitJob(t, q, failed.ID)
+
+	if _, ok := q.get(ids[0], time.Now()); ok {
+		t.Error("Expected the oldest result to be evicted")
+	}
+	for _, id := range ids[1:] {
+		if _, ok := q.get(id, time.Now()); !ok {
+			t.Errorf("Expected the result of job %s to be retained", id)
+		}
+	}
+
+	q.get("", time.Now().Add(time.Hour))
+	if q.results != 0 {
+		t.Errorf("Expected the size of the expired results to be released, got %d", q.results)
+	}
+}
+
+func TestJobControllers(t *testing.T) {
+	defer func() { jobs = nil }()
+	jobs = newJobQueue(1, 10, time.Minute, 0)
+	o := ServerOptions{}
+
+	w := httptest.NewRecorder()
+	submitJob(w, httptest.NewRequest(http.MethodPost, "/resize", nil), func() (Image, error) {
+		return Image{Body: []byte("image"), Mime: ImagePNG}, nil
+	}, ImageOptions{}, "Accept", o)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Invalid submit status: %d", w.Code)
+	}
+	var submitted Job
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("Cannot decode job: %s", err)
+	}
+	if location := w.Header().Get("Location"); location != "/jobs/"+submitted.ID {
+		t.Errorf("Invalid job location: %s", location)
+	}
+	waitJob(t, jobs, submitted.ID)
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/"+submitted.ID, nil)
+	req.SetPathValue("id", submitted.ID)
+	w = httptest.NewRecorder()
+	jobController(o)(w, req)
+	var status Job
+	_ = json.Unmarshal(w.Body.Bytes(), &status)
+	if status.Status != JobDone || status.Result != "/jobs/"+submitted.ID+"/result" {
+		t.Errorf("Invalid job status: %+v", status)
+	}
+	if cache := w.Header().Get("Cache-Control"); cache != "no-store" {
+		t.Errorf("Invalid cache control: %s", cache)
+	}
+
+	w = httptest.NewRecorder()
+	jobResultController(o)(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "image" || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("Invalid job result: %d %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil)
+	req.SetPathValue("id", "unknown")
+	w = httptest.NewRecorder()
+	jobController(o)(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown jobs to reply 404: %d", w.Code)
+	}
+}
+
+func TestAsyncJobProcessingTimeout(t *testing.T) {
+	defer func() { jobs = nil }()
+	jobs = newJobQueue(1, 10, time.Minute, 0)
+	o := ServerOptions{MaxAllowedPixels: 18.0, ProcessingTimeout: 10}
+	LoadSources(o)
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := Operation(func(buf []byte, _ ImageOptions) (Image, error) {
+		<-release
+		return Image{Body: buf}, nil
+	})
+
+	buf, _ := os.ReadFile("testdata/test.png")
+	w := httptest.NewRecorder()
+	imageController(o, slow)(w, httptest.NewRequest(http.MethodPost, "/resize?async=true", bytes.NewReader(buf)))
+	var submitted Job
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("Cannot submit job: %d, %s", w.Code, w.Body.String())
+	}
+
+	if j := waitJob(t, jobs, submitted.ID); j.Error == nil || *j.Error != ErrProcessingTimeout {
+		t.Errorf("Expected the job to be bound to the processing timeout: %+v", j)
+	}
+}
+
+func TestSubmitJobDisabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	submitJob(w, httptest.NewRequest(http.MethodPost, "/resize", nil), nil, ImageOptions{}, "", ServerOptions{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected async jobs to be rejected when disabled: %d", w.Code)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MaxJSONBodySize is the maximum size in bytes of JSON request bodies.
+const MaxJSONBodySize = 1024 * 1024
+
+// JSONBody holds the image source and the params of a JSON request body, as
+// an alternative to query params.
+type JSONBody struct {
+	Source JSONSource             `json:"source"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// JSONSource is the image source of a JSON request body, either a remote URL
+// or a path within the mounted directory.
+type JSONSource struct {
+	URL  string `json:"url"`
+	File string `json:"file"`
+}
+
+// jsonBody turns POST requests with a JSON body into the equivalent GET
+// request, moving the image source and the params to the query, so every
+// endpoint and source handle them as usual. Query params of the request URL,
+// such as sign, are kept. It wraps the controllers, so the body is only
+// decoded once the client is authorized.
+func jsonBody(next http.Handler, o ServerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !isJSONBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query, err := readJSONBody(http.MaxBytesReader(w, r.Body, MaxJSONBodySize), r.URL.Query())
+		if err != nil {
+			ErrorReply(r, w, NewError("Invalid JSON body: "+err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		req := r.Clone(r.Context())
+		req.Method = http.MethodGet
+		req.URL.RawQuery = query.Encode()
+		req.Body = http.NoBody
+		req.ContentLength = 0
+		req.Header.Del("Content-Type")
+		next.ServeHTTP(w, req)
+	})
+}
+
+func isJSONBody(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == ContentTypeJSON
+}
+
+// readJSONBody decodes the JSON body, returning the given query along with
+// its image source and params.
+func readJSONBody(body io.Reader, query url.Values) (url.Values, error) {
+	var payload JSONBody
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	for key, value := range payload.Params {
+		param, err := jsonParamValue(value)
+		if err != nil {
+			return nil, err
+		}
+		query.Set(key, param)
+	}
+
+	switch {
+	case payload.Source.URL != "" && payload.Source.File != "":
+		return nil, errors.New("source must define either url or file")
+	case payload.Source.URL != "":
+		query.Set(URLQueryKey, payload.Source.URL)
+	case payload.Source.File != "":
+		query.Set("file", payload.Source.File)
+	}
+	return query, nil
+}
+
+// jsonParamValue returns the query value of a JSON param. Lists and objects,
+// such as pipeline operations, are passed as JSON.
+func jsonParamValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestReadJSONBody(t *testing.T) {
+	body := `{
+		"source": {"url": "https://example.com/image.jpg"},
+		"params": {"width": 300, "opacity": 0.5, "force": true, "text": "© 2025\nimaginary",
+			"operations": [{"operation": "crop", "params": {"width": 300}}]}
+	}`
+
+	query, err := readJSONBody(strings.NewReader(body), url.Values{"sign": {"signature"}})
+	if err != nil {
+		t.Fatalf("Cannot read JSON body: %s", err)
+	}
+
+	expected := map[string]string{
+		"url":        "https://example.com/image.jpg",
+		"width":      "300",
+		"opacity":    "0.5",
+		"force":      "true",
+		"text":       "© 2025\nimaginary",
+		"operations": `[{"operation":"crop","params":{"width":300}}]`,
+		"sign":       "signature",
+	}
+	for key, value := range expected {
+		if query.Get(key) != value {
+			t.Errorf("Invalid %s param: %q != %q", key, query.Get(key), value)
+		}
+	}
+
+	invalid := []string{
+		`{"params": `,
+		`{"source": {"url": "https://example.com/image.jpg", "file": "image.jpg"}}`,
+		`{"params": ["width"]}`,
+	}
+	for _, body := range invalid {
+		if _, err := readJSONBody(strings.NewReader(body), url.Values{}); err == nil {
+			t.Errorf("Expected an error with %s", body)
+		}
+	}
+}
+
+func TestJSONBodyMountDirectory(t *testing.T) {
+	opts := ServerOptions{Mount: "testdata", MaxAllowedPixels: 18.0}
+	fn := ImageMiddleware(opts)(Crop)
+	LoadSources(opts)
+
+	ts := httptest.NewServer(fn)
+	defer ts.Close()
+
+	body := `{"source": {"file": "large.jpg"}, "params": {"width": 200, "height": 200}}`
+	status, _, image := sendRequest(t, http.MethodPost, ts.URL, ContentTypeJSON, strings.NewReader(body))
+	if status != 200 {
+		t.Fatalf(InvalidResponseStatusD, status)
+	}
+
+	assertImageSize(t, image, 200, 200)
+	if bimg.DetermineImageTypeName(image) != "jpeg" {
+		t.Fatal(InvalidImageType)
+	}
+}
+
+func TestJSONBodyAuthorization(t *testing.T) {
+	opts := ServerOptions{Mount: "testdata", MaxAllowedPixels: 18.0, APIKey: "secret"}
+	LoadSources(opts)
+
+	ts := httptest.NewServer(ImageMiddleware(opts)(Crop))
+	defer ts.Close()
+
+	// The body isn't decoded before the API key is checked
+	status, _, _ := sendRequest(t, http.MethodPost, ts.URL, ContentTypeJSON, strings.NewReader("{"))
+	if status != http.StatusUnauthorized {
+		t.Fatalf(InvalidResponseStatusD, status)
+	}
+	status, _, _ = sendRequest(t, http.MethodPost, ts.URL+"?key=secret", ContentTypeJSON, strings.NewReader("{"))
+	if status != http.StatusBadRequest {
+		t.Fatalf(InvalidResponseStatusD, status)
+	}
+}
+
+func TestJSONBodySignature(t *testing.T) {
+	opts := ServerOptions{
+		Mount:              "testdata",
+		MaxAllowedPixels:   18.0,
+		EnableURLSignature: true,
+		URLSignatureKey:    "4f46feebafc4b5e988f131c4ff8b5997",
+	}
+	LoadSources(opts)
+
+	ts := httptest.NewServer(ImageMiddleware(opts)(Crop))
+	defer ts.Close()
+
+	body := `{"source": {"file": "large.jpg"}, "params": {"width": 200, "height": 200}}`
+	sign := base64.RawURLEncoding.EncodeToString(bodySignature("/", url.Values{}, []byte(body), opts.URLSignatureKey))
+
+	status, _, image := sendRequest(t, http.MethodPost, ts.URL+"?sign="+sign, ContentTypeJSON, strings.NewReader(body))
+	if status != http.StatusOK {
+		t.Fatalf(InvalidResponseStatusD, status)
+	}
+	assertImageSize(t, image, 200, 200)
+
+	tampered := strings.Replace(body, "200", "2000", 1)
+	status, _, _ = sendRequest(t, http.MethodPost, ts.URL+"?sign="+sign, ContentTypeJSON, strings.NewReader(tampered))
+	if status != http.StatusForbidden {
+		t.Fatalf(InvalidResponseStatusD, status)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxCompositeLayers is the maximum number of layers of a composite request.
+const MaxCompositeLayers = 10
+
+// Blend modes supported by the composite operation, following the CSS
+// mix-blend-mode definitions.
+const (
+	BlendNormal     = "normal"
+	BlendMultiply   = "multiply"
+	BlendScreen     = "screen"
+	BlendOverlay    = "overlay"
+	BlendDarken     = "darken"
+	BlendLighten    = "lighten"
+	BlendDifference = "difference"
+)
+
+// blendModes maps every blend mode to its function, applied to the backdrop
+// and source channels in the 0..1 range.
+var blendModes = map[string]func(b, s float64) float64{
+	BlendNormal:   func(_, s float64) float64 { return s },
+	BlendMultiply: func(b, s float64) float64 { return b * s },
+	BlendScreen:   func(b, s float64) float64 { return b + s - b*s },
+	BlendOverlay: func(b, s float64) float64 {
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	},
+	BlendDarken:     func(b, s float64) float64 { return min(b, s) },
+	BlendLighten:    func(b, s float64) float64 { return max(b, s) },
+	BlendDifference: func(b, s float64) float64 { return max(b-s, s-b) },
+}
+
+// CompositeLayer represents an image drawn over the base image, loaded
+// either from a remote URL or from the mounted directory.
+type CompositeLayer struct {
+	URL     string  `json:"url"`
+	File    string  `json:"file"`
+	Left    int     `json:"left"`
+	Top     int     `json:"top"`
+	Opacity float64 `json:"opacity"`
+	Blend   string  `json:"blend"`
+}
+
+// parseLayers parses a JSON list of composite layers, validating them.
+func parseLayers(data string) ([]CompositeLayer, error) {
+	var layers []CompositeLayer
+
+	d := json.NewDecoder(strings.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&layers); err != nil {
+		return nil, err
+	}
+
+	if len(layers) > MaxCompositeLayers {
+		return nil, fmt.Errorf("too many layers, the maximum allowed is %d", MaxCompositeLayers)
+	}
+	for i, layer := range layers {
+		if (layer.URL == "") == (layer.File == "") {
+			return nil, fmt.Errorf("layer %d must define either url or file", i)
+		}
+		if layer.Opacity < 0 || layer.Opacity > 1 {
+			return nil, fmt.Errorf("layer %d opacity must be between 0 and 1", i)
+		}
+		if _, ok := blendModes[layer.Blend]; !ok && layer.Blend != "" {
+			return nil, fmt.Errorf("layer %d has an unsupported blend mode: %s", i, layer.Blend)
+		}
+	}
+
+	return layers, nil
+}
+
+// layerLimits are the limits the images fetched by the operations themselves,
+// e.g. the collage tiles, are checked against by validateLayerSize, and the
+// images the operations enlarge, e.g. the padded ones, by validateOutputSize.
+// The resolution ceilings of the trusted API keys don't apply to them.
+var layerLimits = ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels}
+
+// LoadLayerLimits sets the limits of the fetched images.
+func LoadLayerLimits(o ServerOptions) {
+	layerLimits = o
+}
+
+// validateLayerSize checks the resolution of a fetched image against the
+// limits before it is decoded, as validateImageSize does for the source image.
+func validateLayerSize(buf []byte) error {
+	return validateImageSize(buf, ImageOptions{}, layerLimits)
+}
+
+// validateOutputSize checks the resolution of an image an operation is about
+// to allocate, as its params, e.g. the padding, may enlarge it past the limits.
+func validateOutputSize(width, height int) error {
+	if float64(width)*float64(height)/1000000 > layerLimits.MaxAllowedPixels {
+		return ErrResolutionTooBig
+	}
+	return nil
+}
+
+// loadLayer reads the layer image through the image sources, so the mount
+// directory, allowed origins and size limits apply as they do for the base
+// image.
+func loadLayer(layer CompositeLayer) ([]byte, error) {
+	query := url.Values{}
+	var source ImageSource
+
+	if layer.URL != "" {
+		remote, ok := imageSourceMap[ImageSourceTypeHTTP].(*HTTPImageSource)
+		if !ok || !remote.Config.EnableURLSource {
+			return nil, errors.New("url layers require the -enable-url-source flag")
+		}
+		query.Set(URLQueryKey, layer.URL)
+		source = remote
+	} else {
+		local, ok := imageSourceMap[ImageSourceTypeFileSystem].(*FileSystemImageSource)
+		if !ok || (local.Config.MountPath == "" && len(local.Config.Mounts) == 0) {
+			return nil, errors.New("file layers require the -mount flag")
+		}
+		query.Set("file", layer.File)
+		source = local
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, _, err := source.GetImage(req)
+	return buf, err
+}
+
+// compositeLayer draws the source image over the destination one at the
+// given offset, blending their colors with the given function and scaling
+// the source alpha by the opacity.
+func compositeLayer(dst, src *image.NRGBA, left, top int, opacity float64, blend func(b, s float64) float64) {
+	area := src.Rect.Add(image.Pt(left, top)).Intersect(dst.Rect)
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			d := dst.PixOffset(x, y)
+			s := src.PixOffset(x-left, y-top)
+
+			as := float64(src.Pix[s+3]) / 255 * opacity
+			ab := float64(dst.Pix[d+3]) / 255
+			ao := as + ab*(1-as)
+			if ao == 0 {
+				continue
+			}
+
+			for c := 0; c < 3; c++ {
+				cs, cb := float64(src.Pix[s+c])/255, float64(dst.Pix[d+c])/255
+				mixed := (1-ab)*cs + ab*blend(cb, cs)
+				dst.Pix[d+c] = clampUint8((as*mixed + ab*(1-as)*cb) / ao * 255)
+			}
+			dst.Pix[d+3] = clampUint8(ao * 255)
+		}
+	}
+}
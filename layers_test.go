@@ -0,0 +1,121 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"testing"
+)
+
+func TestParseLayers(t *testing.T) {
+	layers, err := parseLayers(`[{"file":"logo.png","left":10,"top":-5,"opacity":0.5,"blend":"multiply"},{"url":"https://example.com/badge.png"}]`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(layers) != 2 || layers[0].Top != -5 || layers[0].Blend != BlendMultiply || layers[1].URL == "" {
+		t.Fatalf("Invalid layers: %+v", layers)
+	}
+
+	invalid := []string{
+		`{"file":"logo.png"}`,
+		`[{"left":10}]`,
+		`[{"file":"logo.png","url":"https://example.com/badge.png"}]`,
+		`[{"file":"logo.png","opacity":2}]`,
+		`[{"file":"logo.png","blend":"dodge"}]`,
+		`[{"file":"logo.png","scale":2}]`,
+		`[{"file":"1"},{"file":"2"},{"file":"3"},{"file":"4"},{"file":"5"},{"file":"6"},{"file":"7"},{"file":"8"},{"file":"9"},{"file":"10"},{"file":"11"}]`,
+	}
+	for _, data := range invalid {
+		if _, err := parseLayers(data); err == nil {
+			t.Errorf("Expected error for layers: %s", data)
+		}
+	}
+}
+
+func TestCompositeLayer(t *testing.T) {
+	dst := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	for x := 0; x < 3; x++ {
+		dst.SetNRGBA(x, 0, color.NRGBA{R: 200, G: 100, B: 0, A: 255})
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 128, G: 128, B: 128, A: 255})
+
+	compositeLayer(dst, src, 0, 0, 1, blendModes[BlendNormal])
+	if c := dst.NRGBAAt(0, 0); c != (color.NRGBA{R: 128, G: 128, B: 128, A: 255}) {
+		t.Errorf("Expected the layer to cover the pixel, got %v", c)
+	}
+
+	compositeLayer(dst, src, 1, 0, 1, blendModes[BlendMultiply])
+	if c := dst.NRGBAAt(1, 0); c != (color.NRGBA{R: 100, G: 50, B: 0, A: 255}) {
+		t.Errorf("Expected the pixel to be multiplied, got %v", c)
+	}
+
+	compositeLayer(dst, src, 2, 0, 0.5, blendModes[BlendNormal])
+	if c := dst.NRGBAAt(2, 0); c != (color.NRGBA{R: 164, G: 114, B: 64, A: 255}) {
+		t.Errorf("Expected the layer to be blended by half, got %v", c)
+	}
+
+	compositeLayer(dst, src, 5, 0, 1, blendModes[BlendNormal])
+	if c := dst.NRGBAAt(2, 0); c != (color.NRGBA{R: 164, G: 114, B: 64, A: 255}) {
+		t.Errorf("Expected out of bounds layers to be ignored, got %v", c)
+	}
+}
+
+func TestLoadLayer(t *testing.T) {
+	t.Cleanup(func() { LoadSources(ServerOptions{}) })
+
+	LoadSources(ServerOptions{})
+	if _, err := loadLayer(CompositeLayer{File: "test.png"}); err == nil {
+		t.Error("Expected file layers to require a mount directory")
+	}
+	if _, err := loadLayer(CompositeLayer{URL: "http://localhost/test.png"}); err == nil {
+		t.Error("Expected url layers to require the url source")
+	}
+
+	LoadSources(ServerOptions{Mount: "testdata"})
+	buf, err := loadLayer(CompositeLayer{File: "test.png"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected, _ := os.ReadFile("testdata/test.png")
+	if len(buf) != len(expected) {
+		t.Errorf("Invalid layer image size: %d", len(buf))
+	}
+
+	if _, err := loadLayer(CompositeLayer{File: "../go.mod"}); err == nil {
+		t.Error("Expected file layers outside of the mount directory to be rejected")
+	}
+}
+
+func TestCompositeLayerSize(t *testing.T) {
+	t.Cleanup(func() {
+		LoadSources(ServerOptions{})
+		LoadLayerLimits(ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels})
+	})
+	LoadSources(ServerOptions{Mount: "testdata"})
+	LoadLayerLimits(ServerOptions{MaxAllowedPixels: 0.1})
+
+	buf, _ := os.ReadFile("testdata/test.png")
+	_, err := Composite(buf, ImageOptions{Layers: []CompositeLayer{{File: "imaginary.jpg"}}})
+	if err != ErrResolutionTooBig {
+		t.Errorf("Expected the layers above the resolution limit to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoadHeader is the response header reporting the utilization of the
+// instance, so layer-7 load balancers can route around the hot ones.
+const LoadHeader = "X-Load"
+
+// loadWindow is the number of seconds the processing latency is averaged on.
+const loadWindow = 10
+
+// load tracks the image requests in progress and their latency. It is nil,
+// hence disabled, if neither -overload-requests nor -overload-latency is set.
+var load *loadMonitor
+
+// latencyBucket sums the latencies of the requests completed within a second.
+type latencyBucket struct {
+	second int64
+	total  time.Duration
+	count  int
+}
+
+// loadMonitor scores the utilization of the instance as the highest ratio of
+// the image requests in progress and of their average latency over the last
+// seconds to the configured thresholds, 1 meaning overloaded.
+type loadMonitor struct {
+	maxRequests int
+	maxLatency  time.Duration
+
+	mu       sync.Mutex
+	requests int
+	buckets  [loadWindow]latencyBucket
+}
+
+func newLoadMonitor(maxRequests int, maxLatency time.Duration) *loadMonitor {
+	return &loadMonitor{maxRequests: maxRequests, maxLatency: maxLatency}
+}
+
+// LoadOverloadMonitor enables the load reporting when configured.
+func LoadOverloadMonitor(o ServerOptions) {
+	if o.OverloadRequests > 0 || o.OverloadLatency > 0 {
+		load = newLoadMonitor(o.OverloadRequests, time.Duration(o.OverloadLatency)*time.Millisecond)
+	}
+}
+
+// begin registers a new request in progress.
+func (m *loadMonitor) begin() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests++
+}
+
+// end registers the completion of a request started at the given time.
+func (m *loadMonitor) end(started, now time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests--
+	bucket := &m.buckets[now.Unix()%loadWindow]
+	if bucket.second != now.Unix() {
+		*bucket = latencyBucket{second: now.Unix()}
+	}
+	bucket.total += now.Sub(started)
+	bucket.count++
+}
+
+// utilization returns the load score, 0 when idle or disabled.
+func (m *loadMonitor) utilization(now time.Time) float64 {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	score := 0.0
+	if m.maxRequests > 0 {
+		score = float64(m.requests) / float64(m.maxRequests)
+	}
+	if m.maxLatency > 0 {
+		score = math.Max(score, float64(m.latency(now))/float64(m.maxLatency))
+	}
+	return toFixed(score, 2)
+}
+
+// latency returns the average latency of the requests completed within the
+// window. The mutex must be held.
+func (m *loadMonitor) latency(now time.Time) time.Duration {
+	var total time.Duration
+	count := 0
+	for _, bucket := range m.buckets {
+		if now.Unix()-bucket.second < loadWindow {
+			total += bucket.total
+			count += bucket.count
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// Readiness is the reply of the readiness endpoint.
+type Readiness struct {
+	Ready bool    `json:"ready"`
+	Load  float64 `json:"load"`
+}
+
+// loadHeader formats the load score.
+func loadHeader(score float64) string {
+	return strconv.FormatFloat(score, 'f', 2, 64)
+}
+
+// trackLoad measures the image requests and reports the load score, as of
+// their start, in the X-Load header.
+func trackLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if load == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		started := time.Now()
+		w.Header().Set(LoadHeader, loadHeader(load.utilization(started)))
+
+		load.begin()
+		defer func() { load.end(started, time.Now()) }()
+
+		next.ServeHTTP(w, r)
+	})
+}
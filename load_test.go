@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadMonitor(t *testing.T) {
+	monitor := newLoadMonitor(4, time.Second)
+	now := time.Now()
+
+	if score := monitor.utilization(now); score != 0 {
+		t.Errorf("Expected idle monitor to score 0: %f", score)
+	}
+
+	monitor.begin()
+	monitor.begin()
+	if score := monitor.utilization(now); score != 0.5 {
+		t.Errorf("Expected the requests in progress to be scored: %f != 0.5", score)
+	}
+
+	monitor.end(now.Add(-1500*time.Millisecond), now)
+	monitor.end(now.Add(-500*time.Millisecond), now)
+	if score := monitor.utilization(now); score != 1 {
+		t.Errorf("Expected the average latency to be scored: %f != 1", score)
+	}
+
+	if score := monitor.utilization(now.Add(loadWindow * time.Second)); score != 0 {
+		t.Errorf("Expected the latency to expire after the window: %f", score)
+	}
+
+	var disabled *loadMonitor
+	disabled.begin()
+	disabled.end(now, now)
+	if score := disabled.utilization(now); score != 0 {
+		t.Errorf("Expected no monitor to score 0: %f", score)
+	}
+}
+
+func TestReadyController(t *testing.T) {
+	defer func() { load = nil }()
+
+	cases := []struct {
+		requests int
+		status   int
+		header   string
+	}{
+		{0, http.StatusOK, "0.00"},
+		{1, http.StatusOK, "0.50"},
+		{2, http.StatusServiceUnavailable, "1.00"},
+	}
+
+	for _, c := range cases {
+		load = newLoadMonitor(2, 0)
+		for i := 0; i < c.requests; i++ {
+			load.begin()
+		}
+
+		w := httptest.NewRecorder()
+		readyController(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		if w.Code != c.status {
+			t.Errorf("Invalid status with %d requests: %d != %d", c.requests, w.Code, c.status)
+		}
+		if header := w.Header().Get(LoadHeader); header != c.header {
+			t.Errorf("Invalid load header with %d requests: %s != %s", c.requests, header, c.header)
+		}
+
+		var readiness Readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("Cannot decode the readiness: %s", err)
+		}
+		if readiness.Ready != (c.status == http.StatusOK) {
+			t.Errorf("Invalid readiness with %d requests: %v", c.requests, readiness.Ready)
+		}
+	}
+}
+
+func TestTrackLoad(t *testing.T) {
+	load = newLoadMonitor(1, 0)
+	defer func() { load = nil }()
+
+	handler := trackLoad(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if score := load.utilization(time.Now()); score != 1 {
+			t.Errorf("Expected the request to be in progress: %f", score)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/resize", nil))
+
+	if header := w.Header().Get(LoadHeader); header != "0.00" {
+		t.Errorf("Invalid load header: %s", header)
+	}
+	if score := load.utilization(time.Now()); score != 0 {
+		t.Errorf("Expected the request to be completed: %f", score)
+	}
+}
+
+func TestSignedMiddlewareTracksLoad(t *testing.T) {
+	load = newLoadMonitor(1, 0)
+	defer func() { load = nil }()
+
+	handler := SignedMiddleware(func(w http.ResponseWriter, _ *http.Request) {
+		if score := load.utilization(time.Now()); score != 1 {
+			t.Errorf("Expected the request to be in progress: %f", score)
+		}
+	}, ServerOptions{HTTPCacheTTL: -1})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/generate", nil))
+	if header := w.Header().Get(LoadHeader); header != "0.00" {
+		t.Errorf("Invalid load header: %s", header)
+	}
+}
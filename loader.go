@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxDPI bounds the density PDF and SVG sources are rasterized at.
+const MaxDPI = 2400
+
+// AllPages is the pages param value rendering every page of the document.
+const AllPages = -1
+
+// DefaultCMYKProfile is the libvips built-in CMYK profile, converting the
+// CMYK sources without embedded profile when no -cmyk-profile is given.
+const DefaultCMYKProfile = "cmyk"
+
+// cmykProfile is the ICC profile converting the CMYK sources without
+// embedded profile.
+var cmykProfile = DefaultCMYKProfile
+
+// LoadCMYKProfile sets the fallback profile of the CMYK sources.
+func LoadCMYKProfile(o ServerOptions) {
+	cmykProfile = DefaultCMYKProfile
+	if o.CMYKProfile != "" {
+		cmykProfile = o.CMYKProfile
+	}
+}
+
+// hasLoadOptions reports whether the source image must be loaded with the
+// requested pages or density before the operation runs, as bimg always loads
+// the first page of PDF and TIFF documents, and rasterizes PDF and SVG
+// sources at 72 DPI.
+func hasLoadOptions(buf []byte, opts ImageOptions) bool {
+	switch bimg.DetermineImageType(buf) {
+	case bimg.PDF:
+		return opts.Page > 0 || opts.Pages != 0 || opts.DPI > 0
+	case bimg.TIFF:
+		return opts.Page > 0 || opts.Pages != 0
+	case bimg.SVG:
+		return opts.DPI > 0
+	default:
+		return false
+	}
+}
+
+// loadInput renders the source image with the requested pages, stacked
+// vertically when there are several of them, and density. It is handed over
+// as lossless PNG and the output type defaults to JPEG for PDF documents, as
+// usual, and to the source one otherwise.
+func loadInput(buf []byte, opts ImageOptions) ([]byte, ImageOptions, error) {
+	options, err := loadOptions(buf, opts)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	loaded, err := loadPNG(buf, options, cmykProfile)
+	if err != nil {
+		return nil, opts, NewError("Cannot load image: "+err.Error(), http.StatusBadRequest)
+	}
+
+	if opts.Type == "" && bimg.DetermineImageType(buf) == bimg.PDF {
+		opts.Type = JPEG
+	}
+	opts.Type = outputTypeName(buf, opts)
+	return loaded, opts, nil
+}
+
+// loadedSize returns the size of the source image once rendered, so the
+// resolution limits apply to the requested pages and density.
+func loadedSize(buf []byte, opts ImageOptions) (bimg.ImageSize, error) {
+	options, err := loadOptions(buf, opts)
+	if err != nil {
+		return bimg.ImageSize{}, err
+	}
+
+	width, height, err := loadSize(buf, options)
+	if err != nil {
+		return bimg.ImageSize{}, NewError("Cannot load image: "+err.Error(), http.StatusBadRequest)
+	}
+	return bimg.ImageSize{Width: width, Height: height}, nil
+}
+
+// loadOptions returns the libvips load options selecting the pages of PDF and
+// TIFF documents, which start at 0 for libvips, and the density of PDF and
+// SVG sources.
+func loadOptions(buf []byte, opts ImageOptions) (string, error) {
+	if opts.Page < 0 {
+		return "", NewError("Invalid param: page must be greater than 0", http.StatusBadRequest)
+	}
+	if opts.Pages < AllPages {
+		return "", NewError("Invalid param: pages must be greater than 0, or -1 for all pages", http.StatusBadRequest)
+	}
+	if opts.DPI < 0 || opts.DPI > MaxDPI {
+		return "", NewError(fmt.Sprintf("Invalid param: dpi must be between 1 and %d", MaxDPI), http.StatusBadRequest)
+	}
+
+	var options []string
+	imageType := bimg.DetermineImageType(buf)
+	if imageType == bimg.PDF || imageType == bimg.TIFF {
+		options = append(options, fmt.Sprintf("page=%d", max(opts.Page-1, 0)))
+		if opts.Pages != 0 {
+			options = append(options, fmt.Sprintf("n=%d", opts.Pages))
+		}
+	}
+	if opts.DPI > 0 && (imageType == bimg.PDF || imageType == bimg.SVG) {
+		options = append(options, fmt.Sprintf("dpi=%d", opts.DPI))
+	}
+	return strings.Join(options, ","), nil
+}
+
+// isCMYK reports whether the image is a CMYK JPEG or TIFF one, only reading
+// its header.
+func isCMYK(buf []byte) bool {
+	switch bimg.DetermineImageType(buf) {
+	case bimg.JPEG, bimg.TIFF:
+		interpretation, err := bimg.ImageInterpretation(buf)
+		return err == nil && interpretation == bimg.InterpretationCMYK
+	default:
+		return false
+	}
+}
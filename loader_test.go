@@ -0,0 +1,215 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestPageLoadOptions(t *testing.T) {
+	pdf, _ := io.ReadAll(readFile("pages.pdf"))
+	tiff, _ := io.ReadAll(readFile("pages.tiff"))
+	svg, _ := io.ReadAll(readFile("flyio-button.svg"))
+
+	cases := []struct {
+		buf      []byte
+		opts     ImageOptions
+		expected string
+	}{
+		{pdf, ImageOptions{}, "page=0"},
+		{pdf, ImageOptions{Page: 3}, "page=2"},
+		{pdf, ImageOptions{DPI: 150}, "page=0,dpi=150"},
+		{pdf, ImageOptions{Page: 2, Pages: 2, DPI: 300}, "page=1,n=2,dpi=300"},
+		{tiff, ImageOptions{Pages: AllPages, DPI: 300}, "page=0,n=-1"},
+		{svg, ImageOptions{Page: 2, DPI: 300}, "dpi=300"},
+	}
+
+	for _, c := range cases {
+		options, err := loadOptions(c.buf, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if options != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, options)
+		}
+	}
+
+	invalid := []ImageOptions{{Page: -1}, {Pages: -2}, {DPI: MaxDPI + 1}}
+	for _, opts := range invalid {
+		if _, err := loadOptions(pdf, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestTIFFPageInput(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("pages.tiff"))
+
+	if !hasLoadOptions(buf, ImageOptions{Pages: AllPages}) {
+		t.Fatal("The pages of TIFF sources should be selected")
+	}
+	if hasLoadOptions(buf, ImageOptions{DPI: 150}) {
+		t.Error("The density of TIFF sources should be ignored")
+	}
+
+	cases := []struct {
+		opts          ImageOptions
+		width, height int
+	}{
+		// Pages are 100x50 pixels
+		{ImageOptions{Page: 2}, 100, 50},
+		{ImageOptions{Pages: AllPages}, 100, 100},
+	}
+	for _, c := range cases {
+		size, err := loadedSize(buf, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size.Width != c.width || size.Height != c.height {
+			t.Errorf("Invalid pages size: %dx%d", size.Width, size.Height)
+		}
+
+		pages, _, err := loadInput(buf, c.opts)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if err := assertSize(pages, c.width, c.height); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, _, err := loadInput(buf, ImageOptions{Page: 3}); err == nil {
+		t.Error("Expected an error with a missing page")
+	}
+}
+
+func TestPDFPageInput(t *testing.T) {
+	if !bimg.IsTypeSupported(bimg.PDF) {
+		t.Skip("libvips is built without PDF support")
+	}
+	buf, _ := io.ReadAll(readFile("pages.pdf"))
+
+	if !hasLoadOptions(buf, ImageOptions{Page: 2}) {
+		t.Fatal("The page of PDF sources should be selected")
+	}
+	jpeg, _ := io.ReadAll(readFile(ImaginaryJpeg))
+	if hasLoadOptions(jpeg, ImageOptions{Page: 2}) {
+		t.Error("The page of JPEG sources should not be selected")
+	}
+
+	cases := []struct {
+		opts          ImageOptions
+		width, height int
+	}{
+		// Pages are 200x100 and 100x200 points
+		{ImageOptions{Page: 1}, 200, 100},
+		{ImageOptions{Page: 2}, 100, 200},
+		{ImageOptions{Page: 2, DPI: 144}, 200, 400},
+	}
+	for _, c := range cases {
+		page, opts, err := loadInput(buf, c.opts)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if opts.Type != JPEG {
+			t.Errorf("Invalid output type: %s", opts.Type)
+		}
+		if err := assertSize(page, c.width, c.height); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, _, err := loadInput(buf, ImageOptions{Page: 3}); err == nil {
+		t.Error("Expected an error with a missing page")
+	}
+}
+
+func TestSVGDensity(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("flyio-button.svg"))
+
+	if hasLoadOptions(buf, ImageOptions{Page: 2}) {
+		t.Error("SVG sources have no pages")
+	}
+	if !hasLoadOptions(buf, ImageOptions{DPI: 144}) {
+		t.Fatal("The density of SVG sources should be selected")
+	}
+
+	size, err := bimg.Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twice the default 72 DPI
+	svg, opts, err := loadInput(buf, ImageOptions{DPI: 144})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if opts.Type != PNG {
+		t.Errorf("Invalid output type: %s", opts.Type)
+	}
+	if err := assertSize(svg, size.Width*2, size.Height*2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCMYKInput(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("imaginary.jpg"))
+	cmyk, err := bimg.NewImage(buf).Process(bimg.Options{Interpretation: bimg.InterpretationCMYK, Type: bimg.JPEG})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if !isCMYK(cmyk) || isCMYK(buf) {
+		t.Fatal("Expected only the CMYK source to be detected")
+	}
+
+	img, err := Process(cmyk, bimg.Options{Width: 300})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageJPEG {
+		t.Error(InvalidMimeType)
+	}
+	if interpretation, _ := bimg.ImageInterpretation(img.Body); interpretation != bimg.InterpretationSRGB {
+		t.Errorf("Expected an sRGB output, got %d", interpretation)
+	}
+
+	pixels, _, err := decodeImagePixels(cmyk)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	size, _ := bimg.Size(buf)
+	if pixels.Rect.Dx() != size.Width || pixels.Rect.Dy() != size.Height {
+		t.Errorf("Invalid pixels size: %v", pixels.Rect)
+	}
+}
+
+func TestLoadCMYKProfile(t *testing.T) {
+	defer LoadCMYKProfile(ServerOptions{})
+
+	LoadCMYKProfile(ServerOptions{CMYKProfile: "/profiles/fogra39.icc"})
+	if cmykProfile != "/profiles/fogra39.icc" {
+		t.Errorf("Invalid CMYK profile: %s", cmykProfile)
+	}
+	LoadCMYKProfile(ServerOptions{})
+	if cmykProfile != DefaultCMYKProfile {
+		t.Errorf("Expected the default CMYK profile, got %s", cmykProfile)
+	}
+}
@@ -23,12 +23,82 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 const formatPattern = "%s - - [%s] \"%s\" %d %d %.4f\n"
 
+// requestIDFormatPattern appends the request ID to the log entry
+const requestIDFormatPattern = "%s - - [%s] \"%s\" %d %d %.4f \"%s\"\n"
+
+// Log levels, from the least to the most verbose.
+const (
+	LogLevelError int32 = iota
+	LogLevelWarning
+	LogLevelInfo
+	LogLevelDebug
+)
+
+var logLevelNames = []string{"error", "warning", "info", "debug"}
+
+// currentLogLevel is the log level in use, shared by the HTTP access log and
+// debug output. It can be switched at runtime.
+var currentLogLevel atomic.Int32
+
+func init() {
+	currentLogLevel.Store(LogLevelInfo)
+}
+
+// parseLogLevel returns the log level matching the given name.
+func parseLogLevel(name string) (int32, error) {
+	for level, levelName := range logLevelNames {
+		if name == levelName {
+			return int32(level), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level: %q. Allowed values are: %s", name, strings.Join(logLevelNames, ", "))
+}
+
+// SetLogLevel switches the log level in use.
+func SetLogLevel(name string) error {
+	level, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+	currentLogLevel.Store(level)
+	return nil
+}
+
+// CurrentLogLevel returns the name of the log level in use.
+func CurrentLogLevel() string {
+	return logLevelNames[currentLogLevel.Load()]
+}
+
+// cycleLogLevel switches to the next more verbose log level, wrapping around
+// from debug back to error, and returns its name.
+func cycleLogLevel() string {
+	level := (currentLogLevel.Load() + 1) % int32(len(logLevelNames))
+	currentLogLevel.Store(level)
+	return logLevelNames[level]
+}
+
+// watchLogLevelSignal cycles the log level every time a signal is received.
+func watchLogLevelSignal(signals <-chan os.Signal) {
+	for range signals {
+		log.Printf("Log level set to %s", cycleLogLevel())
+	}
+}
+
+// logf writes the message to the standard logger if the given level is enabled.
+func logf(level int32, msg string, values ...interface{}) {
+	if currentLogLevel.Load() >= level {
+		log.Printf("["+logLevelNames[level]+"] "+msg, values...)
+	}
+}
+
 // LogRecord implements an Apache-compatible HTTP logging
 type LogRecord struct {
 	http.ResponseWriter
@@ -38,13 +108,18 @@ type LogRecord struct {
 	method, uri, protocol string
 	time                  time.Time
 	elapsedTime           time.Duration
+	requestID             string
 }
 
 // Log writes a log entry in the passed io.Writer stream
 func (r *LogRecord) Log(out io.Writer) {
 	timeFormat := r.time.Format("02/Jan/2006 15:04:05")
 	request := fmt.Sprintf("%s %s %s", r.method, r.uri, r.protocol)
-	_, _ = fmt.Fprintf(out, formatPattern, r.ip, timeFormat, request, r.status, r.responseBytes, r.elapsedTime.Seconds())
+	if r.requestID == "" {
+		_, _ = fmt.Fprintf(out, formatPattern, r.ip, timeFormat, request, r.status, r.responseBytes, r.elapsedTime.Seconds())
+		return
+	}
+	_, _ = fmt.Fprintf(out, requestIDFormatPattern, r.ip, timeFormat, request, r.status, r.responseBytes, r.elapsedTime.Seconds(), r.requestID) //nolint:lll
 }
 
 // Write acts like a proxy passing the given bytes buffer to the ResponseWritter
@@ -63,14 +138,16 @@ func (r *LogRecord) WriteHeader(status int) {
 
 // LogHandler maps the HTTP handler with a custom io.Writer compatible stream
 type LogHandler struct {
-	handler  http.Handler
-	io       io.Writer
-	logLevel string
+	handler http.Handler
+	io      io.Writer
 }
 
-// NewLog creates a new logger
+// NewLog creates a new logger, setting the process wide log level
 func NewLog(handler http.Handler, io io.Writer, logLevel string) http.Handler {
-	return &LogHandler{handler, io, logLevel}
+	if err := SetLogLevel(logLevel); err != nil {
+		log.Fatalln(err)
+	}
+	return &LogHandler{handler, io}
 }
 
 // ServeHTTP implements the required method as standard HTTP handler, serving the request.
@@ -89,6 +166,7 @@ func (h *LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		protocol:       r.Proto,
 		status:         http.StatusOK,
 		elapsedTime:    time.Duration(0),
+		requestID:      requestIDFromContext(r.Context()),
 	}
 
 	startTime := time.Now()
@@ -98,18 +176,16 @@ func (h *LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	record.time = finishTime.UTC()
 	record.elapsedTime = finishTime.Sub(startTime)
 
-	switch h.logLevel {
-	case "error":
+	switch currentLogLevel.Load() {
+	case LogLevelError:
 		if record.status >= http.StatusInternalServerError {
 			record.Log(h.io)
 		}
-	case "warning":
+	case LogLevelWarning:
 		if record.status >= http.StatusBadRequest {
 			record.Log(h.io)
 		}
-	case "info":
-		record.Log(h.io)
 	default:
-		log.Fatalln("Invalid log level")
+		record.Log(h.io)
 	}
 }
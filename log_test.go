@@ -21,7 +21,9 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -76,3 +78,62 @@ func TestLogError(t *testing.T) {
 		t.Fatalf("Invalid log output: %s", data)
 	}
 }
+
+func TestLogDebug(t *testing.T) {
+	ts, writer := setupTest(t, "debug")
+	t.Cleanup(func() { _ = SetLogLevel("info") })
+	_, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(writer.buf), " 200 ") {
+		t.Fatalf("Invalid log output: %s", writer.buf)
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	t.Cleanup(func() { _ = SetLogLevel("info") })
+
+	if err := SetLogLevel("verbose"); err == nil {
+		t.Fatal("Expected error for an invalid log level")
+	}
+	if err := SetLogLevel("error"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if CurrentLogLevel() != "error" {
+		t.Fatalf("Invalid log level: %s", CurrentLogLevel())
+	}
+
+	for _, expected := range []string{"warning", "info", "debug", "error"} {
+		if level := cycleLogLevel(); level != expected {
+			t.Fatalf("Invalid cycled log level: %s != %s", level, expected)
+		}
+	}
+}
+
+func TestWatchLogLevelSignal(t *testing.T) {
+	t.Cleanup(func() { _ = SetLogLevel("info") })
+
+	signals := make(chan os.Signal, 2)
+	signals <- syscall.SIGUSR1
+	signals <- syscall.SIGUSR1
+	close(signals)
+	watchLogLevelSignal(signals)
+
+	if CurrentLogLevel() != "error" {
+		t.Fatalf("Invalid log level: %s", CurrentLogLevel())
+	}
+}
+
+func TestLogRequestID(t *testing.T) {
+	writer := &testWriter{}
+	handler := requestID(NewLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), writer, "info"), DefaultRequestIDHeader)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(DefaultRequestIDHeader, "abc123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if data := string(writer.buf); !strings.HasSuffix(data, " \"abc123\"\n") {
+		t.Fatalf("Invalid log output: %s", data)
+	}
+}
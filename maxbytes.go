@@ -0,0 +1,140 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// QualityHeader is the response header reporting the quality the image was
+// encoded at by the maxbytes param or the auto quality.
+const QualityHeader = "Image-Quality"
+
+// maxBytesOutputType returns the lossy output type requested along with the
+// maxbytes param.
+func maxBytesOutputType(buf []byte, opts ImageOptions) (bimg.ImageType, error) {
+	switch {
+	case opts.MaxBytes < 0:
+		return bimg.UNKNOWN, NewError("Invalid param: maxbytes must be positive", http.StatusBadRequest)
+	case opts.Lossless:
+		return bimg.UNKNOWN, NewError("Invalid params: maxbytes and lossless cannot be combined", http.StatusBadRequest)
+	case opts.AutoQuality:
+		return bimg.UNKNOWN, NewError("Invalid params: maxbytes and quality=auto cannot be combined",
+			http.StatusBadRequest)
+	}
+	return lossyOutputType(buf, opts, "maxbytes")
+}
+
+// lossyOutputType returns the output type requested, explicitly or keeping
+// the input type, checking it is a lossy one as required by the param.
+func lossyOutputType(buf []byte, opts ImageOptions, param string) (bimg.ImageType, error) {
+	t := ImageType(opts.Type)
+	if opts.Type == "" {
+		t = bimg.DetermineImageType(buf)
+	}
+	switch t {
+	case bimg.JPEG, bimg.WEBP, bimg.AVIF, bimg.HEIF:
+		return t, nil
+	default:
+		return bimg.UNKNOWN, NewError("Invalid param: "+param+" requires a jpeg, webp, avif or heif output",
+			http.StatusBadRequest)
+	}
+}
+
+// EncodeMaxBytes encodes the PNG image produced by the operation with the
+// highest quality, up to the quality param, fitting under the maxbytes param,
+// found by binary search. The quality is reported in the Image-Quality
+// header. Other outputs, such as JSON ones, are returned as is.
+func EncodeMaxBytes(img Image, t bimg.ImageType, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	high := o.Quality
+	if high == 0 {
+		high = 100
+	}
+
+	// Most images fit at the highest quality, which is tried first
+	best, err := encodeQuality(img, t, o, high)
+	if err != nil {
+		return Image{}, err
+	}
+	quality := high
+	if len(best.Body) > o.MaxBytes {
+		best, quality = Image{}, 0
+		low := 1
+		high--
+		for low <= high {
+			mid := (low + high) / 2
+			image, err := encodeQuality(img, t, o, mid)
+			if err != nil {
+				return Image{}, err
+			}
+			if len(image.Body) <= o.MaxBytes {
+				best, quality = image, mid
+				low = mid + 1
+			} else {
+				high = mid - 1
+			}
+		}
+	}
+	if quality == 0 {
+		return Image{}, NewError("Cannot encode the image under "+strconv.Itoa(o.MaxBytes)+" bytes, even at quality 1",
+			http.StatusUnprocessableEntity)
+	}
+
+	header := best.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(QualityHeader, strconv.Itoa(quality))
+	best.Header = header
+	return best, nil
+}
+
+// encodeQuality encodes the PNG image as the lossy type at the given quality,
+// with the chroma subsampling and HEIF encoder options, if any.
+func encodeQuality(img Image, t bimg.ImageType, o ImageOptions, quality int) (Image, error) {
+	o.Type = bimg.ImageTypeName(t)
+	o.Quality = quality
+	switch {
+	case isTunedHEIFOutput(o):
+		return EncodeHEIF(img, o)
+	case o.Chroma != "" && (t == bimg.JPEG || t == bimg.WEBP):
+		return EncodeSubsampled(img, t, o)
+	}
+
+	body, err := bimg.NewImage(img.Body).Process(bimg.Options{
+		Type:          t,
+		Quality:       quality,
+		Speed:         o.Speed,
+		Interlace:     o.Interlace,
+		StripMetadata: o.StripMetadata,
+	})
+	if err != nil {
+		name := bimg.ImageTypeName(t)
+		return Image{}, NewError("Cannot encode "+strings.ToUpper(name)+" image: "+err.Error(), http.StatusBadRequest)
+	}
+	return Image{Body: body, Mime: GetImageMimeType(t), Header: img.Header}, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestMaxBytesOutputType(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+	cases := []struct {
+		buf      []byte
+		opts     ImageOptions
+		expected bimg.ImageType
+	}{
+		{jpeg, ImageOptions{MaxBytes: 1000}, bimg.JPEG},
+		{png, ImageOptions{Type: WebP, MaxBytes: 1000}, bimg.WEBP},
+		{png, ImageOptions{Type: AVIF, MaxBytes: 1000}, bimg.AVIF},
+		{png, ImageOptions{MaxBytes: 1000}, bimg.UNKNOWN},
+		{jpeg, ImageOptions{Type: PNG, MaxBytes: 1000}, bimg.UNKNOWN},
+		{jpeg, ImageOptions{MaxBytes: -1}, bimg.UNKNOWN},
+		{jpeg, ImageOptions{Type: WebP, MaxBytes: 1000, Lossless: true}, bimg.UNKNOWN},
+	}
+
+	for _, c := range cases {
+		actual, err := maxBytesOutputType(c.buf, c.opts)
+		if actual != c.expected || (err == nil) != (c.expected != bimg.UNKNOWN) {
+			t.Errorf("Invalid output type with %+v: %s, %v", c.opts, bimg.ImageTypeName(actual), err)
+		}
+	}
+}
+
+func TestEncodeMaxBytes(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("large.jpg"))
+	png, err := bimg.NewImage(buf).Process(bimg.Options{Width: 800, Type: bimg.PNG})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	source := Image{Body: png, Mime: ImagePNG}
+
+	for _, outputType := range []bimg.ImageType{bimg.JPEG, bimg.WEBP} {
+		img, err := EncodeMaxBytes(source, outputType, ImageOptions{MaxBytes: 20000})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if img.Mime != GetImageMimeType(outputType) || bimg.DetermineImageType(img.Body) != outputType {
+			t.Error(InvalidMimeType)
+		}
+		if len(img.Body) > 20000 {
+			t.Errorf("Image of %d bytes exceeds maxbytes", len(img.Body))
+		}
+		quality, err := strconv.Atoi(img.Header.Get(QualityHeader))
+		if err != nil || quality < 1 || quality > 99 {
+			t.Errorf("Invalid %s header: %q", QualityHeader, img.Header.Get(QualityHeader))
+		}
+	}
+
+	img, err := EncodeMaxBytes(source, bimg.JPEG, ImageOptions{MaxBytes: 10000000, Quality: 80})
+	if err != nil || img.Header.Get(QualityHeader) != "80" {
+		t.Errorf("Expected the quality param to be kept when fitting: %q, %v", img.Header.Get(QualityHeader), err)
+	}
+	if _, err := EncodeMaxBytes(source, bimg.JPEG, ImageOptions{MaxBytes: 100}); err == nil {
+		t.Error("Expected an error when the image cannot fit")
+	}
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	img, err = EncodeMaxBytes(json, bimg.JPEG, ImageOptions{MaxBytes: 100})
+	if err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+}
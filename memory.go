@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h2non/bimg"
+)
+
+// DefaultLowMemoryCooldown is the default time in seconds the degraded mode
+// lasts after the last libvips allocation failure.
+const DefaultLowMemoryCooldown = 60
+
+// MinDegradedMegapixels is the lowest resolution the degraded mode shrinks
+// the accepted inputs to, so small images are always served.
+const MinDegradedMegapixels = 1.0
+
+// lowMemory tracks the libvips allocation failures. It is nil, hence
+// disabled, if the -low-memory-cooldown flag is 0.
+var lowMemory *memoryGuard
+
+// memoryGuard rejects large inputs for a while after libvips failed to
+// allocate memory, halving the accepted resolution on every new failure, so
+// small images are still served while memory is fragmented.
+type memoryGuard struct {
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	threshold float64
+	until     time.Time
+}
+
+func newMemoryGuard(cooldown time.Duration) *memoryGuard {
+	return &memoryGuard{cooldown: cooldown, threshold: math.Inf(1)}
+}
+
+// LoadMemoryGuard enables the degraded mode on low memory when configured.
+func LoadMemoryGuard(o ServerOptions) {
+	if o.LowMemoryCooldown > 0 {
+		lowMemory = newMemoryGuard(time.Duration(o.LowMemoryCooldown) * time.Second)
+	}
+}
+
+// isOutOfMemory reports whether the error is a libvips allocation failure.
+func isOutOfMemory(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "out of memory") ||
+		strings.Contains(msg, "cannot allocate memory") ||
+		strings.Contains(msg, "memory allocation failed")
+}
+
+// degrade shrinks the accepted resolution under the one of the failed input.
+func (g *memoryGuard) degrade(megapixels float64, now time.Time) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.expire(now)
+	g.threshold = math.Max(math.Min(g.threshold, megapixels)/2, MinDegradedMegapixels)
+	g.until = now.Add(g.cooldown)
+	logf(LogLevelWarning, "libvips is low on memory, rejecting images above %.1f megapixels for %s",
+		g.threshold, g.cooldown)
+}
+
+// rejects reports whether the input is too large to be processed while
+// degraded.
+func (g *memoryGuard) rejects(megapixels float64, now time.Time) bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.expire(now)
+	return megapixels > g.threshold
+}
+
+// expire leaves the degraded mode once the cooldown is over. The mutex must
+// be held.
+func (g *memoryGuard) expire(now time.Time) {
+	if now.After(g.until) {
+		g.threshold = math.Inf(1)
+	}
+}
+
+// inputMegapixels returns the resolution of the source image, or of the
+// generated one when there is no source.
+func inputMegapixels(buf []byte, opts ImageOptions) float64 {
+	if buf == nil {
+		return float64(opts.Width) * float64(opts.Height) / 1000000
+	}
+
+	size, err := bimg.Size(buf)
+	if err != nil {
+		return 0
+	}
+	return float64(size.Width) * float64(size.Height) / 1000000
+}
@@ -0,0 +1,79 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryGuard(t *testing.T) {
+	guard := newMemoryGuard(time.Minute)
+	now := time.Now()
+
+	if guard.rejects(100, now) {
+		t.Error("Expected healthy guard not to reject images")
+	}
+
+	guard.degrade(40, now)
+	if !guard.rejects(30, now) {
+		t.Error("Expected images above half the failed resolution to be rejected")
+	}
+	if guard.rejects(20, now) {
+		t.Error("Expected images under the threshold to be served")
+	}
+
+	guard.degrade(100, now)
+	if guard.threshold != 10 {
+		t.Errorf("Expected the threshold to keep shrinking: %f != 10", guard.threshold)
+	}
+
+	guard.degrade(0.5, now)
+	if guard.rejects(MinDegradedMegapixels, now) {
+		t.Error("Expected the smallest images to always be served")
+	}
+
+	if guard.rejects(100, now.Add(2*time.Minute)) {
+		t.Error("Expected the degraded mode to end after the cooldown")
+	}
+
+	var disabled *memoryGuard
+	disabled.degrade(40, now)
+	if disabled.rejects(100, now) {
+		t.Error("Expected no guard to never reject")
+	}
+}
+
+func TestIsOutOfMemory(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("vips_malloc: out of memory --- size == 1.2GB"), true},
+		{errors.New("VipsImage: Cannot allocate memory"), true},
+		{errors.New("VipsJpeg: Premature end of input file"), false},
+	}
+
+	for _, c := range cases {
+		if isOutOfMemory(c.err) != c.expected {
+			t.Errorf("Invalid out of memory detection for %q", c.err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MetadataFull is the metadata param value returning the EXIF, XMP and IPTC
+// blocks along with the image info.
+const MetadataFull = "full"
+
+// redactGPS hides the GPS location from the returned metadata when the
+// -redact-gps flag is set.
+var redactGPS bool
+
+// ImageMetadata represents the full metadata blocks of the image.
+type ImageMetadata struct {
+	EXIF map[string]any      `json:"exif,omitempty"`
+	XMP  string              `json:"xmp,omitempty"`
+	IPTC map[string][]string `json:"iptc,omitempty"`
+}
+
+// iptcDatasets names the IPTC IIM application record datasets.
+var iptcDatasets = map[byte]string{
+	5:   "ObjectName",
+	10:  "Urgency",
+	15:  "Category",
+	20:  "SupplementalCategories",
+	25:  "Keywords",
+	40:  "SpecialInstructions",
+	55:  "DateCreated",
+	60:  "TimeCreated",
+	80:  "Byline",
+	85:  "BylineTitle",
+	90:  "City",
+	92:  "Sublocation",
+	95:  "ProvinceState",
+	100: "CountryCode",
+	101: "CountryName",
+	103: "OriginalTransmissionReference",
+	105: "Headline",
+	110: "Credit",
+	115: "Source",
+	116: "CopyrightNotice",
+	118: "Contact",
+	120: "Caption",
+	122: "Writer",
+}
+
+var (
+	xmpGPSAttribute = regexp.MustCompile(`\s+exif:GPS\w+="[^"]*"`)
+	xmpGPSElement   = regexp.MustCompile(`(?s)<exif:GPS\w+\s*/>|<exif:GPS\w+>.*?</exif:GPS\w+>`)
+)
+
+// LoadMetadataRedaction enables the GPS redaction when configured.
+func LoadMetadataRedaction(o ServerOptions) {
+	redactGPS = o.RedactGPS
+}
+
+// fullMetadata gathers the EXIF, XMP and IPTC metadata of the image, without
+// the GPS location if redacted.
+func fullMetadata(buf []byte, meta bimg.ImageMetadata, redact bool) *ImageMetadata {
+	metadata := &ImageMetadata{
+		EXIF: exifTags(meta.EXIF, redact),
+		XMP:  xmpPacket(buf),
+		IPTC: iptcRecords(buf),
+	}
+	if redact {
+		metadata.XMP = redactXMPLocation(metadata.XMP)
+	}
+	return metadata
+}
+
+// exifTags lists the EXIF tags set in the image, by their name.
+func exifTags(exif bimg.EXIF, redact bool) map[string]any {
+	tags := make(map[string]any)
+	v := reflect.ValueOf(exif)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if v.Field(i).IsZero() || (redact && strings.HasPrefix(name, "GPS")) {
+			continue
+		}
+		tags[name] = v.Field(i).Interface()
+	}
+	return tags
+}
+
+// xmpPacket returns the XMP packet embedded in the image, which is stored as
+// plain XML by every format.
+func xmpPacket(buf []byte) string {
+	start := bytes.Index(buf, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return ""
+	}
+	end := bytes.Index(buf[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return ""
+	}
+	return string(buf[start : start+end+len("</x:xmpmeta>")])
+}
+
+// redactXMPLocation removes the GPS properties from the XMP packet.
+func redactXMPLocation(xmp string) string {
+	xmp = xmpGPSAttribute.ReplaceAllString(xmp, "")
+	return xmpGPSElement.ReplaceAllString(xmp, "")
+}
+
+// iptcRecords parses the IPTC application record from the Photoshop APP13
+// segment of JPEG images, keyed by dataset name.
+func iptcRecords(buf []byte) map[string][]string {
+	iim := photoshopResource(jpegSegment(buf, 0xED, "Photoshop 3.0\x00"), 0x0404)
+
+	records := make(map[string][]string)
+	for len(iim) >= 5 && iim[0] == 0x1C {
+		record, dataset := iim[1], iim[2]
+		size := int(binary.BigEndian.Uint16(iim[3:5]))
+		// Extended datasets, over 32767 bytes, are not supported
+		if size&0x8000 != 0 || len(iim) < 5+size {
+			break
+		}
+		value := iim[5 : 5+size]
+		iim = iim[5+size:]
+
+		if record != 2 || dataset == 0 {
+			continue
+		}
+		name, ok := iptcDatasets[dataset]
+		if !ok {
+			name = fmt.Sprintf("2:%03d", dataset)
+		}
+		records[name] = append(records[name], string(value))
+	}
+	return records
+}
+
+// jpegSegment returns the payload of the first JPEG segment with the given
+// marker and signature, without the signature.
+func jpegSegment(buf []byte, marker byte, signature string) []byte {
+	if len(buf) < 4 || buf[0] != 0xFF || buf[1] != 0xD8 {
+		return nil
+	}
+
+	for i := 2; i+4 <= len(buf) && buf[i] == 0xFF; {
+		// Image data follows the start of scan segment
+		if buf[i+1] == 0xDA {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(buf[i+2 : i+4]))
+		if size < 2 || i+2+size > len(buf) {
+			return nil
+		}
+		payload := buf[i+4 : i+2+size]
+		if buf[i+1] == marker && bytes.HasPrefix(payload, []byte(signature)) {
+			return payload[len(signature):]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// photoshopResource returns the data of the Photoshop image resource with the
+// given ID.
+func photoshopResource(buf []byte, id uint16) []byte {
+	for len(buf) >= 7 && bytes.HasPrefix(buf, []byte("8BIM")) {
+		resource := binary.BigEndian.Uint16(buf[4:6])
+		// The resource name is a Pascal string padded to an even size
+		nameSize := int(buf[6]) + 1
+		nameSize += nameSize % 2
+		if len(buf) < 6+nameSize+4 {
+			return nil
+		}
+		buf = buf[6+nameSize:]
+
+		size := int(binary.BigEndian.Uint32(buf[:4]))
+		if len(buf) < 4+size {
+			return nil
+		}
+		if resource == id {
+			return buf[4 : 4+size]
+		}
+		buf = buf[min(4+size+size%2, len(buf)):]
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:Description exif:GPSLatitude="48,51.4N" tiff:Make="Canon">` +
+	`<exif:GPSLongitude>2,21.1E</exif:GPSLongitude></rdf:Description></x:xmpmeta>`
+
+// jpegWithMetadata builds the headers of a JPEG image carrying the XMP packet
+// and the IPTC datasets.
+func jpegWithMetadata(datasets ...[]byte) []byte {
+	segment := func(marker byte, payload []byte) []byte {
+		size := make([]byte, 2)
+		binary.BigEndian.PutUint16(size, uint16(len(payload)+2))
+		return append(append([]byte{0xFF, marker}, size...), payload...)
+	}
+
+	iim := bytes.Join(datasets, nil)
+	resource := append([]byte("8BIM\x04\x04\x00\x00"), binary.BigEndian.AppendUint32(nil, uint32(len(iim)))...)
+	resource = append(resource, iim...)
+
+	buf := []byte{0xFF, 0xD8}
+	buf = append(buf, segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"+testXMP))...)
+	buf = append(buf, segment(0xED, append([]byte("Photoshop 3.0\x00"), resource...))...)
+	return append(buf, 0xFF, 0xDA)
+}
+
+func iptcDataset(dataset byte, value string) []byte {
+	return append(binary.BigEndian.AppendUint16([]byte{0x1C, 2, dataset}, uint16(len(value))), value...)
+}
+
+func TestIPTCRecords(t *testing.T) {
+	buf := jpegWithMetadata(
+		iptcDataset(0, "\x00\x04"),
+		iptcDataset(80, "Jane Doe"),
+		iptcDataset(25, "paris"),
+		iptcDataset(25, "night"),
+		iptcDataset(200, "custom"),
+	)
+
+	records := iptcRecords(buf)
+	if !slices.Equal(records["Byline"], []string{"Jane Doe"}) {
+		t.Errorf("Invalid byline: %v", records["Byline"])
+	}
+	if !slices.Equal(records["Keywords"], []string{"paris", "night"}) {
+		t.Errorf("Invalid keywords: %v", records["Keywords"])
+	}
+	if !slices.Equal(records["2:200"], []string{"custom"}) {
+		t.Errorf("Expected unknown datasets to be named by number: %v", records)
+	}
+	if len(records) != 3 {
+		t.Errorf("Expected the record version to be skipped: %v", records)
+	}
+
+	if records := iptcRecords([]byte("not a jpeg")); len(records) != 0 {
+		t.Errorf("Expected no IPTC records: %v", records)
+	}
+}
+
+func TestXMPPacket(t *testing.T) {
+	buf := jpegWithMetadata()
+
+	if xmp := xmpPacket(buf); xmp != testXMP {
+		t.Errorf("Invalid XMP packet: %s", xmp)
+	}
+
+	redacted := redactXMPLocation(xmpPacket(buf))
+	if strings.Contains(redacted, "GPS") || !strings.Contains(redacted, `tiff:Make="Canon"`) {
+		t.Errorf("Invalid redacted XMP packet: %s", redacted)
+	}
+}
+
+func TestEXIFTags(t *testing.T) {
+	exif := bimg.EXIF{Make: "Canon", ISOSpeedRatings: 100, GPSLatitude: "48/1 51/1 0/1"}
+
+	tags := exifTags(exif, false)
+	if len(tags) != 3 || tags["Make"] != "Canon" || tags["ISOSpeedRatings"] != 100 {
+		t.Errorf("Invalid EXIF tags: %v", tags)
+	}
+
+	tags = exifTags(exif, true)
+	if _, ok := tags["GPSLatitude"]; ok || len(tags) != 2 {
+		t.Errorf("Expected the GPS tags to be redacted: %v", tags)
+	}
+}
+
+func TestImageInfoFullMetadata(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("medium.jpg"))
+
+	img, err := Info(buf, ImageOptions{Metadata: MetadataFull})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+
+	var info ImageInfo
+	if err := json.Unmarshal(img.Body, &info); err != nil {
+		t.Fatalf("Cannot decode info: %s", err)
+	}
+	if info.Metadata == nil || !strings.HasPrefix(info.Metadata.XMP, "<x:xmpmeta") {
+		t.Errorf("Expected the XMP packet: %+v", info.Metadata)
+	}
+
+	if _, err := Info(buf, ImageOptions{Metadata: "partial"}); err == nil {
+		t.Error("Expected unsupported metadata values to be rejected")
+	}
+}
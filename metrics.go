@@ -21,8 +21,10 @@ package main
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/h2non/bimg"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -94,11 +96,87 @@ var (
 			Help:      "HTTP response sizes in bytes.",
 		}, labels,
 	)
+
+	originInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "origin_requests_in_flight",
+			Help:      "Remote image fetches in progress per allowed origin.",
+		}, []string{"origin"},
+	)
+
+	originQueued = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "origin_requests_queued",
+			Help:      "Remote image fetches waiting for their origin host per allowed origin.",
+		}, []string{"origin"},
+	)
+
+	originWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "origin_queue_wait_seconds",
+			Help:      "Time remote image fetches waited for their origin host in seconds.",
+		}, []string{"origin"},
+	)
+
+	originRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "origin_requests_rejected_total",
+			Help:      "Total number of remote image fetches rejected after waiting too long per allowed origin.",
+		}, []string{"origin"},
+	)
+
+	originFetchedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "origin_fetched_bytes_total",
+			Help:      "Total number of bytes fetched per allowed origin.",
+		}, []string{"origin"},
+	)
+
+	tenantServedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tenant_served_bytes_total",
+			Help:      "Total number of bytes served per tenant.",
+		}, []string{"tenant"},
+	)
+
+	tenantRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tenant_requests_rejected_total",
+			Help:      "Total number of requests rejected after the tenant exceeded its daily quota.",
+		}, []string{"tenant"},
+	)
+
+	stagedBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "staged_bytes",
+			Help:      "Size in bytes reserved by the uploads staged in the -tmp-dir directory.",
+		},
+	)
+
+	processingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "image_processing_duration_seconds",
+			Help:      "Image decoding, processing and encoding latencies in seconds per input and output format.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}, []string{"input", "output"},
+	)
 )
 
 // init registers the prometheus metrics
 func init() {
 	prometheus.MustRegister(uptime, reqCount, reqDuration, reqSizeBytes, respSizeBytes)
+	prometheus.MustRegister(originInFlight, originQueued, originWait, originRejected)
+	prometheus.MustRegister(originFetchedBytes, tenantServedBytes, tenantRejected)
+	prometheus.MustRegister(processingDuration, stagedBytes)
 	go recordUptime()
 }
 
@@ -133,3 +211,30 @@ func calcRequestSize(r *http.Request) float64 {
 	}
 	return float64(size)
 }
+
+// observeProcessing records the time taken to turn the source image into the
+// output one. libvips decodes, processes and encodes the image in a single
+// lazy pass, so the whole operation is measured per format pair.
+func observeProcessing(buf []byte, image Image, elapsed time.Duration) {
+	processingDuration.WithLabelValues(inputFormat(buf), outputFormat(image)).Observe(elapsed.Seconds())
+}
+
+// inputFormat names the source image type, or none for generated images.
+func inputFormat(buf []byte) string {
+	if buf == nil {
+		return "none"
+	}
+	return bimg.DetermineImageTypeName(buf)
+}
+
+// outputFormat names the output image type, or the MIME subtype of non image
+// outputs such as JSON or raw pixels.
+func outputFormat(image Image) string {
+	if t := bimg.DetermineImageType(image.Body); t != bimg.UNKNOWN {
+		return bimg.ImageTypeName(t)
+	}
+	if _, subtype, ok := strings.Cut(image.Mime, "/"); ok {
+		return subtype
+	}
+	return "unknown"
+}
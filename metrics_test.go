@@ -0,0 +1,50 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestProcessingFormats(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	if format := inputFormat(buf); format != "jpeg" {
+		t.Errorf("Invalid input format: %s", format)
+	}
+	if format := inputFormat(nil); format != "none" {
+		t.Errorf("Invalid generated image input format: %s", format)
+	}
+
+	cases := []struct {
+		image    Image
+		expected string
+	}{
+		{Image{Body: buf, Mime: "image/jpeg"}, "jpeg"},
+		{Image{Body: []byte(`{"width":1}`), Mime: "application/json"}, "json"},
+		{Image{Body: []byte{0}}, "unknown"},
+	}
+
+	for _, c := range cases {
+		if format := outputFormat(c.image); format != c.expected {
+			t.Errorf("Invalid output format: %s != %s", format, c.expected)
+		}
+	}
+}
@@ -19,11 +19,18 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,6 +44,7 @@ func Middleware(fn func(http.ResponseWriter, *http.Request), o ServerOptions) ht
 	next := http.Handler(http.HandlerFunc(fn))
 
 	next = metrics(next)
+	next = tenantBandwidth(next, o)
 
 	if len(o.Endpoints) > 0 {
 		next = filterEndpoint(next, o)
@@ -50,8 +58,11 @@ func Middleware(fn func(http.ResponseWriter, *http.Request), o ServerOptions) ht
 	if o.APIKey != "" {
 		next = authorizeClient(next, o)
 	}
-	if o.HTTPCacheTTL >= 0 {
-		next = setCacheHeaders(next, o.HTTPCacheTTL, o.SrcResponseHeaders)
+	if o.MinReadRate > 0 {
+		next = minReadRate(next, o.MinReadRate)
+	}
+	if o.HTTPCacheTTL >= 0 || len(o.EndpointCacheTTL) > 0 || len(presetTTLs) > 0 {
+		next = setCacheHeaders(next, o)
 	}
 
 	return validate(defaultHeaders(next), o)
@@ -59,16 +70,34 @@ func Middleware(fn func(http.ResponseWriter, *http.Request), o ServerOptions) ht
 
 func ImageMiddleware(o ServerOptions) func(Operation) http.Handler {
 	return func(fn Operation) http.Handler {
-		handler := validateImage(Middleware(imageController(o, fn), o), o)
+		controller := trackLoad(http.HandlerFunc(imageController(o, fn)))
+		if o.EnableEarlyHints {
+			controller = earlyHints(controller, o)
+		}
+
+		// JSON bodies are only decoded once the client is authorized
+		handler := validateImage(Middleware(jsonBody(controller, o).ServeHTTP, o), o)
 
 		if o.EnableURLSignature {
-			return validateURLSignature(handler, o)
+			handler = validateURLSignature(handler, o)
 		}
 
 		return handler
 	}
 }
 
+// SignedMiddleware wraps the processing controllers not bound to an image
+// source, validating the URL signature when enabled.
+func SignedMiddleware(fn func(http.ResponseWriter, *http.Request), o ServerOptions) http.Handler {
+	handler := Middleware(jsonBody(trackLoad(http.HandlerFunc(fn)), o).ServeHTTP, o)
+
+	if o.EnableURLSignature {
+		handler = validateURLSignature(handler, o)
+	}
+
+	return handler
+}
+
 func filterEndpoint(next http.Handler, o ServerOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if o.Endpoints.IsValid(r) {
@@ -126,7 +155,7 @@ func validateImage(next http.Handler, o ServerOptions) http.Handler {
 			return
 		}
 
-		if r.Method == http.MethodGet && o.Mount == "" && !o.EnableURLSource {
+		if r.Method == http.MethodGet && !hasMounts(o) && !o.EnableURLSource {
 			ErrorReply(r, w, ErrGetMethodNotAllowed, o)
 			return
 		}
@@ -137,12 +166,8 @@ func validateImage(next http.Handler, o ServerOptions) http.Handler {
 
 func authorizeClient(next http.Handler, o ServerOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := r.Header.Get("API-Key")
-		if key == "" {
-			key = r.URL.Query().Get("key")
-		}
-
-		if key != o.APIKey {
+		key := requestAPIKey(r)
+		if _, trusted := o.TrustedKeys[key]; key != o.APIKey && !trusted {
 			ErrorReply(r, w, ErrInvalidAPIKey, o)
 			return
 		}
@@ -151,6 +176,24 @@ func authorizeClient(next http.Handler, o ServerOptions) http.Handler {
 	})
 }
 
+// requestAPIKey returns the API key sent either as header or query param.
+func requestAPIKey(r *http.Request) string {
+	key := r.Header.Get("API-Key")
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	return key
+}
+
+// withRequestLimits returns the server options to apply to the request,
+// raising the resolution limit to the ceiling of a trusted API key.
+func withRequestLimits(r *http.Request, o ServerOptions) ServerOptions {
+	if ceiling, ok := o.TrustedKeys[requestAPIKey(r)]; ok && ceiling > o.MaxAllowedPixels {
+		o.MaxAllowedPixels = ceiling
+	}
+	return o
+}
+
 func defaultHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Server", fmt.Sprintf("imaginary %s (bimg %s, vips %s) ", Version, bimg.Version, bimg.VipsVersion))
@@ -167,7 +210,7 @@ func insensitiveArrayContains(haystack []string, needle string) bool {
 	return false
 }
 
-func setCacheHeaders(next http.Handler, ttl int, srcResponseHeaders []string) http.Handler {
+func setCacheHeaders(next http.Handler, o ServerOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer next.ServeHTTP(w, r)
 
@@ -175,7 +218,22 @@ func setCacheHeaders(next http.Handler, ttl int, srcResponseHeaders []string) ht
 			return
 		}
 
-		if insensitiveArrayContains(srcResponseHeaders, "cache-control") && len(w.Header().Get("cache-control")) > 0 {
+		if insensitiveArrayContains(o.SrcResponseHeaders, "cache-control") && len(w.Header().Get("cache-control")) > 0 {
+			return
+		}
+
+		query := r.URL.Query()
+		ttl, ok := mountCacheTTL(query.Get("file"), o.Mounts)
+		if !ok {
+			ttl, ok = presetCacheTTL(query.Get(PresetParam))
+		}
+		if !ok {
+			ttl, ok = o.EndpointCacheTTL[endpointName(r.URL.Path)]
+		}
+		if !ok {
+			ttl = o.HTTPCacheTTL
+		}
+		if ttl < 0 {
 			return
 		}
 
@@ -195,7 +253,7 @@ func getCacheControl(ttl int) string {
 }
 
 func isPublicPath(path string) bool {
-	return path == "/" || path == "/health" || path == "/form"
+	return path == "/" || path == "/health" || path == "/ready" || path == "/form"
 }
 
 func validateURLSignature(next http.Handler, o ServerOptions) http.Handler {
@@ -205,11 +263,19 @@ func validateURLSignature(next http.Handler, o ServerOptions) http.Handler {
 		sign := query.Get("sign")
 		query.Del("sign")
 
-		// Compute expected URL signature
-		h := hmac.New(sha256.New, []byte(o.URLSignatureKey))
-		_, _ = h.Write([]byte(r.URL.Path))
-		_, _ = h.Write([]byte(query.Encode()))
-		expectedSign := h.Sum(nil)
+		// JSON bodies are decoded after the signature check, so it covers
+		// the raw body
+		var body []byte
+		if r.Method == http.MethodPost && isJSONBody(r) {
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, MaxJSONBodySize)); err != nil {
+				ErrorReply(r, w, NewError("Invalid JSON body: "+err.Error(), http.StatusBadRequest), o)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		expectedSign := bodySignature(r.URL.Path, query, body, o.URLSignatureKey)
 
 		urlSign, err := base64.RawURLEncoding.DecodeString(sign)
 		if err != nil {
@@ -226,6 +292,138 @@ func validateURLSignature(next http.Handler, o ServerOptions) http.Handler {
 	})
 }
 
+// urlSignature computes the HMAC digest of the URL path and query params.
+func urlSignature(path string, query url.Values, key string) []byte {
+	return bodySignature(path, query, nil, key)
+}
+
+// bodySignature computes the HMAC digest of the URL path, query params and
+// raw JSON body of a request.
+func bodySignature(path string, query url.Values, body []byte, key string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	_, _ = h.Write([]byte(path))
+	_, _ = h.Write([]byte(query.Encode()))
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
+
+// MaxPreloadVariants is the maximum number of variants listed by the preload param.
+const MaxPreloadVariants = 10
+
+// earlyHints emits a 103 Early Hints response with Link preload headers for
+// the sibling variants listed by the preload param, i.e. the same URL with
+// each one of the given widths, so browsers can fetch them while the
+// requested image is processed.
+func earlyHints(next http.Handler, o ServerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preload := r.URL.Query().Get("preload")
+		if r.Method != http.MethodGet || preload == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		links, err := variantLinks(r.URL, preload, o)
+		if err != nil {
+			ErrorReply(r, w, NewError(err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		for _, link := range links {
+			w.Header().Add("Link", "<"+link+">; rel=preload; as=image")
+		}
+		w.WriteHeader(http.StatusEarlyHints)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// variantLinks returns the URLs of the variants of the requested image
+// resized to the given comma separated widths, signed if URL signature is
+// enabled.
+func variantLinks(u *url.URL, widths string, o ServerOptions) ([]string, error) {
+	values := strings.Split(widths, ",")
+	if len(values) > MaxPreloadVariants {
+		return nil, NewError(fmt.Sprintf("Invalid preload param: maximum of %d variants exceeded", MaxPreloadVariants), http.StatusBadRequest)
+	}
+
+	links := make([]string, 0, len(values))
+	for _, value := range values {
+		width, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || width <= 0 {
+			return nil, NewError(fmt.Sprintf("Invalid preload param: %q is not a valid width", value), http.StatusBadRequest)
+		}
+
+		query := u.Query()
+		query.Del("preload")
+		query.Del("sign")
+		query.Set("width", strconv.Itoa(width))
+		if o.EnableURLSignature {
+			sign := urlSignature(u.Path, query, o.URLSignatureKey)
+			query.Set("sign", base64.RawURLEncoding.EncodeToString(sign))
+		}
+
+		links = append(links, (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String())
+	}
+	return links, nil
+}
+
+// batchWriteTimeout overrides the server write timeout for long running
+// endpoints, such as batch and pipeline, when -http-batch-write-timeout is set.
+func batchWriteTimeout(next http.Handler, o ServerOptions) http.Handler {
+	if o.HTTPBatchTimeout <= 0 {
+		return next
+	}
+
+	timeout := time.Duration(o.HTTPBatchTimeout) * time.Second
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Not every transport supports deadlines, e.g. HTTP/3
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MinReadRateGrace is the time allowed to read the request body before the
+// minimum read rate is enforced.
+const MinReadRateGrace = 5 * time.Second
+
+// minReadRate aborts requests whose body is sent slower than the given rate,
+// in bytes per second, to protect against slowloris like attacks.
+func minReadRate(next http.Handler, rate int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &rateLimitedBody{
+				ReadCloser: r.Body,
+				controller: http.NewResponseController(w),
+				rate:       rate,
+				grace:      MinReadRateGrace,
+				start:      time.Now(),
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitedBody extends the connection read deadline as the body is read,
+// so the average read rate must stay above the minimum rate.
+type rateLimitedBody struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	rate       int
+	grace      time.Duration
+	start      time.Time
+	read       int64
+}
+
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	allowed := time.Duration(float64(b.read) / float64(b.rate) * float64(time.Second))
+	// Not every transport supports deadlines, e.g. HTTP/3
+	_ = b.controller.SetReadDeadline(b.start.Add(b.grace + allowed))
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
 func metrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -238,3 +436,51 @@ func metrics(next http.Handler) http.Handler {
 		respSizeBytes.WithLabelValues(lvs...).Observe(float64(rw.Length))
 	})
 }
+
+// DefaultRequestIDHeader is the header carrying the request ID.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// MaxRequestIDLength is the maximum length of a request ID sent by the client.
+const MaxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// requestID attaches an ID to every request, keeping the one sent by the
+// client in the given header if valid, and echoes it in the response.
+func requestID(next http.Handler, header string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestIDFromContext returns the request ID attached to the context, if any.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// isValidRequestID restricts client IDs to printable ASCII without spaces,
+// so they can be written to logs and forwarded as is.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
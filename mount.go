@@ -0,0 +1,161 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mountNamePattern matches the names of the mounts, so mount paths given
+// without a name aren't mistaken for named ones.
+var mountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Mount is a named local directory, selected by prefixing the file param
+// with its name, e.g. file=assets:logos/logo.png.
+type Mount struct {
+	Path string
+	// Allow lists the path patterns of the files that can be read, any file
+	// being readable when empty.
+	Allow []string
+	// CacheTTL overrides -http-cache-ttl and -endpoint-cache-ttl for the
+	// images of the mount. It is -1 when unset.
+	CacheTTL int
+}
+
+// allows reports whether the file, relative to the mount directory, matches
+// the allowlist.
+func (m Mount) allows(file string) bool {
+	if len(m.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range m.Allow {
+		if ok, _ := path.Match(pattern, file); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listFlag is a flag that can be repeated, each value being also a comma
+// separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, parseHeadersList(value)...)
+	return nil
+}
+
+// newListFlag defines a listFlag on the command line.
+func newListFlag(name, usage string) *listFlag {
+	l := &listFlag{}
+	flag.Var(l, name, usage)
+	return l
+}
+
+// parseMounts parses the -mount entries, either a path or a name:path pair,
+// along with the -mount-allow name:pattern and -mount-cache-ttl name:ttl
+// comma separated lists. It returns the unnamed mount path and the named
+// mounts.
+func parseMounts(entries []string, allow, cacheTTL string) (string, map[string]Mount, error) {
+	var root string
+	mounts := make(map[string]Mount)
+
+	for _, entry := range entries {
+		name, dir, ok := strings.Cut(entry, ":")
+		if !ok || !mountNamePattern.MatchString(name) {
+			if root != "" {
+				return "", nil, fmt.Errorf("only one -mount path can be unnamed, got %q and %q", root, entry)
+			}
+			root = entry
+			continue
+		}
+		if dir == "" {
+			return "", nil, fmt.Errorf("missing path of -mount %q", name)
+		}
+		if _, exists := mounts[name]; exists {
+			return "", nil, fmt.Errorf("duplicate -mount name %q", name)
+		}
+		mounts[name] = Mount{Path: dir, CacheTTL: -1}
+	}
+
+	for _, entry := range parseHeadersList(allow) {
+		name, pattern, _ := strings.Cut(entry, ":")
+		mount, ok := mounts[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown mount name in -mount-allow entry %q", entry)
+		}
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return "", nil, fmt.Errorf("invalid pattern in -mount-allow entry %q", entry)
+		}
+		mount.Allow = append(mount.Allow, pattern)
+		mounts[name] = mount
+	}
+
+	for _, entry := range parseHeadersList(cacheTTL) {
+		name, value, _ := strings.Cut(entry, ":")
+		mount, ok := mounts[name]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown mount name in -mount-cache-ttl entry %q", entry)
+		}
+		ttl, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || ttl < 0 || ttl > MaxHTTPCacheTTL {
+			return "", nil, fmt.Errorf("invalid TTL in -mount-cache-ttl entry %q, only a value from 0 to %d is accepted",
+				entry, MaxHTTPCacheTTL)
+		}
+		mount.CacheTTL = ttl
+		mounts[name] = mount
+	}
+
+	return root, mounts, nil
+}
+
+// splitMountFile splits the file param into the name of the mount and the
+// path within it. Files not prefixed by a known mount name belong to the
+// unnamed mount.
+func splitMountFile(file string, mounts map[string]Mount) (string, string) {
+	name, rel, ok := strings.Cut(file, ":")
+	if _, exists := mounts[name]; ok && exists {
+		return name, rel
+	}
+	return "", file
+}
+
+// mountCacheTTL returns the cache TTL of the mount of the file param, if any.
+func mountCacheTTL(file string, mounts map[string]Mount) (int, bool) {
+	name, _ := splitMountFile(file, mounts)
+	mount, ok := mounts[name]
+	if !ok || mount.CacheTTL < 0 {
+		return 0, false
+	}
+	return mount.CacheTTL, true
+}
+
+// hasMounts reports whether images can be read from local directories.
+func hasMounts(o ServerOptions) bool {
+	return o.Mount != "" || len(o.Mounts) > 0
+}
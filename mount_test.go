@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	root, mounts, err := parseMounts(
+		[]string{"testdata", "assets:/mnt/assets", "media:/mnt/media"},
+		"assets:*.png, assets:icons/*.svg,", "assets:3600")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if root != "testdata" {
+		t.Errorf("Invalid unnamed mount: %s", root)
+	}
+
+	expected := map[string]Mount{
+		"assets": {Path: "/mnt/assets", Allow: []string{"*.png", "icons/*.svg"}, CacheTTL: 3600},
+		"media":  {Path: "/mnt/media", CacheTTL: -1},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("Invalid mounts: %v", mounts)
+	}
+
+	invalid := []struct {
+		entries         []string
+		allow, cacheTTL string
+	}{
+		{[]string{"/mnt/a", "/mnt/b"}, "", ""},
+		{[]string{"assets:/mnt/a", "assets:/mnt/b"}, "", ""},
+		{[]string{"assets:"}, "", ""},
+		{[]string{"assets:/mnt/a"}, "media:*.png", ""},
+		{[]string{"assets:/mnt/a"}, "assets:[", ""},
+		{[]string{"assets:/mnt/a"}, "assets:", ""},
+		{[]string{"assets:/mnt/a"}, "", "media:60"},
+		{[]string{"assets:/mnt/a"}, "", "assets:-1"},
+	}
+	for _, c := range invalid {
+		if _, _, err := parseMounts(c.entries, c.allow, c.cacheTTL); err == nil {
+			t.Errorf("Expected error for %v %q %q", c.entries, c.allow, c.cacheTTL)
+		}
+	}
+}
+
+func TestListFlag(t *testing.T) {
+	var mounts listFlag
+	_ = mounts.Set("assets:/mnt/assets")
+	_ = mounts.Set("media:/mnt/media, /mnt/images")
+
+	if !reflect.DeepEqual([]string(mounts), []string{"assets:/mnt/assets", "media:/mnt/media", "/mnt/images"}) {
+		t.Errorf("Invalid list: %v", mounts)
+	}
+}
+
+func TestMountAllows(t *testing.T) {
+	mount := Mount{Allow: []string{"*.png", "icons/*.svg"}}
+	cases := map[string]bool{
+		"logo.png":       true,
+		"icons/logo.svg": true,
+		"logo.svg":       false,
+		"icons/logo.png": false,
+	}
+
+	for file, expected := range cases {
+		if mount.allows(file) != expected {
+			t.Errorf("Invalid allowlist match of %s", file)
+		}
+	}
+	if !(Mount{}).allows("any/file.jpg") {
+		t.Error("Expected mounts without allowlist to allow any file")
+	}
+}
+
+func TestMountCacheTTL(t *testing.T) {
+	opts := ServerOptions{
+		HTTPCacheTTL:     60,
+		EndpointCacheTTL: map[string]int{"thumbnail": 3600},
+		Mounts: map[string]Mount{
+			"assets": {Path: "testdata", CacheTTL: 31556926},
+			"media":  {Path: "testdata", CacheTTL: -1},
+		},
+	}
+	handler := setCacheHeaders(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), opts)
+
+	cases := map[string]string{
+		"/thumbnail?file=assets:logo.png": getCacheControl(31556926),
+		"/resize?file=assets:logo.png":    getCacheControl(31556926),
+		"/thumbnail?file=media:logo.png":  getCacheControl(3600),
+		"/resize?file=media:logo.png":     getCacheControl(60),
+		"/resize?file=logo.png":           getCacheControl(60),
+	}
+
+	for target, expected := range cases {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+		if actual := res.Header().Get(CacheControl); actual != expected {
+			t.Errorf("Invalid cache-control header for %s: %q != %q", target, actual, expected)
+		}
+	}
+}
@@ -41,6 +41,8 @@ type ImageOptions struct {
 	Margin        int
 	Factor        int
 	DPI           int
+	Page          int
+	Pages         int
 	TextWidth     int
 	Flip          bool
 	Flop          bool
@@ -54,6 +56,15 @@ type ImageOptions struct {
 	Opacity       float32
 	Sigma         float64
 	MinAmpl       float64
+	Strength      float64
+	Flat          float64
+	Jagged        float64
+	Brightness    float64
+	Contrast      float64
+	Gamma         float64
+	Saturation    float64
+	Hue           float64
+	Threshold     float64
 	Text          string
 	Image         string
 	Font          string
@@ -61,11 +72,66 @@ type ImageOptions struct {
 	AspectRatio   string
 	Color         []uint8
 	Background    []uint8
+	Shadow        []uint8
+	Highlight     []uint8
 	Interlace     bool
+	Analyze       bool
+	Crop          bool
+	Lossless      bool
+	AutoQuality   bool
+	Manifest      bool
+	Async         bool
 	Speed         int
+	Effort        int
+	BitDepth      int
+	Colors        int
+	Dither        float64
+	Interval      float64
+	DPR           float64
+	Scale         float64
+	WidthPercent  float64
+	HeightPercent float64
+	Predictor     string
+	Loop          int
+	MaxBytes      int
+	Columns       int
+	BlockSize     int
+	Border        int
+	Angle         int
+	Radius        int
+	CenterX       int
+	CenterY       int
+	FocalX        float64
+	FocalY        float64
+	Kernel        string
+	Mode          string
+	Pattern       string
+	Layout        string
+	PageSize      string
+	Sprite        string
+	Callback      string
+	Payload       string
+	Store         string
+	TIFFCodec     string
+	Name          string
+	Enhance       string
+	Metadata      string
+	Ratio         string
+	Chroma        string
+	ChannelOrder  string
+	Mean          []float64
+	Std           []float64
+	Regions       []Region
+	Layers        []CompositeLayer
+	URLs          []string
+	Fields        []string
+	Delays        []int
+	Widths        []int
+	Blend         string
 	Extend        bimg.Extend
 	Gravity       bimg.Gravity
 	Colorspace    bimg.Interpretation
+	Interpolator  bimg.Interpolator
 	Operations    PipelineOperations
 }
 
@@ -83,15 +149,28 @@ type IsDefinedField struct {
 	StripMetadata bool
 	Interlace     bool
 	Palette       bool
+	Angle         bool
+	Jagged        bool
+	Saturation    bool
+	Threshold     bool
+	CenterX       bool
+	CenterY       bool
+	FocalX        bool
+	FocalY        bool
+	Gravity       bool
+	Effort        bool
+	Dither        bool
 }
 
 // PipelineOperation represents the structure for an operation field.
 type PipelineOperation struct {
 	Name          string                 `json:"operation"`
 	IgnoreFailure bool                   `json:"ignore_failure"`
+	If            string                 `json:"if"`
 	Params        map[string]interface{} `json:"params"`
 	ImageOptions  ImageOptions           `json:"-"`
 	Operation     Operation              `json:"-"`
+	Condition     Condition              `json:"-"`
 }
 
 // PipelineOperations defines the expected interface for a list of operations.
@@ -163,14 +242,21 @@ func BimgOptions(o ImageOptions) bimg.Options {
 		Embed:          o.Embed,
 		Extend:         o.Extend,
 		Interpretation: o.Colorspace,
+		Interpolator:   o.Interpolator,
 		StripMetadata:  o.StripMetadata,
 		Type:           ImageType(o.Type),
 		Rotate:         bimg.Angle(o.Rotate),
 		Interlace:      o.Interlace,
 		Palette:        o.Palette,
 		Speed:          o.Speed,
+		Lossless:       o.Lossless,
+		Brightness:     o.Brightness,
+		Contrast:       o.Contrast,
+		Gamma:          o.Gamma,
 	}
 
+	opts.Gravity = libvipsGravity(opts.Gravity)
+
 	if len(o.Background) != 0 {
 		opts.Background = bimg.Color{R: o.Background[0], G: o.Background[1], B: o.Background[2]}
 	}
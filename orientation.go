@@ -0,0 +1,112 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/h2non/bimg"
+)
+
+// OrientationHeader reports the EXIF orientation of the returned pixels: 1
+// once normalized, or the source one when the rotation is disabled.
+const OrientationHeader = "Image-Orientation"
+
+// exifOrientationTag is the IFD0 tag holding the EXIF orientation.
+const exifOrientationTag = 0x0112
+
+// uprightInput hands over the image with its EXIF orientation applied as a
+// lossless PNG, so the operation gets upright pixels whatever its resizing.
+func uprightInput(buf []byte, opts ImageOptions) ([]byte, ImageOptions, error) {
+	upright, err := bimg.Resize(buf, bimg.Options{Type: bimg.PNG})
+	if err != nil {
+		return nil, opts, err
+	}
+
+	opts.Type = outputTypeName(buf, opts)
+	// The orientation tag may be kept by the intermediate image
+	opts.NoRotation = true
+	return upright, opts, nil
+}
+
+// resetOrientation marks the JPEG or WebP image as upright once its pixels
+// were rotated, as libvips keeps the source EXIF orientation which would
+// make viewers rotate the image again. The buffer is edited in place.
+func resetOrientation(buf []byte) {
+	if tiff := jpegSegment(buf, 0xE1, "Exif\x00\x00"); tiff != nil {
+		resetTIFFOrientation(tiff)
+	} else if tiff := webpChunk(buf, "EXIF"); tiff != nil {
+		resetTIFFOrientation(bytes.TrimPrefix(tiff, []byte("Exif\x00\x00")))
+	}
+}
+
+// resetTIFFOrientation sets the orientation tag of the first IFD of the TIFF
+// structure holding the EXIF data to upright.
+func resetTIFFOrientation(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return
+	}
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return
+		}
+		if order.Uint16(tiff[entry:entry+2]) == exifOrientationTag {
+			order.PutUint16(tiff[entry+8:entry+10], 1)
+			return
+		}
+	}
+}
+
+// webpChunk returns the data of the first chunk of the WebP image with the
+// given FourCC.
+func webpChunk(buf []byte, fourcc string) []byte {
+	if len(buf) < 12 || string(buf[:4]) != "RIFF" || string(buf[8:12]) != "WEBP" {
+		return nil
+	}
+
+	for i := 12; i+8 <= len(buf); {
+		size := int(binary.LittleEndian.Uint32(buf[i+4 : i+8]))
+		if i+8+size > len(buf) {
+			return nil
+		}
+		if string(buf[i:i+4]) == fourcc {
+			return buf[i+8 : i+8+size]
+		}
+		i += 8 + size + size%2
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// exifTIFF builds a TIFF structure whose first IFD holds a resolution unit
+// tag followed by the orientation tag.
+func exifTIFF(order byteOrder, orientation uint16) []byte {
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 2)
+	for _, tag := range [][2]uint16{{0x0128, 2}, {exifOrientationTag, orientation}} {
+		tiff = order.AppendUint16(tiff, tag[0])
+		tiff = order.AppendUint16(tiff, 3)
+		tiff = order.AppendUint32(tiff, 1)
+		tiff = order.AppendUint16(tiff, tag[1])
+		tiff = append(tiff, 0, 0)
+	}
+	return order.AppendUint32(tiff, 0)
+}
+
+func tiffOrientation(tiff []byte, order binary.ByteOrder) uint16 {
+	return order.Uint16(tiff[10+12+8:])
+}
+
+func TestResetOrientationJPEG(t *testing.T) {
+	for _, order := range []byteOrder{binary.BigEndian, binary.LittleEndian} {
+		payload := append([]byte("Exif\x00\x00"), exifTIFF(order, 6)...)
+		buf := append([]byte{0xFF, 0xD8, 0xFF, 0xE1}, binary.BigEndian.AppendUint16(nil, uint16(len(payload)+2))...)
+		buf = append(append(buf, payload...), 0xFF, 0xDA)
+
+		resetOrientation(buf)
+		if orientation := tiffOrientation(buf[4+2+6:], order); orientation != 1 {
+			t.Errorf("Invalid %s orientation: %d", order, orientation)
+		}
+	}
+}
+
+func TestResetOrientationWebP(t *testing.T) {
+	tiff := exifTIFF(binary.LittleEndian, 8)
+	buf := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8X"), binary.LittleEndian.AppendUint32(nil, 10)...)
+	buf = append(buf, make([]byte, 10)...)
+	buf = append(append(buf, "EXIF"...), binary.LittleEndian.AppendUint32(nil, uint32(len(tiff)))...)
+	buf = append(buf, tiff...)
+
+	resetOrientation(buf)
+	if orientation := tiffOrientation(buf[len(buf)-len(tiff):], binary.LittleEndian); orientation != 1 {
+		t.Errorf("Invalid orientation: %d", orientation)
+	}
+}
+
+func TestResetOrientationUnknown(t *testing.T) {
+	buf := []byte("not an image")
+	resetOrientation(buf)
+	if string(buf) != "not an image" {
+		t.Error("Expected unknown images to be left untouched")
+	}
+}
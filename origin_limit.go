@@ -0,0 +1,188 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultOriginQueueTimeout is the default maximum time in seconds a remote
+// image fetch waits for its origin host to be available.
+const DefaultOriginQueueTimeout = 10
+
+// originLimiter bounds the outbound requests sent to every origin host, both
+// in concurrency and rate, queueing the exceeding ones. Idle hosts are evicted
+// so the limiter doesn't grow with every host ever fetched.
+type originLimiter struct {
+	concurrency int
+	interval    time.Duration
+	timeout     time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	slots chan struct{}
+	next  time.Time
+	// users counts the queued and in-flight requests
+	users int
+}
+
+// idle reports whether the host can be evicted, having no request left and
+// no rate reservation still pending.
+func (h *hostLimiter) idle(now time.Time) bool {
+	return h.users == 0 && !h.next.After(now)
+}
+
+// newOriginLimiter returns a limiter allowing the given number of concurrent
+// requests and requests per second per host, or nil if both are unlimited.
+func newOriginLimiter(concurrency, rate int, timeout time.Duration) *originLimiter {
+	if concurrency <= 0 && rate <= 0 {
+		return nil
+	}
+
+	l := &originLimiter{concurrency: concurrency, timeout: timeout, hosts: make(map[string]*hostLimiter)}
+	if rate > 0 {
+		l.interval = time.Second / time.Duration(rate)
+	}
+	return l
+}
+
+// enter returns the limiter of the host, counting a new user of it. Creating
+// a limiter evicts the idle hosts whose rate reservation has since expired.
+func (l *originLimiter) enter(name string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.hosts[name]
+	if !ok {
+		now := time.Now()
+		for other, limiter := range l.hosts {
+			if limiter.idle(now) {
+				delete(l.hosts, other)
+			}
+		}
+
+		h = &hostLimiter{}
+		if l.concurrency > 0 {
+			h.slots = make(chan struct{}, l.concurrency)
+		}
+		l.hosts[name] = h
+	}
+	h.users++
+	return h
+}
+
+// leave counts a user of the host out, evicting the host once idle.
+func (l *originLimiter) leave(name string, h *hostLimiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h.users--
+	if h.idle(time.Now()) && l.hosts[name] == h {
+		delete(l.hosts, name)
+	}
+}
+
+// reserve returns the delay before the next request to the host may start,
+// booking its turn unless it exceeds the given deadline.
+func (l *originLimiter) reserve(h *hostLimiter, deadline time.Time) (time.Duration, bool) {
+	if l.interval == 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	start := now
+	if h.next.After(now) {
+		start = h.next
+		if start.After(deadline) {
+			return 0, false
+		}
+	}
+	h.next = start.Add(l.interval)
+	return start.Sub(now), true
+}
+
+// Acquire waits for the host to accept a new request, returning the function
+// releasing it once the request is done. ErrOriginBusy is returned if the
+// wait exceeds the queue timeout. The metrics are labeled with label, which
+// must have a bounded cardinality, unlike the host names.
+func (l *originLimiter) Acquire(ctx context.Context, name, label string) (func(), error) {
+	h := l.enter(name)
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	originQueued.WithLabelValues(label).Inc()
+	defer originQueued.WithLabelValues(label).Dec()
+
+	reject := func() (func(), error) {
+		l.leave(name, h)
+		originRejected.WithLabelValues(label).Inc()
+		return nil, ErrOriginBusy
+	}
+
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		default:
+			select {
+			case h.slots <- struct{}{}:
+			case <-ctx.Done():
+				return reject()
+			}
+		}
+	}
+	free := func() {
+		originInFlight.WithLabelValues(label).Dec()
+		if h.slots != nil {
+			<-h.slots
+		}
+	}
+	originInFlight.WithLabelValues(label).Inc()
+
+	deadline, _ := ctx.Deadline()
+	delay, ok := l.reserve(h, deadline)
+	if !ok {
+		free()
+		return reject()
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			free()
+			return reject()
+		}
+	}
+
+	originWait.WithLabelValues(label).Observe(time.Since(started).Seconds())
+	return func() {
+		free()
+		l.leave(name, h)
+	}, nil
+}
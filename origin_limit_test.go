@@ -0,0 +1,108 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewOriginLimiter(t *testing.T) {
+	if newOriginLimiter(0, 0, time.Second) != nil {
+		t.Error("Expected no limiter when both concurrency and rate are unlimited")
+	}
+}
+
+func TestOriginLimiterConcurrency(t *testing.T) {
+	limiter := newOriginLimiter(1, 0, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, err := limiter.Acquire(ctx, "small.example.com", otherOrigin)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := limiter.Acquire(ctx, "small.example.com", otherOrigin); err != ErrOriginBusy {
+		t.Fatalf("Expected origin busy error, got %v", err)
+	}
+
+	other, err := limiter.Acquire(ctx, "other.example.com", otherOrigin)
+	if err != nil {
+		t.Fatalf("Expected other hosts not to be limited, got %v", err)
+	}
+	other()
+
+	release()
+	release, err = limiter.Acquire(ctx, "small.example.com", otherOrigin)
+	if err != nil {
+		t.Fatalf("Expected released slot to be available, got %v", err)
+	}
+	release()
+}
+
+func TestOriginLimiterRate(t *testing.T) {
+	limiter := newOriginLimiter(0, 10, time.Second)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		release, err := limiter.Acquire(ctx, "small.example.com", otherOrigin)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected the second fetch to be delayed, took %s", elapsed)
+	}
+
+	limiter = newOriginLimiter(0, 1, 0)
+	release, _ := limiter.Acquire(ctx, "small.example.com", otherOrigin)
+	release()
+	if _, err := limiter.Acquire(ctx, "small.example.com", otherOrigin); err != ErrOriginBusy {
+		t.Errorf("Expected origin busy error, got %v", err)
+	}
+}
+
+func TestOriginLimiterEviction(t *testing.T) {
+	limiter := newOriginLimiter(1, 0, time.Second)
+	ctx := context.Background()
+
+	release, _ := limiter.Acquire(ctx, "small.example.com", otherOrigin)
+	if len(limiter.hosts) != 1 {
+		t.Fatalf("Expected the busy host to be tracked, got %d hosts", len(limiter.hosts))
+	}
+	release()
+	if len(limiter.hosts) != 0 {
+		t.Errorf("Expected the idle host to be evicted, got %d hosts", len(limiter.hosts))
+	}
+
+	limiter = newOriginLimiter(0, 20, time.Second)
+	release, _ = limiter.Acquire(ctx, "small.example.com", otherOrigin)
+	release()
+	if len(limiter.hosts) != 1 {
+		t.Fatalf("Expected the host to be kept until its rate reservation expires, got %d hosts", len(limiter.hosts))
+	}
+	time.Sleep(60 * time.Millisecond)
+	release, _ = limiter.Acquire(ctx, "other.example.com", otherOrigin)
+	if _, ok := limiter.hosts["small.example.com"]; ok {
+		t.Error("Expected the expired host to be evicted")
+	}
+	release()
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -32,49 +33,225 @@ import (
 
 var ErrUnsupportedValue = errors.New("unsupported value")
 
+// ErrUnsupportedKernel is returned for kernels libvips doesn't support.
+var ErrUnsupportedKernel = errors.New(
+	"unsupported resize kernel, allowed values are: nearest, linear, cubic, mitchell, lanczos2, lanczos3")
+
 // Coercion is the type that type coerces a parameter and defines the appropriate field on ImageOptions
 type Coercion func(*ImageOptions, interface{}) error
 
 var paramTypeCoercions = map[string]Coercion{
-	"width":       coerceWidth,
-	"height":      coerceHeight,
-	"quality":     coerceQuality,
-	"top":         coerceTop,
-	"left":        coerceLeft,
-	"areawidth":   coerceAreaWidth,
-	"areaheight":  coerceAreaHeight,
-	"compression": coerceCompression,
-	"rotate":      coerceRotate,
-	"margin":      coerceMargin,
-	"factor":      coerceFactor,
-	"dpi":         coerceDPI,
-	"textwidth":   coerceTextWidth,
-	"opacity":     coerceOpacity,
-	"flip":        coerceFlip,
-	"flop":        coerceFlop,
-	"nocrop":      coerceNoCrop,
-	"noprofile":   coerceNoProfile,
-	"norotation":  coerceNoRotation,
-	"noreplicate": coerceNoReplicate,
-	"force":       coerceForce,
-	"embed":       coerceEmbed,
-	"stripmeta":   coerceStripMeta,
-	"text":        coerceText,
-	"image":       coerceImage,
-	"font":        coerceFont,
-	"type":        coerceImageType,
-	"color":       coerceColor,
-	"colorspace":  coerceColorSpace,
-	"gravity":     coerceGravity,
-	"background":  coerceBackground,
-	"extend":      coerceExtend,
-	"sigma":       coerceSigma,
-	"minampl":     coerceMinAmpl,
-	"operations":  coerceOperations,
-	"interlace":   coerceInterlace,
-	"aspectratio": coerceAspectRatio,
-	"palette":     coercePalette,
-	"speed":       coerceSpeed,
+	"width":        coerceWidth,
+	"height":       coerceHeight,
+	"quality":      coerceQuality,
+	"top":          coerceTop,
+	"left":         coerceLeft,
+	"areawidth":    coerceAreaWidth,
+	"areaheight":   coerceAreaHeight,
+	"compression":  coerceCompression,
+	"rotate":       coerceRotate,
+	"margin":       coerceMargin,
+	"factor":       coerceFactor,
+	"dpi":          coerceDPI,
+	"textwidth":    coerceTextWidth,
+	"opacity":      coerceOpacity,
+	"flip":         coerceFlip,
+	"flop":         coerceFlop,
+	"nocrop":       coerceNoCrop,
+	"noprofile":    coerceNoProfile,
+	"norotation":   coerceNoRotation,
+	"noreplicate":  coerceNoReplicate,
+	"force":        coerceForce,
+	"embed":        coerceEmbed,
+	"stripmeta":    coerceStripMeta,
+	"text":         coerceText,
+	"image":        coerceImage,
+	"font":         coerceFont,
+	"type":         coerceImageType,
+	"color":        coerceColor,
+	"colorspace":   coerceColorSpace,
+	"gravity":      coerceGravity,
+	"background":   coerceBackground,
+	"shadow":       coerceShadow,
+	"highlight":    coerceHighlight,
+	"extend":       coerceExtend,
+	"sigma":        coerceSigma,
+	"minampl":      coerceMinAmpl,
+	"operations":   coerceOperations,
+	"interlace":    coerceInterlace,
+	"aspectratio":  coerceAspectRatio,
+	"palette":      coercePalette,
+	"speed":        coerceSpeed,
+	"interpolator": coerceInterpolator,
+	"kernel":       coerceKernel,
+	"channelorder": coerceChannelOrder,
+	"mean":         coerceMean,
+	"std":          coerceStd,
+	"regions":      coerceRegions,
+	"layers":       coerceLayers,
+	"blend":        coerceBlend,
+	"blocksize":    coerceBlockSize,
+	"mode":         coerceMode,
+	"border":       coerceBorder,
+	"strength":     coerceStrength,
+	"pattern":      coercePattern,
+	"angle":        coerceAngle,
+	"name":         coerceName,
+	"flat":         coerceFlat,
+	"jagged":       coerceJagged,
+	"enhance":      coerceEnhance,
+	"brightness":   coerceBrightness,
+	"contrast":     coerceContrast,
+	"gamma":        coerceGamma,
+	"saturation":   coerceSaturation,
+	"hue":          coerceHue,
+	"threshold":    coerceThreshold,
+	"radius":       coerceRadius,
+	"cx":           coerceCenterX,
+	"cy":           coerceCenterY,
+	"fx":           coerceFocalX,
+	"fy":           coerceFocalY,
+	"analyze":      coerceAnalyze,
+	"metadata":     coerceMetadata,
+	"ar":           coerceRatio,
+	"crop":         coerceCrop,
+	"page":         coercePage,
+	"pages":        coercePages,
+	"urls":         coerceURLs,
+	"layout":       coerceLayout,
+	"lossless":     coerceLossless,
+	"chroma":       coerceChroma,
+	"subsample":    coerceChroma,
+	"effort":       coerceEffort,
+	"bitdepth":     coerceBitDepth,
+	"colors":       coerceColors,
+	"pagesize":     coercePageSize,
+	"tiffcodec":    coerceTIFFCodec,
+	"predictor":    coercePredictor,
+	"delay":        coerceDelay,
+	"loop":         coerceLoop,
+	"maxbytes":     coerceMaxBytes,
+	"columns":      coerceColumns,
+	"interval":     coerceInterval,
+	"sprite":       coerceSprite,
+	"dpr":          coerceDPR,
+	"scale":        coerceScale,
+	"dither":       coerceDither,
+	"widths":       coerceWidths,
+	"manifest":     coerceManifest,
+	"async":        coerceAsync,
+	"fields":       coerceFields,
+	"callback":     coerceCallback,
+	"payload":      coercePayload,
+	"store":        coerceStore,
+}
+
+// paramTypes are the expected types of the params, reported when a
+// pipeline operation param cannot be coerced.
+var paramTypes = map[string]string{
+	"width":        "int",
+	"height":       "int",
+	"quality":      "int",
+	"top":          "int",
+	"left":         "int",
+	"areawidth":    "int",
+	"areaheight":   "int",
+	"compression":  "int",
+	"rotate":       "int",
+	"margin":       "int",
+	"factor":       "int",
+	"dpi":          "int",
+	"textwidth":    "int",
+	"opacity":      "float",
+	"flip":         "bool",
+	"flop":         "bool",
+	"nocrop":       "bool",
+	"noprofile":    "bool",
+	"norotation":   "bool",
+	"noreplicate":  "bool",
+	"force":        "bool",
+	"embed":        "bool",
+	"stripmeta":    "bool",
+	"text":         "string",
+	"image":        "string",
+	"font":         "string",
+	"type":         "string",
+	"color":        "string",
+	"colorspace":   "string",
+	"gravity":      "string",
+	"background":   "string",
+	"shadow":       "string",
+	"highlight":    "string",
+	"extend":       "string",
+	"sigma":        "float",
+	"minampl":      "float",
+	"operations":   "json",
+	"interlace":    "bool",
+	"aspectratio":  "string",
+	"palette":      "bool",
+	"speed":        "int",
+	"interpolator": "string",
+	"kernel":       "string",
+	"channelorder": "string",
+	"mean":         "string",
+	"std":          "string",
+	"regions":      "string",
+	"layers":       "json",
+	"blend":        "string",
+	"blocksize":    "int",
+	"mode":         "string",
+	"border":       "int",
+	"strength":     "float",
+	"pattern":      "string",
+	"angle":        "int",
+	"name":         "string",
+	"flat":         "float",
+	"jagged":       "float",
+	"enhance":      "string",
+	"brightness":   "float",
+	"contrast":     "float",
+	"gamma":        "float",
+	"saturation":   "float",
+	"hue":          "float",
+	"threshold":    "float",
+	"radius":       "int",
+	"cx":           "int",
+	"cy":           "int",
+	"fx":           "float",
+	"fy":           "float",
+	"analyze":      "bool",
+	"metadata":     "string",
+	"ar":           "string",
+	"crop":         "bool",
+	"page":         "int",
+	"pages":        "int",
+	"urls":         "json",
+	"layout":       "string",
+	"lossless":     "bool",
+	"chroma":       "string",
+	"subsample":    "string",
+	"effort":       "int",
+	"bitdepth":     "int",
+	"colors":       "int",
+	"pagesize":     "string",
+	"tiffcodec":    "string",
+	"predictor":    "string",
+	"delay":        "string",
+	"loop":         "int",
+	"maxbytes":     "int",
+	"columns":      "int",
+	"interval":     "float",
+	"sprite":       "string",
+	"dpr":          "float",
+	"scale":        "float",
+	"dither":       "float",
+	"widths":       "string",
+	"manifest":     "bool",
+	"async":        "bool",
+	"fields":       "string",
+	"callback":     "string",
+	"payload":      "string",
+	"store":        "string",
 }
 
 func coerceTypeInt(param interface{}) (int, error) {
@@ -114,6 +291,24 @@ func coerceTypeFloat(param interface{}) (float64, error) {
 	return 0, ErrUnsupportedValue
 }
 
+// coerceTypeSignedFloat is like coerceTypeFloat but preserves negative values.
+func coerceTypeSignedFloat(param interface{}) (float64, error) {
+	if v, ok := param.(string); ok {
+		if v == "" {
+			return 0, nil
+		}
+
+		result, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, ErrUnsupportedValue
+		}
+
+		return result, nil
+	}
+
+	return coerceTypeFloat(param)
+}
+
 func coerceTypeBool(param interface{}) (bool, error) {
 	if v, ok := param.(bool); ok {
 		return v, nil
@@ -139,17 +334,44 @@ func coerceTypeString(param interface{}) (string, error) {
 	return "", ErrUnsupportedValue
 }
 
+// coerceHeight accepts a height in pixels, or a percentage of the source
+// image height, e.g. "25%".
 func coerceHeight(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok && strings.HasSuffix(v, "%") {
+		return coercePercent(&io.HeightPercent, v)
+	}
 	io.Height, err = coerceTypeInt(param)
 	return err
 }
 
+// coerceWidth accepts a width in pixels, or a percentage of the source image
+// width, e.g. "50%".
 func coerceWidth(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok && strings.HasSuffix(v, "%") {
+		return coercePercent(&io.WidthPercent, v)
+	}
 	io.Width, err = coerceTypeInt(param)
 	return err
 }
 
+func coercePercent(percent *float64, val string) error {
+	var ok bool
+	if *percent, ok = parsePercent(val); !ok {
+		return ErrUnsupportedValue
+	}
+	return nil
+}
+
+func coerceScale(io *ImageOptions, param interface{}) (err error) {
+	io.Scale, err = coerceTypeFloat(param)
+	return err
+}
+
 func coerceQuality(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok && v == QualityAuto {
+		io.AutoQuality = true
+		return nil
+	}
 	io.Quality, err = coerceTypeInt(param)
 	return err
 }
@@ -305,6 +527,7 @@ func coerceColorSpace(io *ImageOptions, param interface{}) error {
 func coerceGravity(io *ImageOptions, param interface{}) error {
 	if v, ok := param.(string); ok {
 		io.Gravity = parseGravity(v)
+		io.IsDefinedField.Gravity = true
 		return nil
 	}
 
@@ -320,6 +543,24 @@ func coerceBackground(io *ImageOptions, param interface{}) error {
 	return ErrUnsupportedValue
 }
 
+func coerceShadow(io *ImageOptions, param interface{}) error {
+	if v, ok := param.(string); ok {
+		io.Shadow = parseColor(v)
+		return nil
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceHighlight(io *ImageOptions, param interface{}) error {
+	if v, ok := param.(string); ok {
+		io.Highlight = parseColor(v)
+		return nil
+	}
+
+	return ErrUnsupportedValue
+}
+
 func coerceAspectRatio(io *ImageOptions, param interface{}) (err error) {
 	io.AspectRatio, err = coerceTypeString(param)
 	return err
@@ -374,6 +615,403 @@ func coerceSpeed(io *ImageOptions, param interface{}) (err error) {
 	return err
 }
 
+func coerceInterpolator(io *ImageOptions, param interface{}) error {
+	if v, ok := param.(string); ok {
+		interpolator, err := parseInterpolator(v)
+		if err != nil {
+			return err
+		}
+		// kernel takes precedence, whatever the params order
+		if io.Kernel == "" {
+			io.Interpolator = interpolator
+		}
+		return nil
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceKernel(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok {
+		io.Kernel = strings.TrimSpace(strings.ToLower(v))
+		io.Interpolator, err = parseKernel(io.Kernel)
+		return err
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceChannelOrder(io *ImageOptions, param interface{}) error {
+	if v, ok := param.(string); ok {
+		io.ChannelOrder = parseChannelOrder(v)
+		return nil
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceMean(io *ImageOptions, param interface{}) (err error) {
+	io.Mean, err = coerceTypeFloatList(param)
+	return err
+}
+
+func coerceStd(io *ImageOptions, param interface{}) (err error) {
+	io.Std, err = coerceTypeFloatList(param)
+	return err
+}
+
+func coerceRegions(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok {
+		io.Regions, err = parseRegions(v)
+		return err
+	}
+
+	return ErrUnsupportedValue
+}
+
+// coerceLayers accepts the layers as a JSON string, or as a JSON array
+// within pipeline operation params.
+func coerceLayers(io *ImageOptions, param interface{}) (err error) {
+	switch v := param.(type) {
+	case string:
+		io.Layers, err = parseLayers(v)
+		return err
+	case []interface{}:
+		data, _ := json.Marshal(v)
+		io.Layers, err = parseLayers(string(data))
+		return err
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceBlend(io *ImageOptions, param interface{}) (err error) {
+	io.Blend, err = coerceTypeString(param)
+	if _, ok := blendModes[io.Blend]; err == nil && !ok {
+		return ErrUnsupportedValue
+	}
+	return err
+}
+
+func coerceBlockSize(io *ImageOptions, param interface{}) (err error) {
+	io.BlockSize, err = coerceTypeInt(param)
+	// The mosaic and checkerboard loops step by the block size
+	if err == nil && io.BlockSize <= 0 {
+		return ErrUnsupportedValue
+	}
+	return err
+}
+
+func coerceMode(io *ImageOptions, param interface{}) (err error) {
+	io.Mode, err = coerceTypeString(param)
+	return err
+}
+
+func coerceBorder(io *ImageOptions, param interface{}) (err error) {
+	io.Border, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceStrength(io *ImageOptions, param interface{}) (err error) {
+	io.Strength, err = coerceTypeFloat(param)
+	return err
+}
+
+func coercePattern(io *ImageOptions, param interface{}) (err error) {
+	io.Pattern, err = coerceTypeString(param)
+	return err
+}
+
+func coerceAngle(io *ImageOptions, param interface{}) (err error) {
+	io.Angle, err = coerceTypeInt(param)
+	io.IsDefinedField.Angle = true
+	return err
+}
+
+func coerceName(io *ImageOptions, param interface{}) (err error) {
+	io.Name, err = coerceTypeString(param)
+	return err
+}
+
+func coerceFlat(io *ImageOptions, param interface{}) (err error) {
+	io.Flat, err = coerceTypeFloat(param)
+	return err
+}
+
+func coerceJagged(io *ImageOptions, param interface{}) (err error) {
+	io.Jagged, err = coerceTypeFloat(param)
+	io.IsDefinedField.Jagged = true
+	return err
+}
+
+func coerceEnhance(io *ImageOptions, param interface{}) (err error) {
+	io.Enhance, err = coerceTypeString(param)
+	return err
+}
+
+func coerceBrightness(io *ImageOptions, param interface{}) (err error) {
+	io.Brightness, err = coerceTypeSignedFloat(param)
+	return err
+}
+
+func coerceContrast(io *ImageOptions, param interface{}) (err error) {
+	io.Contrast, err = coerceTypeFloat(param)
+	return err
+}
+
+func coerceGamma(io *ImageOptions, param interface{}) (err error) {
+	io.Gamma, err = coerceTypeFloat(param)
+	return err
+}
+
+func coerceSaturation(io *ImageOptions, param interface{}) (err error) {
+	io.Saturation, err = coerceTypeFloat(param)
+	io.IsDefinedField.Saturation = true
+	return err
+}
+
+func coerceHue(io *ImageOptions, param interface{}) (err error) {
+	io.Hue, err = coerceTypeSignedFloat(param)
+	return err
+}
+
+func coerceThreshold(io *ImageOptions, param interface{}) (err error) {
+	io.Threshold, err = coerceTypeFloat(param)
+	io.IsDefinedField.Threshold = true
+	return err
+}
+
+func coerceRadius(io *ImageOptions, param interface{}) (err error) {
+	io.Radius, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceCenterX(io *ImageOptions, param interface{}) (err error) {
+	io.CenterX, err = coerceTypeInt(param)
+	io.IsDefinedField.CenterX = true
+	return err
+}
+
+func coerceCenterY(io *ImageOptions, param interface{}) (err error) {
+	io.CenterY, err = coerceTypeInt(param)
+	io.IsDefinedField.CenterY = true
+	return err
+}
+
+func coerceFocalX(io *ImageOptions, param interface{}) (err error) {
+	io.FocalX, err = coerceTypeFloat(param)
+	io.IsDefinedField.FocalX = true
+	return err
+}
+
+func coerceFocalY(io *ImageOptions, param interface{}) (err error) {
+	io.FocalY, err = coerceTypeFloat(param)
+	io.IsDefinedField.FocalY = true
+	return err
+}
+
+func coerceAnalyze(io *ImageOptions, param interface{}) (err error) {
+	io.Analyze, err = coerceTypeBool(param)
+	return err
+}
+
+func coerceMetadata(io *ImageOptions, param interface{}) (err error) {
+	io.Metadata, err = coerceTypeString(param)
+	return err
+}
+
+func coerceRatio(io *ImageOptions, param interface{}) (err error) {
+	io.Ratio, err = coerceTypeString(param)
+	return err
+}
+
+func coerceCrop(io *ImageOptions, param interface{}) (err error) {
+	io.Crop, err = coerceTypeBool(param)
+	return err
+}
+
+func coercePage(io *ImageOptions, param interface{}) (err error) {
+	io.Page, err = coerceTypeInt(param)
+	return err
+}
+
+func coercePages(io *ImageOptions, param interface{}) (err error) {
+	io.Pages, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceURLs(io *ImageOptions, param interface{}) (err error) {
+	switch v := param.(type) {
+	case string:
+		io.URLs, err = parseURLs(v)
+		return err
+	case []interface{}:
+		data, _ := json.Marshal(v)
+		io.URLs, err = parseURLs(string(data))
+		return err
+	}
+
+	return ErrUnsupportedValue
+}
+
+func coerceLayout(io *ImageOptions, param interface{}) (err error) {
+	io.Layout, err = coerceTypeString(param)
+	return err
+}
+
+func coerceLossless(io *ImageOptions, param interface{}) (err error) {
+	io.Lossless, err = coerceTypeBool(param)
+	return err
+}
+
+func coerceChroma(io *ImageOptions, param interface{}) (err error) {
+	io.Chroma, err = coerceTypeString(param)
+	return err
+}
+
+func coerceEffort(io *ImageOptions, param interface{}) (err error) {
+	io.Effort, err = coerceTypeInt(param)
+	if err == nil {
+		io.IsDefinedField.Effort = true
+	}
+	return err
+}
+
+func coerceBitDepth(io *ImageOptions, param interface{}) (err error) {
+	io.BitDepth, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceColors(io *ImageOptions, param interface{}) (err error) {
+	io.Colors, err = coerceTypeInt(param)
+	return err
+}
+
+func coercePageSize(io *ImageOptions, param interface{}) (err error) {
+	io.PageSize, err = coerceTypeString(param)
+	return err
+}
+
+func coerceTIFFCodec(io *ImageOptions, param interface{}) (err error) {
+	io.TIFFCodec, err = coerceTypeString(param)
+	return err
+}
+
+func coercePredictor(io *ImageOptions, param interface{}) (err error) {
+	io.Predictor, err = coerceTypeString(param)
+	return err
+}
+
+// coerceDelay accepts a single frame duration, or a comma separated list of
+// durations per frame.
+func coerceDelay(io *ImageOptions, param interface{}) (err error) {
+	if v, ok := param.(string); ok {
+		io.Delays, err = parseDelays(v)
+		return err
+	}
+
+	delay, err := coerceTypeInt(param)
+	io.Delays = []int{delay}
+	return err
+}
+
+func coerceLoop(io *ImageOptions, param interface{}) (err error) {
+	io.Loop, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceMaxBytes(io *ImageOptions, param interface{}) (err error) {
+	io.MaxBytes, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceColumns(io *ImageOptions, param interface{}) (err error) {
+	io.Columns, err = coerceTypeInt(param)
+	return err
+}
+
+func coerceInterval(io *ImageOptions, param interface{}) (err error) {
+	io.Interval, err = coerceTypeFloat(param)
+	return err
+}
+
+func coerceSprite(io *ImageOptions, param interface{}) (err error) {
+	io.Sprite, err = coerceTypeString(param)
+	return err
+}
+
+func coerceDPR(io *ImageOptions, param interface{}) (err error) {
+	io.DPR, err = coerceTypeFloat(param)
+	return err
+}
+
+func coerceDither(io *ImageOptions, param interface{}) (err error) {
+	io.Dither, err = coerceTypeFloat(param)
+	if err == nil {
+		io.IsDefinedField.Dither = true
+	}
+	return err
+}
+
+func coerceWidths(io *ImageOptions, param interface{}) (err error) {
+	v, err := coerceTypeString(param)
+	if err != nil {
+		return err
+	}
+	io.Widths, err = parseWidths(v)
+	return err
+}
+
+func coerceManifest(io *ImageOptions, param interface{}) (err error) {
+	io.Manifest, err = coerceTypeBool(param)
+	return err
+}
+
+func coerceAsync(io *ImageOptions, param interface{}) (err error) {
+	io.Async, err = coerceTypeBool(param)
+	return err
+}
+
+func coerceFields(io *ImageOptions, param interface{}) (err error) {
+	v, err := coerceTypeString(param)
+	io.Fields = parseHeadersList(v)
+	return err
+}
+
+func coerceCallback(io *ImageOptions, param interface{}) (err error) {
+	io.Callback, err = coerceTypeString(param)
+	return err
+}
+
+func coercePayload(io *ImageOptions, param interface{}) (err error) {
+	io.Payload, err = coerceTypeString(param)
+	return err
+}
+
+func coerceStore(io *ImageOptions, param interface{}) (err error) {
+	io.Store, err = coerceTypeString(param)
+	return err
+}
+
+func coerceTypeFloatList(param interface{}) ([]float64, error) {
+	switch v := param.(type) {
+	case string:
+		return parseFloatList(v)
+	case []interface{}:
+		list := make([]float64, 0, len(v))
+		for _, item := range v {
+			f, err := coerceTypeFloat(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, f)
+		}
+		return list, nil
+	}
+
+	return nil, ErrUnsupportedValue
+}
+
 func buildParamsFromOperation(op PipelineOperation) (ImageOptions, error) {
 	var options ImageOptions
 
@@ -388,7 +1026,13 @@ func buildParamsFromOperation(op PipelineOperation) (ImageOptions, error) {
 
 		err := fn(&options, value)
 		if err != nil {
-			return ImageOptions{}, fmt.Errorf(`error while processing parameter "%s" with value %q, error: %s`, key, value, err)
+			message := fmt.Sprintf(`error while processing parameter "%s" with value %q, expected %s, error: %s`,
+				key, value, paramTypes[key], err)
+			return ImageOptions{}, Error{
+				Message: message,
+				Code:    http.StatusBadRequest,
+				Param:   &ParamError{Name: key, Expected: paramTypes[key]},
+			}
 		}
 	}
 
@@ -452,7 +1096,13 @@ func parseColorspace(val string) bimg.Interpretation {
 	return bimg.InterpretationSRGB
 }
 
+// parseColor parses a R,G,B decimal color, or a hex one such as #ff8800,
+// ff8800 or #f80.
 func parseColor(val string) []uint8 {
+	if rgb, ok := parseHexColor(val); ok {
+		return rgb
+	}
+
 	const maxValue float64 = 255
 	var buf []uint8
 	if val != "" {
@@ -464,6 +1114,22 @@ func parseColor(val string) []uint8 {
 	return buf
 }
 
+func parseHexColor(val string) ([]uint8, bool) {
+	hex, prefixed := strings.CutPrefix(strings.TrimSpace(val), "#")
+	if len(hex) == 3 && prefixed {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return nil, false
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return []uint8{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+}
+
 func parseJSONOperations(data string) (PipelineOperations, error) {
 	var operations PipelineOperations
 
@@ -499,6 +1165,72 @@ func parseExtendMode(val string) bimg.Extend {
 	return bimg.ExtendMirror
 }
 
+func parseFloatList(val string) ([]float64, error) {
+	var list []float64
+	if val == "" {
+		return list, nil
+	}
+
+	for _, num := range strings.Split(val, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		if err != nil {
+			return nil, ErrUnsupportedValue
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+func parseInterpolator(val string) (bimg.Interpolator, error) {
+	var m = map[string]bimg.Interpolator{
+		"bicubic":  bimg.Bicubic,
+		"bilinear": bimg.Bilinear,
+		"nohalo":   bimg.Nohalo,
+		"nearest":  bimg.Nearest,
+	}
+
+	val = strings.TrimSpace(strings.ToLower(val))
+	if val == "" {
+		return bimg.Bicubic, nil
+	}
+	if i, ok := m[val]; ok {
+		return i, nil
+	}
+
+	return bimg.Bicubic, ErrUnsupportedValue
+}
+
+// parseKernel maps a resize kernel name to the interpolator upsampling with
+// it, as libvips does: the kernel itself only applies to downsampling, which
+// is done by resizedInput, the other kernels upsampling with bicubic.
+func parseKernel(val string) (bimg.Interpolator, error) {
+	var m = map[string]bimg.Interpolator{
+		"nearest":  bimg.Nearest,
+		"linear":   bimg.Bilinear,
+		"cubic":    bimg.Bicubic,
+		"mitchell": bimg.Bicubic,
+		"lanczos2": bimg.Bicubic,
+		"lanczos3": bimg.Bicubic,
+	}
+
+	val = strings.TrimSpace(strings.ToLower(val))
+	if val == "" {
+		return bimg.Bicubic, nil
+	}
+	if i, ok := m[val]; ok {
+		return i, nil
+	}
+
+	return bimg.Bicubic, ErrUnsupportedKernel
+}
+
+func parseChannelOrder(val string) string {
+	if strings.TrimSpace(strings.ToLower(val)) == ChannelOrderBGR {
+		return ChannelOrderBGR
+	}
+	return ChannelOrderRGB
+}
+
 func parseGravity(val string) bimg.Gravity {
 	var m = map[string]bimg.Gravity{
 		"south": bimg.GravitySouth,
@@ -506,6 +1238,12 @@ func parseGravity(val string) bimg.Gravity {
 		"east":  bimg.GravityEast,
 		"west":  bimg.GravityWest,
 		"smart": bimg.GravitySmart,
+		"face":  GravityFace,
+
+		"northeast": GravityNorthEast,
+		"southeast": GravitySouthEast,
+		"southwest": GravitySouthWest,
+		"northwest": GravityNorthWest,
 	}
 
 	val = strings.TrimSpace(strings.ToLower(val))
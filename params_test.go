@@ -20,6 +20,7 @@ package main
 
 import (
 	"math"
+	"net/http"
 	"net/url"
 	"testing"
 
@@ -129,6 +130,9 @@ func TestParseColor(t *testing.T) {
 		{"0,280,200", []uint8{0, 255, 200}},
 		{" -1, 256 , 50", []uint8{0, 255, 50}},
 		{" a, 20 , &hel0", []uint8{0, 20, 0}},
+		{"#ff8000", []uint8{255, 128, 0}},
+		{"FF8000", []uint8{255, 128, 0}},
+		{"#f80", []uint8{255, 136, 0}},
 		{"", []uint8{}},
 	}
 
@@ -177,6 +181,76 @@ func TestParseExtend(t *testing.T) {
 	}
 }
 
+func TestParseInterpolator(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected bimg.Interpolator
+		err      error
+	}{
+		{"nearest", bimg.Nearest, nil},
+		{"bilinear", bimg.Bilinear, nil},
+		{" NOHALO ", bimg.Nohalo, nil},
+		{"bicubic", bimg.Bicubic, nil},
+		{"invalid", bimg.Bicubic, ErrUnsupportedValue},
+		{"", bimg.Bicubic, nil},
+	}
+
+	for _, interpolator := range cases {
+		i, err := parseInterpolator(interpolator.value)
+		if i != interpolator.expected || err != interpolator.err {
+			t.Errorf("Invalid interpolator value %q: %d != %d (%v)", interpolator.value, i, interpolator.expected, err)
+		}
+	}
+
+	if _, err := buildParamsFromQuery(url.Values{"interpolator": []string{"lanczos"}}); err == nil {
+		t.Error("Expected unsupported interpolator to result in an error")
+	}
+}
+
+func TestParseKernel(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected bimg.Interpolator
+		err      error
+	}{
+		{"nearest", bimg.Nearest, nil},
+		{"Linear", bimg.Bilinear, nil},
+		{"cubic", bimg.Bicubic, nil},
+		{"lanczos3", bimg.Bicubic, nil},
+		{"", bimg.Bicubic, nil},
+		{"lanczos2", bimg.Bicubic, nil},
+		{"mitchell", bimg.Bicubic, nil},
+		{"box", bimg.Bicubic, ErrUnsupportedKernel},
+	}
+
+	for _, kernel := range cases {
+		i, err := parseKernel(kernel.value)
+		if i != kernel.expected || err != kernel.err {
+			t.Errorf("Invalid kernel value %q: %d != %d (%v)", kernel.value, i, kernel.expected, err)
+		}
+	}
+
+	opts, _ := buildParamsFromQuery(url.Values{"kernel": []string{" Nearest"}, "interpolator": []string{"bilinear"}})
+	if opts.Interpolator != bimg.Nearest || opts.Kernel != "nearest" {
+		t.Errorf("Expected kernel to take precedence over interpolator: %d, %q", opts.Interpolator, opts.Kernel)
+	}
+
+	if _, err := buildParamsFromQuery(url.Values{"kernel": []string{"box"}}); err == nil {
+		t.Error("Expected unsupported kernel to result in an error")
+	}
+}
+
+func TestParseFloatList(t *testing.T) {
+	list, err := parseFloatList("0.485, 0.456,0.406")
+	if err != nil || len(list) != 3 || list[0] != 0.485 || list[2] != 0.406 {
+		t.Errorf("Invalid float list: %v %s", list, err)
+	}
+
+	if _, err := parseFloatList("0.4,foo"); err == nil {
+		t.Error("Expected malformed values to result in an error")
+	}
+}
+
 func TestGravity(t *testing.T) {
 	cases := []struct {
 		gravityValue   string
@@ -194,6 +268,24 @@ func TestGravity(t *testing.T) {
 	}
 }
 
+func TestCornerGravity(t *testing.T) {
+	io, _ := buildParamsFromQuery(url.Values{"gravity": []string{"SouthEast"}})
+	if io.Gravity != GravitySouthEast || !io.IsDefinedField.Gravity {
+		t.Errorf("Invalid corner gravity: %d", io.Gravity)
+	}
+	if fx, fy := gravityAnchor(io.Gravity); fx != 1 || fy != 1 {
+		t.Errorf("Invalid anchor: %f,%f", fx, fy)
+	}
+	if g := libvipsGravity(io.Gravity); g != bimg.GravitySouth {
+		t.Errorf("Invalid libvips gravity: %d", g)
+	}
+
+	io, _ = buildParamsFromQuery(url.Values{})
+	if io.IsDefinedField.Gravity {
+		t.Error("Expected the gravity not to be defined")
+	}
+}
+
 func TestReadMapParams(t *testing.T) {
 	cases := []struct {
 		params   map[string]interface{}
@@ -253,6 +345,49 @@ func validateImageOptions(t *testing.T, opts, expected ImageOptions) {
 	}
 }
 
+func TestCoerceBlend(t *testing.T) {
+	opts := ImageOptions{}
+	if err := coerceBlend(&opts, BlendMultiply); err != nil || opts.Blend != BlendMultiply {
+		t.Errorf("Invalid blend mode: %s, %v", opts.Blend, err)
+	}
+	if err := coerceBlend(&opts, "dodge"); err != ErrUnsupportedValue {
+		t.Errorf("Expected unsupported blend mode error, got %v", err)
+	}
+}
+
+func TestCoerceBlockSize(t *testing.T) {
+	opts := ImageOptions{}
+	if err := coerceBlockSize(&opts, "8"); err != nil || opts.BlockSize != 8 {
+		t.Errorf("Invalid block size: %d, %v", opts.BlockSize, err)
+	}
+	// Query params are made absolute, unlike the numbers of JSON bodies
+	for _, size := range []interface{}{"0", -5, -5.0} {
+		if err := coerceBlockSize(&opts, size); err != ErrUnsupportedValue {
+			t.Errorf("Expected block size %v to be rejected, got %v", size, err)
+		}
+	}
+}
+
+func TestCoerceEffort(t *testing.T) {
+	opts := ImageOptions{}
+	if err := coerceEffort(&opts, "max"); err == nil || opts.IsDefinedField.Effort {
+		t.Errorf("Expected invalid effort not to be defined: %v", err)
+	}
+	if err := coerceEffort(&opts, "4"); err != nil || opts.Effort != 4 || !opts.IsDefinedField.Effort {
+		t.Errorf("Invalid effort: %v, %v", opts.Effort, err)
+	}
+}
+
+func TestCoerceDither(t *testing.T) {
+	opts := ImageOptions{}
+	if err := coerceDither(&opts, "strong"); err == nil || opts.IsDefinedField.Dither {
+		t.Errorf("Expected invalid dither not to be defined: %v", err)
+	}
+	if err := coerceDither(&opts, "0.5"); err != nil || opts.Dither != 0.5 || !opts.IsDefinedField.Dither {
+		t.Errorf("Invalid dither: %v, %v", opts.Dither, err)
+	}
+}
+
 func TestParseFunctions(t *testing.T) {
 	t.Run("parseBool", func(t *testing.T) {
 		if r, err := parseBool("true"); r != true {
@@ -308,6 +443,32 @@ func TestBuildParamsFromOperation(t *testing.T) {
 	}
 }
 
+func TestBuildParamsFromOperationError(t *testing.T) {
+	op := PipelineOperation{Params: map[string]interface{}{"width": "wide"}}
+
+	_, err := buildParamsFromOperation(op)
+	xerr, ok := err.(Error)
+	if !ok || xerr.Param == nil {
+		t.Fatalf("Expected an error detailing the param, got %v", err)
+	}
+	if xerr.Param.Name != "width" || xerr.Param.Expected != "int" || xerr.Code != http.StatusBadRequest {
+		t.Errorf("Invalid param error: %+v", xerr.Param)
+	}
+}
+
+func TestParamTypes(t *testing.T) {
+	for name := range paramTypeCoercions {
+		if paramTypes[name] == "" {
+			t.Errorf("Missing expected type of param %s", name)
+		}
+	}
+	for name := range paramTypes {
+		if _, ok := paramTypeCoercions[name]; !ok {
+			t.Errorf("Expected type of unknown param %s", name)
+		}
+	}
+}
+
 // testCase is a generic type to hold a single test case.
 type testCase[T any] struct {
 	Input  interface{}
@@ -354,6 +515,15 @@ func TestCoerceTypeFns(t *testing.T) {
 		return math.Abs(a-b) <= epsilon
 	})
 
+	runTests[float64](t, "coerceTypeSignedFloat", []testCase[float64]{
+		{Input: "-20.5", Expect: -20.5},
+		{Input: "", Expect: 0},
+		{Input: float64(-3), Expect: -3},
+		{Input: "foo", Err: ErrUnsupportedValue},
+	}, coerceTypeSignedFloat, func(a, b float64) bool {
+		return math.Abs(a-b) <= epsilon
+	})
+
 	runTests[bool](t, "coerceTypeBool", []testCase[bool]{
 		{Input: "true", Expect: true},
 		{Input: true, Expect: true},
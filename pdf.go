@@ -0,0 +1,266 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// ContentTypePDF is the MIME type of the ToPDF output.
+const ContentTypePDF = "application/pdf"
+
+// DefaultPDFDPI is the resolution of the images wrapped into a PDF when no
+// dpi param is given.
+const DefaultPDFDPI = 150
+
+// MaxPDFDPI is the maximum resolution of the images wrapped into a PDF.
+const MaxPDFDPI = 600
+
+// MaxNextPages is the maximum number of URLs of the next pages of PDF and
+// TIFF documents.
+const MaxNextPages = 9
+
+// pdfSize is a page size, in points.
+type pdfSize struct {
+	Width  float64
+	Height float64
+}
+
+// PDFPageSizes are the supported page sizes, in portrait orientation. The
+// fit page size, the default, matches the size of every image instead.
+var PDFPageSizes = map[string]pdfSize{
+	"a3":     {842, 1191},
+	"a4":     {595, 842},
+	"a5":     {420, 595},
+	"letter": {612, 792},
+	"legal":  {612, 1008},
+	"fit":    {},
+}
+
+// pdfPage is a PDF page holding a JPEG image at the given position and size,
+// all in points from the bottom left corner.
+type pdfPage struct {
+	Width  float64
+	Height float64
+	X      float64
+	Y      float64
+	W      float64
+	H      float64
+	Image  []byte
+}
+
+// @Summary Convert to PDF
+// @Description Wraps the image, followed by the images of the urls param, into a PDF document with a page per image
+// @Accept multipart/form-data
+// @Produce application/pdf
+// @Param file formData file true "Image file to process"
+// @Param urls query string false "JSON list of the URLs of the next pages images"
+// @Param pagesize query string false "Page size (a3, a4, a5, letter, legal, fit). Defaults to fit"
+// @Param margin query int false "Page margin in points (default 0)"
+// @Param dpi query int false "Resolution of the images (default 150)"
+// @Param quality query int false "JPEG quality of the images (1-100)"
+// @Success 200 {file} binary "PDF document"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /topdf [post]
+func ToPDF(buf []byte, o ImageOptions) (Image, error) {
+	size, ok := PDFPageSizes[strings.ToLower(o.PageSize)]
+	if !ok && o.PageSize != "" {
+		return Image{}, NewError("Unsupported pagesize value. Allowed values are: a3, a4, a5, letter, legal, fit",
+			http.StatusBadRequest)
+	}
+	if o.Margin < 0 {
+		return Image{}, NewError("Invalid param: margin must be positive", http.StatusBadRequest)
+	}
+	dpi := o.DPI
+	if dpi == 0 {
+		dpi = DefaultPDFDPI
+	}
+	if dpi < 0 || dpi > MaxPDFDPI {
+		return Image{}, NewError(fmt.Sprintf("Invalid param: dpi must be between 1 and %d", MaxPDFDPI),
+			http.StatusBadRequest)
+	}
+
+	if err := checkURLs(o.URLs, MaxNextPages); err != nil {
+		return Image{}, err
+	}
+
+	images := [][]byte{buf}
+	for i, url := range o.URLs {
+		image, err := loadLayer(CompositeLayer{URL: url})
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Unable to load page %d: %s", i+2, err), http.StatusBadRequest)
+		}
+		if err := validateLayerSize(image); err != nil {
+			xerr := toError(err)
+			xerr.Message = fmt.Sprintf("page %d: %s", i+2, xerr.Message)
+			return Image{}, xerr
+		}
+		images = append(images, image)
+	}
+
+	pages := make([]pdfPage, 0, len(images))
+	for i, image := range images {
+		page, err := pdfImagePage(image, size, float64(o.Margin), dpi, o.Quality)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot process page %d: %s", i+1, err), http.StatusBadRequest)
+		}
+		pages = append(pages, page)
+	}
+
+	body, err := writePDF(pages)
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{Body: body, Mime: ContentTypePDF}, nil
+}
+
+// pdfImagePage lays out the image on a page and encodes it as JPEG at the
+// resolution it is printed at.
+func pdfImagePage(buf []byte, size pdfSize, margin float64, dpi, quality int) (pdfPage, error) {
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return pdfPage{}, err
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+	if width == 0 || height == 0 {
+		return pdfPage{}, errors.New("width or height of the image is zero")
+	}
+
+	page, pixelWidth, pixelHeight, err := pdfLayout(width, height, size, margin, dpi)
+	if err != nil {
+		return pdfPage{}, err
+	}
+
+	// JPEG images are embedded as is by PDF readers, transparent areas
+	// being flattened on white as on paper
+	image, err := Process(buf, bimg.Options{
+		Width:          pixelWidth,
+		Height:         pixelHeight,
+		Force:          true,
+		Type:           bimg.JPEG,
+		Quality:        quality,
+		Interpretation: bimg.InterpretationSRGB,
+		Background:     bimg.Color{R: 255, G: 255, B: 255},
+		StripMetadata:  true,
+	})
+	if err != nil {
+		return pdfPage{}, err
+	}
+	page.Image = image.Body
+	return page, nil
+}
+
+// pdfLayout lays out an image of the given size in pixels on a page, in the
+// page orientation matching the image one, or sized to the image for the fit
+// page size. The image is printed at the resolution, shrunk to fit within the
+// margin, and its returned size in pixels is never enlarged.
+func pdfLayout(width, height int, size pdfSize, margin float64, dpi int) (pdfPage, int, int, error) {
+	printedWidth := float64(width) * 72 / float64(dpi)
+	printedHeight := float64(height) * 72 / float64(dpi)
+
+	page := pdfPage{Width: size.Width, Height: size.Height}
+	switch {
+	case size == pdfSize{}:
+		page.Width, page.Height = printedWidth+2*margin, printedHeight+2*margin
+	case (width > height && page.Width < page.Height) || (width < height && page.Width > page.Height):
+		page.Width, page.Height = page.Height, page.Width
+	}
+
+	boxWidth, boxHeight := page.Width-2*margin, page.Height-2*margin
+	if boxWidth <= 0 || boxHeight <= 0 {
+		return pdfPage{}, 0, 0, errors.New("the margin leaves no room for the image")
+	}
+
+	scale := math.Min(1, math.Min(boxWidth/printedWidth, boxHeight/printedHeight))
+	page.W, page.H = printedWidth*scale, printedHeight*scale
+	page.X, page.Y = (page.Width-page.W)/2, (page.Height-page.H)/2
+
+	pixelWidth := max(1, int(math.Round(float64(width)*scale)))
+	pixelHeight := max(1, int(math.Round(float64(height)*scale)))
+	return page, pixelWidth, pixelHeight, nil
+}
+
+// writePDF writes a PDF document of the pages, their JPEG images being
+// embedded with the DCTDecode filter.
+func writePDF(pages []pdfPage) ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...any) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&out, format, args...)
+		out.WriteString("\nendobj\n")
+	}
+
+	// The comment of non-ASCII characters flags the file as binary
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 3+3*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	for i, page := range pages {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(page.Image))
+		if err != nil {
+			return nil, fmt.Errorf("invalid image of page %d: %w", i+1, err)
+		}
+		colorSpace := "/DeviceRGB"
+		switch config.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			return nil, fmt.Errorf("invalid image of page %d: CMYK images are not supported", i+1)
+		}
+
+		content := fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm /Im0 Do Q", page.W, page.H, page.X, page.Y)
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> "+
+			"/Contents %d 0 R >>", page.Width, page.Height, 5+3*i, 4+3*i)
+		object("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+		object("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 "+
+			"/Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+			config.Width, config.Height, colorSpace, len(page.Image), page.Image)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
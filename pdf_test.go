@@ -0,0 +1,148 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDFLayout(t *testing.T) {
+	cases := []struct {
+		width, height int
+		size          pdfSize
+		margin        float64
+		dpi           int
+		page          pdfPage
+		pixels        [2]int
+	}{
+		// Printed at 144 DPI, the page matches the image size
+		{288, 144, PDFPageSizes["fit"], 10, 144, pdfPage{Width: 164, Height: 92, X: 10, Y: 10, W: 144, H: 72},
+			[2]int{288, 144}},
+		// Landscape images turn the page, small ones are not enlarged
+		{300, 150, PDFPageSizes["a4"], 0, 150, pdfPage{Width: 842, Height: 595, X: 349, Y: 261.5, W: 144, H: 72},
+			[2]int{300, 150}},
+		// Large images are shrunk to fit within the margin
+		{3000, 6000, PDFPageSizes["a5"], 50, 72, pdfPage{Width: 420, Height: 595, X: 86.25, Y: 50, W: 247.5, H: 495},
+			[2]int{248, 495}},
+	}
+
+	for _, c := range cases {
+		page, width, height, err := pdfLayout(c.width, c.height, c.size, c.margin, c.dpi)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(page, c.page) || width != c.pixels[0] || height != c.pixels[1] {
+			t.Errorf("Invalid layout of %dx%d: %+v, %dx%d", c.width, c.height, page, width, height)
+		}
+	}
+
+	if _, _, _, err := pdfLayout(100, 100, PDFPageSizes["a5"], 300, 72); err == nil {
+		t.Error("Expected an error when the margin leaves no room for the image")
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 20, 10)), nil)
+	page := pdfPage{Width: 100, Height: 50, W: 100, H: 50, Image: buf.Bytes()}
+
+	pdf, err := writePDF([]pdfPage{page, page})
+	if err != nil {
+		t.Fatalf("Cannot write PDF: %s", err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Error("Invalid PDF header or trailer")
+	}
+	contents := []string{"/Count 2", "/Width 20 /Height 10 /ColorSpace /DeviceGray", "/MediaBox [0 0 100.00 50.00]"}
+	for _, expected := range contents {
+		if !bytes.Contains(pdf, []byte(expected)) {
+			t.Errorf("Expected the PDF to contain %q", expected)
+		}
+	}
+
+	// Every cross-reference table entry points to its object
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(pdf, -1)
+	if len(offsets) != 8 {
+		t.Fatalf("Invalid number of objects: %d", len(offsets))
+	}
+	for i, offset := range offsets {
+		start, _ := strconv.Atoi(string(offset[1]))
+		if !bytes.HasPrefix(pdf[start:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Errorf("Invalid offset of object %d: %d", i+1, start)
+		}
+	}
+
+	if _, err := writePDF([]pdfPage{{Image: []byte("not a jpeg")}}); err == nil {
+		t.Error("Expected an error with an invalid image")
+	}
+}
+
+func TestToPDF(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("large.jpg"))
+
+	img, err := ToPDF(buf, ImageOptions{PageSize: "a4", Margin: 36})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ContentTypePDF || !bytes.HasPrefix(img.Body, []byte("%PDF-")) {
+		t.Error(InvalidMimeType)
+	}
+	if GetImageExtension(img.Mime) != "pdf" {
+		t.Errorf("Invalid extension: %s", GetImageExtension(img.Mime))
+	}
+
+	invalid := []ImageOptions{{PageSize: "a0"}, {Margin: -1}, {DPI: 1200}, {URLs: []string{"http://localhost/a.jpg"}}}
+	for _, opts := range invalid {
+		if _, err := ToPDF(buf, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestToPDFPagesLimits(t *testing.T) {
+	t.Cleanup(func() {
+		LoadSources(ServerOptions{})
+		LoadLayerLimits(ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels})
+	})
+	LoadSources(ServerOptions{EnableURLSource: true})
+	LoadLayerLimits(ServerOptions{MaxAllowedPixels: 0.1})
+
+	tsImage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		buf, _ := os.ReadFile("testdata/imaginary.jpg")
+		_, _ = w.Write(buf)
+	}))
+	defer tsImage.Close()
+
+	buf, _ := io.ReadAll(readFile("test.png"))
+	_, err := ToPDF(buf, ImageOptions{URLs: []string{tsImage.URL}})
+	if xerr, ok := err.(Error); !ok || xerr.Code != ErrResolutionTooBig.Code || xerr.Message != "page 2: "+ErrResolutionTooBig.Message {
+		t.Errorf("Expected the pages above the resolution limit to be rejected, got %v", err)
+	}
+}
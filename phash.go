@@ -0,0 +1,173 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	// hashSize is the side of the grid of bits making up every hash.
+	hashSize = 8
+	// dctSize is the side of the image the perceptual hash is computed from.
+	dctSize = 32
+)
+
+// ImageHashes represents the perceptual hashes of an image, as 64 bits
+// hexadecimal fingerprints: the lower the Hamming distance between the
+// hashes of two images, the more similar they look.
+type ImageHashes struct {
+	AHash string `json:"ahash"`
+	DHash string `json:"dhash"`
+	PHash string `json:"phash"`
+}
+
+// @Summary Perceptual hashes
+// @Description Returns the average, difference and DCT based perceptual hashes of the image, to fingerprint it for deduplication
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image file to fingerprint"
+// @Success 200 {object} ImageHashes
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /phash [post]
+func Phash(buf []byte, _ ImageOptions) (Image, error) {
+	image := Image{Mime: "application/json"}
+
+	hashes, err := perceptualHashes(buf)
+	if err != nil {
+		return image, NewError("Cannot hash image: "+err.Error(), http.StatusBadRequest)
+	}
+
+	body, _ := json.Marshal(hashes)
+	image.Body = body
+
+	return image, nil
+}
+
+func perceptualHashes(buf []byte) (ImageHashes, error) {
+	average, err := grayscaleThumbnail(buf, hashSize, hashSize)
+	if err != nil {
+		return ImageHashes{}, err
+	}
+	difference, err := grayscaleThumbnail(buf, hashSize+1, hashSize)
+	if err != nil {
+		return ImageHashes{}, err
+	}
+	perceptual, err := grayscaleThumbnail(buf, dctSize, dctSize)
+	if err != nil {
+		return ImageHashes{}, err
+	}
+
+	return ImageHashes{
+		AHash: formatHash(averageHash(average)),
+		DHash: formatHash(differenceHash(difference)),
+		PHash: formatHash(dctHash(perceptual)),
+	}, nil
+}
+
+// grayscaleThumbnail returns the luma of the image squashed to the given
+// dimensions, discarding its aspect ratio.
+func grayscaleThumbnail(buf []byte, width, height int) ([]float64, error) {
+	reduced, err := bimg.Resize(buf, bimg.Options{Width: width, Height: height, Force: true, Type: bimg.PNG})
+	if err != nil {
+		return nil, err
+	}
+	pixels, _, err := decodePixels(reduced)
+	if err != nil {
+		return nil, err
+	}
+	return luminance(pixels), nil
+}
+
+func formatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// bitsAbove sets the bits, most significant first, of the values above the
+// threshold.
+func bitsAbove(values []float64, threshold float64) uint64 {
+	var hash uint64
+	for _, v := range values {
+		hash <<= 1
+		if v > threshold {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// averageHash compares every pixel of the 8x8 image to their mean.
+func averageHash(gray []float64) uint64 {
+	return bitsAbove(gray, mean(gray))
+}
+
+// differenceHash compares every pixel of the 9x8 image to its left
+// neighbour, following the horizontal gradients.
+func differenceHash(gray []float64) uint64 {
+	var hash uint64
+	for y := 0; y < hashSize; y++ {
+		row := gray[y*(hashSize+1) : (y+1)*(hashSize+1)]
+		for x := 1; x <= hashSize; x++ {
+			hash <<= 1
+			if row[x] > row[x-1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// dctHash compares the lowest 8x8 frequencies of the discrete cosine
+// transform of the 32x32 image to their median, which makes it robust to
+// scaling, compression and slight color changes.
+func dctHash(gray []float64) uint64 {
+	var cosines [hashSize][dctSize]float64
+	for u := 0; u < hashSize; u++ {
+		for x := 0; x < dctSize; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * dctSize))
+		}
+	}
+
+	coefficients := make([]float64, 0, hashSize*hashSize)
+	for v := 0; v < hashSize; v++ {
+		for u := 0; u < hashSize; u++ {
+			var sum float64
+			for y := 0; y < dctSize; y++ {
+				for x := 0; x < dctSize; x++ {
+					sum += gray[y*dctSize+x] * cosines[u][x] * cosines[v][y]
+				}
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	sorted := slices.Clone(coefficients)
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	return bitsAbove(coefficients, median)
+}
@@ -0,0 +1,83 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestAverageHash(t *testing.T) {
+	gray := make([]float64, hashSize*hashSize)
+	for i := len(gray) / 2; i < len(gray); i++ {
+		gray[i] = 255
+	}
+
+	if hash := formatHash(averageHash(gray)); hash != "00000000ffffffff" {
+		t.Errorf("Invalid average hash: %s", hash)
+	}
+}
+
+func TestDifferenceHash(t *testing.T) {
+	gray := make([]float64, (hashSize+1)*hashSize)
+	for i := range gray {
+		gray[i] = float64(i % (hashSize + 1))
+	}
+
+	if hash := formatHash(differenceHash(gray)); hash != "ffffffffffffffff" {
+		t.Errorf("Invalid difference hash: %s", hash)
+	}
+}
+
+func TestDCTHash(t *testing.T) {
+	gray := make([]float64, dctSize*dctSize)
+	adjusted := make([]float64, len(gray))
+	for i := range gray {
+		x, y := i%dctSize, i/dctSize
+		gray[i] = float64((x*7 + y*y*3) % 256)
+		adjusted[i] = gray[i]*0.5 + 20
+	}
+
+	if dctHash(gray) != dctHash(adjusted) {
+		t.Error("Expected brightness and contrast changes not to change the perceptual hash")
+	}
+}
+
+func TestImagePhash(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Phash(buf, ImageOptions{})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != "application/json" {
+		t.Error(InvalidMimeType)
+	}
+
+	var hashes ImageHashes
+	if err := json.Unmarshal(img.Body, &hashes); err != nil {
+		t.Fatalf("Cannot decode hashes: %s", err)
+	}
+	for _, hash := range []string{hashes.AHash, hashes.DHash, hashes.PHash} {
+		if len(hash) != 16 {
+			t.Errorf("Invalid hash: %q", hash)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// decodePixels decodes any image format supported by libvips into a
+// non-premultiplied RGBA canvas, reusing the pixel operations cache if enabled.
+func decodePixels(buf []byte) (*image.NRGBA, bool, error) {
+	if decodedPixels == nil {
+		return decodeImagePixels(buf)
+	}
+
+	key := sha256.Sum256(buf)
+	if pixels, alpha, ok := decodedPixels.get(key); ok {
+		return pixels, alpha, nil
+	}
+
+	pixels, alpha, err := decodeImagePixels(buf)
+	if err != nil {
+		return nil, false, err
+	}
+	decodedPixels.add(key, pixels, alpha)
+	return pixels, alpha, nil
+}
+
+// decodeImagePixels transcodes the buffer to lossless PNG with libvips first
+// so the pixels can be handled by the Go image packages, converting CMYK
+// images to sRGB with their ICC profile.
+func decodeImagePixels(buf []byte) (*image.NRGBA, bool, error) {
+	var err error
+	switch {
+	case isCMYK(buf):
+		buf, err = loadPNG(buf, "", cmykProfile)
+	case bimg.DetermineImageType(buf) != bimg.PNG:
+		buf, err = bimg.Resize(buf, bimg.Options{Type: bimg.PNG})
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return toNRGBA(img), hasAlpha(img), nil
+}
+
+// toNRGBA converts the given image into a NRGBA canvas with origin at 0,0.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+
+	b := img.Bounds()
+	canvas := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), img, b.Min, draw.Src)
+	return canvas
+}
+
+// hasAlpha reports whether the decoded image carries an alpha channel.
+func hasAlpha(img image.Image) bool {
+	switch m := img.(type) {
+	case *image.Gray, *image.Gray16, *image.YCbCr, *image.CMYK:
+		return false
+	case *image.Paletted:
+		for _, c := range m.Palette {
+			if _, _, _, a := c.RGBA(); a != 0xffff {
+				return true
+			}
+		}
+		return false
+	case *image.RGBA:
+		return !m.Opaque()
+	case *image.RGBA64:
+		return !m.Opaque()
+	default:
+		return true
+	}
+}
+
+// processPixels decodes the image, lets fn edit its pixels, either in place
+// or returning a new canvas, and encodes the result back to the requested
+// output type, which defaults to the source image type when libvips can save
+// it, or PNG otherwise.
+func processPixels(buf []byte, o ImageOptions, fn func(*image.NRGBA) (*image.NRGBA, error)) (Image, error) {
+	pixels, _, err := decodePixels(buf)
+	if err != nil {
+		return Image{}, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+
+	pixels, err = fn(pixels)
+	if err != nil {
+		return Image{}, err
+	}
+
+	return encodePixels(pixels, outputType(buf, o), o)
+}
+
+// encodePixels encodes the canvas with libvips applying the output related
+// options, such as quality or interlace.
+func encodePixels(img *image.NRGBA, t bimg.ImageType, o ImageOptions) (Image, error) {
+	var out bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&out, img); err != nil {
+		return Image{}, err
+	}
+
+	opts := bimg.Options{
+		Type:          t,
+		Quality:       o.Quality,
+		Compression:   o.Compression,
+		Interlace:     o.Interlace,
+		Palette:       o.Palette,
+		Speed:         o.Speed,
+		StripMetadata: o.StripMetadata,
+	}
+	if len(o.Background) != 0 {
+		opts.Background = bimg.Color{R: o.Background[0], G: o.Background[1], B: o.Background[2]}
+	}
+	return Process(out.Bytes(), opts)
+}
+
+// supportsAlpha reports whether the image type can be saved with an alpha
+// channel.
+func supportsAlpha(t bimg.ImageType) bool {
+	switch t {
+	case bimg.PNG, bimg.WEBP, bimg.AVIF, bimg.HEIF, bimg.TIFF, bimg.GIF:
+		return true
+	default:
+		return false
+	}
+}
+
+// outputTypeName resolves the type param keeping the source image type, for
+// operations handing over a lossless PNG intermediate image.
+func outputTypeName(buf []byte, o ImageOptions) string {
+	if o.Type != "" {
+		return o.Type
+	}
+	if name := bimg.ImageTypeName(outputType(buf, o)); ImageType(name) != bimg.UNKNOWN {
+		return name
+	}
+	return PNG
+}
+
+// outputType resolves the image type to encode pixel based operations to.
+func outputType(buf []byte, o ImageOptions) bimg.ImageType {
+	if t := ImageType(o.Type); t != bimg.UNKNOWN {
+		return t
+	}
+	if t := bimg.DetermineImageType(buf); bimg.IsTypeSupportedSave(t) {
+		return t
+	}
+	return bimg.PNG
+}
@@ -0,0 +1,106 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/bits"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxPNGColors is the largest PNG palette, i.e. 8 bits per pixel.
+const MaxPNGColors = 256
+
+// isTunedPNGOutput reports whether PNG output is requested, explicitly or
+// keeping the PNG input type, with quantization options bimg does not
+// support, so the image is encoded by imaginary.
+func isTunedPNGOutput(buf []byte, opts ImageOptions) bool {
+	if opts.Colors == 0 && !opts.IsDefinedField.Dither {
+		return false
+	}
+	if opts.Type == "" {
+		return bimg.DetermineImageType(buf) == bimg.PNG
+	}
+	return ImageType(opts.Type) == bimg.PNG
+}
+
+// EncodePNG quantizes the PNG image produced by the operation to a palette of
+// the requested colors, quality and dithering, using libimagequant through
+// libvips. Other outputs, such as JSON ones, are returned as is.
+func EncodePNG(img Image, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	bitDepth, err := pngBitDepth(o.Colors)
+	if err != nil {
+		return Image{}, err
+	}
+	dither := 1.0
+	if o.IsDefinedField.Dither {
+		if o.Dither < 0 || o.Dither > 1 {
+			return Image{}, NewError("Invalid param: dither must be between 0 and 1", http.StatusBadRequest)
+		}
+		dither = o.Dither
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the palette PNG encoded by bimg
+		quality = bimg.Quality
+	}
+	compression := o.Compression
+	if compression == 0 {
+		// Same default as the PNG encoded by bimg
+		compression = 6
+	}
+
+	body, err := savePNG(img.Body, pngOptions{
+		Quality:     quality,
+		BitDepth:    bitDepth,
+		Dither:      dither,
+		Compression: compression,
+		Interlace:   o.Interlace,
+		Strip:       o.StripMetadata,
+	})
+	if err != nil {
+		return Image{}, NewError("Cannot encode PNG image: "+err.Error(), http.StatusBadRequest)
+	}
+	return Image{Body: body, Mime: ImagePNG, Header: img.Header}, nil
+}
+
+// pngBitDepth returns the bits per pixel of a palette of the given colors,
+// libvips supporting 1, 2, 4 and 8 bits palettes only. The palette therefore
+// has up to the next power of two colors, e.g. 16 colors for 10 ones.
+func pngBitDepth(colors int) (int, error) {
+	if colors == 0 {
+		return 8, nil
+	}
+	if colors < 2 || colors > MaxPNGColors {
+		return 0, NewError("Invalid param: colors must be between 2 and 256", http.StatusBadRequest)
+	}
+
+	depth := bits.Len(uint(colors - 1))
+	if depth == 3 {
+		depth = 4
+	} else if depth > 4 {
+		depth = 8
+	}
+	return depth, nil
+}
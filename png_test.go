@@ -0,0 +1,90 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestPNGBitDepth(t *testing.T) {
+	cases := map[int]int{0: 8, 2: 1, 4: 2, 5: 4, 16: 4, 17: 8, 64: 8, 256: 8}
+	for colors, expected := range cases {
+		if depth, err := pngBitDepth(colors); err != nil || depth != expected {
+			t.Errorf("Invalid bit depth for %d colors: %d, %v", colors, depth, err)
+		}
+	}
+
+	for _, colors := range []int{-1, 1, 257} {
+		if _, err := pngBitDepth(colors); err == nil {
+			t.Errorf("Expected an error with %d colors", colors)
+		}
+	}
+}
+
+func TestIsTunedPNGOutput(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+	cases := []struct {
+		buf      []byte
+		opts     ImageOptions
+		expected bool
+	}{
+		{png, ImageOptions{Type: PNG}, false},
+		{png, ImageOptions{Type: PNG, Palette: true}, false},
+		{jpeg, ImageOptions{Type: PNG, Colors: 64}, true},
+		{jpeg, ImageOptions{Type: PNG, IsDefinedField: IsDefinedField{Dither: true}}, true},
+		{png, ImageOptions{Colors: 64}, true},
+		{jpeg, ImageOptions{Colors: 64}, false},
+		{png, ImageOptions{Type: WebP, Colors: 64}, false},
+	}
+
+	for _, c := range cases {
+		if isTunedPNGOutput(c.buf, c.opts) != c.expected {
+			t.Errorf("Expected %v with %+v", c.expected, c.opts)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	opts := ImageOptions{Colors: 16, Dither: 0, IsDefinedField: IsDefinedField{Dither: true}}
+	img, err := EncodePNG(Image{Body: buf, Mime: ImagePNG}, opts)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImagePNG || bimg.DetermineImageType(img.Body) != bimg.PNG {
+		t.Error(InvalidMimeType)
+	}
+	if len(img.Body) >= len(buf) {
+		t.Errorf("Expected the quantized image to be smaller: %d >= %d", len(img.Body), len(buf))
+	}
+
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	if img, err := EncodePNG(json, opts); err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+	opts.Dither = 2
+	if _, err := EncodePNG(Image{Body: buf, Mime: ImagePNG}, opts); err == nil {
+		t.Error("Expected an error with an invalid dither")
+	}
+}
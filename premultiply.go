@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+// premultiplyAlpha enables the premultiplied alpha resizing of the
+// -premultiply-alpha flag.
+var premultiplyAlpha bool
+
+// LoadPremultiplyAlpha enables the premultiplied alpha resizing when
+// configured.
+func LoadPremultiplyAlpha(o ServerOptions) {
+	premultiplyAlpha = o.PremultiplyAlpha
+}
+
+// ErrKernelTransform is returned when the kernel param cannot be applied,
+// bimg downscaling with lanczos3 after these transformations.
+var ErrKernelTransform = NewError("Invalid param: kernel cannot be combined with trim, zoom or area extraction",
+	http.StatusBadRequest)
+
+// resizedInput downscales the image to the size bimg would resize it to with
+// libvips, bimg then only cropping or embedding it: with the kernel param, if
+// any, as bimg always downscales with lanczos3, and with the colors of the
+// images with an alpha channel premultiplied by the alpha if enabled,
+// avoiding the dark fringes around the transparent areas. The resized image
+// is handed over upright as lossless PNG, so the output type defaults to the
+// source one as usual.
+func resizedInput(buf []byte, opts bimg.Options, kernel string) ([]byte, bimg.Options, error) {
+	// Transformations applied before resizing change the size to compute
+	if opts.AreaWidth != 0 || opts.AreaHeight != 0 || opts.Trim || opts.Zoom != 0 {
+		if kernel != "" && kernel != "lanczos3" {
+			return nil, opts, ErrKernelTransform
+		}
+		return buf, opts, nil
+	}
+
+	meta, err := bimg.Metadata(buf)
+	if err != nil {
+		return buf, opts, nil
+	}
+	premultiply := premultiplyAlpha && meta.Alpha
+	if !premultiply && kernel == "" {
+		return buf, opts, nil
+	}
+
+	// bimg rotates the image before resizing it, the EXIF orientation being
+	// ignored with the rotate param. Flipping it doesn't change its size.
+	autorotate := !opts.NoAutoRotate && opts.Rotate <= 0 && meta.Orientation > 1
+	width, height := meta.Size.Width, meta.Size.Height
+	if autorotate && meta.Orientation >= 5 {
+		width, height = height, width
+	}
+	angle := min(opts.Rotate-opts.Rotate%90, bimg.D270)
+	transposed := angle == bimg.D90 || angle == bimg.D270
+	if transposed {
+		width, height = height, width
+	}
+
+	outWidth, outHeight, ok := premultipliedSize(opts, width, height)
+	if !ok {
+		return buf, opts, nil
+	}
+	if transposed {
+		width, height, outWidth, outHeight = height, width, outHeight, outWidth
+	}
+
+	resized, err := resizeBuffer(buf, scaleOptions{
+		HScale:      float64(outWidth) / float64(width),
+		VScale:      float64(outHeight) / float64(height),
+		Kernel:      kernel,
+		Premultiply: premultiply,
+		AutoRotate:  autorotate,
+	})
+	if err != nil {
+		return nil, opts, err
+	}
+	if opts.Type == bimg.UNKNOWN {
+		opts.Type = bimg.DetermineImageType(buf)
+	}
+	return resized, opts, nil
+}
+
+// premultipliedSize returns the size bimg resizes the image to with the
+// given options, as its imageCalculations does, and whether it is a
+// downscale, the only resize done by resizedInput.
+func premultipliedSize(opts bimg.Options, width, height int) (int, int, bool) {
+	force := opts.Force || !opts.Crop && !opts.Embed && !opts.Enlarge
+	xfactor := float64(width) / float64(opts.Width)
+	yfactor := float64(height) / float64(opts.Height)
+
+	var factor float64
+	switch {
+	case opts.Width > 0 && opts.Height > 0 && force:
+		if opts.Width > width || opts.Height > height || opts.Width == width && opts.Height == height {
+			return 0, 0, false
+		}
+		return opts.Width, opts.Height, true
+	case opts.Width > 0 && opts.Height > 0 && opts.Crop:
+		factor = math.Min(xfactor, yfactor)
+	case opts.Width > 0 && opts.Height > 0:
+		factor = math.Max(xfactor, yfactor)
+	case opts.Width > 0 && !opts.Crop:
+		factor = xfactor
+	case opts.Height > 0 && !opts.Crop:
+		factor = yfactor
+	default:
+		return 0, 0, false
+	}
+
+	if factor <= 1 {
+		return 0, 0, false
+	}
+	return max(int(math.Round(float64(width)/factor)), 1), max(int(math.Round(float64(height)/factor)), 1), true
+}
@@ -0,0 +1,143 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestPremultipliedSize(t *testing.T) {
+	cases := []struct {
+		opts          bimg.Options
+		width, height int
+		ok            bool
+	}{
+		{bimg.Options{Width: 100}, 100, 50, true},
+		{bimg.Options{Height: 50, Embed: true}, 100, 50, true},
+		{bimg.Options{Width: 100, Height: 100}, 100, 100, true},
+		{bimg.Options{Width: 100, Height: 100, Embed: true}, 100, 50, true},
+		{bimg.Options{Width: 100, Height: 100, Crop: true}, 200, 100, true},
+		{bimg.Options{Width: 100, Crop: true}, 0, 0, false},
+		{bimg.Options{Width: 400, Height: 200, Crop: true}, 0, 0, false},
+		{bimg.Options{Width: 500}, 0, 0, false},
+	}
+
+	for _, c := range cases {
+		width, height, ok := premultipliedSize(c.opts, 400, 200)
+		if width != c.width || height != c.height || ok != c.ok {
+			t.Errorf("Invalid size with %+v: %dx%d, %t", c.opts, width, height, ok)
+		}
+	}
+}
+
+func TestProcessPremultipliedAlpha(t *testing.T) {
+	t.Cleanup(func() { premultiplyAlpha = false })
+	premultiplyAlpha = true
+
+	// White square on a transparent black background
+	src := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for y := 25; y < 75; y++ {
+		for x := 25; x < 75; x++ {
+			src.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(buf.Bytes(), bimg.Options{Width: 33})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if err := assertSize(img.Body, 33, 33); err != nil {
+		t.Fatal(err)
+	}
+	if img.Mime != "image/png" {
+		t.Error(InvalidMimeType)
+	}
+
+	pixels, _, err := decodeImagePixels(img.Body)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	for i := 0; i < len(pixels.Pix); i += 4 {
+		// Edge pixels must stay white, only getting more transparent
+		if pixels.Pix[i+3] > 16 && pixels.Pix[i] < 224 {
+			t.Fatalf("Dark fringe pixel %d: %v", i/4, pixels.Pix[i:i+4])
+		}
+	}
+}
+
+func TestProcessKernel(t *testing.T) {
+	// Black and white checkerboard of 4x4 squares, downscaled 4 times
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if (x/4+y/4)%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	blended := func(kernel string) int {
+		img, err := processWithKernel(buf.Bytes(), bimg.Options{Width: 16}, kernel)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if err := assertSize(img.Body, 16, 16); err != nil {
+			t.Fatal(err)
+		}
+		pixels, _, err := decodeImagePixels(img.Body)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		n := 0
+		for i := 0; i < len(pixels.Pix); i += 4 {
+			if pixels.Pix[i] != 0 && pixels.Pix[i] != 255 {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := blended("nearest"); n != 0 {
+		t.Errorf("Expected the nearest kernel to keep hard edges: %d blended pixels", n)
+	}
+	if n := blended("lanczos3"); n == 0 {
+		t.Error("Expected the lanczos3 kernel to blend the edges")
+	}
+
+	_, err := processWithKernel(buf.Bytes(), bimg.Options{Width: 16, Trim: true}, "nearest")
+	if err != ErrKernelTransform {
+		t.Errorf("Expected the kernel not to be combined with trim, got %v", err)
+	}
+}
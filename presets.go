@@ -0,0 +1,142 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// PresetParam is the query param selecting a preset of the -presets-file.
+const PresetParam = "preset"
+
+// PresetTTLParam is the preset param setting the Cache-Control TTL, in
+// seconds, of the responses using the preset.
+const PresetTTLParam = "ttl"
+
+// presets holds the params of the named presets of the -presets-file flag,
+// presetTTLs their TTLs and presetsOnly the -presets-only flag.
+var (
+	presets     map[string]url.Values
+	presetTTLs  map[string]int
+	presetsOnly bool
+)
+
+// ErrUnknownPreset is returned for preset params not defined in the
+// -presets-file.
+var ErrUnknownPreset = NewError("Invalid param: unknown preset", http.StatusBadRequest)
+
+// LoadPresets loads the named presets of the -presets-file flag.
+func LoadPresets(o ServerOptions) {
+	presets, presetTTLs, presetsOnly = nil, nil, o.PresetsOnly
+	if o.PresetsFile == "" {
+		return
+	}
+
+	var err error
+	if presets, presetTTLs, err = readPresets(o.PresetsFile); err != nil {
+		exitWithError(newRuntimeError("cannot load the presets file: %w", err))
+	}
+}
+
+// readPresets reads a presets file, a JSON object mapping the preset names to
+// their params, given as a query string, e.g.
+// {"thumb": "width=200&height=200&type=webp&quality=80&ttl=31556926"}.
+// The ttl param isn't an image param, it is returned apart.
+func readPresets(path string) (map[string]url.Values, map[string]int, error) {
+	buf, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, nil, err
+	}
+	var queries map[string]string
+	if err := json.Unmarshal(buf, &queries); err != nil {
+		return nil, nil, err
+	}
+
+	parsed := make(map[string]url.Values, len(queries))
+	ttls := make(map[string]int)
+	for _, name := range slices.Sorted(maps.Keys(queries)) {
+		params, err := url.ParseQuery(queries[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		if params.Has(PresetTTLParam) {
+			ttl, err := strconv.Atoi(params.Get(PresetTTLParam))
+			if err != nil || ttl < 0 || ttl > MaxHTTPCacheTTL {
+				return nil, nil, fmt.Errorf("preset %q: invalid ttl, only a value from 0 to %d is accepted",
+					name, MaxHTTPCacheTTL)
+			}
+			ttls[name] = ttl
+			params.Del(PresetTTLParam)
+		}
+		for key := range params {
+			if _, ok := paramTypeCoercions[key]; !ok {
+				return nil, nil, fmt.Errorf("preset %q: unknown param %q", name, key)
+			}
+		}
+		if _, err := buildParamsFromQuery(params); err != nil {
+			return nil, nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		parsed[name] = params
+	}
+	return parsed, ttls, nil
+}
+
+// presetCacheTTL returns the TTL of the given preset, if it defines one.
+func presetCacheTTL(name string) (int, bool) {
+	ttl, ok := presetTTLs[name]
+	return ttl, ok
+}
+
+// applyPreset returns the query params with the ones of the requested preset
+// applied first, so the request params override them. With -presets-only,
+// requests cannot set image params besides the preset one.
+func applyPreset(query url.Values) (url.Values, error) {
+	if presetsOnly {
+		for key := range query {
+			if _, ok := paramTypeCoercions[key]; ok {
+				return nil, NewError("Invalid param: "+key+" is not allowed, use a preset", http.StatusBadRequest)
+			}
+		}
+	}
+
+	name := query.Get(PresetParam)
+	if name == "" {
+		return query, nil
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return nil, ErrUnknownPreset
+	}
+
+	params := make(url.Values, len(preset)+len(query))
+	maps.Copy(params, preset)
+	for key, values := range query {
+		if key != PresetParam {
+			params[key] = values
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePresets(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "presets.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadPresets(t *testing.T) {
+	path := writePresets(t, `{"thumb": "width=200&height=200&type=webp&quality=80&ttl=3600", "hero": "width=1600"}`)
+	presets, ttls, err := readPresets(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if presets["thumb"].Get("width") != "200" || presets["thumb"].Get("type") != "webp" {
+		t.Errorf("Invalid presets: %v", presets)
+	}
+	if presets["thumb"].Has(PresetTTLParam) || len(ttls) != 1 || ttls["thumb"] != 3600 {
+		t.Errorf("Invalid preset TTLs: %v, %v", presets, ttls)
+	}
+
+	invalid := []string{
+		`["thumb"]`,
+		`{"thumb": "width=abc"}`,
+		`{"thumb": "size=200"}`,
+		`{"thumb": "width=%zz"}`,
+		`{"thumb": "width=200&ttl=abc"}`,
+		`{"thumb": "width=200&ttl=-1"}`,
+	}
+	for _, content := range invalid {
+		if _, _, err := readPresets(writePresets(t, content)); err == nil {
+			t.Errorf("Expected an error with %s", content)
+		}
+	}
+	if _, _, err := readPresets("testdata/missing.json"); err == nil {
+		t.Error("Expected an error with a missing file")
+	}
+}
+
+func TestApplyPreset(t *testing.T) {
+	t.Cleanup(func() { presets, presetsOnly = nil, false })
+	presets = map[string]url.Values{"thumb": {"width": {"200"}, "height": {"200"}, "type": {"webp"}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/fit?preset=thumb&height=100", nil)
+	opts, _, err := processImageOptions(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Width != 200 || opts.Height != 100 || opts.Type != "webp" {
+		t.Errorf("Invalid options: %+v", opts)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/fit?preset=hero", nil)
+	if _, _, err := processImageOptions(req); err != ErrUnknownPreset {
+		t.Errorf("Expected an unknown preset error: %v", err)
+	}
+
+	presetsOnly = true
+	if _, err := applyPreset(url.Values{"preset": {"thumb"}, "url": {"http://localhost/a.jpg"}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := applyPreset(url.Values{"preset": {"thumb"}, "width": {"1000"}}); err == nil {
+		t.Error("Expected an error with an image param besides the preset")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+)
+
+const (
+	RAW = "raw"
+	NPY = "npy"
+
+	ContentTypeOctetStream = "application/octet-stream"
+	ContentTypeNPY         = "application/x-npy"
+
+	ChannelOrderRGB = "rgb"
+	ChannelOrderBGR = "bgr"
+)
+
+// npyMagic is the NumPy .npy format v1.0 preamble.
+var npyMagic = []byte("\x93NUMPY\x01\x00")
+
+// IsRawOutputType returns true if the given type alias is an uncompressed pixel output.
+func IsRawOutputType(name string) bool {
+	return name == RAW || name == NPY
+}
+
+// EncodeRaw converts the processed image into uncompressed 8-bit interleaved
+// pixels (HWC order), either as plain bytes or wrapped in a NumPy .npy container.
+// Dimensions and channels are exposed as response headers, preserving any
+// header already set by the operation.
+func EncodeRaw(img Image, format, channelOrder string) (Image, error) {
+	pixels, alpha, err := decodePixels(img.Body)
+	if err != nil {
+		return Image{}, NewError("Cannot decode image pixels: "+err.Error(), http.StatusBadRequest)
+	}
+
+	channels := 3
+	if alpha {
+		channels = 4
+	}
+
+	width, height := pixels.Rect.Dx(), pixels.Rect.Dy()
+	data := rawPixels(pixels, channels, channelOrder == ChannelOrderBGR)
+
+	header := img.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	out := Image{Body: data, Mime: ContentTypeOctetStream, Header: header}
+	if format == NPY {
+		out.Body = append(npyHeader(height, width, channels), data...)
+		out.Mime = ContentTypeNPY
+	}
+
+	out.Header.Set("Image-Width", strconv.Itoa(width))
+	out.Header.Set("Image-Height", strconv.Itoa(height))
+	out.Header.Set("Image-Channels", strconv.Itoa(channels))
+	out.Header.Set("Image-Channel-Order", channelOrderName(channelOrder, alpha))
+	return out, nil
+}
+
+// rawPixels serializes the canvas row by row, dropping alpha for 3 channels
+// and swapping red and blue when bgr is true.
+func rawPixels(img *image.NRGBA, channels int, bgr bool) []byte {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	if channels == 4 && !bgr && img.Stride == width*4 {
+		return img.Pix[:width*height*4]
+	}
+
+	buf := make([]byte, 0, width*height*channels)
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+width*4]
+		for x := 0; x < len(row); x += 4 {
+			start := len(buf)
+			buf = append(buf, row[x:x+channels]...)
+			if bgr {
+				buf[start], buf[start+2] = buf[start+2], buf[start]
+			}
+		}
+	}
+	return buf
+}
+
+// channelOrderName returns the channel layout exposed in the response headers.
+func channelOrderName(channelOrder string, alpha bool) string {
+	name := "RGB"
+	if channelOrder == ChannelOrderBGR {
+		name = "BGR"
+	}
+	if alpha {
+		name += "A"
+	}
+	return name
+}
+
+// npyHeader builds the .npy v1.0 header describing an uint8 array of the
+// given shape. The header is space padded so data starts 64-byte aligned.
+func npyHeader(shape ...int) []byte {
+	dims := ""
+	for _, dim := range shape {
+		dims += strconv.Itoa(dim) + ", "
+	}
+	dict := fmt.Sprintf("{'descr': '|u1', 'fortran_order': False, 'shape': (%s), }", dims[:len(dims)-2])
+
+	preamble := len(npyMagic) + 2
+	total := preamble + len(dict) + 1
+	padding := (64 - total%64) % 64
+
+	var buf bytes.Buffer
+	buf.Write(npyMagic)
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(len(dict)+padding+1))
+	buf.WriteString(dict)
+	buf.Write(bytes.Repeat([]byte(" "), padding))
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
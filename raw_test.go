@@ -0,0 +1,86 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestRawPixels(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 1, G: 2, B: 3, A: 4})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 5, G: 6, B: 7, A: 8})
+
+	if rgb := rawPixels(img, 3, false); !bytes.Equal(rgb, []byte{1, 2, 3, 5, 6, 7}) {
+		t.Errorf("invalid RGB pixels: %v", rgb)
+	}
+	if rgba := rawPixels(img, 4, false); !bytes.Equal(rgba, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("invalid RGBA pixels: %v", rgba)
+	}
+	if bgr := rawPixels(img, 3, true); !bytes.Equal(bgr, []byte{3, 2, 1, 7, 6, 5}) {
+		t.Errorf("invalid BGR pixels: %v", bgr)
+	}
+	if bgra := rawPixels(img, 4, true); !bytes.Equal(bgra, []byte{3, 2, 1, 4, 7, 6, 5, 8}) {
+		t.Errorf("invalid BGRA pixels: %v", bgra)
+	}
+}
+
+func TestNPYHeader(t *testing.T) {
+	header := npyHeader(740, 550, 3)
+
+	if !bytes.HasPrefix(header, npyMagic) {
+		t.Fatal("missing NumPy magic string")
+	}
+	if len(header)%64 != 0 {
+		t.Errorf("header is not 64-byte aligned: %d", len(header))
+	}
+	if header[len(header)-1] != '\n' {
+		t.Error("header must end with a newline")
+	}
+
+	size := binary.LittleEndian.Uint16(header[len(npyMagic):])
+	if int(size) != len(header)-len(npyMagic)-2 {
+		t.Errorf("invalid header length: %d", size)
+	}
+	if !strings.Contains(string(header), "'shape': (740, 550, 3)") {
+		t.Errorf("invalid header shape: %s", header)
+	}
+}
+
+func TestGetImageExtension(t *testing.T) {
+	cases := map[string]string{
+		ImageJPEG:              JPEG,
+		ImageWebP:              WebP,
+		ContentTypeJSON:        "json",
+		ContentTypeOctetStream: RAW,
+		ContentTypeNPY:         NPY,
+		ContentTypeICO:         ICO,
+	}
+
+	for mime, expected := range cases {
+		if ext := GetImageExtension(mime); ext != expected {
+			t.Errorf("invalid extension for %s: %s != %s", mime, ext, expected)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"strconv"
+	"strings"
+)
+
+// Region represents a rectangular image area, in pixels.
+type Region struct {
+	Left   int
+	Top    int
+	Width  int
+	Height int
+}
+
+// Rect returns the region as an image rectangle.
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.Left, r.Top, r.Left+r.Width, r.Top+r.Height)
+}
+
+// parseRegions parses a semicolon separated list of regions, each one defined
+// as comma separated left, top, width and height values, e.g. "10,20,100,50;200,40,60,60".
+func parseRegions(val string) ([]Region, error) {
+	var regions []Region
+
+	for _, chunk := range strings.Split(val, ";") {
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+
+		values := strings.Split(chunk, ",")
+		if len(values) != 4 {
+			return nil, ErrUnsupportedValue
+		}
+
+		var coords [4]int
+		for i, v := range values {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || n < 0 {
+				return nil, ErrUnsupportedValue
+			}
+			coords[i] = n
+		}
+
+		regions = append(regions, Region{Left: coords[0], Top: coords[1], Width: coords[2], Height: coords[3]})
+	}
+
+	return regions, nil
+}
+
+// imageRegions returns the image areas an operation applies to, clipped to the
+// image bounds: either the regions list, the area defined by top, left,
+// areawidth and areaheight params, or the whole image.
+func imageRegions(o ImageOptions, bounds image.Rectangle) []image.Rectangle {
+	var rects []image.Rectangle
+
+	switch {
+	case len(o.Regions) > 0:
+		for _, region := range o.Regions {
+			rects = append(rects, region.Rect())
+		}
+	case o.AreaWidth > 0 && o.AreaHeight > 0:
+		rects = append(rects, Region{Left: o.Left, Top: o.Top, Width: o.AreaWidth, Height: o.AreaHeight}.Rect())
+	default:
+		return []image.Rectangle{bounds}
+	}
+
+	clipped := rects[:0]
+	for _, rect := range rects {
+		if rect = rect.Intersect(bounds); !rect.Empty() {
+			clipped = append(clipped, rect)
+		}
+	}
+	return clipped
+}
@@ -0,0 +1,65 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestParseRegions(t *testing.T) {
+	regions, err := parseRegions("10,20,100,50; 200,40,60,60;")
+	if err != nil {
+		t.Fatalf("Cannot parse regions: %s", err)
+	}
+	if len(regions) != 2 {
+		t.Fatalf("Invalid regions length: %d", len(regions))
+	}
+	if regions[0] != (Region{Left: 10, Top: 20, Width: 100, Height: 50}) {
+		t.Errorf("Invalid region: %+v", regions[0])
+	}
+	if regions[1].Rect() != image.Rect(200, 40, 260, 100) {
+		t.Errorf("Invalid region rectangle: %v", regions[1].Rect())
+	}
+
+	for _, val := range []string{"10,20,100", "10,20,a,50", "10,-20,100,50"} {
+		if _, err := parseRegions(val); err == nil {
+			t.Errorf("Expected malformed regions %q to result in an error", val)
+		}
+	}
+}
+
+func TestImageRegions(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+
+	if rects := imageRegions(ImageOptions{}, bounds); len(rects) != 1 || rects[0] != bounds {
+		t.Errorf("Expected the whole image by default: %v", rects)
+	}
+
+	rects := imageRegions(ImageOptions{Top: 10, Left: 20, AreaWidth: 30, AreaHeight: 40}, bounds)
+	if len(rects) != 1 || rects[0] != image.Rect(20, 10, 50, 50) {
+		t.Errorf("Invalid area region: %v", rects)
+	}
+
+	o := ImageOptions{Regions: []Region{{80, 80, 50, 50}, {200, 200, 10, 10}}}
+	rects = imageRegions(o, bounds)
+	if len(rects) != 1 || rects[0] != image.Rect(80, 80, 100, 100) {
+		t.Errorf("Expected regions clipped to the image bounds: %v", rects)
+	}
+}
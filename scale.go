@@ -0,0 +1,93 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxScale is the maximum factor of the relative sizes, i.e. 1000%.
+const MaxScale = 10
+
+// hasRelativeSize reports whether the width and height are relative to the
+// source image size, given as percentages or by the scale param.
+func hasRelativeSize(opts ImageOptions) bool {
+	return opts.WidthPercent != 0 || opts.HeightPercent != 0 || opts.Scale != 0
+}
+
+// resolveRelativeSize converts the percentages of the width and height
+// params, or the scale param, to pixels of the source image, as auto rotated,
+// multiplied by the device pixel ratio, if any.
+func resolveRelativeSize(buf []byte, opts ImageOptions) (ImageOptions, error) {
+	if buf == nil {
+		return opts, NewError("Invalid params: relative sizes require a source image", http.StatusBadRequest)
+	}
+	if opts.Scale != 0 && (opts.Width != 0 || opts.Height != 0 || opts.WidthPercent != 0 || opts.HeightPercent != 0) {
+		return opts, NewError("Invalid params: scale cannot be combined with width and height", http.StatusBadRequest)
+	}
+	for _, factor := range []float64{opts.WidthPercent / 100, opts.HeightPercent / 100, opts.Scale} {
+		if factor < 0 || factor > MaxScale {
+			return opts, NewError(fmt.Sprintf("Invalid params: relative sizes must be between 0%% and %d%%", MaxScale*100),
+				http.StatusBadRequest)
+		}
+	}
+
+	metadata, err := bimg.Metadata(buf)
+	if err != nil {
+		return opts, NewError("Cannot retrieve image metadata: "+err.Error(), http.StatusBadRequest)
+	}
+	width, height := metadata.Size.Width, metadata.Size.Height
+	if !opts.NoRotation && metadata.Orientation > 4 {
+		// width/height will be switched with auto rotation
+		width, height = height, width
+	}
+
+	dpr := 1.0
+	if opts.DPR != 0 {
+		dpr = opts.DPR
+	}
+	widthFactor, heightFactor := opts.WidthPercent/100, opts.HeightPercent/100
+	if opts.Scale != 0 {
+		widthFactor, heightFactor = opts.Scale, opts.Scale
+	}
+	if widthFactor != 0 {
+		opts.Width = max(int(math.Round(float64(width)*widthFactor*dpr)), 1)
+	}
+	if heightFactor != 0 {
+		opts.Height = max(int(math.Round(float64(height)*heightFactor*dpr)), 1)
+	}
+	opts.WidthPercent, opts.HeightPercent, opts.Scale = 0, 0, 0
+	return opts, nil
+}
+
+// parsePercent parses a percentage, such as "50%".
+func parsePercent(val string) (float64, bool) {
+	number, ok := strings.CutSuffix(val, "%")
+	if !ok {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	return percent, err == nil
+}
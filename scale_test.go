@@ -0,0 +1,117 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestParsePercent(t *testing.T) {
+	cases := []struct {
+		value   string
+		percent float64
+		ok      bool
+	}{
+		{"50%", 50, true},
+		{"12.5%", 12.5, true},
+		{" 25 %", 25, true},
+		{"50", 0, false},
+		{"abc%", 0, false},
+	}
+
+	for _, c := range cases {
+		percent, ok := parsePercent(c.value)
+		if ok != c.ok || percent != c.percent {
+			t.Errorf("Invalid percent of %q: %f, %t", c.value, percent, ok)
+		}
+	}
+}
+
+func TestProcessImageOptionsPercent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/resize?width=50%25&height=200", nil)
+	opts, _, err := processImageOptions(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.WidthPercent != 50 || opts.Width != 0 || opts.Height != 200 {
+		t.Errorf("Invalid options: %+v", opts)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/resize?height=x%25", nil)
+	if _, _, err := processImageOptions(req); err == nil {
+		t.Error("Expected an error with an invalid percentage")
+	}
+}
+
+func TestResolveRelativeSize(t *testing.T) {
+	buf, _ := os.ReadFile("testdata/imaginary.jpg")
+
+	cases := []struct {
+		opts          ImageOptions
+		width, height int
+	}{
+		{ImageOptions{WidthPercent: 50}, 275, 0},
+		{ImageOptions{WidthPercent: 50, HeightPercent: 25}, 275, 185},
+		{ImageOptions{WidthPercent: 10, Height: 100}, 55, 100},
+		{ImageOptions{Scale: 0.5}, 275, 370},
+		{ImageOptions{Scale: 2, DPR: 2}, 2200, 2960},
+		{ImageOptions{WidthPercent: 0.01}, 1, 0},
+	}
+
+	for _, c := range cases {
+		opts, err := resolveRelativeSize(buf, c.opts)
+		if err != nil {
+			t.Fatalf("Unexpected error with %+v: %v", c.opts, err)
+		}
+		if opts.Width != c.width || opts.Height != c.height || hasRelativeSize(opts) {
+			t.Errorf("Invalid size with %+v: %dx%d", c.opts, opts.Width, opts.Height)
+		}
+	}
+
+	invalid := []ImageOptions{
+		{Scale: 0.5, Width: 100},
+		{Scale: 0.5, WidthPercent: 50},
+		{WidthPercent: -10},
+		{Scale: MaxScale + 1},
+	}
+	for _, opts := range invalid {
+		if _, err := resolveRelativeSize(buf, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+
+	if _, err := resolveRelativeSize(nil, ImageOptions{Scale: 0.5}); err == nil {
+		t.Error("Expected an error without source image")
+	}
+}
+
+func TestRelativeResize(t *testing.T) {
+	buf, _ := os.ReadFile("testdata/imaginary.jpg")
+	opts := ImageOptions{WidthPercent: 50, HeightPercent: 50, Force: true}
+	img, err := applyOperation(Resize, buf, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := assertSize(img.Body, 275, 370); err != nil {
+		t.Error(err)
+	}
+}
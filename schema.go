@@ -0,0 +1,86 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// The schema versions of the JSON replies of the info and health endpoints.
+// Fields may be added within a version, but are never renamed, removed or
+// changed of type: such changes bump the version.
+const (
+	InfoSchemaVersion   = 1
+	HealthSchemaVersion = 1
+)
+
+// canonicalJSON encodes the reply with its keys sorted at every level, so
+// bodies are byte for byte stable, keeping only the given top-level fields,
+// along with the schema version, if any.
+func canonicalJSON(v any, fields []string) ([]byte, error) {
+	allowed := schemaFields(v)
+	for _, field := range fields {
+		if !slices.Contains(allowed, field) {
+			return nil, NewError(fmt.Sprintf("Unsupported fields value %q. Allowed values are: %s",
+				field, strings.Join(allowed, ", ")), http.StatusBadRequest)
+		}
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Keep the numbers as is, e.g. large counters
+	decoder.UseNumber()
+	var reply map[string]any
+	if err := decoder.Decode(&reply); err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		for key := range reply {
+			if key != "schema" && !slices.Contains(fields, key) {
+				delete(reply, key)
+			}
+		}
+	}
+	return json.Marshal(reply)
+}
+
+// schemaFields returns the sorted JSON names of the fields of the reply.
+func schemaFields(v any) []string {
+	t := reflect.Indirect(reflect.ValueOf(v)).Type()
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
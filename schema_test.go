@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// The fields of the replies are part of their schema version: update the
+// version, not these lists, when renaming or removing a field.
+var (
+	infoSchemaFields = []string{
+		"analysis", "channels", "hasAlpha", "hasProfile", "height", "metadata", "orientation", "schema", "space",
+		"type", "width",
+	}
+	healthSchemaFields = []string{
+		"OSMemoryObtained", "allocatedMemory", "completedGCCycles", "cpus", "goroutines", "heapInUse",
+		"maxHeapUsage", "objectsInUse", "schema", "totalAllocatedMemory", "uptime",
+	}
+)
+
+func TestSchemaFields(t *testing.T) {
+	if fields := schemaFields(ImageInfo{}); !slices.Equal(fields, infoSchemaFields) {
+		t.Errorf("Info fields changed without bumping InfoSchemaVersion: %v", fields)
+	}
+	if fields := schemaFields(&HealthStats{}); !slices.Equal(fields, healthSchemaFields) {
+		t.Errorf("Health fields changed without bumping HealthSchemaVersion: %v", fields)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	info := ImageInfo{Schema: InfoSchemaVersion, Width: 300, Height: 200, Type: "jpeg"}
+
+	body, err := canonicalJSON(info, nil)
+	if err != nil {
+		t.Fatalf("Cannot encode reply: %s", err)
+	}
+	expected := `{"channels":0,"hasAlpha":false,"hasProfile":false,"height":200,"orientation":0,"schema":1,` +
+		`"space":"","type":"jpeg","width":300}`
+	if string(body) != expected {
+		t.Errorf("Invalid canonical body: %s", body)
+	}
+
+	body, err = canonicalJSON(info, []string{"width", "height", "analysis"})
+	if err != nil {
+		t.Fatalf("Cannot encode reply: %s", err)
+	}
+	if string(body) != `{"height":200,"schema":1,"width":300}` {
+		t.Errorf("Invalid selected fields: %s", body)
+	}
+
+	if _, err := canonicalJSON(info, []string{"size"}); err == nil || err.(Error).Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown fields to be rejected, got %v", err)
+	}
+}
+
+func TestCanonicalJSONLargeNumbers(t *testing.T) {
+	health := &HealthStats{Schema: HealthSchemaVersion, ObjectsInUse: 1<<63 + 1}
+
+	body, err := canonicalJSON(health, []string{"objectsInUse"})
+	if err != nil {
+		t.Fatalf("Cannot encode reply: %s", err)
+	}
+	if string(body) != `{"objectsInUse":9223372036854775809,"schema":1}` {
+		t.Errorf("Invalid large number: %s", body)
+	}
+}
+
+func TestHealthControllerFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthController(ServerOptions{})(w, httptest.NewRequest(http.MethodGet, "/health?fields=uptime,cpus", nil))
+
+	var reply map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("Cannot decode reply: %s", err)
+	}
+	if len(reply) != 3 || reply["schema"] != float64(HealthSchemaVersion) {
+		t.Errorf("Invalid health reply: %v", reply)
+	}
+
+	w = httptest.NewRecorder()
+	healthController(ServerOptions{})(w, httptest.NewRequest(http.MethodGet, "/health?fields=load", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown fields to reply 400: %d", w.Code)
+	}
+}
+
+func TestInfoFields(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Info(buf, ImageOptions{Fields: []string{"width", "height"}})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if string(img.Body) != `{"height":740,"schema":1,"width":550}` {
+		t.Errorf("Invalid info reply: %s", img.Body)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -39,8 +40,13 @@ import (
 	"github.com/quic-go/quic-go/http3"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "github.com/sycured/imaginary/docs"
+	"golang.org/x/net/netutil"
 )
 
+// DefaultMaxAllowedPixels is the default -max-allowed-resolution, in
+// megapixels.
+const DefaultMaxAllowedPixels = 18.0
+
 type ServerOptions struct {
 	Port               int
 	QUICPort           int
@@ -48,10 +54,33 @@ type ServerOptions struct {
 	Burst              int
 	Concurrency        int
 	HTTPCacheTTL       int
+	EndpointCacheTTL   map[string]int
 	HTTPReadTimeout    int
 	HTTPWriteTimeout   int
+	HTTPHeaderTimeout  int
+	HTTPIdleTimeout    int
+	HTTPBatchTimeout   int
+	MaxConnections     int
+	MinReadRate        int
+	ProcessingTimeout  int
+	OverloadRequests   int
+	OverloadLatency    int
+	JobsWorkers        int
+	JobsQueue          int
+	JobsTTL            int
+	JobsResultsSize    int
 	MaxAllowedSize     int
+	MaxBodySize        int
+	OriginConcurrency  int
+	OriginRate         int
+	OriginQueueTimeout int
 	MaxAllowedPixels   float64
+	PixelCacheSize     int
+	LowMemoryCooldown  int
+	StaleIfError       int
+	StaleCacheSize     int
+	TenantDailyQuota   int
+	TrustedKeys        map[string]float64
 	CORS               bool
 	Gzip               bool // deprecated
 	AuthForwarding     bool
@@ -59,11 +88,29 @@ type ServerOptions struct {
 	AllowInsecureSSL   bool
 	EnablePlaceholder  bool
 	EnableURLSignature bool
+	EnableEarlyHints   bool
+	FaceDetection      bool
+	RedactGPS          bool
+	FallbackFormat     string
+	AutoQualitySSIM    float64
+	CMYKProfile        string
+	PremultiplyAlpha   bool
+	PresetsFile        string
+	PresetsOnly        bool
+	FontsDir           string
+	FontFallback       []string
+	DefaultFont        string
+	AllowedFonts       []string
 	URLSignatureKey    string
+	ResponseHMACKey    string
+	ResponseEd25519Key string
 	Address            string
 	PathPrefix         string
 	APIKey             string
 	Mount              string
+	TmpDir             string
+	TmpDirQuota        int
+	Mounts             map[string]Mount
 	CertFile           string
 	KeyFile            string
 	Authorization      string
@@ -71,9 +118,20 @@ type ServerOptions struct {
 	PlaceholderStatus  int
 	ForwardHeaders     []string
 	SrcResponseHeaders []string
+	RequestIDHeader    string
+	SurrogateKeyHeader string
+	SurrogateKeys      []string
 	PlaceholderImage   []byte
 	Endpoints          Endpoints
 	AllowedOrigins     []*url.URL
+	CallbackOrigins    []*url.URL
+	PublicURL          string
+	StoreEndpoint      string
+	StoreRegion        string
+	StoreBuckets       []string
+	StoreAccessKey     string
+	StoreSecretKey     string
+	StoreSessionToken  string
 	LogLevel           string
 	ReturnSize         bool
 }
@@ -83,8 +141,7 @@ type Endpoints []string
 
 // IsValid validates if a given HTTP request endpoint is valid or not.
 func (e Endpoints) IsValid(r *http.Request) bool {
-	parts := strings.Split(r.URL.Path, "/")
-	endpoint := parts[len(parts)-1]
+	endpoint := endpointName(r.URL.Path)
 	for _, name := range e {
 		if endpoint == name {
 			return false
@@ -93,6 +150,13 @@ func (e Endpoints) IsValid(r *http.Request) bool {
 	return true
 }
 
+// endpointName returns the endpoint name of the request path, i.e. its last
+// segment.
+func endpointName(path string) string {
+	parts := strings.Split(path, "/")
+	return parts[len(parts)-1]
+}
+
 // setupTLSConfig creates and returns the TLS configuration if certificates are provided
 func setupTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 	if certFile == "" || keyFile == "" {
@@ -113,12 +177,14 @@ func setupTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 // createHTTPServer creates an HTTP/HTTPS server with the given handler and options
 func createHTTPServer(addr string, handler http.Handler, o ServerOptions, tlsConfig *tls.Config) *http.Server {
 	srv := &http.Server{
-		Addr:           addr,
-		Handler:        altSvcMiddleware(handler, o.QUICPort),
-		MaxHeaderBytes: 1 << 20,
-		ReadTimeout:    time.Duration(o.HTTPReadTimeout) * time.Second,
-		WriteTimeout:   time.Duration(o.HTTPWriteTimeout) * time.Second,
-		TLSConfig:      tlsConfig,
+		Addr:              addr,
+		Handler:           altSvcMiddleware(handler, o.QUICPort),
+		MaxHeaderBytes:    1 << 20,
+		ReadTimeout:       time.Duration(o.HTTPReadTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(o.HTTPHeaderTimeout) * time.Second,
+		WriteTimeout:      time.Duration(o.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(o.HTTPIdleTimeout) * time.Second,
+		TLSConfig:         tlsConfig,
 	}
 	if o.QUICPublicPort != 0 {
 		srv.Handler = altSvcMiddleware(handler, o.QUICPublicPort)
@@ -155,19 +221,27 @@ func createHTTP3Server(quicAddr string, handler http.Handler, tlsConfig *tls.Con
 }
 
 // startHTTPServer starts the HTTP/HTTPS server in a goroutine
-func startHTTPServer(server *http.Server, certFile, keyFile string) {
+func startHTTPServer(server *http.Server, certFile, keyFile string, maxConnections int) {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		exitWithError(newRuntimeError("HTTP(S) server error: %w", err))
+	}
+	if maxConnections > 0 {
+		ln = netutil.LimitListener(ln, maxConnections)
+	}
+
 	go func() {
 		var err error
 		if certFile != "" && keyFile != "" {
 			log.Printf("Starting HTTPS server on %s", server.Addr)
-			err = server.ListenAndServeTLS(certFile, keyFile)
+			err = server.ServeTLS(ln, certFile, keyFile)
 		} else {
 			log.Printf("Starting HTTP server on %s", server.Addr)
-			err = server.ListenAndServe()
+			err = server.Serve(ln)
 		}
 
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("HTTP(S) server error: %s\n", err)
+			exitWithError(newRuntimeError("HTTP(S) server error: %w", err))
 		}
 	}()
 }
@@ -181,7 +255,7 @@ func startHTTP3Server(server *http3.Server) {
 	go func() {
 		log.Printf("Starting HTTP/3 server on %s", server.Addr)
 		if err := server.ListenAndServe(); err != nil {
-			log.Fatalf("HTTP/3 server error: %s\n", err)
+			exitWithError(newRuntimeError("HTTP/3 server error: %w", err))
 		}
 	}()
 }
@@ -193,12 +267,15 @@ func Server(o ServerOptions) {
 
 	// Create the base handler
 	baseHandler := NewLog(NewServerMux(o), os.Stdout, o.LogLevel)
+	if o.RequestIDHeader != "" {
+		baseHandler = requestID(baseHandler, o.RequestIDHeader)
+	}
 	handler := baseHandler
 
 	// Setup TLS if certificates are provided
 	tlsConfig, err := setupTLSConfig(o.CertFile, o.KeyFile)
 	if err != nil {
-		log.Panic(err)
+		exitWithError(newConfigError("cannot load TLS certificate: %w", err))
 	}
 
 	// Create servers
@@ -206,9 +283,14 @@ func Server(o ServerOptions) {
 	httpServer := createHTTPServer(addr, handler, o, tlsConfig)
 
 	// Start servers
-	startHTTPServer(httpServer, o.CertFile, o.KeyFile)
+	startHTTPServer(httpServer, o.CertFile, o.KeyFile, o.MaxConnections)
 	startHTTP3Server(http3Server)
 
+	// Cycle the log level on SIGUSR1
+	logLevelSignal := make(chan os.Signal, 1)
+	signal.Notify(logLevelSignal, syscall.SIGUSR1)
+	go watchLogLevelSignal(logLevelSignal)
+
 	// Setup graceful shutdown
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -221,6 +303,7 @@ func Server(o ServerOptions) {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown failed: %+v", err)
 	}
+	staging.remove()
 
 	log.Print("Server shutdown completed")
 }
@@ -254,29 +337,66 @@ func NewServerMux(o ServerOptions) http.Handler {
 
 	mux.Handle(join(o, "/"), Middleware(indexController(o), o))
 	mux.Handle(join(o, "/form"), Middleware(formController(o), o))
-	mux.Handle(join(o, "/health"), Middleware(healthController, o))
+	mux.Handle(join(o, "/health"), Middleware(healthController(o), o))
+	mux.Handle(join(o, "/ready"), Middleware(readyController, o))
+	mux.Handle(join(o, "/fonts"), Middleware(fontsController, o))
+	mux.Handle(join(o, "/capabilities"), Middleware(capabilitiesController(o), o))
+	mux.Handle(join(o, "/log-level"), Middleware(logLevelController(o), o))
+	mux.Handle(join(o, "/jobs/{id}"), Middleware(jobController(o), o))
+	mux.Handle(join(o, "/jobs/{id}/result"), Middleware(jobResultController(o), o))
 	mux.Handle(join(o, "/metrics"), metricsHandler())
 	mux.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
 	image := ImageMiddleware(o)
+	mux.Handle(join(o, "/adjust"), image(Adjust))
 	mux.Handle(join(o, "/autorotate"), image(AutoRotate))
 	mux.Handle(join(o, "/blur"), image(GaussianBlur))
+	mux.Handle(join(o, "/border"), image(Border))
+	mux.Handle(join(o, "/circle"), image(Circle))
+	mux.Handle(join(o, "/composite"), image(Composite))
 	mux.Handle(join(o, "/convert"), image(Convert))
 	mux.Handle(join(o, "/crop"), image(Crop))
+	mux.Handle(join(o, "/duotone"), image(Duotone))
 	mux.Handle(join(o, "/enlarge"), image(Enlarge))
 	mux.Handle(join(o, "/extract"), image(Extract))
 	mux.Handle(join(o, "/fit"), image(Fit))
 	mux.Handle(join(o, "/flip"), image(Flip))
 	mux.Handle(join(o, "/flop"), image(Flop))
+	mux.Handle(join(o, "/gamma"), image(Gamma))
+	mux.Handle(join(o, "/grayscale"), image(Grayscale))
+	mux.Handle(join(o, "/histogram"), image(Histogram))
 	mux.Handle(join(o, "/info"), image(Info))
-	mux.Handle(join(o, "/pipeline"), image(Pipeline))
+	mux.Handle(join(o, "/invert"), image(Invert))
+	mux.Handle(join(o, "/modulate"), image(Modulate))
+	mux.Handle(join(o, "/pad"), image(Pad))
+	mux.Handle(join(o, "/phash"), image(Phash))
+	mux.Handle(join(o, "/pipeline"), batchWriteTimeout(image(Pipeline), o))
+	mux.Handle(join(o, "/pixelate"), image(Pixelate))
+	mux.Handle(join(o, "/polaroid"), image(Polaroid))
+	mux.Handle(join(o, "/preprocess"), image(Preprocess))
+	mux.Handle(join(o, "/redact"), image(Redact))
 	mux.Handle(join(o, "/resize"), image(Resize))
 	mux.Handle(join(o, "/rotate"), image(Rotate))
+	mux.Handle(join(o, "/rounded"), image(Rounded))
+	mux.Handle(join(o, "/sepia"), image(Sepia))
+	mux.Handle(join(o, "/sharpen"), image(Sharpen))
 	mux.Handle(join(o, "/smartcrop"), image(SmartCrop))
 	mux.Handle(join(o, "/thumbnail"), image(Thumbnail))
+	mux.Handle(join(o, "/topdf"), image(ToPDF))
+	mux.Handle(join(o, "/totiff"), image(ToTIFF))
+	mux.Handle(join(o, "/trim"), image(Trim))
+	mux.Handle(join(o, "/vignette"), image(Vignette))
+	mux.Handle(join(o, "/variants"), image(Variants))
 	mux.Handle(join(o, "/watermark"), image(Watermark))
 	mux.Handle(join(o, "/watermarkimage"), image(WatermarkImage))
 	mux.Handle(join(o, "/zoom"), image(Zoom))
 
-	return mux
+	mux.Handle(join(o, "/batch"), batchWriteTimeout(SignedMiddleware(batchController(o), o), o))
+	mux.Handle(join(o, "/generate"), SignedMiddleware(generatorController(o, Generate), o))
+	mux.Handle(join(o, "/avatar"), SignedMiddleware(generatorController(o, Avatar), o))
+	mux.Handle(join(o, "/collage"), SignedMiddleware(generatorController(o, Collage), o))
+	mux.Handle(join(o, "/storyboard"), SignedMiddleware(storyboardController(o), o))
+	mux.Handle(join(o, "/animate"), SignedMiddleware(animateController(o), o))
+
+	return limitBody(mux, o)
 }
@@ -20,15 +20,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/h2non/bimg"
 )
@@ -237,6 +244,28 @@ func TestExtract(t *testing.T) {
 	}
 }
 
+func TestExtractGravity(t *testing.T) {
+	ts := testServer(controller(Extract))
+	defer ts.Close()
+
+	for _, gravity := range []string{"centre", "southeast"} {
+		imageReader := readTestFile(LargeImageFileWithExt)
+		url := ts.URL + "?gravity=" + gravity + "&areawidth=200&areaheight=120"
+
+		status, _, body := sendRequest(t, http.MethodPost, url, ImageJPEG, imageReader)
+		checkResponse(t, status, 200, body, EmptyResponseBody)
+
+		assertImageSize(t, body, 200, 120)
+	}
+
+	imageReader := readTestFile(LargeImageFileWithExt)
+	url := ts.URL + "?gravity=centre&areawidth=200000&areaheight=120"
+	status, _, _ := sendRequest(t, http.MethodPost, url, ImageJPEG, imageReader)
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected areas larger than the image to be rejected: %d", status)
+	}
+}
+
 func TestTypeAuto(t *testing.T) {
 	cases := []struct {
 		acceptHeader string
@@ -462,6 +491,249 @@ func TestSrcResponseHeaderWithoutSrcCacheControl(t *testing.T) {
 	}
 }
 
+func TestTrustedKeys(t *testing.T) {
+	opts := ServerOptions{
+		APIKey:           "public",
+		MaxAllowedPixels: 18.0,
+		TrustedKeys:      map[string]float64{"print": 80},
+	}
+	handler := authorizeClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, withRequestLimits(r, opts).MaxAllowedPixels)
+	}), opts)
+
+	cases := []struct {
+		key    string
+		status int
+		limit  string
+	}{
+		{"public", http.StatusOK, "18"},
+		{"print", http.StatusOK, "80"},
+		{"unknown", http.StatusUnauthorized, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/?key="+c.key, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Fatalf("Invalid response status for key %s: %d", c.key, w.Code)
+		}
+		if c.limit != "" && w.Body.String() != c.limit {
+			t.Fatalf("Invalid resolution limit for key %s: %s", c.key, w.Body.String())
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFromContext(r.Context())
+	}), DefaultRequestIDHeader)
+
+	cases := []struct {
+		id   string
+		kept bool
+	}{
+		{"", false},
+		{"origin-42", true},
+		{"with spaces", false},
+		{strings.Repeat("a", MaxRequestIDLength+1), false},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(DefaultRequestIDHeader, c.id)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		echoed := w.Header().Get(DefaultRequestIDHeader)
+		if seen == "" || echoed != seen {
+			t.Fatalf("Invalid request ID for %q: %q != %q", c.id, echoed, seen)
+		}
+		if (seen == c.id) != c.kept {
+			t.Fatalf("Invalid request ID for %q: %q", c.id, seen)
+		}
+	}
+}
+
+func TestParseTrustedKeys(t *testing.T) {
+	keys, err := parseTrustedKeys("print:80, archive:40.5,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(keys) != 2 || keys["print"] != 80 || keys["archive"] != 40.5 {
+		t.Fatalf("Invalid trusted keys: %v", keys)
+	}
+
+	for _, input := range []string{"print", ":80", "print:", "print:abc", "print:-1"} {
+		if _, err := parseTrustedKeys(input); err == nil {
+			t.Fatalf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseEndpointCacheTTL(t *testing.T) {
+	ttls, err := parseEndpointCacheTTL("thumbnail:3600, /Info:0,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(ttls) != 2 || ttls["thumbnail"] != 3600 || ttls["info"] != 0 {
+		t.Fatalf("Invalid endpoint cache TTLs: %v", ttls)
+	}
+
+	for _, input := range []string{"thumbnail", ":80", "thumbnail:", "thumbnail:abc", "thumbnail:-1", "thumbnail:31556927"} {
+		if _, err := parseEndpointCacheTTL(input); err == nil {
+			t.Fatalf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestEndpointCacheTTL(t *testing.T) {
+	opts := ServerOptions{HTTPCacheTTL: -1, EndpointCacheTTL: map[string]int{"thumbnail": 3600, "info": 0}}
+	handler := setCacheHeaders(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), opts)
+
+	cases := map[string]string{
+		"/thumbnail": getCacheControl(3600),
+		"/info":      getCacheControl(0),
+		"/resize":    "",
+	}
+
+	for path, expected := range cases {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		if actual := res.Header().Get(CacheControl); actual != expected {
+			t.Errorf("Invalid cache-control header for %s: %q != %q", path, actual, expected)
+		}
+	}
+}
+
+func TestPresetCacheTTL(t *testing.T) {
+	t.Cleanup(func() { presetTTLs = nil })
+	presetTTLs = map[string]int{"thumb": 3600, "private": 0}
+
+	opts := ServerOptions{HTTPCacheTTL: 60, EndpointCacheTTL: map[string]int{"fit": 120}}
+	handler := setCacheHeaders(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), opts)
+
+	cases := map[string]string{
+		"/fit?preset=thumb":    getCacheControl(3600),
+		"/resize?preset=thumb": getCacheControl(3600),
+		"/fit?preset=private":  getCacheControl(0),
+		"/fit?preset=hero":     getCacheControl(120),
+		"/resize":              getCacheControl(60),
+	}
+
+	for path, expected := range cases {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		if actual := res.Header().Get(CacheControl); actual != expected {
+			t.Errorf("Invalid cache-control header for %s: %q != %q", path, actual, expected)
+		}
+	}
+}
+
+func TestEarlyHints(t *testing.T) {
+	opts := ServerOptions{EnableURLSignature: true, URLSignatureKey: "4f46feebafc4b5e988f131c4ff8b5997"}
+	ts := httptest.NewServer(earlyHints(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "image")
+	}), opts))
+	defer ts.Close()
+
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = header.Values("Link")
+			}
+			return nil
+		},
+	}
+
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace),
+		http.MethodGet, ts.URL+"/resize?url=http://a.com/b.jpg&width=100&preload=320,640", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Cannot perform the request: %s", err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf(InvalidResponseStatusD, res.StatusCode)
+	}
+	if len(hints) != 2 {
+		t.Fatalf("Invalid early hints: %v", hints)
+	}
+
+	query := url.Values{"url": {"http://a.com/b.jpg"}, "width": {"320"}}
+	sign := base64.RawURLEncoding.EncodeToString(urlSignature("/resize", query, opts.URLSignatureKey))
+	query.Set("sign", sign)
+	if expected := "</resize?" + query.Encode() + ">; rel=preload; as=image"; hints[0] != expected {
+		t.Errorf("Invalid early hint: %s, expected: %s", hints[0], expected)
+	}
+
+	if _, err := variantLinks(req.URL, "320,abc", opts); err == nil {
+		t.Error("Expected invalid preload widths to result in an error")
+	}
+}
+
+func TestBatchWriteTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = fmt.Fprint(w, "batch")
+	})
+
+	ts := httptest.NewUnstartedServer(batchWriteTimeout(slow, ServerOptions{HTTPBatchTimeout: 5}))
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	status, _, body := sendRequest(t, http.MethodGet, ts.URL, "", nil)
+	checkResponse(t, status, 200, body, "Invalid body response")
+	if string(body) != "batch" {
+		t.Fatalf("Invalid body response: %s", body)
+	}
+}
+
+func TestMinReadRate(t *testing.T) {
+	readErr := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = &rateLimitedBody{
+			ReadCloser: r.Body,
+			controller: http.NewResponseController(w),
+			rate:       1000,
+			grace:      100 * time.Millisecond,
+			start:      time.Now(),
+		}
+		_, err := io.ReadAll(r.Body)
+		readErr <- err
+	}))
+	defer ts.Close()
+
+	body, writer := io.Pipe()
+	go func() {
+		_, _ = writer.Write([]byte("a"))
+		time.Sleep(time.Second)
+		_ = writer.Close()
+	}()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, body)
+	req.ContentLength = -1
+	go func() {
+		if res, err := http.DefaultClient.Do(req); err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Fatal("Expected slow request body to be aborted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the request body to be aborted")
+	}
+}
+
 func controller(op Operation) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		buf, _ := io.ReadAll(r.Body)
@@ -488,3 +760,102 @@ func assertSize(buf []byte, width, height int) error {
 	}
 	return nil
 }
+
+func TestLogLevelController(t *testing.T) {
+	t.Cleanup(func() { _ = SetLogLevel("info") })
+
+	cases := []struct {
+		method string
+		apiKey string
+		query  string
+		status int
+		level  string
+	}{
+		{http.MethodGet, "", "", http.StatusOK, "info"},
+		{http.MethodPost, "", "?level=debug", http.StatusForbidden, "info"},
+		{http.MethodPost, "secret", "?key=secret&level=verbose", http.StatusBadRequest, "info"},
+		{http.MethodPost, "secret", "?key=wrong&level=debug", http.StatusUnauthorized, "info"},
+		{http.MethodPost, "secret", "?key=secret&level=debug", http.StatusOK, "debug"},
+	}
+
+	for _, c := range cases {
+		opts := ServerOptions{APIKey: c.apiKey}
+		req := httptest.NewRequest(c.method, "/log-level"+c.query, nil)
+		w := httptest.NewRecorder()
+		Middleware(logLevelController(opts), opts).ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Fatalf("Invalid response status for %s %s: %d", c.method, c.query, w.Code)
+		}
+		if CurrentLogLevel() != c.level {
+			t.Fatalf("Invalid log level after %s %s: %s", c.method, c.query, CurrentLogLevel())
+		}
+	}
+}
+
+func TestRunOperationWithin(t *testing.T) {
+	defer func(slots chan struct{}) { abandonedOperations = slots }(abandonedOperations)
+	abandonedOperations = make(chan struct{}, 2)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	slow := Operation(func(buf []byte, o ImageOptions) (Image, error) {
+		<-release
+		return Image{Body: buf}, nil
+	})
+	fast := Operation(func(buf []byte, o ImageOptions) (Image, error) {
+		return Image{Body: buf}, nil
+	})
+
+	if _, err := runOperationWithin(slow, []byte("foo"), ImageOptions{}, 10); err != ErrProcessingTimeout {
+		t.Fatalf("Expected processing timeout error, got %v", err)
+	}
+	image, err := runOperationWithin(fast, []byte("foo"), ImageOptions{}, 1000)
+	if err != nil || string(image.Body) != "foo" {
+		t.Fatalf("Unexpected result: %s, %v", image.Body, err)
+	}
+
+	w := httptest.NewRecorder()
+	ErrorReply(httptest.NewRequest(http.MethodGet, "/resize", nil), w, ErrProcessingTimeout, ServerOptions{})
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Invalid response status: %d", w.Code)
+	}
+}
+
+func TestRunOperationWithinAbandoned(t *testing.T) {
+	defer func(slots chan struct{}) { abandonedOperations = slots }(abandonedOperations)
+	abandonedOperations = make(chan struct{}, 2)
+
+	var running atomic.Int32
+	release := make(chan struct{})
+	slow := Operation(func(buf []byte, o ImageOptions) (Image, error) {
+		running.Add(1)
+		defer running.Add(-1)
+		<-release
+		return Image{Body: buf}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := runOperationWithin(slow, []byte("foo"), ImageOptions{}, 1); err != ErrProcessingTimeout {
+			t.Fatalf("Expected processing timeout error, got %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := runOperationWithin(slow, []byte("foo"), ImageOptions{}, 1); err != ErrProcessingBusy {
+			t.Errorf("Expected new operations to be rejected, got %v", err)
+		}
+	}
+	if n := running.Load(); n > 2 {
+		t.Errorf("Expected at most 2 timed out operations running at once, got %d", n)
+	}
+
+	close(release)
+	for i := 0; i < 100 && len(abandonedOperations) > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	fast := Operation(func(buf []byte, o ImageOptions) (Image, error) {
+		return Image{Body: buf}, nil
+	})
+	if _, err := runOperationWithin(fast, []byte("foo"), ImageOptions{}, 1000); err != nil {
+		t.Errorf("Expected the slots to be released once the operations completed, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const (
+	// SignatureHeader is the URL-safe Base64-encoded signature of the response.
+	SignatureHeader = "X-Signature"
+	// SignatureAlgorithmHeader is the algorithm of the signature, either
+	// hmac-sha256 or ed25519.
+	SignatureAlgorithmHeader = "X-Signature-Algorithm"
+	// SignatureInputHeader is the request path and params covered by the
+	// signature, along with the body.
+	SignatureInputHeader = "X-Signature-Input"
+)
+
+const (
+	SignatureHMAC    = "hmac-sha256"
+	SignatureEd25519 = "ed25519"
+)
+
+// responseSigner signs the image responses. It is nil, hence responses are
+// not signed, if neither -response-signature-key nor
+// -response-signature-ed25519-key is set.
+var responseSigner *signer
+
+// signer computes the detached signatures of the responses, so downstream
+// services can verify the images really come from imaginary before
+// persisting them.
+type signer struct {
+	algorithm  string
+	key        []byte
+	privateKey ed25519.PrivateKey
+}
+
+// LoadResponseSigning enables the response signatures when configured.
+func LoadResponseSigning(o ServerOptions) {
+	switch {
+	case o.ResponseHMACKey != "":
+		responseSigner = &signer{algorithm: SignatureHMAC, key: []byte(o.ResponseHMACKey)}
+	case o.ResponseEd25519Key != "":
+		privateKey, err := readEd25519Key(o.ResponseEd25519Key)
+		if err != nil {
+			exitWithError(newRuntimeError("cannot load the response signature key: %w", err))
+		}
+		responseSigner = &signer{algorithm: SignatureEd25519, privateKey: privateKey}
+	}
+}
+
+// readEd25519Key reads a PEM encoded PKCS #8 Ed25519 private key, as
+// generated by openssl genpkey -algorithm ed25519.
+func readEd25519Key(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %T private key, only Ed25519 is accepted", key)
+	}
+	return privateKey, nil
+}
+
+// signatureInput returns the request path and its params, but the URL
+// signature, as signed along with the body.
+func signatureInput(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("sign")
+	if len(query) == 0 {
+		return r.URL.EscapedPath()
+	}
+	return r.URL.EscapedPath() + "?" + query.Encode()
+}
+
+// signatureMessage returns the signed message: the hexadecimal SHA-256 digest
+// of the body and the signature input, separated by a line feed.
+func signatureMessage(body []byte, input string) []byte {
+	digest := sha256.Sum256(body)
+	return []byte(hex.EncodeToString(digest[:]) + "\n" + input)
+}
+
+func (s *signer) sign(message []byte) []byte {
+	if s.algorithm == SignatureEd25519 {
+		return ed25519.Sign(s.privateKey, message)
+	}
+	h := hmac.New(sha256.New, s.key)
+	_, _ = h.Write(message)
+	return h.Sum(nil)
+}
+
+// signImage adds the signature headers of the image returned to the request,
+// when enabled.
+func signImage(image Image, r *http.Request) Image {
+	if responseSigner == nil {
+		return image
+	}
+
+	header := image.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	responseSigner.setHeaders(header, image.Body, signatureInput(r))
+	image.Header = header
+	return image
+}
+
+// setHeaders sets the signature headers of the body returned to the given
+// signature input.
+func (s *signer) setHeaders(header http.Header, body []byte, input string) {
+	signature := s.sign(signatureMessage(body, input))
+	header.Set(SignatureHeader, base64.RawURLEncoding.EncodeToString(signature))
+	header.Set(SignatureAlgorithmHeader, s.algorithm)
+	header.Set(SignatureInputHeader, input)
+}
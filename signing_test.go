@@ -0,0 +1,142 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEd25519Key writes a new PEM encoded Ed25519 private key, returning its
+// path and public key.
+func writeEd25519Key(t *testing.T) (string, ed25519.PublicKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, publicKey
+}
+
+func TestSignatureInput(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/resize?width=300&type=webp&sign=abc", nil)
+	if input := signatureInput(req); input != "/resize?type=webp&width=300" {
+		t.Errorf("Invalid signature input: %s", input)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/info", nil)
+	if input := signatureInput(req); input != "/info" {
+		t.Errorf("Invalid signature input: %s", input)
+	}
+}
+
+func TestSignImageHMAC(t *testing.T) {
+	defer func() { responseSigner = nil }()
+	key := "4f46feebafc4b5e988f131c4ff8b5997"
+	LoadResponseSigning(ServerOptions{ResponseHMACKey: key})
+
+	req := httptest.NewRequest(http.MethodGet, "/resize?width=300", nil)
+	image := signImage(Image{Body: []byte("image"), Mime: ImagePNG}, req)
+
+	if alg := image.Header.Get(SignatureAlgorithmHeader); alg != SignatureHMAC {
+		t.Errorf("Invalid signature algorithm: %s", alg)
+	}
+	if input := image.Header.Get(SignatureInputHeader); input != "/resize?width=300" {
+		t.Errorf("Invalid signature input: %s", input)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(image.Header.Get(SignatureHeader))
+	if err != nil {
+		t.Fatalf("Cannot decode signature: %s", err)
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	_, _ = h.Write(signatureMessage([]byte("image"), "/resize?width=300"))
+	if !hmac.Equal(signature, h.Sum(nil)) {
+		t.Error("Invalid HMAC signature")
+	}
+}
+
+func TestSignImageEd25519(t *testing.T) {
+	defer func() { responseSigner = nil }()
+	path, publicKey := writeEd25519Key(t)
+	LoadResponseSigning(ServerOptions{ResponseEd25519Key: path})
+
+	req := httptest.NewRequest(http.MethodGet, "/resize?width=300", nil)
+	image := signImage(Image{Body: []byte("image"), Mime: ImagePNG}, req)
+
+	if alg := image.Header.Get(SignatureAlgorithmHeader); alg != SignatureEd25519 {
+		t.Errorf("Invalid signature algorithm: %s", alg)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(image.Header.Get(SignatureHeader))
+	if !ed25519.Verify(publicKey, signatureMessage([]byte("image"), "/resize?width=300"), signature) {
+		t.Error("Invalid Ed25519 signature")
+	}
+	if ed25519.Verify(publicKey, signatureMessage([]byte("tampered"), "/resize?width=300"), signature) {
+		t.Error("Expected a tampered body not to verify")
+	}
+}
+
+func TestSignImageDisabled(t *testing.T) {
+	image := signImage(Image{Body: []byte("image")}, httptest.NewRequest(http.MethodGet, "/resize", nil))
+	if image.Header.Get(SignatureHeader) != "" {
+		t.Error("Expected responses not to be signed when disabled")
+	}
+}
+
+func TestValidateResponseSignature(t *testing.T) {
+	path, _ := writeEd25519Key(t)
+	key := "4f46feebafc4b5e988f131c4ff8b5997"
+
+	cases := []struct {
+		opts  ServerOptions
+		valid bool
+	}{
+		{ServerOptions{}, true},
+		{ServerOptions{ResponseHMACKey: key}, true},
+		{ServerOptions{ResponseEd25519Key: path}, true},
+		{ServerOptions{ResponseHMACKey: "short"}, false},
+		{ServerOptions{ResponseEd25519Key: "testdata/server.key"}, false},
+		{ServerOptions{ResponseEd25519Key: "testdata/missing.pem"}, false},
+		{ServerOptions{ResponseHMACKey: key, ResponseEd25519Key: path}, false},
+	}
+
+	for _, c := range cases {
+		if err := validateResponseSignature(c.opts); (err == nil) != c.valid {
+			t.Errorf("Invalid response signature validation for %+v: %v", c.opts, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"slices"
+
+	"github.com/h2non/bimg"
+)
+
+// Phases of the libvips processing of a single bimg pass: the image is
+// resized, then watermarked, then encoded.
+const (
+	passResize = iota
+	passWatermark
+	passEncode
+)
+
+// outputParams are the params of the pipeline operations only affecting the
+// encoding of their output.
+var outputParams = []string{"type", "quality", "compression", "interlace", "stripmeta", "lossless", "speed", "palette"}
+
+// watermarkParams are the params of the text watermark operation.
+var watermarkParams = []string{"text", "font", "dpi", "margin", "textwidth", "opacity", "noreplicate", "color"}
+
+// singlePassStep is a pipeline operation running a single bimg pass, which
+// can be merged with the adjacent ones of later phases.
+type singlePassStep struct {
+	phase int
+	// params are the params the operation may take when merged into a
+	// previous one
+	params []string
+	// valid reports whether the operation would run with the options, so
+	// merged operations fail as the original ones
+	valid   func(ImageOptions) bool
+	options func(ImageOptions) (bimg.Options, error)
+}
+
+var singlePassSteps = map[string]singlePassStep{
+	"resize": {
+		phase: passResize,
+		valid: func(o ImageOptions) bool { return o.Mode == "" && hasSize(o) },
+		options: func(o ImageOptions) (bimg.Options, error) {
+			return resizeOptions(o), nil
+		},
+	},
+	"enlarge": {
+		phase: passResize,
+		valid: func(o ImageOptions) bool {
+			return (o.Width != 0 || o.WidthPercent != 0 || o.Scale != 0) &&
+				(o.Height != 0 || o.HeightPercent != 0 || o.Scale != 0)
+		},
+		options: func(o ImageOptions) (bimg.Options, error) {
+			return enlargeOptions(o), nil
+		},
+	},
+	"thumbnail": {
+		phase: passResize,
+		valid: hasSize,
+		options: func(o ImageOptions) (bimg.Options, error) {
+			return BimgOptions(o), nil
+		},
+	},
+	"watermark": {
+		phase:   passWatermark,
+		params:  append(watermarkParams, outputParams...),
+		valid:   func(o ImageOptions) bool { return o.Text != "" && validateText(o.Text) == nil },
+		options: watermarkOptions,
+	},
+	"convert": {
+		phase:  passEncode,
+		params: outputParams,
+		valid:  func(o ImageOptions) bool { return ImageType(o.Type) != bimg.UNKNOWN },
+		options: func(o ImageOptions) (bimg.Options, error) {
+			return BimgOptions(o), nil
+		},
+	},
+}
+
+// hasSize reports whether the width or height, absolute or relative, is
+// given.
+func hasSize(o ImageOptions) bool {
+	return o.Width != 0 || o.Height != 0 || hasRelativeSize(o)
+}
+
+// mergePipelineOperations merges the adjacent pipeline operations bimg can
+// run in a single pass, e.g. a resize followed by a text watermark and a
+// conversion, saving the intermediate decodings and encodings. The merged
+// operations are run by the first one of their group, the others being left
+// without Operation, so the indexes of the operations are kept.
+func mergePipelineOperations(operations []PipelineOperation) {
+	for i := 0; i < len(operations); {
+		end := singlePassGroupEnd(operations, i)
+		if end > i+1 {
+			operations[i].Operation = singlePassOperation(operations[i:end])
+			for j := i + 1; j < end; j++ {
+				operations[j].Operation = nil
+			}
+		}
+		i = max(end, i+1)
+	}
+}
+
+// singlePassGroupEnd returns the end of the group of operations starting at
+// the given index which can be merged.
+func singlePassGroupEnd(operations []PipelineOperation, start int) int {
+	first, ok := singlePassSteps[operations[start].Name]
+	if !ok || operations[start].IgnoreFailure || operations[start].If != "" ||
+		!first.valid(operations[start].ImageOptions) {
+		return start
+	}
+
+	phase := first.phase
+	end := start + 1
+	for ; end < len(operations); end++ {
+		operation := operations[end]
+		step, ok := singlePassSteps[operation.Name]
+		if !ok || step.phase <= phase || operation.IgnoreFailure || operation.If != "" ||
+			!step.valid(operation.ImageOptions) || !hasOnlyParams(operation.Params, step.params) {
+			break
+		}
+		phase = step.phase
+	}
+	return end
+}
+
+// hasOnlyParams reports whether the known params are all in the given list.
+func hasOnlyParams(params map[string]interface{}, allowed []string) bool {
+	for key := range params {
+		if _, known := paramTypeCoercions[key]; known && !slices.Contains(allowed, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// singlePassOperation returns the operation running the merged operations in
+// a single bimg pass: the resize of the first one, the text watermark of the
+// watermark one and the encoding of the last one, keeping the previous output
+// type when it has none.
+func singlePassOperation(operations []PipelineOperation) Operation {
+	return func(buf []byte, o ImageOptions) (Image, error) {
+		opts, err := singlePassSteps[operations[0].Name].options(o)
+		if err != nil {
+			return Image{}, err
+		}
+
+		for _, operation := range operations[1:] {
+			stepOpts, err := singlePassSteps[operation.Name].options(operation.ImageOptions)
+			if err != nil {
+				return Image{}, err
+			}
+			if operation.Name == "watermark" {
+				opts.Watermark = stepOpts.Watermark
+			}
+			if stepOpts.Type != bimg.UNKNOWN {
+				opts.Type = stepOpts.Type
+			}
+			opts.Quality = stepOpts.Quality
+			opts.Compression = stepOpts.Compression
+			opts.Interlace = stepOpts.Interlace
+			opts.StripMetadata = opts.StripMetadata || stepOpts.StripMetadata
+			opts.Lossless = stepOpts.Lossless
+			opts.Speed = stepOpts.Speed
+			opts.Palette = stepOpts.Palette
+		}
+		return processWithKernel(buf, opts, o.Kernel)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func pipelineOperations(t *testing.T, operations PipelineOperations) PipelineOperations {
+	for i, operation := range operations {
+		var err error
+		operations[i].Operation = OperationsMap[operation.Name]
+		if operations[i].ImageOptions, err = buildParamsFromOperation(operation); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	return operations
+}
+
+func TestMergePipelineOperations(t *testing.T) {
+	cases := []struct {
+		operations PipelineOperations
+		merged     []bool
+	}{
+		{
+			PipelineOperations{
+				{Name: "resize", Params: map[string]interface{}{"width": 300, "type": "png"}},
+				{Name: "watermark", Params: map[string]interface{}{"text": "imaginary", "opacity": 0.5}},
+				{Name: "convert", Params: map[string]interface{}{"type": "webp", "quality": 70}},
+			},
+			[]bool{false, true, true},
+		},
+		{
+			PipelineOperations{
+				{Name: "convert", Params: map[string]interface{}{"type": "png"}},
+				{Name: "resize", Params: map[string]interface{}{"width": 300}},
+				{Name: "convert", Params: map[string]interface{}{"type": "webp"}},
+			},
+			[]bool{false, false, true},
+		},
+		{
+			// The watermark resizes, it must run after the first resize
+			PipelineOperations{
+				{Name: "resize", Params: map[string]interface{}{"width": 300}},
+				{Name: "watermark", Params: map[string]interface{}{"text": "imaginary", "width": 200}},
+			},
+			[]bool{false, false},
+		},
+		{
+			PipelineOperations{
+				{Name: "resize", Params: map[string]interface{}{"width": 300, "mode": "fill", "height": 200}},
+				{Name: "convert", Params: map[string]interface{}{"type": "webp"}},
+				{Name: "watermark", Params: map[string]interface{}{"text": "imaginary"}},
+				{Name: "convert", Params: map[string]interface{}{"type": "jpeg"}},
+			},
+			[]bool{false, false, false, true},
+		},
+		{
+			PipelineOperations{
+				{Name: "resize", Params: map[string]interface{}{"width": 300}},
+				{Name: "convert", Params: map[string]interface{}{"type": "webp"}, IgnoreFailure: true},
+				{Name: "watermark", Params: map[string]interface{}{}},
+			},
+			[]bool{false, false, false},
+		},
+	}
+
+	for i, c := range cases {
+		operations := pipelineOperations(t, c.operations)
+		mergePipelineOperations(operations)
+		for j, operation := range operations {
+			if (operation.Operation == nil) != c.merged[j] {
+				t.Errorf("Invalid merge of operation %d of case %d", j, i)
+			}
+		}
+	}
+}
+
+func TestSinglePassPipeline(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+	operations := PipelineOperations{
+		{Name: "resize", Params: map[string]interface{}{"width": 300, "type": "png"}},
+		{Name: "watermark", Params: map[string]interface{}{"text": "imaginary"}},
+		{Name: "convert", Params: map[string]interface{}{"type": "webp"}},
+	}
+
+	img, err := Pipeline(buf, ImageOptions{Operations: operations})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != "image/webp" {
+		t.Error(InvalidMimeType)
+	}
+	if err := assertSize(img.Body, 300, 404); err != nil {
+		t.Error(err)
+	}
+
+	timing := img.Header.Values(PipelineTimingHeader)
+	if len(timing) != 3 || !strings.HasPrefix(timing[0], "0;operation=resize;dur=") ||
+		timing[1] != "1;operation=watermark;merged" || timing[2] != "2;operation=convert;merged" {
+		t.Errorf("Invalid timing steps: %v", timing)
+	}
+}
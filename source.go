@@ -21,6 +21,7 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type ImageSourceType string
@@ -30,12 +31,16 @@ type SourceConfig struct {
 	AuthForwarding     bool
 	Authorization      string
 	MountPath          string
+	Mounts             map[string]Mount
 	Type               ImageSourceType
 	ForwardHeaders     []string
 	SrcResponseHeaders []string
+	RequestIDHeader    string
 	AllowedOrigins     []*url.URL
 	MaxAllowedSize     int
 	AllowInsecureSSL   bool
+	EnableURLSource    bool
+	OriginLimiter      *originLimiter
 }
 
 var imageSourceMap = make(map[ImageSourceType]ImageSource)
@@ -51,17 +56,22 @@ func RegisterSource(sourceType ImageSourceType, factory ImageSourceFactoryFuncti
 }
 
 func LoadSources(o ServerOptions) {
+	limiter := newOriginLimiter(o.OriginConcurrency, o.OriginRate, time.Duration(o.OriginQueueTimeout)*time.Second)
 	for name, factory := range imageSourceFactoryMap {
 		imageSourceMap[name] = factory(&SourceConfig{
 			Type:               name,
 			MountPath:          o.Mount,
+			Mounts:             o.Mounts,
 			AuthForwarding:     o.AuthForwarding,
 			Authorization:      o.Authorization,
 			AllowedOrigins:     o.AllowedOrigins,
 			MaxAllowedSize:     o.MaxAllowedSize,
 			ForwardHeaders:     o.ForwardHeaders,
 			SrcResponseHeaders: o.SrcResponseHeaders,
+			RequestIDHeader:    o.RequestIDHeader,
 			AllowInsecureSSL:   o.AllowInsecureSSL,
+			EnableURLSource:    o.EnableURLSource,
+			OriginLimiter:      limiter,
 		})
 	}
 }
@@ -59,9 +59,13 @@ func isFormBody(r *http.Request) bool {
 }
 
 func readFormBody(r *http.Request) ([]byte, error) {
+	if err := staging.stage(r); err != nil {
+		return nil, err
+	}
+
 	err := r.ParseMultipartForm(maxMemory)
 	if err != nil {
-		return nil, err
+		return nil, bodyReadError(err)
 	}
 
 	file, _, err := r.FormFile(formFieldName)
@@ -80,8 +84,30 @@ func readFormBody(r *http.Request) ([]byte, error) {
 	return buf, err
 }
 
+// readFormFiles returns every file uploaded under the form field name.
+func readFormFiles(r *http.Request) ([]*multipart.FileHeader, error) {
+	if !isFormBody(r) {
+		return nil, ErrMissingParamFile
+	}
+	if err := staging.stage(r); err != nil {
+		return nil, err
+	}
+
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return nil, bodyReadError(err)
+	}
+
+	files := r.MultipartForm.File[formFieldName]
+	if len(files) == 0 {
+		return nil, ErrMissingParamFile
+	}
+
+	return files, nil
+}
+
 func readRawBody(r *http.Request) ([]byte, error) {
-	return io.ReadAll(r.Body)
+	buf, err := io.ReadAll(r.Body)
+	return buf, bodyReadError(err)
 }
 
 func init() {
@@ -24,6 +24,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -55,20 +56,50 @@ func (s *FileSystemImageSource) GetImage(r *http.Request) ([]byte, http.Header,
 		return nil, nil, ErrMissingParamFile
 	}
 
-	file, err = s.buildPath(file)
+	mount, file, err := s.resolveMount(file)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	file, rel, err := buildPath(mount.Path, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Matched on the path actually read, once cleaned
+	if !mount.allows(rel) {
+		return nil, nil, ErrFileNotAllowed
+	}
+
 	return s.read(file)
 }
 
-func (s *FileSystemImageSource) buildPath(file string) (string, error) {
-	file = path.Clean(path.Join(s.Config.MountPath, file))
-	if !strings.HasPrefix(file, s.Config.MountPath) {
-		return "", ErrInvalidFilePath
+// resolveMount returns the mount the file param refers to, either a named one
+// or the unnamed -mount path, and the file path within it.
+func (s *FileSystemImageSource) resolveMount(file string) (Mount, string, error) {
+	name, file := splitMountFile(file, s.Config.Mounts)
+	if name == "" {
+		if s.Config.MountPath == "" {
+			return Mount{}, "", ErrInvalidFilePath
+		}
+		return Mount{Path: s.Config.MountPath}, file, nil
+	}
+	return s.Config.Mounts[name], file, nil
+}
+
+// buildPath returns the path of the file within the root directory and its
+// path relative to it, rejecting the files escaping the directory, e.g. with
+// "..".
+func buildPath(root, file string) (string, string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", "", ErrInvalidFilePath
+	}
+	file = path.Join(root, file)
+	rel, ok := strings.CutPrefix(file, strings.TrimSuffix(root, "/")+"/")
+	if !ok {
+		return "", "", ErrInvalidFilePath
 	}
-	return file, nil
+	return file, rel, nil
 }
 
 func (s *FileSystemImageSource) read(file string) ([]byte, http.Header, error) {
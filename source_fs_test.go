@@ -53,3 +53,67 @@ func TestFileSystemImageSource(t *testing.T) {
 		t.Error("Invalid response body")
 	}
 }
+
+func TestFileSystemImageSourceMounts(t *testing.T) {
+	source := NewFileSystemImageSource(&SourceConfig{
+		MountPath: "docs",
+		Mounts: map[string]Mount{
+			"assets": {Path: "testdata", Allow: []string{"*.jpg"}, CacheTTL: -1},
+		},
+	})
+
+	cases := []struct {
+		file string
+		err  error
+	}{
+		{"assets:large.jpg", nil},
+		{"assets:./large.jpg", nil},
+		{"assets:test.png", ErrFileNotAllowed},
+		{"assets:../go.mod", ErrInvalidFilePath},
+		{"assets:../testdata/large.jpg", nil},
+		{"assets:sub/../../testdata/large.jpg", nil},
+		{"assets:../testdata-other/large.jpg", ErrInvalidFilePath},
+		{"large.jpg", ErrInvalidFilePath},
+		{"media:large.jpg", ErrInvalidFilePath},
+	}
+
+	expected, _ := os.ReadFile("testdata/large.jpg")
+	for _, c := range cases {
+		r, _ := http.NewRequest(http.MethodGet, "http://foo/bar?file="+c.file, nil)
+		buf, _, err := source.GetImage(r)
+		if err != c.err {
+			t.Errorf("Invalid error for %s: %v != %v", c.file, err, c.err)
+		}
+		if err == nil && len(buf) != len(expected) {
+			t.Errorf("Invalid image for %s", c.file)
+		}
+	}
+
+	unnamed := NewFileSystemImageSource(&SourceConfig{Mounts: map[string]Mount{"assets": {Path: "testdata"}}})
+	r, _ := http.NewRequest(http.MethodGet, "http://foo/bar?file=large.jpg", nil)
+	if _, _, err := unnamed.GetImage(r); err != ErrInvalidFilePath {
+		t.Errorf("Expected files without mount name to be rejected without unnamed mount, got %v", err)
+	}
+}
+
+func TestBuildPath(t *testing.T) {
+	cases := []struct {
+		root, file, path, rel string
+		err                   error
+	}{
+		{"/mnt/assets", "logo.png", "/mnt/assets/logo.png", "logo.png", nil},
+		{"/mnt/assets/", "./img/../logo.png", "/mnt/assets/logo.png", "logo.png", nil},
+		{"/mnt/assets", "/logo.png", "/mnt/assets/logo.png", "logo.png", nil},
+		{"/", "logo.png", "/logo.png", "logo.png", nil},
+		{"/mnt/assets", "../assets-private/logo.png", "", "", ErrInvalidFilePath},
+		{"/mnt/assets", "..", "", "", ErrInvalidFilePath},
+		{"/mnt/assets", "", "", "", ErrInvalidFilePath},
+	}
+
+	for _, c := range cases {
+		file, rel, err := buildPath(c.root, c.file)
+		if err != c.err || file != c.path || rel != c.rel {
+			t.Errorf("Invalid path of %s in %s: %s, %s, %v", c.file, c.root, file, rel, err)
+		}
+	}
+}
@@ -55,6 +55,16 @@ func (s *HTTPImageSource) GetImage(req *http.Request) ([]byte, http.Header, erro
 }
 
 func (s *HTTPImageSource) fetchImage(url *url.URL, ireq *http.Request) ([]byte, http.Header, error) {
+	// Spare the origin host during cache-miss storms
+	if s.Config.OriginLimiter != nil {
+		release, err := s.Config.OriginLimiter.Acquire(ireq.Context(), url.Hostname(),
+			originLabel(url, s.Config.AllowedOrigins))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+	}
+
 	// Check remote image size by fetching HTTP Headers
 	if s.Config.MaxAllowedSize > 0 {
 		req := newHTTPRequest(s, ireq, http.MethodHead, url)
@@ -93,6 +103,7 @@ func (s *HTTPImageSource) fetchImage(url *url.URL, ireq *http.Request) ([]byte,
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to create image from response body: %s (url=%s)", req.URL.String(), err)
 	}
+	originFetchedBytes.WithLabelValues(originLabel(url, s.Config.AllowedOrigins)).Add(float64(len(buf)))
 	return buf, res.Header, nil
 }
 
@@ -135,6 +146,11 @@ func newHTTPRequest(s *HTTPImageSource, ireq *http.Request, method string, url *
 		s.setForwardHeaders(req, ireq)
 	}
 
+	// Correlate the origin logs with the imaginary ones
+	if id := requestIDFromContext(ireq.Context()); id != "" && s.Config.RequestIDHeader != "" {
+		req.Header.Set(s.Config.RequestIDHeader, id)
+	}
+
 	// Forward auth header to the target server, if necessary
 	if s.Config.AuthForwarding || s.Config.Authorization != "" {
 		s.setAuthorizationHeader(req, ireq)
@@ -161,6 +177,22 @@ func shouldRestrictOrigin(url *url.URL, origins []*url.URL) bool {
 	return true
 }
 
+// otherOrigin labels the origin metrics of the hosts matching no allowed
+// origin, or all of them when the origins aren't restricted.
+const otherOrigin = "other"
+
+// originLabel returns the host of the allowed origin matching the URL, e.g.
+// *.example.org, bounding the cardinality of the origin metrics to the
+// -allowed-origins entries whatever the requested hosts.
+func originLabel(url *url.URL, origins []*url.URL) string {
+	for _, origin := range origins {
+		if isExactMatch(url, origin) || isSubdomainMatch(url, origin) {
+			return origin.Host
+		}
+	}
+	return otherOrigin
+}
+
 func isExactMatch(url *url.URL, origin *url.URL) bool {
 	return origin.Host == url.Host && strings.HasPrefix(url.Path, origin.Path)
 }
@@ -19,6 +19,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -196,6 +197,25 @@ func TestHttpImageSourceNotForwardHeaders(t *testing.T) {
 	}
 }
 
+func TestHttpImageSourceRequestID(t *testing.T) {
+	testURL := createURL(HttpBarCom, t)
+
+	r, _ := http.NewRequest(http.MethodGet, HttpFooBarUrl+testURL.String(), nil)
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, "abc123"))
+
+	source := &HTTPImageSource{&SourceConfig{RequestIDHeader: DefaultRequestIDHeader}}
+	oreq := newHTTPRequest(source, r, http.MethodGet, testURL)
+	if id := oreq.Header.Get(DefaultRequestIDHeader); id != "abc123" {
+		t.Fatalf("Invalid forwarded request ID: %q", id)
+	}
+
+	source = &HTTPImageSource{&SourceConfig{}}
+	oreq = newHTTPRequest(source, r, http.MethodGet, testURL)
+	if id := oreq.Header.Get(DefaultRequestIDHeader); id != "" {
+		t.Fatalf("Unexpected forwarded request ID: %q", id)
+	}
+}
+
 func TestHttpImageSourceForwardedHeadersNotOverride(t *testing.T) {
 	cases := []string{
 		"Authorization",
@@ -451,6 +471,24 @@ func TestShouldRestrictOrigin(t *testing.T) {
 	}
 }
 
+func TestOriginLabel(t *testing.T) {
+	origins := parseOrigins("https://*.example.org,https://example.com/assets")
+	cases := map[string]string{
+		"https://cdn.example.org/a.jpg":    "*.example.org",
+		"https://example.com/assets/a.jpg": "example.com",
+		"https://example.com/a.jpg":        otherOrigin,
+		"https://random.example.net/a.jpg": otherOrigin,
+	}
+	for urlStr, expected := range cases {
+		if label := originLabel(createURL(urlStr, t), origins); label != expected {
+			t.Errorf("Invalid origin label of %s: %s, expected: %s", urlStr, label, expected)
+		}
+	}
+	if label := originLabel(createURL("https://example.com/a.jpg", t), nil); label != otherOrigin {
+		t.Errorf("Expected unrestricted origins to share a label, got %s", label)
+	}
+}
+
 func TestParseOrigins(t *testing.T) {
 	t.Run("Appending a trailing slash on paths", func(t *testing.T) {
 		origins := parseOrigins("http://foo.example.org/assets")
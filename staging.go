@@ -0,0 +1,122 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// staging is the directory of the temporary files, such as the multipart
+// uploads exceeding the in-memory limit and the libvips disc caches. It is
+// nil, hence the system temporary directory is used, if the -tmp-dir flag is
+// not set.
+var staging *stagingDir
+
+// stagingDir is a per process directory created within the -tmp-dir one,
+// e.g. a tmpfs mount, so the temporary files don't churn the writable layer
+// of containers and are all removed on shutdown.
+type stagingDir struct {
+	path  string
+	quota int64
+
+	mu sync.Mutex
+	// staged is the size in bytes reserved by the uploads in progress
+	staged int64
+}
+
+// newStagingDir creates the staging directory and makes it the temporary
+// directory of the process, used by both Go and libvips.
+func newStagingDir(parent string, quota int64) (*stagingDir, error) {
+	dir, err := os.MkdirTemp(parent, "imaginary-")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Setenv("TMPDIR", dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return &stagingDir{path: dir, quota: quota}, nil
+}
+
+// LoadStaging creates the staging directory when configured.
+func LoadStaging(o ServerOptions) {
+	if o.TmpDir == "" {
+		return
+	}
+
+	dir, err := newStagingDir(o.TmpDir, int64(o.TmpDirQuota))
+	if err != nil {
+		exitWithError(newRuntimeError("cannot create the -tmp-dir staging directory: %w", err))
+	}
+	staging = dir
+	debug("Staging temporary files in %s", dir.path)
+}
+
+// stage reserves the room the multipart upload of the request may take in
+// the staging directory until the request completes, capping its body to
+// it. Uploads fitting in memory aren't staged, while the others may be staged
+// whole: their length is reserved, or the remaining quota when unknown.
+// ErrStagingFull is returned when the quota can't fit the upload.
+func (s *stagingDir) stage(r *http.Request) error {
+	if s == nil || s.quota <= 0 || r.MultipartForm != nil {
+		return nil
+	}
+	if r.ContentLength >= 0 && r.ContentLength <= maxMemory {
+		return nil
+	}
+
+	s.mu.Lock()
+	remaining := s.quota - s.staged
+	size := r.ContentLength
+	if size < 0 {
+		size = remaining
+	}
+	if size <= 0 || size > remaining {
+		s.mu.Unlock()
+		return ErrStagingFull
+	}
+	s.staged += size
+	stagedBytes.Set(float64(s.staged))
+	s.mu.Unlock()
+
+	r.Body = http.MaxBytesReader(nil, r.Body, max(size, maxMemory))
+	context.AfterFunc(r.Context(), func() { s.release(size) })
+	return nil
+}
+
+// release frees the room reserved by a completed upload.
+func (s *stagingDir) release(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.staged -= size
+	stagedBytes.Set(float64(s.staged))
+}
+
+// remove deletes the staging directory along with the files left over.
+func (s *stagingDir) remove() {
+	if s == nil {
+		return
+	}
+	if err := os.RemoveAll(s.path); err != nil {
+		logf(LogLevelWarning, "cannot remove the staging directory %s: %s", s.path, err)
+	}
+}
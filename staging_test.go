@@ -0,0 +1,109 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStagingDir(t *testing.T) {
+	t.Setenv("TMPDIR", os.TempDir())
+	parent := t.TempDir()
+
+	dir, err := newStagingDir(parent, 1024)
+	if err != nil {
+		t.Fatalf("Cannot create the staging directory: %s", err)
+	}
+	if filepath.Dir(dir.path) != parent {
+		t.Errorf("Expected the staging directory within %s: %s", parent, dir.path)
+	}
+	if os.TempDir() != dir.path {
+		t.Errorf("Expected the staging directory to be the temporary one: %s", os.TempDir())
+	}
+
+	dir.remove()
+	if _, err := os.Stat(dir.path); !os.IsNotExist(err) {
+		t.Errorf("Expected the staging directory to be removed: %v", err)
+	}
+
+	var disabled *stagingDir
+	if err := disabled.stage(httptest.NewRequest(http.MethodPost, "/", nil)); err != nil {
+		t.Errorf("Expected no staging directory to never be full: %v", err)
+	}
+	disabled.remove()
+}
+
+func TestStagingDirStage(t *testing.T) {
+	dir := &stagingDir{quota: 2 * maxMemory}
+	staged := func() int64 {
+		dir.mu.Lock()
+		defer dir.mu.Unlock()
+		return dir.staged
+	}
+	upload := func(ctx context.Context, length int64) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("")).WithContext(ctx)
+		req.ContentLength = length
+		return req
+	}
+
+	if err := dir.stage(upload(context.Background(), 1024)); err != nil || staged() != 0 {
+		t.Errorf("Expected the uploads fitting in memory not to be staged: %d, %v", staged(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := dir.stage(upload(ctx, maxMemory+1)); err != nil || staged() != maxMemory+1 {
+		t.Errorf("Expected the upload length to be reserved: %d, %v", staged(), err)
+	}
+	if err := dir.stage(upload(context.Background(), maxMemory+1)); err != ErrStagingFull {
+		t.Errorf("Expected the upload exceeding the remaining quota to be rejected, got: %v", err)
+	}
+	if err := dir.stage(upload(context.Background(), -1)); err != nil || staged() != 2*maxMemory {
+		t.Errorf("Expected the remaining quota to be reserved: %d, %v", staged(), err)
+	}
+
+	cancel()
+	for i := 0; i < 100 && staged() != maxMemory-1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if staged() != maxMemory-1 {
+		t.Errorf("Expected the reservation to be released once the request completes: %d", staged())
+	}
+}
+
+func TestStagingFullRejectsUploads(t *testing.T) {
+	staging = &stagingDir{quota: 1}
+	defer func() { staging = nil }()
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=foo")
+	req.ContentLength = maxMemory + 1
+	if _, err := readFormFiles(req); err != ErrStagingFull {
+		t.Errorf("Expected uploads to be rejected, got: %v", err)
+	}
+	if _, err := readFormBody(req); err != ErrStagingFull {
+		t.Errorf("Expected uploads to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StaleWarning is the Warning header value of the stale responses.
+const StaleWarning = `110 imaginary "Response is Stale"`
+
+// staleResults caches the images processed from remote sources, to be served
+// when their origin fails. It is nil, hence disabled, unless the
+// -stale-if-error flag is set.
+var staleResults *resultCache
+
+// resultCache is a LRU cache of processed images bounded by the total size
+// of their bodies, whose entries expire after the stale window.
+type resultCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	window  time.Duration
+	entries *list.List
+	items   map[string]*list.Element
+}
+
+type resultCacheEntry struct {
+	key    string
+	image  Image
+	vary   string
+	stored time.Time
+}
+
+func newResultCache(maxSize int, window time.Duration) *resultCache {
+	return &resultCache{
+		maxSize: maxSize,
+		window:  window,
+		entries: list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// LoadStaleCache enables serving stale images on origin errors when a window
+// is configured.
+func LoadStaleCache(o ServerOptions) {
+	if o.StaleIfError > 0 && o.StaleCacheSize > 0 {
+		staleResults = newResultCache(o.StaleCacheSize, time.Duration(o.StaleIfError)*time.Second)
+	}
+}
+
+// staleKey identifies the processed image by the request path and params,
+// along with the accepted types when the output type is negotiated.
+func staleKey(r *http.Request) string {
+	query := r.URL.Query()
+	key := r.URL.Path + "?" + query.Encode()
+	if query.Get("type") == "auto" {
+		key += "|" + r.Header.Get("Accept")
+	}
+	return key
+}
+
+// get returns the image cached for the key unless older than the window.
+func (c *resultCache) get(key string, now time.Time) (Image, string, bool) {
+	if c == nil {
+		return Image{}, "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return Image{}, "", false
+	}
+	entry := el.Value.(*resultCacheEntry)
+	if now.Sub(entry.stored) > c.window {
+		c.remove(el)
+		return Image{}, "", false
+	}
+	c.entries.MoveToFront(el)
+	return entry.image, entry.vary, true
+}
+
+// add stores the image, evicting the least recently used entries to stay
+// within the size limit.
+func (c *resultCache) add(key string, image Image, vary string, now time.Time) {
+	if c == nil || len(image.Body) > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	entry := &resultCacheEntry{key: key, image: image, vary: vary, stored: now}
+	c.items[key] = c.entries.PushFront(entry)
+	c.size += len(image.Body)
+
+	for c.size > c.maxSize {
+		c.remove(c.entries.Back())
+	}
+}
+
+// remove evicts the entry. The mutex must be held.
+func (c *resultCache) remove(el *list.Element) {
+	entry := c.entries.Remove(el).(*resultCacheEntry)
+	delete(c.items, entry.key)
+	c.size -= len(entry.image.Body)
+}
+
+// isOriginFailure reports whether the source error is caused by the origin
+// being unavailable, i.e. a network or server error, rather than by the
+// request or a missing image.
+func isOriginFailure(err error) bool {
+	if xerr, ok := err.(Error); ok {
+		return xerr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// keepStale caches the image processed from a remote source, to be served by
+// replyWithStale.
+func keepStale(r *http.Request, image Image, vary string) {
+	if staleResults == nil || r.Method != http.MethodGet || r.URL.Query().Get(URLQueryKey) == "" {
+		return
+	}
+	staleResults.add(staleKey(r), image, vary, time.Now())
+}
+
+// replyWithStale serves the stale image cached for the request, if any, when
+// the origin failed.
+func replyWithStale(w http.ResponseWriter, r *http.Request, err error, o ServerOptions) bool {
+	if staleResults == nil || !isOriginFailure(err) {
+		return false
+	}
+
+	image, vary, ok := staleResults.get(staleKey(r), time.Now())
+	if !ok {
+		return false
+	}
+
+	w.Header().Set("Warning", StaleWarning)
+	sendResponse(w, image, vary, o)
+	return true
+}
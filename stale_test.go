@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	cache := newResultCache(10, time.Minute)
+	now := time.Now()
+
+	cache.add("a", Image{Body: []byte("12345")}, "", now)
+	cache.add("b", Image{Body: []byte("12345")}, "Accept", now)
+	if image, vary, ok := cache.get("b", now); !ok || string(image.Body) != "12345" || vary != "Accept" {
+		t.Fatalf("Expected cached image, got %v, %q, %t", image, vary, ok)
+	}
+
+	cache.add("c", Image{Body: []byte("12345")}, "", now)
+	if _, _, ok := cache.get("a", now); ok {
+		t.Error("Expected least recently used image to be evicted")
+	}
+	if _, _, ok := cache.get("b", now.Add(2*time.Minute)); ok {
+		t.Error("Expected image older than the window not to be served")
+	}
+
+	cache.add("d", Image{Body: []byte("12345678901")}, "", now)
+	if _, _, ok := cache.get("d", now); ok {
+		t.Error("Expected image larger than the cache not to be cached")
+	}
+	if cache.size != 5 {
+		t.Errorf("Invalid cache size: %d", cache.size)
+	}
+}
+
+func TestIsOriginFailure(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("error fetching remote http image: connection refused"), true},
+		{NewError("error fetching remote http image: (status=502)", http.StatusBadGateway), true},
+		{ErrOriginBusy, true},
+		{NewError("error fetching remote http image: (status=404)", http.StatusNotFound), false},
+		{ErrInvalidImageURL, false},
+	}
+
+	for _, c := range cases {
+		if isOriginFailure(c.err) != c.expected {
+			t.Errorf("Invalid origin failure for %q", c.err)
+		}
+	}
+}
+
+func TestReplyWithStale(t *testing.T) {
+	staleResults = newResultCache(1024, time.Minute)
+	defer func() { staleResults = nil }()
+
+	target := "/resize?width=100&url=http://example.com/image.jpg"
+	keepStale(httptest.NewRequest(http.MethodGet, target, nil), Image{Body: []byte("image"), Mime: "image/jpeg"}, "")
+
+	res := httptest.NewRecorder()
+	if !replyWithStale(res, httptest.NewRequest(http.MethodGet, target, nil), ErrOriginBusy, ServerOptions{}) {
+		t.Fatal("Expected stale image to be served")
+	}
+	if res.Body.String() != "image" || res.Header().Get("Warning") != StaleWarning {
+		t.Errorf("Invalid stale response: %q, %q", res.Body.String(), res.Header().Get("Warning"))
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/resize?width=200&url=http://example.com/image.jpg", nil)
+	if replyWithStale(httptest.NewRecorder(), other, ErrOriginBusy, ServerOptions{}) {
+		t.Error("Expected other derivatives not to be served")
+	}
+}
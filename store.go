@@ -0,0 +1,298 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StoreScheme is the scheme of the destinations given by the store param,
+// e.g. s3://bucket/key.
+const StoreScheme = "s3://"
+
+// MaxStoreKeyLength is the maximum length in bytes of the object keys.
+const MaxStoreKeyLength = 1024
+
+// storeTimeout bounds the upload of an output to the storage.
+const storeTimeout = 30 * time.Second
+
+// bucketPattern matches the bucket names of S3-compatible storages.
+var bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+var (
+	ErrStoreDisabled = NewError("Storing the output requires the -store-endpoint flag", http.StatusBadRequest)
+	ErrStoreURL      = NewError("Invalid store param: an s3://bucket/key URL is expected", http.StatusBadRequest)
+	ErrStoreBucket   = NewError("Storing the output in this bucket or key is not allowed", http.StatusForbidden)
+)
+
+// objectStore uploads the outputs requested with the store param. It is nil,
+// hence disabled, if -store-endpoint is not set.
+var objectStore *s3Store
+
+// s3Store uploads objects to an S3-compatible storage, using path-style URLs
+// and AWS Signature Version 4 authenticated requests.
+type s3Store struct {
+	endpoint     *url.URL
+	region       string
+	buckets      []string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// LoadObjectStore enables the upload of the outputs when configured.
+func LoadObjectStore(o ServerOptions) {
+	if o.StoreEndpoint == "" {
+		return
+	}
+
+	endpoint, err := url.Parse(o.StoreEndpoint)
+	if err != nil {
+		exitWithError(newConfigError("invalid -store-endpoint: %w", err))
+	}
+	objectStore = &s3Store{
+		endpoint:     endpoint,
+		region:       o.StoreRegion,
+		buckets:      o.StoreBuckets,
+		accessKey:    o.StoreAccessKey,
+		secretKey:    o.StoreSecretKey,
+		sessionToken: o.StoreSessionToken,
+		client:       &http.Client{Timeout: storeTimeout},
+	}
+}
+
+// StoredObject is the descriptor replied instead of the output once stored.
+type StoredObject struct {
+	URL      string `json:"url"`
+	Location string `json:"location"`
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	ETag     string `json:"etag,omitempty"`
+}
+
+// storeDestination is the object an output is uploaded to.
+type storeDestination struct {
+	bucket string
+	key    string
+}
+
+func (d storeDestination) String() string {
+	return StoreScheme + d.bucket + "/" + d.key
+}
+
+// parseStoreDestination parses the store param, only accepting the buckets
+// and key prefixes listed by -store-buckets. It returns nil if the param is
+// not set.
+func parseStoreDestination(value string) (*storeDestination, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if objectStore == nil {
+		return nil, ErrStoreDisabled
+	}
+
+	location, ok := strings.CutPrefix(value, StoreScheme)
+	if !ok {
+		return nil, ErrStoreURL
+	}
+	bucket, key, _ := strings.Cut(location, "/")
+	if !bucketPattern.MatchString(bucket) || key == "" || len(key) > MaxStoreKeyLength {
+		return nil, ErrStoreURL
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return nil, ErrStoreURL
+		}
+	}
+
+	if !objectStore.allows(bucket, key) {
+		return nil, ErrStoreBucket
+	}
+	return &storeDestination{bucket: bucket, key: key}, nil
+}
+
+// allows reports whether the object matches a -store-buckets entry, either a
+// bucket name or a bucket name followed by a key prefix, e.g. assets/thumbs/.
+func (s *s3Store) allows(bucket, key string) bool {
+	return slices.ContainsFunc(s.buckets, func(entry string) bool {
+		name, prefix, _ := strings.Cut(entry, "/")
+		return name == bucket && strings.HasPrefix(key, prefix)
+	})
+}
+
+// storeImage uploads the output to the destination, returning the descriptor
+// of the stored object in its place.
+func storeImage(ctx context.Context, dest *storeDestination, image Image) (Image, error) {
+	object, err := objectStore.put(ctx, *dest, image)
+	if err != nil {
+		logf(LogLevelWarning, "cannot store the output in %s: %s", dest, err)
+		return Image{}, NewError("Cannot store the output: "+err.Error(), http.StatusBadGateway)
+	}
+
+	body, _ := json.Marshal(object)
+	// The descriptor is only valid as long as the object is not overwritten
+	header := http.Header{"Cache-Control": {"no-store"}}
+	return Image{Body: body, Mime: ContentTypeJSON, Header: header}, nil
+}
+
+// put uploads the image body to the destination object.
+func (s *s3Store) put(ctx context.Context, dest storeDestination, image Image) (StoredObject, error) {
+	location := s.objectURL(dest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), bytes.NewReader(image.Body))
+	if err != nil {
+		return StoredObject{}, err
+	}
+	req.Header.Set(ContentType, image.Mime)
+	s.sign(req, image.Body, time.Now())
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return StoredObject{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode != http.StatusOK {
+		return StoredObject{}, fmt.Errorf("storage replied %s", res.Status)
+	}
+
+	return StoredObject{
+		URL:      dest.String(),
+		Location: location.String(),
+		Bucket:   dest.bucket,
+		Key:      dest.key,
+		Type:     image.Mime,
+		Size:     len(image.Body),
+		ETag:     strings.Trim(res.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+// objectURL returns the path-style URL of the object.
+func (s *s3Store) objectURL(dest storeDestination) *url.URL {
+	location := *s.endpoint
+	base := strings.TrimSuffix(s.endpoint.EscapedPath(), "/")
+	location.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/" + dest.bucket + "/" + dest.key
+	location.RawPath = base + "/" + dest.bucket + "/" + uriEncode(dest.key, false)
+	return &location
+}
+
+// sign authenticates the request with AWS Signature Version 4, signing the
+// host and every header set beforehand.
+func (s *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	date := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date[:8] + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{date[:8], s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery returns the query sorted by name and value, encoded as
+// expected by AWS Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte but the unreserved characters and,
+// unless encodeSlash is set, the slashes.
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,229 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	VTT            = "vtt"
+	ContentTypeVTT = "text/vtt"
+)
+
+// MaxStoryboardFrames is the maximum number of frames of a storyboard.
+const MaxStoryboardFrames = MaxURLs
+
+// DefaultStoryboardColumns is the number of frames per row of a storyboard
+// when no columns param is given.
+const DefaultStoryboardColumns = 10
+
+// DefaultStoryboardInterval is the duration, in seconds, of the video
+// segment of every frame when no interval param is given.
+const DefaultStoryboardInterval = 10
+
+var defaultStoryboardBackground = [4]uint8{0, 0, 0, 255}
+
+// @Summary Storyboard
+// @Description Composes the video frames of the urls param into a storyboard sprite, or its WebVTT thumbnails track
+// @Produce image/*
+// @Produce text/vtt
+// @Param urls query string true "JSON list of the frame URLs"
+// @Param width query int true "Width of the frames"
+// @Param height query int true "Height of the frames"
+// @Param columns query int false "Number of frames per row (default 10)"
+// @Param interval query number false "Duration in seconds of the video segment of every frame (default 10)"
+// @Param sprite query string false "URL of the sprite referenced by the WebVTT track (default: same request without type)"
+// @Param gravity query string false "Gravity of the frames cropped to their cell"
+// @Param type query string false "Sprite image format (jpeg, png, webp, etc.), or vtt for the WebVTT track"
+// @Success 200 {file} binary "Storyboard sprite or WebVTT track"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /storyboard [get]
+func storyboardController(o ServerOptions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		o := withRequestLimits(req, o)
+
+		// The WebVTT track references the sprite served by the same request
+		// without the type param, signed again since the signature covers it
+		query := req.URL.Query()
+		track := query.Get("type") == VTT
+		if track {
+			query.Del("type")
+			query.Del("sign")
+			if o.EnableURLSignature {
+				sign := urlSignature(req.URL.Path, query, o.URLSignatureKey)
+				query.Set("sign", base64.RawURLEncoding.EncodeToString(sign))
+			}
+			req = req.Clone(req.Context())
+			req.URL.RawQuery = query.Encode()
+		}
+
+		opts, vary, err := processImageOptions(req)
+		if err != nil {
+			ErrorReply(req, w, NewError(err.Error(), http.StatusBadRequest), o)
+			return
+		}
+
+		if opts.Async || opts.Callback != "" {
+			ErrorReply(req, w, ErrAsyncUnsupported, o)
+			return
+		}
+
+		cells, err := storyboardCells(opts)
+		if err != nil {
+			ErrorReply(req, w, toError(err), o)
+			return
+		}
+		bounds := storyboardBounds(cells)
+		if float64(bounds.Dx())*float64(bounds.Dy())/1000000 > o.MaxAllowedPixels {
+			ErrorReply(req, w, ErrResolutionTooBig, o)
+			return
+		}
+
+		if track {
+			sprite := opts.Sprite
+			if sprite == "" {
+				sprite = endpointName(req.URL.Path) + "?" + req.URL.RawQuery
+			}
+			body, err := storyboardTrack(sprite, cells, opts.Interval)
+			if err != nil {
+				ErrorReply(req, w, toError(err), o)
+				return
+			}
+			sendResponse(w, signImage(Image{Body: body, Mime: ContentTypeVTT}, req), vary, o)
+			return
+		}
+
+		image, err := runOperation(Storyboard, nil, opts)
+		if err != nil {
+			if xerr, ok := err.(Error); ok {
+				ErrorReply(req, w, xerr, o)
+			} else {
+				handleProcessingError(w, req, vary, err, o)
+			}
+			return
+		}
+
+		sendResponse(w, signImage(image, req), vary, o)
+	}
+}
+
+// Storyboard composes the frames of the urls param into a sprite, in rows of
+// the columns param, each frame being cropped to cover its cell. Sprites
+// default to JPEG.
+func Storyboard(_ []byte, o ImageOptions) (Image, error) {
+	cells, err := storyboardCells(o)
+	if err != nil {
+		return Image{}, err
+	}
+
+	img := image.NewNRGBA(storyboardBounds(cells))
+	fillRect(img, img.Rect, opaqueColor(o.Background, defaultStoryboardBackground))
+	for i, url := range o.URLs {
+		buf, err := loadLayer(CompositeLayer{URL: url})
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Unable to load frame %d: %s", i, err), http.StatusBadRequest)
+		}
+		tile, err := collageTile(buf, cells[i], o.Gravity)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot process frame %d: %s", i, err), http.StatusBadRequest)
+		}
+		compositeLayer(img, tile, cells[i].Min.X, cells[i].Min.Y, 1, blendModes[BlendNormal])
+	}
+
+	t := bimg.JPEG
+	if o.Type != "" {
+		t = outputType(nil, o)
+	}
+	return encodePixels(img, t, o)
+}
+
+// storyboardCells lays out the frames of the urls param in rows of the
+// columns param, every cell having the requested width and height.
+func storyboardCells(o ImageOptions) ([]image.Rectangle, error) {
+	if len(o.URLs) == 0 {
+		return nil, NewError("Missing required param: urls", http.StatusBadRequest)
+	}
+	if err := checkURLs(o.URLs, MaxStoryboardFrames); err != nil {
+		return nil, err
+	}
+	if o.Width <= 0 || o.Height <= 0 {
+		return nil, NewError("Missing required params: height and width", http.StatusBadRequest)
+	}
+	if o.Columns < 0 {
+		return nil, NewError("Invalid param: columns must be positive", http.StatusBadRequest)
+	}
+
+	columns := o.Columns
+	if columns == 0 {
+		columns = DefaultStoryboardColumns
+	}
+	columns = min(columns, len(o.URLs))
+
+	cells := make([]image.Rectangle, 0, len(o.URLs))
+	for i := range o.URLs {
+		left, top := i%columns*o.Width, i/columns*o.Height
+		cells = append(cells, image.Rect(left, top, left+o.Width, top+o.Height))
+	}
+	return cells, nil
+}
+
+// storyboardBounds returns the bounds of the sprite holding the cells.
+func storyboardBounds(cells []image.Rectangle) image.Rectangle {
+	var bounds image.Rectangle
+	for _, cell := range cells {
+		bounds = bounds.Union(cell)
+	}
+	return bounds
+}
+
+// storyboardTrack returns the WebVTT thumbnails track of the storyboard, a
+// cue per frame referencing its cell of the sprite with a media fragment.
+func storyboardTrack(sprite string, cells []image.Rectangle, interval float64) ([]byte, error) {
+	if interval < 0 {
+		return nil, NewError("Invalid param: interval must be positive", http.StatusBadRequest)
+	}
+	if interval == 0 {
+		interval = DefaultStoryboardInterval
+	}
+
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n")
+	for i, cell := range cells {
+		fmt.Fprintf(&out, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(float64(i)*interval), vttTimestamp(float64(i+1)*interval),
+			sprite, cell.Min.X, cell.Min.Y, cell.Dx(), cell.Dy())
+	}
+	return out.Bytes(), nil
+}
+
+// vttTimestamp formats the seconds as a WebVTT hh:mm:ss.ttt timestamp.
+func vttTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
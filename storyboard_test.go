@@ -0,0 +1,160 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStoryboardCells(t *testing.T) {
+	urls := []string{"a", "b", "c", "d", "e"}
+	cells, err := storyboardCells(ImageOptions{URLs: urls, Width: 160, Height: 90, Columns: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []image.Rectangle{
+		image.Rect(0, 0, 160, 90), image.Rect(160, 0, 320, 90), image.Rect(320, 0, 480, 90),
+		image.Rect(0, 90, 160, 180), image.Rect(160, 90, 320, 180),
+	}
+	if !reflect.DeepEqual(cells, expected) {
+		t.Errorf("Invalid cells: %v", cells)
+	}
+	if bounds := storyboardBounds(cells); bounds != image.Rect(0, 0, 480, 180) {
+		t.Errorf("Invalid bounds: %v", bounds)
+	}
+
+	// Columns default to 10, and to the number of frames when fewer
+	cells, _ = storyboardCells(ImageOptions{URLs: urls, Width: 160, Height: 90})
+	if storyboardBounds(cells) != image.Rect(0, 0, 800, 90) {
+		t.Errorf("Invalid default columns: %v", cells)
+	}
+
+	invalid := []ImageOptions{
+		{Width: 160, Height: 90},
+		{URLs: urls, Width: 160},
+		{URLs: urls, Width: 160, Height: 90, Columns: -1},
+		{URLs: make([]string, MaxStoryboardFrames+1), Width: 160, Height: 90},
+	}
+	for _, opts := range invalid {
+		if _, err := storyboardCells(opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestStoryboardTrack(t *testing.T) {
+	cells := []image.Rectangle{image.Rect(0, 0, 160, 90), image.Rect(160, 0, 320, 90)}
+	track, err := storyboardTrack("sprite.jpg", cells, 2.5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "WEBVTT\n" +
+		"\n00:00:00.000 --> 00:00:02.500\nsprite.jpg#xywh=0,0,160,90\n" +
+		"\n00:00:02.500 --> 00:00:05.000\nsprite.jpg#xywh=160,0,160,90\n"
+	if string(track) != expected {
+		t.Errorf("Invalid track:\n%s", track)
+	}
+
+	if _, err := storyboardTrack("sprite.jpg", cells, -1); err == nil {
+		t.Error("Expected an error with a negative interval")
+	}
+}
+
+func TestVTTTimestamp(t *testing.T) {
+	cases := map[float64]string{
+		0:       "00:00:00.000",
+		9.5:     "00:00:09.500",
+		3725.25: "01:02:05.250",
+	}
+	for seconds, expected := range cases {
+		if actual := vttTimestamp(seconds); actual != expected {
+			t.Errorf("Invalid timestamp of %f: %s", seconds, actual)
+		}
+	}
+}
+
+func TestStoryboard(t *testing.T) {
+	t.Cleanup(func() { LoadSources(ServerOptions{}) })
+	LoadSources(ServerOptions{EnableURLSource: true})
+
+	tsImage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		buf, _ := os.ReadFile("testdata/imaginary.jpg")
+		_, _ = w.Write(buf)
+	}))
+	defer tsImage.Close()
+
+	urls := `["` + tsImage.URL + `","` + tsImage.URL + `","` + tsImage.URL + `"]`
+	query := url.Values{"urls": {urls}, "width": {"160"}, "height": {"90"}, "columns": {"2"}}
+	controller := storyboardController(ServerOptions{MaxAllowedPixels: 18.0})
+
+	res := httptest.NewRecorder()
+	controller(res, httptest.NewRequest(http.MethodGet, "/storyboard?"+query.Encode(), nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("Invalid response status: %d, %s", res.Code, res.Body.String())
+	}
+	if res.Header().Get(ContentType) != ImageJPEG {
+		t.Error(InvalidMimeType)
+	}
+	if err := assertSize(res.Body.Bytes(), 320, 180); err != nil {
+		t.Error(err)
+	}
+
+	query.Set("type", VTT)
+	res = httptest.NewRecorder()
+	controller(res, httptest.NewRequest(http.MethodGet, "/storyboard?"+query.Encode(), nil))
+	if res.Code != http.StatusOK || res.Header().Get(ContentType) != ContentTypeVTT {
+		t.Fatalf("Invalid track response: %d, %s", res.Code, res.Body.String())
+	}
+	query.Del("type")
+	sprite := "storyboard?" + query.Encode() + "#xywh=0,90,160,90"
+	if !strings.HasPrefix(res.Body.String(), "WEBVTT\n") || !strings.Contains(res.Body.String(), sprite) {
+		t.Errorf("Invalid track:\n%s", res.Body.String())
+	}
+
+	// The sprite URL is signed again without the type param
+	signed := storyboardController(ServerOptions{MaxAllowedPixels: 18.0, EnableURLSignature: true, URLSignatureKey: "secret"})
+	query.Set("type", VTT)
+	query.Set("sign", "track-signature")
+	res = httptest.NewRecorder()
+	signed(res, httptest.NewRequest(http.MethodGet, "/storyboard?"+query.Encode(), nil))
+	query.Del("type")
+	query.Del("sign")
+	query.Set("sign", base64.RawURLEncoding.EncodeToString(urlSignature("/storyboard", query, "secret")))
+	if sprite := "storyboard?" + query.Encode() + "#"; !strings.Contains(res.Body.String(), sprite) {
+		t.Errorf("Expected the sprite URL to be signed:\n%s", res.Body.String())
+	}
+	query.Del("sign")
+
+	query.Set("width", "4000")
+	query.Set("height", "3000")
+	res = httptest.NewRecorder()
+	controller(res, httptest.NewRequest(http.MethodGet, "/storyboard?"+query.Encode(), nil))
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a too big sprite to be rejected: %d", res.Code)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// subsampledOutputType returns the JPEG or WebP output type requested,
+// explicitly or keeping the input type, with the chroma param, bimg not
+// supporting the chroma subsampling of these formats. UNKNOWN is returned
+// otherwise.
+func subsampledOutputType(buf []byte, opts ImageOptions) bimg.ImageType {
+	if opts.Chroma == "" {
+		return bimg.UNKNOWN
+	}
+
+	t := ImageType(opts.Type)
+	if opts.Type == "" {
+		t = bimg.DetermineImageType(buf)
+	}
+	if t != bimg.JPEG && t != bimg.WEBP {
+		return bimg.UNKNOWN
+	}
+	return t
+}
+
+// EncodeSubsampled encodes the PNG image produced by the operation as JPEG or
+// WebP with the requested chroma subsampling. Other outputs, such as JSON
+// ones, are returned as is.
+//
+// Lossy WebP images are always 4:2:0, so chroma=420 selects the sharp RGB to
+// YUV conversion, keeping colored edges, such as red text, crisp, while
+// chroma=444 requires lossless ones.
+func EncodeSubsampled(img Image, t bimg.ImageType, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	subsample, err := chromaSubsample(o.Chroma)
+	if err != nil {
+		return Image{}, err
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the formats encoded by bimg
+		quality = bimg.Quality
+	}
+
+	if t == bimg.WEBP && subsample == subsampleOff && !o.Lossless {
+		return Image{}, ErrLossyWebP444
+	}
+
+	var body []byte
+	if t == bimg.WEBP {
+		body, err = saveWebP(img.Body, webpOptions{
+			Quality:  quality,
+			Lossless: o.Lossless,
+			SharpYUV: subsample == subsampleOn,
+			Strip:    o.StripMetadata,
+		})
+	} else {
+		body, err = saveJPEG(img.Body, jpegOptions{
+			Quality:   quality,
+			Subsample: subsample,
+			Interlace: o.Interlace,
+			Strip:     o.StripMetadata,
+		})
+	}
+	if err != nil {
+		name := bimg.ImageTypeName(t)
+		return Image{}, NewError("Cannot encode "+strings.ToUpper(name)+" image: "+err.Error(), http.StatusBadRequest)
+	}
+	return Image{Body: body, Mime: GetImageMimeType(t), Header: img.Header}, nil
+}
@@ -0,0 +1,85 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestSubsampledOutputType(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+	cases := []struct {
+		buf      []byte
+		opts     ImageOptions
+		expected bimg.ImageType
+	}{
+		{jpeg, ImageOptions{Type: JPEG}, bimg.UNKNOWN},
+		{png, ImageOptions{Type: JPEG, Chroma: Chroma444}, bimg.JPEG},
+		{png, ImageOptions{Type: WebP, Chroma: Chroma444}, bimg.WEBP},
+		{jpeg, ImageOptions{Chroma: Chroma444}, bimg.JPEG},
+		{png, ImageOptions{Chroma: Chroma444}, bimg.UNKNOWN},
+		{jpeg, ImageOptions{Type: AVIF, Chroma: Chroma444}, bimg.UNKNOWN},
+	}
+
+	for _, c := range cases {
+		if actual := subsampledOutputType(c.buf, c.opts); actual != c.expected {
+			t.Errorf("Invalid output type with %+v: %s", c.opts, bimg.ImageTypeName(actual))
+		}
+	}
+}
+
+func TestEncodeSubsampled(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	cases := []struct {
+		outputType bimg.ImageType
+		opts       ImageOptions
+	}{
+		{bimg.JPEG, ImageOptions{Chroma: Chroma444}},
+		{bimg.JPEG, ImageOptions{Chroma: Chroma420}},
+		{bimg.WEBP, ImageOptions{Chroma: Chroma420}},
+		{bimg.WEBP, ImageOptions{Chroma: Chroma444, Lossless: true}},
+	}
+	for _, c := range cases {
+		outputType := c.outputType
+		img, err := EncodeSubsampled(Image{Body: buf, Mime: ImagePNG}, outputType, c.opts)
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if img.Mime != GetImageMimeType(outputType) || bimg.DetermineImageType(img.Body) != outputType {
+			t.Error(InvalidMimeType)
+		}
+	}
+
+	if _, err := EncodeSubsampled(Image{Body: buf, Mime: ImagePNG}, bimg.JPEG, ImageOptions{Chroma: "422"}); err == nil {
+		t.Error("Expected an error with an unsupported chroma")
+	}
+	if _, err := EncodeSubsampled(Image{Body: buf, Mime: ImagePNG}, bimg.WEBP, ImageOptions{Chroma: Chroma444}); err != ErrLossyWebP444 {
+		t.Errorf("Expected lossy 444 WebP images to be rejected, got %v", err)
+	}
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	img, err := EncodeSubsampled(json, bimg.JPEG, ImageOptions{Chroma: Chroma444})
+	if err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Surrogate keys supported by -surrogate-keys.
+const (
+	SurrogateKeyHash   = "hash"
+	SurrogateKeyHost   = "host"
+	SurrogateKeyTenant = "tenant"
+)
+
+// DefaultSurrogateKeys is the default -surrogate-keys value.
+const DefaultSurrogateKeys = "hash,host,tenant"
+
+// SurrogateKeyHeader is the Fastly header, whose keys are space separated.
+// Other CDNs, such as Cloudflare with Cache-Tag, expect comma separated keys.
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// isSurrogateKey reports whether the name is a supported surrogate key.
+func isSurrogateKey(name string) bool {
+	return name == SurrogateKeyHash || name == SurrogateKeyHost || name == SurrogateKeyTenant
+}
+
+// surrogateKeys derives the CDN cache tags of the image from its source, so
+// every derivative of a source can be purged at once.
+func surrogateKeys(r *http.Request, buf []byte, o ServerOptions) []string {
+	query := r.URL.Query()
+
+	keys := make([]string, 0, len(o.SurrogateKeys))
+	for _, name := range o.SurrogateKeys {
+		switch name {
+		case SurrogateKeyHash:
+			keys = append(keys, "src-"+sourceHash(query, buf))
+		case SurrogateKeyHost:
+			if u, err := url.Parse(query.Get(URLQueryKey)); err == nil && u.Hostname() != "" {
+				keys = append(keys, "host-"+strings.ToLower(u.Hostname()))
+			}
+		case SurrogateKeyTenant:
+			keys = append(keys, "tenant-"+requestTenant(r, o))
+		}
+	}
+	return keys
+}
+
+// sourceHash identifies the source by its URL or file path, so the key to
+// purge can be computed from the original location, or by its content for
+// uploads.
+func sourceHash(query url.Values, buf []byte) string {
+	var sum [sha256.Size]byte
+	if src := query.Get(URLQueryKey); src != "" {
+		sum = sha256.Sum256([]byte(src))
+	} else if file := query.Get("file"); file != "" {
+		sum = sha256.Sum256([]byte(file))
+	} else {
+		sum = sha256.Sum256(buf)
+	}
+	return hex.EncodeToString(sum[:8])
+}
+
+// setSurrogateKeys sets the surrogate keys header of the response.
+func setSurrogateKeys(w http.ResponseWriter, r *http.Request, buf []byte, o ServerOptions) {
+	keys := surrogateKeys(r, buf, o)
+	if len(keys) == 0 {
+		return
+	}
+
+	sep := ","
+	if strings.EqualFold(o.SurrogateKeyHeader, SurrogateKeyHeader) {
+		sep = " "
+	}
+	w.Header().Set(o.SurrogateKeyHeader, strings.Join(keys, sep))
+}
@@ -0,0 +1,62 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSurrogateKeys(t *testing.T) {
+	o := ServerOptions{SurrogateKeys: []string{SurrogateKeyHash, SurrogateKeyHost, SurrogateKeyTenant}}
+
+	cases := []struct {
+		header   string
+		target   string
+		expected string
+	}{
+		{
+			"Surrogate-Key",
+			"/resize?url=https://Images.example.com/photo.jpg",
+			"src-3ffedb6363a91139 host-images.example.com tenant-anonymous",
+		},
+		{
+			"Cache-Tag",
+			"/resize?file=photo.jpg",
+			"src-aff6100bd4df0ea6,tenant-anonymous",
+		},
+	}
+
+	for _, c := range cases {
+		o.SurrogateKeyHeader = c.header
+		res := httptest.NewRecorder()
+		setSurrogateKeys(res, httptest.NewRequest(http.MethodGet, c.target, nil), []byte("image"), o)
+		if actual := res.Header().Get(c.header); actual != c.expected {
+			t.Errorf("Invalid %s header: %q != %q", c.header, actual, c.expected)
+		}
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/resize", nil)
+	if sourceHash(req.URL.Query(), []byte("a")) == sourceHash(req.URL.Query(), []byte("b")) {
+		t.Error("Expected uploads to be identified by their content")
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/h2non/bimg"
+)
+
+const (
+	TIFF      = "tiff"
+	ImageTIFF = "image/tiff"
+)
+
+// TIFF compression values.
+const (
+	TIFFCompressionNone    = "none"
+	TIFFCompressionLZW     = "lzw"
+	TIFFCompressionDeflate = "deflate"
+	TIFFCompressionJPEG    = "jpeg"
+)
+
+// TIFF predictor values.
+const (
+	TIFFPredictorNone       = "none"
+	TIFFPredictorHorizontal = "horizontal"
+	TIFFPredictorFloat      = "float"
+)
+
+// libvips VipsForeignTiffCompression values.
+var tiffCompressions = map[string]int{
+	TIFFCompressionNone:    0,
+	TIFFCompressionJPEG:    1,
+	TIFFCompressionDeflate: 2,
+	TIFFCompressionLZW:     5,
+}
+
+// libvips VipsForeignTiffPredictor values.
+var tiffPredictors = map[string]int{
+	TIFFPredictorNone:       1,
+	TIFFPredictorHorizontal: 2,
+	TIFFPredictorFloat:      3,
+}
+
+// isTunedTIFFOutput reports whether TIFF output is requested with the
+// compression options bimg does not support, so the image is encoded by
+// imaginary.
+func isTunedTIFFOutput(opts ImageOptions) bool {
+	return ImageType(opts.Type) == bimg.TIFF && (opts.TIFFCodec != "" || opts.Predictor != "")
+}
+
+// EncodeTIFF encodes the PNG image produced by the operation as TIFF with the
+// requested compression and predictor. Other outputs, such as JSON ones, are
+// returned as is.
+func EncodeTIFF(img Image, o ImageOptions) (Image, error) {
+	if img.Mime != ImagePNG {
+		return img, nil
+	}
+
+	compression, predictor, err := tiffCompression(o.TIFFCodec, o.Predictor)
+	if err != nil {
+		return Image{}, err
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the formats encoded by bimg
+		quality = bimg.Quality
+	}
+
+	body, err := saveTIFF(img.Body, tiffOptions{
+		Compression: compression,
+		Predictor:   predictor,
+		Quality:     quality,
+		Strip:       o.StripMetadata,
+	})
+	if err != nil {
+		return Image{}, NewError("Cannot encode TIFF image: "+err.Error(), http.StatusBadRequest)
+	}
+	return Image{Body: body, Mime: ImageTIFF, Header: img.Header}, nil
+}
+
+// tiffCompression returns the libvips compression and predictor of the
+// tiffcodec and predictor params. The predictor only applies to the
+// lossless LZW and deflate compressions, defaulting to the horizontal one.
+func tiffCompression(compression, predictor string) (int, int, error) {
+	if compression == "" {
+		compression = TIFFCompressionNone
+	}
+	value, ok := tiffCompressions[compression]
+	if !ok {
+		return 0, 0, NewError("Unsupported tiffcodec value. Allowed values are: none, lzw, deflate, jpeg",
+			http.StatusBadRequest)
+	}
+
+	if predictor == "" {
+		return value, tiffPredictors[TIFFPredictorHorizontal], nil
+	}
+	if compression != TIFFCompressionLZW && compression != TIFFCompressionDeflate {
+		return 0, 0, NewError("Invalid param: predictor requires the lzw or deflate tiffcodec", http.StatusBadRequest)
+	}
+	predictorValue, ok := tiffPredictors[predictor]
+	if !ok {
+		return 0, 0, NewError("Unsupported predictor value. Allowed values are: none, horizontal, float",
+			http.StatusBadRequest)
+	}
+	return value, predictorValue, nil
+}
+
+// @Summary Convert to multi-page TIFF
+// @Description Assembles the image, followed by the images of the urls param, into a multi-page TIFF
+// @Accept multipart/form-data
+// @Produce image/tiff
+// @Param file formData file true "Image file to process"
+// @Param urls query string false "JSON list of the URLs of the next pages images"
+// @Param tiffcodec query string false "Compression (none, lzw, deflate, jpeg)"
+// @Param predictor query string false "Predictor of the lzw and deflate compressions (none, horizontal, float)"
+// @Param quality query int false "Quality of the jpeg compression (1-100)"
+// @Success 200 {file} binary "Multi-page TIFF image"
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /totiff [post]
+func ToTIFF(buf []byte, o ImageOptions) (Image, error) {
+	compression, predictor, err := tiffCompression(o.TIFFCodec, o.Predictor)
+	if err != nil {
+		return Image{}, err
+	}
+	quality := o.Quality
+	if quality == 0 {
+		// Same default as the formats encoded by bimg
+		quality = bimg.Quality
+	}
+
+	if err := checkURLs(o.URLs, MaxNextPages); err != nil {
+		return Image{}, err
+	}
+
+	images := [][]byte{buf}
+	for i, url := range o.URLs {
+		image, err := loadLayer(CompositeLayer{URL: url})
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Unable to load page %d: %s", i+2, err), http.StatusBadRequest)
+		}
+		if err := validateLayerSize(image); err != nil {
+			xerr := toError(err)
+			xerr.Message = fmt.Sprintf("page %d: %s", i+2, xerr.Message)
+			return Image{}, xerr
+		}
+		images = append(images, image)
+	}
+
+	var width, height int
+	pages := make([][]byte, 0, len(images))
+	for i, image := range images {
+		page, err := fitPage(image, width, height)
+		if err != nil {
+			return Image{}, NewError(fmt.Sprintf("Cannot process page %d: %s", i+1, err), http.StatusBadRequest)
+		}
+		if i == 0 {
+			size, err := bimg.Size(page)
+			if err != nil {
+				return Image{}, NewError("Cannot process page 1: "+err.Error(), http.StatusBadRequest)
+			}
+			width, height = size.Width, size.Height
+		}
+		pages = append(pages, page)
+	}
+
+	body, err := saveTIFFPages(pages, tiffOptions{
+		Compression: compression,
+		Predictor:   predictor,
+		Quality:     quality,
+		Strip:       o.StripMetadata,
+	})
+	if err != nil {
+		return Image{}, NewError("Cannot encode TIFF image: "+err.Error(), http.StatusBadRequest)
+	}
+	return Image{Body: body, Mime: ImageTIFF}, nil
+}
+
+// fitPage renders the image of a TIFF page, or of an animation frame, as PNG,
+// auto rotated and fitted to the given size, if any, so every page shares the
+// size of the first one.
+func fitPage(buf []byte, width, height int) ([]byte, error) {
+	opts := bimg.Options{Type: bimg.PNG}
+	if width != 0 {
+		metadata, err := bimg.Metadata(buf)
+		if err != nil {
+			return nil, err
+		}
+		pageWidth, pageHeight := metadata.Size.Width, metadata.Size.Height
+		if metadata.Orientation > 4 {
+			// width/height will be switched with auto rotation
+			pageWidth, pageHeight = pageHeight, pageWidth
+		}
+		if pageWidth == 0 || pageHeight == 0 {
+			return nil, errors.New("width or height of the image is zero")
+		}
+		opts.Width, opts.Height = calculateDestinationFitDimension(pageWidth, pageHeight, width, height)
+		opts.Force = true
+	}
+
+	image, err := Process(buf, opts)
+	if err != nil {
+		return nil, err
+	}
+	return image.Body, nil
+}
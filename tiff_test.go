@@ -0,0 +1,162 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestIsTunedTIFFOutput(t *testing.T) {
+	cases := []struct {
+		opts     ImageOptions
+		expected bool
+	}{
+		{ImageOptions{Type: TIFF}, false},
+		{ImageOptions{Type: TIFF, TIFFCodec: TIFFCompressionLZW}, true},
+		{ImageOptions{Type: TIFF, Predictor: TIFFPredictorNone}, true},
+		{ImageOptions{Type: PNG, TIFFCodec: TIFFCompressionLZW}, false},
+	}
+
+	for _, c := range cases {
+		if isTunedTIFFOutput(c.opts) != c.expected {
+			t.Errorf("Expected %v with %+v", c.expected, c.opts)
+		}
+	}
+}
+
+func TestTIFFCompression(t *testing.T) {
+	cases := []struct {
+		compression, predictor string
+		expected               [2]int
+	}{
+		{"", "", [2]int{0, 2}},
+		{TIFFCompressionJPEG, "", [2]int{1, 2}},
+		{TIFFCompressionDeflate, TIFFPredictorFloat, [2]int{2, 3}},
+		{TIFFCompressionLZW, TIFFPredictorNone, [2]int{5, 1}},
+	}
+
+	for _, c := range cases {
+		compression, predictor, err := tiffCompression(c.compression, c.predictor)
+		if err != nil || compression != c.expected[0] || predictor != c.expected[1] {
+			t.Errorf("Invalid compression of %q and %q: %d, %d, %v", c.compression, c.predictor, compression, predictor, err)
+		}
+	}
+
+	invalid := [][2]string{{"zip", ""}, {TIFFCompressionLZW, "vertical"}, {TIFFCompressionJPEG, TIFFPredictorHorizontal}}
+	for _, c := range invalid {
+		if _, _, err := tiffCompression(c[0], c[1]); err == nil {
+			t.Errorf("Expected an error with %q and %q", c[0], c[1])
+		}
+	}
+}
+
+func TestEncodeTIFF(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	for _, compression := range []string{TIFFCompressionLZW, TIFFCompressionDeflate, TIFFCompressionJPEG} {
+		img, err := EncodeTIFF(Image{Body: buf, Mime: ImagePNG}, ImageOptions{TIFFCodec: compression})
+		if err != nil {
+			t.Fatalf(CannotProcessImageS, err)
+		}
+		if img.Mime != ImageTIFF || bimg.DetermineImageType(img.Body) != bimg.TIFF {
+			t.Error(InvalidMimeType)
+		}
+	}
+
+	json := Image{Body: []byte("{}"), Mime: ContentTypeJSON}
+	img, err := EncodeTIFF(json, ImageOptions{TIFFCodec: TIFFCompressionLZW})
+	if err != nil || img.Mime != ContentTypeJSON {
+		t.Error("Expected JSON outputs to be returned as is")
+	}
+}
+
+func TestTIFFPages(t *testing.T) {
+	png, _ := io.ReadAll(readFile("test.png"))
+	jpeg, _ := io.ReadAll(readFile("large.jpg"))
+
+	first, err := fitPage(png, 0, 0)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	size, _ := bimg.Size(first)
+	second, err := fitPage(jpeg, size.Width, size.Height)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+
+	opts := tiffOptions{
+		Compression: tiffCompressions[TIFFCompressionLZW],
+		Predictor:   tiffPredictors[TIFFPredictorHorizontal],
+	}
+	body, err := saveTIFFPages([][]byte{first, second}, opts)
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	// The second page is fitted and centered on a page of the first page size
+	loaded, err := loadedSize(body, ImageOptions{Pages: AllPages})
+	if err != nil || loaded.Width != size.Width || loaded.Height != 2*size.Height {
+		t.Errorf("Invalid pages size: %+v, %v", loaded, err)
+	}
+}
+
+func TestToTIFF(t *testing.T) {
+	buf, _ := io.ReadAll(readFile("test.png"))
+
+	img, err := ToTIFF(buf, ImageOptions{TIFFCodec: TIFFCompressionDeflate})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ImageTIFF || bimg.DetermineImageType(img.Body) != bimg.TIFF {
+		t.Error(InvalidMimeType)
+	}
+
+	invalid := []ImageOptions{{TIFFCodec: "zip"}, {URLs: []string{"http://localhost/a.jpg"}}}
+	for _, opts := range invalid {
+		if _, err := ToTIFF(buf, opts); err == nil {
+			t.Errorf("Expected an error with %+v", opts)
+		}
+	}
+}
+
+func TestToTIFFPagesLimits(t *testing.T) {
+	t.Cleanup(func() {
+		LoadSources(ServerOptions{})
+		LoadLayerLimits(ServerOptions{MaxAllowedPixels: DefaultMaxAllowedPixels})
+	})
+	LoadSources(ServerOptions{EnableURLSource: true})
+	LoadLayerLimits(ServerOptions{MaxAllowedPixels: 0.1})
+
+	tsImage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		buf, _ := os.ReadFile("testdata/imaginary.jpg")
+		_, _ = w.Write(buf)
+	}))
+	defer tsImage.Close()
+
+	buf, _ := io.ReadAll(readFile("test.png"))
+	_, err := ToTIFF(buf, ImageOptions{URLs: []string{tsImage.URL}})
+	if xerr, ok := err.(Error); !ok || xerr.Code != ErrResolutionTooBig.Code || xerr.Message != "page 2: "+ErrResolutionTooBig.Message {
+		t.Errorf("Expected the pages above the resolution limit to be rejected, got %v", err)
+	}
+}
@@ -46,6 +46,10 @@ func IsImageMimeTypeSupported(mime string) bool {
 	if format == "xml" {
 		format = SVG
 	}
+	// HEIC images are HEIF ones using the HEVC codec
+	if format == "heic" {
+		format = HEIF
+	}
 
 	return bimg.IsTypeNameSupported(format)
 }
@@ -57,6 +61,8 @@ func ImageType(name string) bimg.ImageType {
 		return bimg.AVIF
 	case "gif":
 		return bimg.GIF
+	case HEIF, "heic":
+		return bimg.HEIF
 	case "jpeg":
 		return bimg.JPEG
 	case "pdf":
@@ -81,6 +87,8 @@ func GetImageMimeType(code bimg.ImageType) string {
 		return "image/avif"
 	case bimg.GIF:
 		return "image/gif"
+	case bimg.HEIF:
+		return ImageHEIF
 	case bimg.PDF:
 		return "application/pdf"
 	case bimg.PNG:
@@ -95,3 +103,17 @@ func GetImageMimeType(code bimg.ImageType) string {
 		return "image/jpeg"
 	}
 }
+
+// GetImageExtension returns the file name extension for the given MIME type.
+func GetImageExtension(mime string) string {
+	switch mime {
+	case ContentTypeOctetStream:
+		return RAW
+	case ContentTypeNPY:
+		return NPY
+	case ContentTypeICO:
+		return ICO
+	default:
+		return ExtractImageTypeFromMime(mime)
+	}
+}
@@ -64,6 +64,8 @@ func TestIsImageTypeSupported(t *testing.T) {
 		{"image/svg", bimg.IsImageTypeSupportedByVips(bimg.SVG).Load},
 		{"image/tiff", bimg.IsImageTypeSupportedByVips(bimg.TIFF).Load},
 		{"application/pdf", bimg.IsImageTypeSupportedByVips(bimg.PDF).Load},
+		{ImageHEIF, bimg.IsImageTypeSupportedByVips(bimg.HEIF).Load},
+		{"image/heic", bimg.IsImageTypeSupportedByVips(bimg.HEIF).Load},
 		{"text/plain", false},
 		{"blablabla", false},
 		{"", false},
@@ -89,6 +91,8 @@ func TestImageType(t *testing.T) {
 		{"gif", bimg.GIF},
 		{SVG, bimg.SVG},
 		{"pdf", bimg.PDF},
+		{HEIF, bimg.HEIF},
+		{"heic", bimg.HEIF},
 		{multipartFormData, bimg.UNKNOWN},
 		{"json", bimg.UNKNOWN},
 		{"text", bimg.UNKNOWN},
@@ -116,6 +120,7 @@ func TestGetImageMimeType(t *testing.T) {
 		{bimg.GIF, "image/gif"},
 		{bimg.PDF, "application/pdf"},
 		{bimg.SVG, ImageSVG},
+		{bimg.HEIF, ImageHEIF},
 		{bimg.UNKNOWN, ImageJPEG},
 	}
 
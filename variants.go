@@ -0,0 +1,164 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/h2non/bimg"
+)
+
+// MaxVariants is the maximum number of widths rendered by a single request.
+const MaxVariants = 10
+
+var (
+	ErrMissingWidths = NewError("Missing required param: widths", http.StatusBadRequest)
+	ErrTooManyWidths = NewError(fmt.Sprintf("Maximum allowed widths exceeded, up to %d are accepted", MaxVariants),
+		http.StatusBadRequest)
+)
+
+// Variant describes a rendition of the variants manifest.
+type Variant struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Type   string `json:"type"`
+	Size   int    `json:"size"`
+}
+
+// VariantsManifest is the reply of the variants endpoint when the manifest
+// param is set.
+type VariantsManifest struct {
+	Variants []Variant `json:"variants"`
+	Srcset   string    `json:"srcset"`
+}
+
+// @Summary Responsive variants
+// @Description Renders the image at several widths from a single decoding of the source, returned as a ZIP archive or a JSON manifest
+// @Accept multipart/form-data
+// @Produce application/zip
+// @Produce json
+// @Param file formData file true "Image file to render"
+// @Param widths query string true "Comma separated list of the widths to render"
+// @Param type query string false "Output type of the renditions"
+// @Param manifest query bool false "Return the JSON manifest of the renditions instead of the archive"
+// @Success 200 {object} VariantsManifest
+// @Failure 400 {object} Error "Bad request"
+// @Failure 404 {object} Error "Not found"
+// @Failure 401 {object} Error "Unauthorized"
+// @Failure 422 {object} Error "Unprocessable entity"
+// @Router /variants [post]
+func Variants(buf []byte, o ImageOptions) (Image, error) {
+	if len(o.Widths) == 0 {
+		return Image{}, ErrMissingWidths
+	}
+	widths := slices.Compact(slices.Sorted(slices.Values(o.Widths)))
+	if len(widths) > MaxVariants {
+		return Image{}, ErrTooManyWidths
+	}
+
+	outputType := outputTypeName(buf, o)
+	// The source is decoded once, at the largest width, into a lossless
+	// image the renditions are resized from.
+	source, err := Process(buf, bimg.Options{Width: widths[len(widths)-1], Type: bimg.PNG, NoAutoRotate: o.NoRotation})
+	if err != nil {
+		return Image{}, err
+	}
+
+	manifest := VariantsManifest{Variants: make([]Variant, 0, len(widths))}
+	renditions := make([]Image, 0, len(widths))
+	srcset := make([]string, 0, len(widths))
+	for _, width := range widths {
+		image, err := applyOperation(Resize, source.Body, variantOptions(o, width, outputType))
+		if err != nil {
+			return Image{}, err
+		}
+
+		variant := Variant{
+			Name:  strconv.Itoa(width) + "w." + GetImageExtension(image.Mime),
+			Width: width,
+			Type:  image.Mime,
+			Size:  len(image.Body),
+		}
+		if size, err := bimg.Size(image.Body); err == nil {
+			variant.Width, variant.Height = size.Width, size.Height
+		}
+		manifest.Variants = append(manifest.Variants, variant)
+		renditions = append(renditions, image)
+		srcset = append(srcset, fmt.Sprintf("%s %dw", variant.Name, variant.Width))
+	}
+
+	if o.Manifest {
+		manifest.Srcset = strings.Join(srcset, ", ")
+		body, _ := json.Marshal(manifest)
+		return Image{Body: body, Mime: ContentTypeJSON}, nil
+	}
+
+	var archive bytes.Buffer
+	zw, mimeType, err := NewArchiveWriter(&archive, ArchiveZip)
+	if err != nil {
+		return Image{}, err
+	}
+	for i, image := range renditions {
+		if err := zw.WriteFile(manifest.Variants[i].Name, image.Mime, image.Body); err != nil {
+			return Image{}, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return Image{}, err
+	}
+	return Image{
+		Body:   archive.Bytes(),
+		Mime:   mimeType,
+		Header: http.Header{"Content-Disposition": {`attachment; filename="variants.zip"`}},
+	}, nil
+}
+
+// variantOptions returns the options of the rendition of the given width,
+// the source being already oriented and resized by the variants endpoint.
+func variantOptions(o ImageOptions, width int, outputType string) ImageOptions {
+	opts := o
+	opts.Width = width
+	opts.Height = 0
+	opts.Mode = ""
+	opts.Widths = nil
+	opts.Manifest = false
+	opts.Type = outputType
+	opts.NoRotation = true
+	return opts
+}
+
+// parseWidths parses a comma separated list of widths.
+func parseWidths(val string) ([]int, error) {
+	var widths []int
+	for _, chunk := range strings.Split(val, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(chunk))
+		if err != nil || width <= 0 {
+			return nil, ErrUnsupportedValue
+		}
+		widths = append(widths, width)
+	}
+	return widths, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/h2non/bimg"
+)
+
+func TestParseWidths(t *testing.T) {
+	widths, err := parseWidths("320, 640,1280")
+	if err != nil || len(widths) != 3 || widths[0] != 320 || widths[2] != 1280 {
+		t.Errorf("Invalid widths: %v, %v", widths, err)
+	}
+
+	for _, val := range []string{"", "320,abc", "0", "-320"} {
+		if _, err := parseWidths(val); err == nil {
+			t.Errorf("Expected an error for %q", val)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Variants(buf, ImageOptions{Widths: []int{200, 100, 200}, Type: WebP})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != "application/zip" {
+		t.Error(InvalidMimeType)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(img.Body), int64(len(img.Body)))
+	if err != nil {
+		t.Fatalf("Cannot read archive: %s", err)
+	}
+	if len(archive.File) != 2 || archive.File[0].Name != "100w.webp" || archive.File[1].Name != "200w.webp" {
+		t.Fatalf("Invalid archive entries: %v", archive.File)
+	}
+
+	f, _ := archive.File[1].Open()
+	rendition, _ := io.ReadAll(f)
+	if bimg.DetermineImageType(rendition) != bimg.WEBP {
+		t.Error("Expected a WebP rendition")
+	}
+	if size, _ := bimg.Size(rendition); size.Width != 200 {
+		t.Errorf("Invalid rendition width: %d", size.Width)
+	}
+}
+
+func TestVariantsManifest(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	img, err := Variants(buf, ImageOptions{Widths: []int{100, 200}, Manifest: true})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ContentTypeJSON {
+		t.Error(InvalidMimeType)
+	}
+
+	var manifest VariantsManifest
+	if err := json.Unmarshal(img.Body, &manifest); err != nil {
+		t.Fatalf("Cannot decode manifest: %s", err)
+	}
+	if len(manifest.Variants) != 2 || manifest.Variants[0].Type != ImageJPEG || manifest.Variants[0].Size == 0 {
+		t.Fatalf("Invalid variants: %+v", manifest.Variants)
+	}
+	if manifest.Srcset != "100w.jpeg 100w, 200w.jpeg 200w" {
+		t.Errorf("Invalid srcset: %s", manifest.Srcset)
+	}
+}
+
+func TestVariantsErrors(t *testing.T) {
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+
+	if _, err := Variants(buf, ImageOptions{}); err != ErrMissingWidths {
+		t.Errorf("Expected missing widths error, got %v", err)
+	}
+	widths := make([]int, MaxVariants+1)
+	for i := range widths {
+		widths[i] = i + 1
+	}
+	if _, err := Variants(buf, ImageOptions{Widths: widths}); err != ErrTooManyWidths {
+		t.Errorf("Expected too many widths error, got %v", err)
+	}
+}
+
+func TestVariantsEncoding(t *testing.T) {
+	if !encodesOutput(Variants) || encodesOutput(Resize) {
+		t.Fatal("Expected only Variants to encode its outputs itself")
+	}
+
+	// The widths param doesn't skip the encoding of the other operations
+	buf, _ := io.ReadAll(readFile(ImaginaryJpeg))
+	img, err := applyOperation(Resize, buf, ImageOptions{Width: 64, Widths: []int{100}, Type: ICO})
+	if err != nil {
+		t.Fatalf(CannotProcessImageS, err)
+	}
+	if img.Mime != ContentTypeICO {
+		t.Errorf("Expected an ICO image, got %s", img.Mime)
+	}
+}
@@ -0,0 +1,729 @@
+/*
+ * SPDX-License-Identifier: AGPL-3.0-only
+ *
+ * Copyright (c) 2025 sycured
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, version 3.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package main
+
+/*
+#cgo pkg-config: vips
+#include <stdlib.h>
+#include <vips/vips.h>
+
+static int
+load_png_buffer(void *buf, size_t len, const char *options, const char *cmyk_profile, void **out, size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, options, NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	// CMYK images are converted with their embedded profile, or the fallback
+	// one, instead of the naive conversion of the PNG saver
+	if (vips_image_guess_interpretation(image) == VIPS_INTERPRETATION_CMYK) {
+		VipsImage *srgb;
+		if (vips_icc_transform(image, &srgb, "srgb", "input_profile", cmyk_profile, "embedded", 1, NULL) != 0) {
+			g_object_unref(image);
+			return -1;
+		}
+		g_object_unref(image);
+		image = srgb;
+	}
+
+	int code = vips_pngsave_buffer(image, out, out_len, "compression", 0, NULL);
+	g_object_unref(image);
+	return code;
+}
+
+// resize_buffer resizes the image with the given kernel, once rotated upright
+// if requested. With premultiply, its color channels are premultiplied by the
+// alpha one, so the color of the transparent pixels, often black, doesn't
+// bleed into the visible ones.
+static int
+resize_buffer(void *buf, size_t len, double hscale, double vscale, int kernel, int premultiply, int autorotate,
+	void **out, size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	if (autorotate) {
+		VipsImage *rotated;
+		if (vips_autorot(image, &rotated, NULL) != 0) {
+			g_object_unref(image);
+			return -1;
+		}
+		g_object_unref(image);
+		image = rotated;
+	}
+
+	VipsImage *t[4] = {NULL, NULL, NULL, NULL};
+	int code;
+	if (premultiply) {
+		code = vips_premultiply(image, &t[0], NULL) ||
+			vips_resize(t[0], &t[1], hscale, "vscale", vscale, "kernel", kernel, NULL) ||
+			vips_unpremultiply(t[1], &t[2], NULL) ||
+			vips_cast(t[2], &t[3], vips_image_get_format(image), NULL) ||
+			vips_pngsave_buffer(t[3], out, out_len, "compression", 0, NULL);
+	} else {
+		code = vips_resize(image, &t[0], hscale, "vscale", vscale, "kernel", kernel, NULL) ||
+			vips_pngsave_buffer(t[0], out, out_len, "compression", 0, NULL);
+	}
+	for (int i = 0; i < 4; i++) {
+		if (t[i] != NULL) {
+			g_object_unref(t[i]);
+		}
+	}
+	g_object_unref(image);
+	return code ? -1 : 0;
+}
+
+static int
+heifsave_buffer(void *buf, size_t len, int quality, int lossless, int compression, int effort, int bitdepth,
+	int subsample, int strip, void **out, size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+#if VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 13)
+	// A zero bitdepth ends the options, keeping the libvips default
+	int code = vips_heifsave_buffer(image, out, out_len,
+		"Q", quality,
+		"lossless", lossless,
+		"compression", compression,
+		"effort", effort,
+		"subsample_mode", subsample,
+		"strip", strip,
+		bitdepth > 0 ? "bitdepth" : NULL, bitdepth,
+		NULL);
+#else
+	vips_error("heifsave", "effort, bitdepth and chroma require libvips 8.13 or later");
+	int code = -1;
+#endif
+	g_object_unref(image);
+	return code;
+}
+
+static int
+pngsave_buffer(void *buf, size_t len, int quality, int bitdepth, double dither, int compression, int interlace,
+	int strip, void **out, size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+#if VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 10)
+	int code = vips_pngsave_buffer(image, out, out_len,
+		"palette", 1,
+		"Q", quality,
+		"bitdepth", bitdepth,
+		"dither", dither,
+		"compression", compression,
+		"interlace", interlace,
+		"strip", strip,
+		NULL);
+#else
+	vips_error("pngsave", "colors and dither require libvips 8.10 or later");
+	int code = -1;
+#endif
+	g_object_unref(image);
+	return code;
+}
+
+static int
+tiffsave_buffer(void *buf, size_t len, int compression, int predictor, int quality, int strip, void **out,
+	size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	int code = vips_tiffsave_buffer(image, out, out_len,
+		"compression", compression,
+		"predictor", predictor,
+		"Q", quality,
+		"strip", strip,
+		NULL);
+	g_object_unref(image);
+	return code;
+}
+
+// join_pages stacks the pages vertically, smaller ones being centered on
+// white, setting the page height of the multi-page image.
+static int
+join_pages(void **bufs, size_t *lens, int count, VipsImage **out) {
+	VipsImage **pages = g_new0(VipsImage *, count);
+	VipsArrayDouble *background = NULL;
+	int code = -1;
+
+	for (int i = 0; i < count; i++) {
+		pages[i] = vips_image_new_from_buffer(bufs[i], lens[i], "", NULL);
+		if (pages[i] == NULL) {
+			goto done;
+		}
+	}
+
+	background = vips_array_double_newv(1, 255.0);
+	code = vips_arrayjoin(pages, out, count,
+		"across", 1,
+		"halign", VIPS_ALIGN_CENTRE,
+		"valign", VIPS_ALIGN_CENTRE,
+		"background", background,
+		NULL);
+	if (code == 0) {
+		vips_image_set_int(*out, "page-height", vips_image_get_height(*out) / count);
+	}
+
+done:
+	if (background != NULL) {
+		vips_area_unref(VIPS_AREA(background));
+	}
+	for (int i = 0; i < count; i++) {
+		if (pages[i] != NULL) {
+			g_object_unref(pages[i]);
+		}
+	}
+	g_free(pages);
+	return code;
+}
+
+static int
+tiffsave_pages(void **bufs, size_t *lens, int count, int compression, int predictor, int quality, int strip,
+	void **out, size_t *out_len) {
+	VipsImage *joined;
+	if (join_pages(bufs, lens, count, &joined)) {
+		return -1;
+	}
+
+	int code = vips_tiffsave_buffer(joined, out, out_len,
+		"compression", compression,
+		"predictor", predictor,
+		"Q", quality,
+		"strip", strip,
+		NULL);
+	g_object_unref(joined);
+	return code;
+}
+
+static int
+animsave_frames(void **bufs, size_t *lens, int count, int *delays, int loop, int webp, int quality, void **out,
+	size_t *out_len) {
+	VipsImage *joined;
+	if (join_pages(bufs, lens, count, &joined)) {
+		return -1;
+	}
+
+	vips_image_set_array_int(joined, "delay", delays, count);
+	vips_image_set_int(joined, "loop", loop);
+#if VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 12)
+	int code = webp ?
+		vips_webpsave_buffer(joined, out, out_len, "Q", quality, NULL) :
+		vips_gifsave_buffer(joined, out, out_len, NULL);
+#else
+	vips_error("animsave", "animations require libvips 8.12 or later");
+	int code = -1;
+#endif
+	g_object_unref(joined);
+	return code;
+}
+
+static int
+jpegsave_buffer(void *buf, size_t len, int quality, int subsample, int interlace, int strip, void **out,
+	size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+#if VIPS_MAJOR_VERSION > 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION >= 13)
+	int code = vips_jpegsave_buffer(image, out, out_len,
+		"Q", quality,
+		"optimize_coding", 1,
+		"subsample_mode", subsample,
+		"interlace", interlace,
+		"strip", strip,
+		NULL);
+#else
+	vips_error("jpegsave", "chroma requires libvips 8.13 or later");
+	int code = -1;
+#endif
+	g_object_unref(image);
+	return code;
+}
+
+static int
+webpsave_buffer(void *buf, size_t len, int quality, int lossless, int sharp_yuv, int strip, void **out,
+	size_t *out_len) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, "", NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	int code = vips_webpsave_buffer(image, out, out_len,
+		"Q", quality,
+		"lossless", lossless,
+		"smart_subsample", sharp_yuv,
+		"strip", strip,
+		NULL);
+	g_object_unref(image);
+	return code;
+}
+
+static int
+register_font(const char *fontfile) {
+	VipsImage *image;
+	if (vips_text(&image, ".", "fontfile", fontfile, NULL)) {
+		return -1;
+	}
+
+	g_object_unref(image);
+	return 0;
+}
+
+static int
+load_font(const char *font) {
+	VipsImage *image;
+	if (vips_text(&image, ".", "font", font, NULL)) {
+		return -1;
+	}
+
+	g_object_unref(image);
+	return 0;
+}
+
+static int
+load_size(void *buf, size_t len, const char *options, int *width, int *height) {
+	VipsImage *image = vips_image_new_from_buffer(buf, len, options, NULL);
+	if (image == NULL) {
+		return -1;
+	}
+
+	*width = vips_image_get_width(image);
+	*height = vips_image_get_height(image);
+	g_object_unref(image);
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+)
+
+// loadPNG decodes the image with libvips load options bimg does not expose,
+// such as "page=1,dpi=150", and encodes it as lossless PNG. CMYK images are
+// converted to sRGB with their embedded ICC profile, or the given one.
+func loadPNG(buf []byte, options, cmykProfile string) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	cOptions := C.CString(options)
+	defer C.free(unsafe.Pointer(cOptions))
+	cProfile := C.CString(cmykProfile)
+	defer C.free(unsafe.Pointer(cProfile))
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	if C.load_png_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), cOptions, cProfile, &out, &length) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// vipsKernels are the libvips resize kernels of the kernel param, lanczos3
+// being the libvips default.
+var vipsKernels = map[string]C.VipsKernel{
+	"":         C.VIPS_KERNEL_LANCZOS3,
+	"nearest":  C.VIPS_KERNEL_NEAREST,
+	"linear":   C.VIPS_KERNEL_LINEAR,
+	"cubic":    C.VIPS_KERNEL_CUBIC,
+	"mitchell": C.VIPS_KERNEL_MITCHELL,
+	"lanczos2": C.VIPS_KERNEL_LANCZOS2,
+	"lanczos3": C.VIPS_KERNEL_LANCZOS3,
+}
+
+// scaleOptions are the libvips resize options bimg does not expose.
+type scaleOptions struct {
+	HScale      float64
+	VScale      float64
+	Kernel      string
+	Premultiply bool
+	AutoRotate  bool
+}
+
+// resizeBuffer resizes the image by the horizontal and vertical scales with
+// the given options, and encodes it as lossless PNG.
+func resizeBuffer(buf []byte, o scaleOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	kernel, ok := vipsKernels[o.Kernel]
+	if !ok {
+		return nil, ErrUnsupportedKernel
+	}
+	premultiply, autorotate := C.int(0), C.int(0)
+	if o.Premultiply {
+		premultiply = 1
+	}
+	if o.AutoRotate {
+		autorotate = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	if C.resize_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.double(o.HScale), C.double(o.VScale),
+		C.int(kernel), premultiply, autorotate, &out, &length) != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// loadSize returns the size of the image loaded with the given libvips load
+// options, only reading its header.
+func loadSize(buf []byte, options string) (int, int, error) {
+	if len(buf) == 0 {
+		return 0, 0, errors.New("empty image buffer")
+	}
+
+	cOptions := C.CString(options)
+	defer C.free(unsafe.Pointer(cOptions))
+
+	var width, height C.int
+	//nolint:gosec // libvips reads the buffer without retaining it
+	if C.load_size(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), cOptions, &width, &height) != 0 {
+		return 0, 0, vipsError()
+	}
+	return int(width), int(height), nil
+}
+
+// registerFont makes the font file available to text rendering, libvips
+// adding it to the fontconfig fonts the first time it is used.
+func registerFont(path string) error {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	if C.register_font(cPath) != 0 {
+		return vipsError()
+	}
+	return nil
+}
+
+// loadFont renders a text with the Pango font description, Pango then
+// keeping the font loaded for the next renderings.
+func loadFont(font string) error {
+	cFont := C.CString(font)
+	defer C.free(unsafe.Pointer(cFont))
+
+	if C.load_font(cFont) != 0 {
+		return vipsError()
+	}
+	return nil
+}
+
+// heifOptions are the HEIF encoder options bimg does not expose. AV1 selects
+// the AVIF compression instead of the HEVC one.
+type heifOptions struct {
+	Quality   int
+	Lossless  bool
+	AV1       bool
+	Effort    int
+	BitDepth  int
+	Subsample int
+	Strip     bool
+}
+
+// saveHEIF encodes the image as HEIF, or AVIF, with the given options.
+func saveHEIF(buf []byte, o heifOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	lossless, strip := C.int(0), C.int(0)
+	if o.Lossless {
+		lossless = 1
+	}
+	if o.Strip {
+		strip = 1
+	}
+
+	compression := C.int(C.VIPS_FOREIGN_HEIF_COMPRESSION_HEVC)
+	if o.AV1 {
+		compression = C.VIPS_FOREIGN_HEIF_COMPRESSION_AV1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	code := C.heifsave_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(o.Quality), lossless, compression,
+		C.int(o.Effort), C.int(o.BitDepth), C.int(o.Subsample), strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// pngOptions are the palette PNG encoder options bimg does not expose.
+type pngOptions struct {
+	Quality     int
+	BitDepth    int
+	Dither      float64
+	Compression int
+	Interlace   bool
+	Strip       bool
+}
+
+// savePNG encodes the image as a palette PNG, quantized by libimagequant,
+// with the given options.
+func savePNG(buf []byte, o pngOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	interlace, strip := C.int(0), C.int(0)
+	if o.Interlace {
+		interlace = 1
+	}
+	if o.Strip {
+		strip = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	code := C.pngsave_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(o.Quality), C.int(o.BitDepth),
+		C.double(o.Dither), C.int(o.Compression), interlace, strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// tiffOptions are the TIFF encoder options bimg does not expose.
+type tiffOptions struct {
+	Compression int
+	Predictor   int
+	Quality     int
+	Strip       bool
+}
+
+// saveTIFF encodes the image as TIFF with the given options.
+func saveTIFF(buf []byte, o tiffOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	strip := C.int(0)
+	if o.Strip {
+		strip = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	code := C.tiffsave_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(o.Compression), C.int(o.Predictor),
+		C.int(o.Quality), strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// saveTIFFPages encodes the images as the pages of a multi-page TIFF with the
+// given options. Pages smaller than the largest one are centered on white.
+func saveTIFFPages(pages [][]byte, o tiffOptions) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("no pages")
+	}
+
+	bufs, lens, free := cPages(pages)
+	defer free()
+
+	strip := C.int(0)
+	if o.Strip {
+		strip = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	code := C.tiffsave_pages(bufs, lens, C.int(len(pages)), C.int(o.Compression), C.int(o.Predictor),
+		C.int(o.Quality), strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// animationOptions are the options of the animations encoded from frames.
+// Delays are the frame durations, in milliseconds, and Loop the number of
+// loops, 0 looping forever.
+type animationOptions struct {
+	Delays  []int
+	Loop    int
+	WebP    bool
+	Quality int
+}
+
+// saveAnimation encodes the frames as an animated GIF, or WebP, with the given
+// options. Frames smaller than the largest one are centered on white.
+func saveAnimation(frames [][]byte, o animationOptions) ([]byte, error) {
+	if len(frames) == 0 || len(o.Delays) != len(frames) {
+		return nil, errors.New("a delay is required per frame")
+	}
+
+	bufs, lens, free := cPages(frames)
+	defer free()
+
+	delays := (*C.int)(C.malloc(C.size_t(len(frames)) * C.size_t(unsafe.Sizeof(C.int(0)))))
+	defer C.free(unsafe.Pointer(delays))
+	delaySlice := unsafe.Slice(delays, len(frames))
+	for i, delay := range o.Delays {
+		delaySlice[i] = C.int(delay)
+	}
+	webp := C.int(0)
+	if o.WebP {
+		webp = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	code := C.animsave_frames(bufs, lens, C.int(len(frames)), delays, C.int(o.Loop), webp, C.int(o.Quality), &out,
+		&length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// cPages copies the image buffers and their lengths to C memory, cgo
+// forbidding to pass C an array of Go pointers, returning the function
+// freeing them.
+func cPages(pages [][]byte) (*unsafe.Pointer, *C.size_t, func()) {
+	bufs := (*unsafe.Pointer)(C.malloc(C.size_t(len(pages)) * C.size_t(unsafe.Sizeof(unsafe.Pointer(nil)))))
+	lens := (*C.size_t)(C.malloc(C.size_t(len(pages)) * C.size_t(unsafe.Sizeof(C.size_t(0)))))
+	bufSlice, lenSlice := unsafe.Slice(bufs, len(pages)), unsafe.Slice(lens, len(pages))
+	for i, page := range pages {
+		bufSlice[i], lenSlice[i] = C.CBytes(page), C.size_t(len(page))
+	}
+
+	return bufs, lens, func() {
+		for _, buf := range bufSlice {
+			C.free(buf)
+		}
+		C.free(unsafe.Pointer(bufs))
+		C.free(unsafe.Pointer(lens))
+	}
+}
+
+// jpegOptions are the JPEG encoder options bimg does not expose.
+type jpegOptions struct {
+	Quality   int
+	Subsample int
+	Interlace bool
+	Strip     bool
+}
+
+// saveJPEG encodes the image as JPEG with the given options.
+func saveJPEG(buf []byte, o jpegOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	interlace, strip := C.int(0), C.int(0)
+	if o.Interlace {
+		interlace = 1
+	}
+	if o.Strip {
+		strip = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	code := C.jpegsave_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(o.Quality), C.int(o.Subsample),
+		interlace, strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// webpOptions are the WebP encoder options bimg does not expose. SharpYUV
+// enables the slower and sharper RGB to YUV conversion.
+type webpOptions struct {
+	Quality  int
+	Lossless bool
+	SharpYUV bool
+	Strip    bool
+}
+
+// saveWebP encodes the image as WebP with the given options.
+func saveWebP(buf []byte, o webpOptions) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, errors.New("empty image buffer")
+	}
+
+	lossless, sharpYUV, strip := C.int(0), C.int(0), C.int(0)
+	if o.Lossless {
+		lossless = 1
+	}
+	if o.SharpYUV {
+		sharpYUV = 1
+	}
+	if o.Strip {
+		strip = 1
+	}
+
+	var out unsafe.Pointer
+	var length C.size_t
+	//nolint:gosec // libvips reads the buffer without retaining it
+	code := C.webpsave_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), C.int(o.Quality), lossless, sharpYUV,
+		strip, &out, &length)
+	if code != 0 {
+		return nil, vipsError()
+	}
+	defer C.g_free(C.gpointer(out))
+
+	return C.GoBytes(out, C.int(length)), nil
+}
+
+// vipsError returns the last libvips error, clearing it.
+func vipsError() error {
+	message := strings.TrimSpace(C.GoString(C.vips_error_buffer()))
+	C.vips_error_clear()
+	return errors.New(message)
+}